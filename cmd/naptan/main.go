@@ -0,0 +1,51 @@
+// Command naptan imports the stops of a NaPTAN Stops.csv file into the places.
+// It reads the same config as the server, so it is run from the root of the repository:
+//
+//	go run ./cmd/naptan -path Stops.csv -dry-run
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+
+	"github.com/leonardchinonso/lokate-go/datasource"
+	"github.com/leonardchinonso/lokate-go/repository"
+	"github.com/leonardchinonso/lokate-go/service"
+)
+
+func main() {
+	path := flag.String("path", "", "path of the NaPTAN Stops.csv file, NAPTAN_STOPS_PATH when not set")
+	dryRun := flag.Bool("dry-run", false, "read the stops and report the removed ones without storing anything")
+	flag.Parse()
+
+	if err := run(*path, *dryRun); err != nil {
+		log.Fatalf("Failed to import NaPTAN stops: %v", err)
+	}
+}
+
+// run imports the stops and logs the report, the data sources are released before it returns
+func run(path string, dryRun bool) error {
+	dataSource, err := datasource.InitDataSource()
+	if err != nil {
+		return err
+	}
+
+	// release resource when the import is done
+	defer dataSource.Close()
+
+	naptanService := service.NewNaptanService(dataSource.Cfg, repository.NewPlaceRepository(dataSource.Database))
+
+	report, err := naptanService.Import(context.Background(), path, dryRun)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Stops: %d, inserted: %d, updated: %d, inactive: %d, skipped: %d, removed: %d\n",
+		report.Stops, report.Inserted, report.Updated, report.Inactive, report.Skipped, report.Removed)
+	for _, stop := range report.RemovedStops {
+		log.Printf("Removed stop %s with place id %s\n", stop.ATCOCode, stop.Id)
+	}
+
+	return nil
+}
@@ -39,6 +39,59 @@ const (
 	// TAPIServiceName is the global config name for the TAPI_SERVICE_NAME variable
 	TAPIServiceName = "TAPI_SERVICE_NAME"
 
+	// TAPIBusDeparturesUrl is the global config name for the TAPI_BUS_DEPARTURES_URL variable
+	TAPIBusDeparturesUrl = "TAPI_BUS_DEPARTURES_URL"
+	// TAPITrainDeparturesUrl is the global config name for the TAPI_TRAIN_DEPARTURES_URL variable
+	TAPITrainDeparturesUrl = "TAPI_TRAIN_DEPARTURES_URL"
+
+	// DeparturesCacheSeconds is the global config name for the DEPARTURES_CACHE_SECONDS variable
+	DeparturesCacheSeconds = "DEPARTURES_CACHE_SECONDS"
+	// DeparturesStreamSeconds is the global config name for the DEPARTURES_STREAM_SECONDS variable
+	DeparturesStreamSeconds = "DEPARTURES_STREAM_SECONDS"
+	// DashboardWorkers is the global config name for the DASHBOARD_WORKERS variable
+	DashboardWorkers = "DASHBOARD_WORKERS"
+	// ReverseGeocodeMetres is the global config name for the REVERSE_GEOCODE_METRES variable
+	ReverseGeocodeMetres = "REVERSE_GEOCODE_METRES"
+	// PlaceDuplicateMetres is the global config name for the PLACE_DUPLICATE_METRES variable
+	PlaceDuplicateMetres = "PLACE_DUPLICATE_METRES"
+	// AdminEmails is the global config name for the ADMIN_EMAILS variable
+	AdminEmails = "ADMIN_EMAILS"
+	// PostcodeDirectoryPath is the global config name for the POSTCODE_DIRECTORY_PATH variable
+	PostcodeDirectoryPath = "POSTCODE_DIRECTORY_PATH"
+	// PostcodeBatchMaxRows is the global config name for the POSTCODE_BATCH_MAX_ROWS variable
+	PostcodeBatchMaxRows = "POSTCODE_BATCH_MAX_ROWS"
+	// PostcodeUpstreamWorkers is the global config name for the POSTCODE_UPSTREAM_WORKERS variable
+	PostcodeUpstreamWorkers = "POSTCODE_UPSTREAM_WORKERS"
+	// DefaultCountry is the global config name for the DEFAULT_COUNTRY variable
+	DefaultCountry = "DEFAULT_COUNTRY"
+	// PlaceSearchMinLocal is the global config name for the PLACE_SEARCH_MIN_LOCAL variable
+	PlaceSearchMinLocal = "PLACE_SEARCH_MIN_LOCAL"
+	// AutocompleteRefreshSeconds is the global config name for the AUTOCOMPLETE_REFRESH_SECONDS variable
+	AutocompleteRefreshSeconds = "AUTOCOMPLETE_REFRESH_SECONDS"
+	// NaptanStopsPath is the global config name for the NAPTAN_STOPS_PATH variable
+	NaptanStopsPath = "NAPTAN_STOPS_PATH"
+
+	// TransitProvider is the global config name for the TRANSIT_PROVIDER variable
+	TransitProvider = "TRANSIT_PROVIDER"
+	// TransitFallbackProviders is the global config name for the TRANSIT_FALLBACK_PROVIDERS variable
+	TransitFallbackProviders = "TRANSIT_FALLBACK_PROVIDERS"
+	// OTPBaseUrl is the global config name for the OTP_BASE_URL variable
+	OTPBaseUrl = "OTP_BASE_URL"
+	// OTPFeedId is the global config name for the OTP_FEED_ID variable
+	OTPFeedId = "OTP_FEED_ID"
+	// GTFSFeedPaths is the global config name for the GTFS_FEED_PATHS variable
+	GTFSFeedPaths = "GTFS_FEED_PATHS"
+	// GTFSMaxWalkMetres is the global config name for the GTFS_MAX_WALK_METRES variable
+	GTFSMaxWalkMetres = "GTFS_MAX_WALK_METRES"
+	// GTFSRealtimeUrls is the global config name for the GTFS_RT_URLS variable
+	GTFSRealtimeUrls = "GTFS_RT_URLS"
+	// GTFSRealtimePollSeconds is the global config name for the GTFS_RT_POLL_SECONDS variable
+	GTFSRealtimePollSeconds = "GTFS_RT_POLL_SECONDS"
+	// GBFSSources is the global config name for the GBFS_SOURCES variable
+	GBFSSources = "GBFS_SOURCES"
+	// GBFSPollSeconds is the global config name for the GBFS_POLL_SECONDS variable
+	GBFSPollSeconds = "GBFS_POLL_SECONDS"
+
 	// DatabaseName is the global config name for the DATABASE_NAME variable
 	DatabaseName = "DATABASE_NAME"
 	// BaseUri  is the global config name for the BASE_URI variable
@@ -53,6 +106,35 @@ type TAPIConfig struct {
 	AppKey string
 }
 
+// optionalConfig holds the config variables that fall back to a default value when they are not set
+var optionalConfig = map[string]string{
+	TAPIBusDeparturesUrl:       "https://transportapi.com/v3/uk/bus/stop",
+	TAPITrainDeparturesUrl:     "https://transportapi.com/v3/uk/train/station",
+	DeparturesCacheSeconds:     "10",
+	DeparturesStreamSeconds:    "15",
+	DashboardWorkers:           "4",
+	ReverseGeocodeMetres:       "50",
+	PlaceDuplicateMetres:       "30",
+	AdminEmails:                "",
+	PostcodeDirectoryPath:      "",
+	PostcodeBatchMaxRows:       "1000",
+	PostcodeUpstreamWorkers:    "4",
+	DefaultCountry:             "GB",
+	PlaceSearchMinLocal:        "3",
+	AutocompleteRefreshSeconds: "30",
+	NaptanStopsPath:            "",
+	TransitProvider:            "tapi",
+	TransitFallbackProviders:   "",
+	OTPBaseUrl:                 "http://localhost:8080/otp/routers/default",
+	OTPFeedId:                  "1",
+	GTFSFeedPaths:              "",
+	GTFSMaxWalkMetres:          "500",
+	GTFSRealtimeUrls:           "",
+	GTFSRealtimePollSeconds:    "30",
+	GBFSSources:                "",
+	GBFSPollSeconds:            "60",
+}
+
 // getEnv retrieves the value of a given key from the environment variables set
 func getEnv(key string) (string, error) {
 	if value, exists := os.LookupEnv(key); exists {
@@ -83,5 +165,14 @@ func InitConfig() (*map[string]string, error) {
 		Map[c] = v
 	}
 
+	// iterate the optional config variables and fall back to their defaults when they are not set
+	for c, def := range optionalConfig {
+		v, err := getEnv(c)
+		if err != nil {
+			v = def
+		}
+		Map[c] = v
+	}
+
 	return &Map, nil
 }
@@ -0,0 +1,109 @@
+package datasource
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/leonardchinonso/lokate-go/utils"
+)
+
+// csvRow is a row of a csv file with access to the values by the column names
+type csvRow struct {
+	file    string
+	line    int
+	columns map[string]int
+	values  []string
+}
+
+// get returns the value of a column, or an empty string if the column is not in the file
+func (r csvRow) get(column string) string {
+	if i, ok := r.columns[column]; ok && i < len(r.values) {
+		return strings.TrimSpace(r.values[i])
+	}
+	return ""
+}
+
+// int returns the value of a column as an integer
+func (r csvRow) int(column string) (int, error) {
+	v, err := strconv.Atoi(r.get(column))
+	if err != nil {
+		return 0, fmt.Errorf("%s line %d: invalid %s: %q", r.file, r.line, column, r.get(column))
+	}
+	return v, nil
+}
+
+// float returns the value of a column as a float
+func (r csvRow) float(column string) (float64, error) {
+	v, err := strconv.ParseFloat(r.get(column), 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s line %d: invalid %s: %q", r.file, r.line, column, r.get(column))
+	}
+	return v, nil
+}
+
+// time returns the value of a column in the "HH:MM:SS" format as seconds after midnight
+// the fallback column is read when the column is empty
+func (r csvRow) time(column, fallback string) (int, error) {
+	if r.get(column) == "" {
+		column = fallback
+	}
+
+	parts := strings.Split(r.get(column), ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("%s line %d: invalid %s: %q", r.file, r.line, column, r.get(column))
+	}
+
+	seconds := 0
+	for _, part := range parts {
+		v, err := strconv.Atoi(part)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("%s line %d: invalid %s: %q", r.file, r.line, column, r.get(column))
+		}
+		seconds = seconds*60 + v
+	}
+	return seconds, nil
+}
+
+// readCSV reads a csv file with a header and calls the handler with each row
+func readCSV(f io.Reader, name string, handle func(row csvRow) error) error {
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	r.ReuseRecord = true
+
+	header, err := r.Read()
+	if err != nil {
+		return fmt.Errorf("failed to read the header of %s: %v", name, err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, column := range utils.CSVHeader(header) {
+		columns[column] = i
+	}
+
+	for line := 2; ; line++ {
+		values, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s line %d: %v", name, line, err)
+		}
+
+		if err := handle(csvRow{file: name, line: line, columns: columns, values: values}); err != nil {
+			return err
+		}
+	}
+}
+
+// first returns the value of the first of the columns that is in the file
+func (r csvRow) first(columns ...string) string {
+	for _, column := range columns {
+		if _, ok := r.columns[column]; ok {
+			return r.get(column)
+		}
+	}
+	return ""
+}
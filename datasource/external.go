@@ -2,17 +2,22 @@ package datasource
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"time"
 )
 
+// httpClient is the client used for requests to external services
+var httpClient = &http.Client{Timeout: 15 * time.Second}
+
 // Get makes an HTTP Get request to the url provided
 func Get(url string, resp interface{}) error {
-	log.Printf("INFO: making a request to TransportAPI with URL: %s\n", url)
+	log.Printf("INFO: making an external request with URL: %s\n", url)
 
 	// make a Get request to the url
-	res, err := http.Get(url)
+	res, err := httpClient.Get(url)
 	if err != nil {
 		log.Printf("Failed to make http request to url: %s. Error: %v", url, err)
 		return err
@@ -32,6 +37,12 @@ func Get(url string, resp interface{}) error {
 		return err
 	}
 
+	// treat error statuses as failures so callers do not decode error pages
+	if res.StatusCode >= http.StatusBadRequest {
+		log.Printf("Failed request to url: %s. Status: %v", url, res.Status)
+		return fmt.Errorf("request to %s failed with status: %s", url, res.Status)
+	}
+
 	// unmarshal the raw byte data into the interface
 	err = json.Unmarshal(data, resp)
 	if err != nil {
@@ -0,0 +1,322 @@
+package datasource
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
+
+const (
+	// GBFSSystemInformation is the name of the feed with the id of a bike share system
+	GBFSSystemInformation = "system_information"
+	// GBFSStationInformation is the name of the feed with the stations of a bike share system
+	GBFSStationInformation = "station_information"
+	// GBFSStationStatus is the name of the feed with the availability of the stations of a bike share system
+	GBFSStationStatus = "station_status"
+)
+
+// gbfsDiscoveryLanguage is the language the feeds of a version 2 discovery file are preferred in
+const gbfsDiscoveryLanguage = "en"
+
+// gbfsFeed is a feed listed in a gbfs.json discovery file
+type gbfsFeed struct {
+	Name string `json:"name"`
+	Url  string `json:"url"`
+}
+
+// DiscoverGBFS reads the feeds of a bike share system from its gbfs.json discovery url, or from a
+// local directory with a {feed}.json file for each feed when the source has no http scheme
+func DiscoverGBFS(source string) (*dao.GBFSSystem, error) {
+	feeds := make(map[string]string)
+
+	if isHTTPSource(source) {
+		listed, err := readGBFSDiscovery(source)
+		if err != nil {
+			return nil, err
+		}
+		for _, feed := range listed {
+			feeds[feed.Name] = feed.Url
+		}
+	} else {
+		dir := strings.TrimPrefix(source, "file://")
+		for _, name := range []string{GBFSSystemInformation, GBFSStationInformation, GBFSStationStatus} {
+			feeds[name] = filepath.Join(dir, name+".json")
+		}
+	}
+
+	for _, name := range []string{GBFSSystemInformation, GBFSStationInformation, GBFSStationStatus} {
+		if feeds[name] == "" {
+			return nil, fmt.Errorf("GBFS system %s has no %s feed", source, name)
+		}
+	}
+
+	var info struct {
+		Data struct {
+			SystemId gbfsString `json:"system_id"`
+		} `json:"data"`
+	}
+	if err := readGBFSFeed(feeds[GBFSSystemInformation], &info); err != nil {
+		return nil, err
+	}
+	if info.Data.SystemId == "" {
+		return nil, fmt.Errorf("GBFS system %s has no system id", source)
+	}
+
+	return &dao.GBFSSystem{SystemId: string(info.Data.SystemId), Feeds: feeds}, nil
+}
+
+// readGBFSDiscovery reads the feeds listed in a gbfs.json discovery file. Version 3 lists the feeds
+// in the data, the older versions list them by language and the English feeds are preferred
+func readGBFSDiscovery(source string) ([]gbfsFeed, error) {
+	var discovery struct {
+		Data map[string]json.RawMessage `json:"data"`
+	}
+	if err := readGBFSFeed(source, &discovery); err != nil {
+		return nil, err
+	}
+
+	list, ok := discovery.Data["feeds"]
+	if !ok {
+		languages := make([]string, 0, len(discovery.Data))
+		for lang := range discovery.Data {
+			languages = append(languages, lang)
+		}
+		if len(languages) == 0 {
+			return nil, fmt.Errorf("GBFS discovery %s lists no feeds", source)
+		}
+		sort.Strings(languages)
+
+		language := discovery.Data[languages[0]]
+		if preferred, found := discovery.Data[gbfsDiscoveryLanguage]; found {
+			language = preferred
+		}
+
+		var feeds struct {
+			Feeds json.RawMessage `json:"feeds"`
+		}
+		if err := json.Unmarshal(language, &feeds); err != nil {
+			return nil, fmt.Errorf("failed to decode GBFS discovery %s: %v", source, err)
+		}
+		list = feeds.Feeds
+	}
+
+	var listed []gbfsFeed
+	if err := json.Unmarshal(list, &listed); err != nil {
+		return nil, fmt.Errorf("failed to decode GBFS discovery %s: %v", source, err)
+	}
+	return listed, nil
+}
+
+// FetchGBFSStations reads the stations of a bike share system as bike dock places. The stations
+// without an id, a name or a valid coordinate are left out
+func FetchGBFSStations(system *dao.GBFSSystem) ([]dao.Place, error) {
+	var information struct {
+		Data struct {
+			Stations []struct {
+				StationId gbfsString      `json:"station_id"`
+				Name      json.RawMessage `json:"name"`
+				Address   string          `json:"address"`
+				Lat       *float64        `json:"lat"`
+				Lon       *float64        `json:"lon"`
+			} `json:"stations"`
+		} `json:"data"`
+	}
+	if err := readGBFSFeed(system.Feeds[GBFSStationInformation], &information); err != nil {
+		return nil, err
+	}
+
+	places := make([]dao.Place, 0, len(information.Data.Stations))
+	for _, s := range information.Data.Stations {
+		name := gbfsName(s.Name)
+		if s.StationId == "" || name == "" || s.Lat == nil || s.Lon == nil ||
+			*s.Lat < -90 || *s.Lat > 90 || *s.Lon < -180 || *s.Lon > 180 {
+			continue
+		}
+
+		lat, lon := *s.Lat, *s.Lon
+		place := dao.Place{
+			Type:          dao.PlaceTypeBikeDock,
+			Name:          name,
+			Latitude:      &lat,
+			Longitude:     &lon,
+			Description:   s.Address,
+			BikeStationId: system.SystemId + ":" + string(s.StationId),
+			Source:        dao.PlaceSourceGBFS,
+			Location:      dao.NewGeoPoint(lat, lon),
+		}
+		place.Key = dao.PlaceKey(&place)
+		places = append(places, place)
+	}
+
+	return places, nil
+}
+
+// FetchGBFSStationStatus reads the availability of the stations of a bike share system
+func FetchGBFSStationStatus(system *dao.GBFSSystem) ([]dao.GBFSStationStatus, error) {
+	var status struct {
+		Data struct {
+			Stations []struct {
+				StationId gbfsString `json:"station_id"`
+				// version 3 renamed the available bikes to the available vehicles
+				BikesAvailable    *int      `json:"num_bikes_available"`
+				VehiclesAvailable *int      `json:"num_vehicles_available"`
+				DocksAvailable    *int      `json:"num_docks_available"`
+				IsInstalled       *gbfsBool `json:"is_installed"`
+				IsRenting         *gbfsBool `json:"is_renting"`
+				IsReturning       *gbfsBool `json:"is_returning"`
+				LastReported      *gbfsTime `json:"last_reported"`
+			} `json:"stations"`
+		} `json:"data"`
+	}
+	if err := readGBFSFeed(system.Feeds[GBFSStationStatus], &status); err != nil {
+		return nil, err
+	}
+
+	statuses := make([]dao.GBFSStationStatus, 0, len(status.Data.Stations))
+	for _, s := range status.Data.Stations {
+		if s.StationId == "" {
+			continue
+		}
+
+		st := dao.GBFSStationStatus{
+			StationId: system.SystemId + ":" + string(s.StationId),
+			// the flags are required by the older versions, a station that leaves them out is open
+			IsInstalled: s.IsInstalled == nil || bool(*s.IsInstalled),
+			IsRenting:   s.IsRenting == nil || bool(*s.IsRenting),
+			IsReturning: s.IsReturning == nil || bool(*s.IsReturning),
+		}
+		switch {
+		case s.BikesAvailable != nil:
+			st.BikesAvailable = *s.BikesAvailable
+		case s.VehiclesAvailable != nil:
+			st.BikesAvailable = *s.VehiclesAvailable
+		}
+		if s.DocksAvailable != nil {
+			st.DocksAvailable = *s.DocksAvailable
+		}
+		if s.LastReported != nil && !time.Time(*s.LastReported).IsZero() {
+			t := time.Time(*s.LastReported)
+			st.LastReported = &t
+		}
+		statuses = append(statuses, st)
+	}
+
+	return statuses, nil
+}
+
+// isHTTPSource reports whether a feed source is a url rather than a local path
+func isHTTPSource(source string) bool {
+	return strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://")
+}
+
+// readGBFSFeed reads and decodes a GBFS feed from a url or a local file
+func readGBFSFeed(location string, v interface{}) error {
+	var body []byte
+	var err error
+
+	if isHTTPSource(location) {
+		body, err = getBytes(location)
+	} else {
+		body, err = os.ReadFile(strings.TrimPrefix(location, "file://"))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read GBFS feed %s: %v", location, err)
+	}
+
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("failed to decode GBFS feed %s: %v", location, err)
+	}
+	return nil
+}
+
+// gbfsName reads a name that is a string in the older versions and a list of translations in version 3
+func gbfsName(raw json.RawMessage) string {
+	var name string
+	if err := json.Unmarshal(raw, &name); err == nil {
+		return strings.TrimSpace(name)
+	}
+
+	var translations []struct {
+		Text     string `json:"text"`
+		Language string `json:"language"`
+	}
+	if err := json.Unmarshal(raw, &translations); err != nil || len(translations) == 0 {
+		return ""
+	}
+	for _, t := range translations {
+		if strings.HasPrefix(strings.ToLower(t.Language), gbfsDiscoveryLanguage) {
+			return strings.TrimSpace(t.Text)
+		}
+	}
+	return strings.TrimSpace(translations[0].Text)
+}
+
+// gbfsString is an id that some systems publish as a number
+type gbfsString string
+
+// UnmarshalJSON decodes an id from a string or a number
+func (s *gbfsString) UnmarshalJSON(b []byte) error {
+	var str string
+	if err := json.Unmarshal(b, &str); err == nil {
+		*s = gbfsString(strings.TrimSpace(str))
+		return nil
+	}
+
+	var num json.Number
+	if err := json.Unmarshal(b, &num); err != nil {
+		return fmt.Errorf("invalid GBFS id: %s", b)
+	}
+	*s = gbfsString(num.String())
+	return nil
+}
+
+// gbfsBool is a flag that the older versions publish as 0 or 1
+type gbfsBool bool
+
+// UnmarshalJSON decodes a flag from a boolean or a number
+func (f *gbfsBool) UnmarshalJSON(b []byte) error {
+	switch string(bytes.TrimSpace(b)) {
+	case "true", "1":
+		*f = true
+	case "false", "0", "null":
+		*f = false
+	default:
+		return fmt.Errorf("invalid GBFS flag: %s", b)
+	}
+	return nil
+}
+
+// gbfsTime is a time that the older versions publish as a unix timestamp and version 3 as an RFC 3339 string
+type gbfsTime time.Time
+
+// UnmarshalJSON decodes a time from a unix timestamp or an RFC 3339 string
+func (t *gbfsTime) UnmarshalJSON(b []byte) error {
+	var str string
+	if err := json.Unmarshal(b, &str); err == nil {
+		parsed, err := time.Parse(time.RFC3339, str)
+		if err != nil {
+			return fmt.Errorf("invalid GBFS time: %s", b)
+		}
+		*t = gbfsTime(parsed)
+		return nil
+	}
+
+	var num json.Number
+	if err := json.Unmarshal(b, &num); err != nil {
+		return fmt.Errorf("invalid GBFS time: %s", b)
+	}
+	seconds, err := strconv.ParseInt(num.String(), 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid GBFS time: %s", b)
+	}
+	*t = gbfsTime(time.Unix(seconds, 0).UTC())
+	return nil
+}
@@ -0,0 +1,274 @@
+package datasource
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
+
+// newGBFSServer serves the feeds of testdata/gbfs, with the {{server}} in the discovery files
+// replaced by the url of the server
+func newGBFSServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := os.ReadFile(filepath.Join("testdata", "gbfs", filepath.FromSlash(r.URL.Path)))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(strings.ReplaceAll(string(body), "{{server}}", "http://"+r.Host)))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDiscoverGBFS(t *testing.T) {
+	server := newGBFSServer(t)
+
+	tests := []struct {
+		name     string
+		source   string
+		systemId string
+		feeds    map[string]string
+	}{
+		{
+			name:     "version 2 prefers the English feeds",
+			source:   server.URL + "/v2/gbfs.json",
+			systemId: "london",
+			feeds: map[string]string{
+				GBFSSystemInformation:  server.URL + "/v2/system_information.json",
+				GBFSStationInformation: server.URL + "/v2/station_information.json",
+				GBFSStationStatus:      server.URL + "/v2/station_status.json",
+				"system_pricing_plans": server.URL + "/v2/system_pricing_plans.json",
+			},
+		},
+		{
+			name:     "version 3 lists the feeds in the data",
+			source:   server.URL + "/v3/gbfs.json",
+			systemId: "edinburgh",
+			feeds: map[string]string{
+				GBFSSystemInformation:  server.URL + "/v3/system_information.json",
+				GBFSStationInformation: server.URL + "/v3/station_information.json",
+				GBFSStationStatus:      server.URL + "/v3/station_status.json",
+				"vehicle_types":        server.URL + "/v3/vehicle_types.json",
+			},
+		},
+		{
+			name:     "a local directory",
+			source:   "file://" + filepath.Join("testdata", "gbfs", "v2"),
+			systemId: "london",
+			feeds: map[string]string{
+				GBFSSystemInformation:  filepath.Join("testdata", "gbfs", "v2", "system_information.json"),
+				GBFSStationInformation: filepath.Join("testdata", "gbfs", "v2", "station_information.json"),
+				GBFSStationStatus:      filepath.Join("testdata", "gbfs", "v2", "station_status.json"),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			system, err := DiscoverGBFS(tt.source)
+			if err != nil {
+				t.Fatalf("DiscoverGBFS returned an error: %v", err)
+			}
+			if system.SystemId != tt.systemId || !reflect.DeepEqual(system.Feeds, tt.feeds) {
+				t.Errorf("got system %q with feeds %v, want %q with %v", system.SystemId, system.Feeds, tt.systemId, tt.feeds)
+			}
+		})
+	}
+}
+
+func TestDiscoverGBFSErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/no-status/gbfs.json":
+			_, _ = w.Write([]byte(`{"data": {"feeds": [
+				{"name": "system_information", "url": "http://` + r.Host + `/system_information.json"},
+				{"name": "station_information", "url": "http://` + r.Host + `/station_information.json"}]}}`))
+		case "/no-languages/gbfs.json":
+			_, _ = w.Write([]byte(`{"data": {}}`))
+		case "/no-system-id/gbfs.json":
+			_, _ = w.Write([]byte(`{"data": {"feeds": [
+				{"name": "system_information", "url": "http://` + r.Host + `/system_information.json"},
+				{"name": "station_information", "url": "http://` + r.Host + `/station_information.json"},
+				{"name": "station_status", "url": "http://` + r.Host + `/station_status.json"}]}}`))
+		case "/system_information.json":
+			_, _ = w.Write([]byte(`{"data": {"system_id": " "}}`))
+		case "/not-json/gbfs.json":
+			_, _ = w.Write([]byte(`<html></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	for _, source := range []string{
+		server.URL + "/no-status/gbfs.json",
+		server.URL + "/no-languages/gbfs.json",
+		server.URL + "/no-system-id/gbfs.json",
+		server.URL + "/not-json/gbfs.json",
+		server.URL + "/missing/gbfs.json",
+		filepath.Join("testdata", "gbfs", "missing"),
+	} {
+		if system, err := DiscoverGBFS(source); err == nil {
+			t.Errorf("DiscoverGBFS(%q) = %+v, want an error", source, system)
+		}
+	}
+}
+
+func TestFetchGBFSStations(t *testing.T) {
+	server := newGBFSServer(t)
+
+	tests := []struct {
+		version string
+		want    map[string]string
+	}{
+		// the numeric station ids are read as strings, the stations without an id, a name or a
+		// valid coordinate are left out
+		{"v2", map[string]string{"london:101": "Waterloo Place, St. James's", "london:102": "Strand, Strand"}},
+		// the English translation of a name is preferred, then the first one
+		{"v3", map[string]string{"edinburgh:st-1": "Waverley Bridge", "edinburgh:st-2": "Sràid a' Phrionnsa"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			system, err := DiscoverGBFS(server.URL + "/" + tt.version + "/gbfs.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			places, err := FetchGBFSStations(system)
+			if err != nil {
+				t.Fatalf("FetchGBFSStations returned an error: %v", err)
+			}
+
+			got := make(map[string]string, len(places))
+			for _, p := range places {
+				got[p.BikeStationId] = p.Name
+				if p.Type != dao.PlaceTypeBikeDock || p.Source != dao.PlaceSourceGBFS || p.Key != "gbfs:"+p.BikeStationId {
+					t.Errorf("got place %+v, want a GBFS bike dock keyed by its station", p)
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got stations %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFetchGBFSStationStatus(t *testing.T) {
+	server := newGBFSServer(t)
+	at := func(hour, minute int) *time.Time {
+		t := time.Date(2023, 3, 1, hour, minute, 0, 0, time.UTC)
+		return &t
+	}
+
+	tests := []struct {
+		version string
+		want    []dao.GBFSStationStatus
+	}{
+		{"v2", []dao.GBFSStationStatus{
+			// 0 and 1 flags and unix times, a station without flags is open and a station without an id is left out
+			{StationId: "london:101", BikesAvailable: 7, DocksAvailable: 13, IsInstalled: true, IsRenting: false, IsReturning: true, LastReported: at(8, 0)},
+			{StationId: "london:102", IsInstalled: false, IsRenting: false, IsReturning: false, LastReported: at(7, 0)},
+			{StationId: "london:103", BikesAvailable: 2, DocksAvailable: 3, IsInstalled: true, IsRenting: true, IsReturning: true},
+		}},
+		{"v3", []dao.GBFSStationStatus{
+			// the available vehicles and RFC 3339 times of any offset
+			{StationId: "edinburgh:st-1", BikesAvailable: 5, DocksAvailable: 10, IsInstalled: true, IsRenting: true, IsReturning: false, LastReported: at(8, 0)},
+			{StationId: "edinburgh:st-2", BikesAvailable: 1, DocksAvailable: 2, IsInstalled: true, IsRenting: true, IsReturning: true, LastReported: at(8, 30)},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.version, func(t *testing.T) {
+			system, err := DiscoverGBFS(server.URL + "/" + tt.version + "/gbfs.json")
+			if err != nil {
+				t.Fatal(err)
+			}
+			statuses, err := FetchGBFSStationStatus(system)
+			if err != nil {
+				t.Fatalf("FetchGBFSStationStatus returned an error: %v", err)
+			}
+
+			if len(statuses) != len(tt.want) {
+				t.Fatalf("got %d statuses, want %d: %+v", len(statuses), len(tt.want), statuses)
+			}
+			for i, got := range statuses {
+				want := tt.want[i]
+				if !sameReportedTime(got.LastReported, want.LastReported) {
+					t.Errorf("got %s last reported at %v, want %v", got.StationId, got.LastReported, want.LastReported)
+				}
+				got.LastReported, want.LastReported = nil, nil
+				if got != want {
+					t.Errorf("got status %+v, want %+v", got, want)
+				}
+			}
+		})
+	}
+}
+
+// sameReportedTime determines if two optional times are the same instant
+func sameReportedTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+func TestGBFSValues(t *testing.T) {
+	tests := []struct {
+		raw  string
+		id   string
+		flag *bool
+		time *time.Time
+	}{
+		{raw: `"st-1"`, id: "st-1"},
+		{raw: `" 7 "`, id: "7"},
+		{raw: `101`, id: "101", time: timePtr(time.Unix(101, 0))},
+		{raw: `1`, id: "1", flag: boolPtr(true), time: timePtr(time.Unix(1, 0))},
+		{raw: `0`, id: "0", flag: boolPtr(false), time: timePtr(time.Unix(0, 0))},
+		{raw: `true`, flag: boolPtr(true)},
+		{raw: `false`, flag: boolPtr(false)},
+		{raw: `1677657600`, id: "1677657600", time: timePtr(time.Date(2023, 3, 1, 8, 0, 0, 0, time.UTC))},
+		{raw: `"2023-03-01T09:00:00+01:00"`, id: "2023-03-01T09:00:00+01:00", time: timePtr(time.Date(2023, 3, 1, 8, 0, 0, 0, time.UTC))},
+		{raw: `2`, id: "2", time: timePtr(time.Unix(2, 0))},
+		{raw: `"yes"`, id: "yes"},
+		{raw: `1.5`, id: "1.5"},
+		{raw: `{}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			var id gbfsString
+			if err := json.Unmarshal([]byte(tt.raw), &id); (err == nil) != (tt.id != "") || string(id) != tt.id {
+				t.Errorf("got id %q and error %v, want %q", id, err, tt.id)
+			}
+
+			var flag gbfsBool
+			err := json.Unmarshal([]byte(tt.raw), &flag)
+			if (err == nil) != (tt.flag != nil) || (tt.flag != nil && bool(flag) != *tt.flag) {
+				t.Errorf("got flag %v and error %v, want %v", flag, err, tt.flag)
+			}
+
+			var reported gbfsTime
+			err = json.Unmarshal([]byte(tt.raw), &reported)
+			if (err == nil) != (tt.time != nil) || (tt.time != nil && !time.Time(reported).Equal(*tt.time)) {
+				t.Errorf("got time %v and error %v, want %v", time.Time(reported), err, tt.time)
+			}
+		})
+	}
+}
+
+func boolPtr(b bool) *bool { return &b }
+
+func timePtr(t time.Time) *time.Time { return &t }
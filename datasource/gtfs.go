@@ -0,0 +1,211 @@
+package datasource
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
+
+// errGTFSFileMissing is returned when an optional file is not in the feed
+var errGTFSFileMissing = errors.New("file missing from feed")
+
+// GTFSChecksum returns the sha256 checksum of a GTFS zip file to detect changed feeds
+func GTFSChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// ReadGTFSFeed reads a GTFS static zip file from the local disk
+func ReadGTFSFeed(feedId, path string) (*dao.GTFSFeed, error) {
+	zr, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GTFS feed %s: %v", path, err)
+	}
+	defer zr.Close()
+
+	checksum, err := GTFSChecksum(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to checksum GTFS feed %s: %v", path, err)
+	}
+
+	feed := &dao.GTFSFeed{Info: dao.GTFSFeedInfo{FeedId: feedId, Path: path, Checksum: checksum, Timezone: "Europe/London"}}
+
+	// the agency timezone is what the stop times are expressed in
+	err = readGTFSFile(&zr.Reader, "agency.txt", false, func(row csvRow) error {
+		if tz := row.get("agency_timezone"); tz != "" {
+			feed.Info.Timezone = tz
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// a stop without an id or a coordinate cannot be routed through or shown, it is left out and
+	// the stop times at it are skipped by the router
+	err = readGTFSFile(&zr.Reader, "stops.txt", true, func(row csvRow) error {
+		lat, latErr := row.float("stop_lat")
+		lon, lonErr := row.float("stop_lon")
+		if row.get("stop_id") == "" || latErr != nil || lonErr != nil || !(lat >= -90 && lat <= 90 && lon >= -180 && lon <= 180) {
+			feed.RejectedStops++
+			return nil
+		}
+		feed.Stops = append(feed.Stops, dao.GTFSStop{
+			FeedId: feedId, StopId: row.get("stop_id"), StopCode: row.get("stop_code"),
+			Name: row.get("stop_name"), Latitude: lat, Longitude: lon,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readGTFSFile(&zr.Reader, "routes.txt", true, func(row csvRow) error {
+		routeType, err := row.int("route_type")
+		if err != nil {
+			return err
+		}
+		feed.Routes = append(feed.Routes, dao.GTFSRoute{
+			FeedId: feedId, RouteId: row.get("route_id"), ShortName: row.get("route_short_name"),
+			LongName: row.get("route_long_name"), Type: routeType,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readGTFSFile(&zr.Reader, "trips.txt", true, func(row csvRow) error {
+		feed.Trips = append(feed.Trips, dao.GTFSTrip{
+			FeedId: feedId, TripId: row.get("trip_id"), RouteId: row.get("route_id"),
+			ServiceId: row.get("service_id"), Headsign: row.get("trip_headsign"),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readGTFSFile(&zr.Reader, "stop_times.txt", true, func(row csvRow) error {
+		sequence, err := row.int("stop_sequence")
+		if err != nil {
+			return err
+		}
+
+		// stops that are not timepoints may leave out their times, they are skipped since the
+		// router can only board and alight at stops with a known time
+		if row.get("arrival_time") == "" && row.get("departure_time") == "" {
+			return nil
+		}
+
+		// a stop with a single time arrives and departs at the same time
+		arrival, err := row.time("arrival_time", "departure_time")
+		if err != nil {
+			return err
+		}
+		departure, err := row.time("departure_time", "arrival_time")
+		if err != nil {
+			return err
+		}
+		feed.StopTimes = append(feed.StopTimes, dao.GTFSStopTime{
+			FeedId: feedId, TripId: row.get("trip_id"), StopId: row.get("stop_id"),
+			StopSequence: sequence, ArrivalTime: arrival, DepartureTime: departure,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	days := []string{"sunday", "monday", "tuesday", "wednesday", "thursday", "friday", "saturday"}
+	err = readGTFSFile(&zr.Reader, "calendar.txt", false, func(row csvRow) error {
+		cal := dao.GTFSCalendar{
+			FeedId: feedId, ServiceId: row.get("service_id"),
+			StartDate: row.get("start_date"), EndDate: row.get("end_date"),
+		}
+		for i, day := range days {
+			cal.Days[i] = row.get(day) == "1"
+		}
+		feed.Calendars = append(feed.Calendars, cal)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readGTFSFile(&zr.Reader, "calendar_dates.txt", false, func(row csvRow) error {
+		exceptionType, err := row.int("exception_type")
+		if err != nil {
+			return err
+		}
+		feed.CalendarDates = append(feed.CalendarDates, dao.GTFSCalendarDate{
+			FeedId: feedId, ServiceId: row.get("service_id"), Date: row.get("date"), ExceptionType: exceptionType,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readGTFSFile(&zr.Reader, "transfers.txt", false, func(row csvRow) error {
+		// only transfers with a known walking time are useful to the router
+		minTime, err := row.int("min_transfer_time")
+		if err != nil {
+			return nil
+		}
+		feed.Transfers = append(feed.Transfers, dao.GTFSTransfer{
+			FeedId: feedId, FromStopId: row.get("from_stop_id"), ToStopId: row.get("to_stop_id"), MinTransferTime: minTime,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(feed.Calendars) == 0 && len(feed.CalendarDates) == 0 {
+		return nil, fmt.Errorf("GTFS feed %s has neither calendar.txt nor calendar_dates.txt", path)
+	}
+
+	return feed, nil
+}
+
+// readGTFSFile reads a csv file in the feed and calls the handler with each row
+func readGTFSFile(zr *zip.Reader, name string, required bool, handle func(row csvRow) error) error {
+	f, err := openZipFile(zr, name)
+	if err != nil {
+		if errors.Is(err, errGTFSFileMissing) && !required {
+			return nil
+		}
+		return fmt.Errorf("failed to open %s: %v", name, err)
+	}
+	defer f.Close()
+
+	return readCSV(f, name, handle)
+}
+
+// openZipFile opens a file in the zip, the feed files may be nested in a single folder
+func openZipFile(zr *zip.Reader, name string) (io.ReadCloser, error) {
+	for _, f := range zr.File {
+		if f.Name == name || strings.HasSuffix(f.Name, "/"+name) {
+			return f.Open()
+		}
+	}
+	return nil, errGTFSFileMissing
+}
@@ -0,0 +1,390 @@
+package datasource
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
+
+// FetchGTFSRealtime reads a GTFS-Realtime feed from a url, or from a local file
+// when the source has no http scheme
+func FetchGTFSRealtime(source string) (*dao.GTFSRTFeed, error) {
+	var body []byte
+	var err error
+
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		body, err = getBytes(source)
+	} else {
+		body, err = os.ReadFile(strings.TrimPrefix(source, "file://"))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GTFS-Realtime feed %s: %v", source, err)
+	}
+
+	feed, err := DecodeGTFSRealtime(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode GTFS-Realtime feed %s: %v", source, err)
+	}
+
+	return feed, nil
+}
+
+// getBytes sends a GET request to a url and returns the raw body
+func getBytes(url string) ([]byte, error) {
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return io.ReadAll(resp.Body)
+}
+
+// DecodeGTFSRealtime decodes a GTFS-Realtime FeedMessage from its protobuf encoding
+// only the fields used by the application are read, the rest are skipped
+func DecodeGTFSRealtime(b []byte) (*dao.GTFSRTFeed, error) {
+	feed := &dao.GTFSRTFeed{}
+
+	err := forEachField(b, func(f protoField) error {
+		switch f.num {
+		case 1: // header
+			return forEachField(f.bytes, func(hf protoField) error {
+				if hf.num == 3 {
+					feed.Timestamp = unixTime(hf.varint)
+				}
+				return nil
+			})
+		case 2: // entity
+			return decodeEntity(f.bytes, feed)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return feed, nil
+}
+
+// decodeEntity decodes a FeedEntity and adds its trip update, vehicle position or alert to the feed
+func decodeEntity(b []byte, feed *dao.GTFSRTFeed) error {
+	var id string
+	var deleted bool
+	var tripUpdate *dao.GTFSRTTripUpdate
+	var vehicle *dao.GTFSRTVehiclePosition
+	var alert *dao.GTFSRTAlert
+
+	err := forEachField(b, func(f protoField) error {
+		var err error
+		switch f.num {
+		case 1:
+			id = string(f.bytes)
+		case 2:
+			deleted = f.varint != 0
+		case 3:
+			tripUpdate, err = decodeTripUpdate(f.bytes)
+		case 4:
+			vehicle, err = decodeVehiclePosition(f.bytes)
+		case 5:
+			alert, err = decodeAlert(f.bytes)
+		}
+		return err
+	})
+	if err != nil || deleted {
+		return err
+	}
+
+	if tripUpdate != nil {
+		feed.TripUpdates = append(feed.TripUpdates, *tripUpdate)
+	}
+	if vehicle != nil {
+		feed.VehiclePositions = append(feed.VehiclePositions, *vehicle)
+	}
+	if alert != nil {
+		alert.Id = id
+		feed.Alerts = append(feed.Alerts, *alert)
+	}
+
+	return nil
+}
+
+// decodeTripUpdate decodes a TripUpdate message
+func decodeTripUpdate(b []byte) (*dao.GTFSRTTripUpdate, error) {
+	tu := &dao.GTFSRTTripUpdate{}
+
+	err := forEachField(b, func(f protoField) error {
+		switch f.num {
+		case 1: // trip
+			return decodeTripDescriptor(f.bytes, &tu.TripId, &tu.RouteId, &tu.StartDate, &tu.Cancelled)
+		case 2: // stop_time_update
+			stu, err := decodeStopTimeUpdate(f.bytes)
+			if err != nil {
+				return err
+			}
+			tu.StopTimeUpdates = append(tu.StopTimeUpdates, *stu)
+		case 4:
+			tu.Timestamp = unixTime(f.varint)
+		case 5:
+			delay := int(int32(f.varint))
+			tu.Delay = &delay
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return tu, nil
+}
+
+// decodeTripDescriptor decodes the fields of a TripDescriptor message
+func decodeTripDescriptor(b []byte, tripId, routeId, startDate *string, cancelled *bool) error {
+	return forEachField(b, func(f protoField) error {
+		switch f.num {
+		case 1:
+			*tripId = string(f.bytes)
+		case 3:
+			*startDate = string(f.bytes)
+		case 4:
+			*cancelled = f.varint == dao.GTFSRTTripCancelled
+		case 5:
+			*routeId = string(f.bytes)
+		}
+		return nil
+	})
+}
+
+// decodeStopTimeUpdate decodes a StopTimeUpdate message
+func decodeStopTimeUpdate(b []byte) (*dao.GTFSRTStopTimeUpdate, error) {
+	stu := &dao.GTFSRTStopTimeUpdate{}
+
+	err := forEachField(b, func(f protoField) error {
+		switch f.num {
+		case 1:
+			sequence := int(f.varint)
+			stu.StopSequence = &sequence
+		case 2:
+			return decodeStopTimeEvent(f.bytes, &stu.ArrivalDelay, &stu.ArrivalTime)
+		case 3:
+			return decodeStopTimeEvent(f.bytes, &stu.DepartureDelay, &stu.DepartureTime)
+		case 4:
+			stu.StopId = string(f.bytes)
+		case 5:
+			stu.Skipped = f.varint == dao.GTFSRTStopSkipped
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return stu, nil
+}
+
+// decodeStopTimeEvent decodes the delay and the absolute time of a StopTimeEvent message
+func decodeStopTimeEvent(b []byte, delay **int, at **time.Time) error {
+	return forEachField(b, func(f protoField) error {
+		switch f.num {
+		case 1:
+			d := int(int32(f.varint))
+			*delay = &d
+		case 2:
+			t := unixTime(f.varint)
+			*at = &t
+		}
+		return nil
+	})
+}
+
+// decodeVehiclePosition decodes a VehiclePosition message
+func decodeVehiclePosition(b []byte) (*dao.GTFSRTVehiclePosition, error) {
+	vp := &dao.GTFSRTVehiclePosition{}
+
+	err := forEachField(b, func(f protoField) error {
+		switch f.num {
+		case 1: // trip
+			var startDate string
+			var cancelled bool
+			return decodeTripDescriptor(f.bytes, &vp.TripId, &vp.RouteId, &startDate, &cancelled)
+		case 2: // position
+			return forEachField(f.bytes, func(pf protoField) error {
+				value := float64(math.Float32frombits(uint32(pf.varint)))
+				switch pf.num {
+				case 1:
+					vp.Latitude = value
+				case 2:
+					vp.Longitude = value
+				case 3:
+					vp.Bearing = &value
+				}
+				return nil
+			})
+		case 5:
+			vp.Timestamp = unixTime(f.varint)
+		case 7:
+			vp.StopId = string(f.bytes)
+		case 8: // vehicle
+			return forEachField(f.bytes, func(vf protoField) error {
+				switch vf.num {
+				case 1:
+					vp.VehicleId = string(vf.bytes)
+				case 2:
+					vp.Label = string(vf.bytes)
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return vp, nil
+}
+
+// decodeAlert decodes an Alert message
+func decodeAlert(b []byte) (*dao.GTFSRTAlert, error) {
+	alert := &dao.GTFSRTAlert{}
+
+	err := forEachField(b, func(f protoField) error {
+		switch f.num {
+		case 1: // active_period, the first one is kept
+			if alert.Start != nil || alert.End != nil {
+				return nil
+			}
+			return forEachField(f.bytes, func(tf protoField) error {
+				t := unixTime(tf.varint)
+				switch tf.num {
+				case 1:
+					alert.Start = &t
+				case 2:
+					alert.End = &t
+				}
+				return nil
+			})
+		case 5: // informed_entity
+			return forEachField(f.bytes, func(ef protoField) error {
+				switch ef.num {
+				case 2:
+					alert.RouteIds = append(alert.RouteIds, string(ef.bytes))
+				case 4:
+					var tripId, routeId, startDate string
+					var cancelled bool
+					if err := decodeTripDescriptor(ef.bytes, &tripId, &routeId, &startDate, &cancelled); err != nil {
+						return err
+					}
+					if tripId != "" {
+						alert.TripIds = append(alert.TripIds, tripId)
+					}
+				case 5:
+					alert.StopIds = append(alert.StopIds, string(ef.bytes))
+				}
+				return nil
+			})
+		case 6:
+			alert.Cause = int(f.varint)
+		case 7:
+			alert.Effect = int(f.varint)
+		case 10:
+			return decodeTranslatedString(f.bytes, &alert.Header)
+		case 11:
+			return decodeTranslatedString(f.bytes, &alert.Description)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return alert, nil
+}
+
+// decodeTranslatedString keeps the English translation of a TranslatedString message, or the first one
+func decodeTranslatedString(b []byte, text *string) error {
+	return forEachField(b, func(f protoField) error {
+		if f.num != 1 {
+			return nil
+		}
+
+		var value, language string
+		err := forEachField(f.bytes, func(tf protoField) error {
+			switch tf.num {
+			case 1:
+				value = string(tf.bytes)
+			case 2:
+				language = string(tf.bytes)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if *text == "" || strings.HasPrefix(strings.ToLower(language), "en") {
+			*text = value
+		}
+		return nil
+	})
+}
+
+// protoField is a field of a protobuf message, numeric values are in varint
+// whatever their wire type and length delimited values are in bytes
+type protoField struct {
+	num    protowire.Number
+	varint uint64
+	bytes  []byte
+}
+
+// forEachField calls the handler with each field of an encoded protobuf message
+func forEachField(b []byte, handle func(f protoField) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		f := protoField{num: num}
+		switch typ {
+		case protowire.VarintType:
+			f.varint, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			f.varint = uint64(v)
+		case protowire.Fixed64Type:
+			f.varint, n = protowire.ConsumeFixed64(b)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		if err := handle(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// unixTime converts the POSIX time of a feed to a time
+func unixTime(seconds uint64) time.Time {
+	return time.Unix(int64(seconds), 0)
+}
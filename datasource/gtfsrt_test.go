@@ -0,0 +1,199 @@
+package datasource
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
+
+// varintField encodes a varint field of a protobuf message
+func varintField(num protowire.Number, v uint64) []byte {
+	b := protowire.AppendTag(nil, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// bytesField encodes a length delimited field of a protobuf message
+func bytesField(num protowire.Number, v []byte) []byte {
+	b := protowire.AppendTag(nil, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+// message concatenates the encoded fields of a protobuf message
+func message(fields ...[]byte) []byte {
+	var b []byte
+	for _, f := range fields {
+		b = append(b, f...)
+	}
+	return b
+}
+
+// signed encodes a negative int32 the way protobuf does, as a 64 bit two's complement varint
+func signed(v int32) uint64 {
+	return uint64(int64(v))
+}
+
+// feedMessage encodes a FeedMessage with a header at a time and the entities
+func feedMessage(timestamp uint64, entities ...[]byte) []byte {
+	fields := [][]byte{bytesField(1, message(bytesField(1, []byte("2.0")), varintField(3, timestamp)))}
+	for _, e := range entities {
+		fields = append(fields, bytesField(2, e))
+	}
+	return message(fields...)
+}
+
+// tripUpdateEntity encodes a FeedEntity with a TripUpdate of a trip on a day and its stop time updates
+func tripUpdateEntity(id, tripId, startDate string, stopTimeUpdates ...[]byte) []byte {
+	fields := [][]byte{bytesField(1, message(bytesField(1, []byte(tripId)), bytesField(3, []byte(startDate))))}
+	for _, stu := range stopTimeUpdates {
+		fields = append(fields, bytesField(2, stu))
+	}
+	return message(bytesField(1, []byte(id)), bytesField(3, message(fields...)))
+}
+
+func TestDecodeGTFSRealtime(t *testing.T) {
+	arrival := message(
+		varintField(1, 3),
+		bytesField(4, []byte("STOP3")),
+		bytesField(2, message(varintField(1, 120))),
+		bytesField(3, message(varintField(2, 1677661500))),
+	)
+	skipped := message(varintField(1, 4), bytesField(4, []byte("STOP4")), varintField(5, dao.GTFSRTStopSkipped))
+	early := message(bytesField(4, []byte("STOP5")), bytesField(2, message(varintField(1, signed(-60)))))
+
+	// the fields of a newer version of the spec, or extensions, of every wire type
+	unknown := message(
+		varintField(99, 7),
+		bytesField(100, []byte("extension")),
+		protowire.AppendFixed32(protowire.AppendTag(nil, 101, protowire.Fixed32Type), 1),
+		protowire.AppendFixed64(protowire.AppendTag(nil, 102, protowire.Fixed64Type), 1),
+	)
+
+	tests := []struct {
+		name    string
+		feed    []byte
+		wantErr bool
+		check   func(t *testing.T, feed *dao.GTFSRTFeed)
+	}{
+		{
+			name: "trip update with stop time updates",
+			feed: feedMessage(1677661200, tripUpdateEntity("e1", "T100", "20230301", arrival, early)),
+			check: func(t *testing.T, feed *dao.GTFSRTFeed) {
+				if !feed.Timestamp.Equal(time.Unix(1677661200, 0)) {
+					t.Errorf("got timestamp %v", feed.Timestamp)
+				}
+				if len(feed.TripUpdates) != 1 {
+					t.Fatalf("got %d trip updates, want 1", len(feed.TripUpdates))
+				}
+				tu := feed.TripUpdates[0]
+				if tu.TripId != "T100" || tu.StartDate != "20230301" || tu.Cancelled || len(tu.StopTimeUpdates) != 2 {
+					t.Fatalf("got trip update %+v", tu)
+				}
+				stu := tu.StopTimeUpdates[0]
+				if stu.StopSequence == nil || *stu.StopSequence != 3 || stu.StopId != "STOP3" || stu.Skipped {
+					t.Errorf("got stop time update %+v", stu)
+				}
+				if stu.ArrivalDelay == nil || *stu.ArrivalDelay != 120 || stu.ArrivalTime != nil {
+					t.Errorf("got arrival delay %v and time %v, want a delay of 120", stu.ArrivalDelay, stu.ArrivalTime)
+				}
+				if stu.DepartureTime == nil || !stu.DepartureTime.Equal(time.Unix(1677661500, 0)) || stu.DepartureDelay != nil {
+					t.Errorf("got departure delay %v and time %v, want the time only", stu.DepartureDelay, stu.DepartureTime)
+				}
+				if stu := tu.StopTimeUpdates[1]; stu.StopSequence != nil || stu.ArrivalDelay == nil || *stu.ArrivalDelay != -60 {
+					t.Errorf("got stop time update %+v, want no sequence and a delay of -60", stu)
+				}
+			},
+		},
+		{
+			name: "skipped stop",
+			feed: feedMessage(1677661200, tripUpdateEntity("e1", "T100", "", skipped)),
+			check: func(t *testing.T, feed *dao.GTFSRTFeed) {
+				stu := feed.TripUpdates[0].StopTimeUpdates[0]
+				if !stu.Skipped || stu.StopId != "STOP4" {
+					t.Errorf("got stop time update %+v, want STOP4 skipped", stu)
+				}
+			},
+		},
+		{
+			name: "cancelled trip",
+			feed: feedMessage(1677661200, message(bytesField(3, message(
+				bytesField(1, message(bytesField(1, []byte("T200")), varintField(4, dao.GTFSRTTripCancelled))),
+			)))),
+			check: func(t *testing.T, feed *dao.GTFSRTFeed) {
+				if tu := feed.TripUpdates[0]; tu.TripId != "T200" || !tu.Cancelled {
+					t.Errorf("got trip update %+v, want T200 cancelled", tu)
+				}
+			},
+		},
+		{
+			name: "deleted entity",
+			feed: feedMessage(1677661200, message(bytesField(1, []byte("e1")), varintField(2, 1),
+				bytesField(3, message(bytesField(1, message(bytesField(1, []byte("T100")))))))),
+			check: func(t *testing.T, feed *dao.GTFSRTFeed) {
+				if len(feed.TripUpdates) != 0 {
+					t.Errorf("got %d trip updates, want the deleted one left out", len(feed.TripUpdates))
+				}
+			},
+		},
+		{
+			name: "unknown fields",
+			feed: message(feedMessage(1677661200, append(tripUpdateEntity("e1", "T100", "", append(arrival, unknown...)), unknown...)), unknown),
+			check: func(t *testing.T, feed *dao.GTFSRTFeed) {
+				if len(feed.TripUpdates) != 1 || len(feed.TripUpdates[0].StopTimeUpdates) != 1 {
+					t.Fatalf("got trip updates %+v", feed.TripUpdates)
+				}
+				if stu := feed.TripUpdates[0].StopTimeUpdates[0]; stu.StopId != "STOP3" || *stu.ArrivalDelay != 120 {
+					t.Errorf("got stop time update %+v", stu)
+				}
+			},
+		},
+		{
+			name: "empty feed",
+			feed: nil,
+			check: func(t *testing.T, feed *dao.GTFSRTFeed) {
+				if len(feed.TripUpdates) != 0 || !feed.Timestamp.IsZero() {
+					t.Errorf("got feed %+v, want an empty one", feed)
+				}
+			},
+		},
+		{
+			name:    "truncated varint",
+			feed:    append(feedMessage(1677661200), 0x18, 0x80),
+			wantErr: true,
+		},
+		{
+			name:    "varint longer than ten bytes",
+			feed:    append([]byte{0x18}, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01),
+			wantErr: true,
+		},
+		{
+			name:    "truncated tag",
+			feed:    []byte{0x80},
+			wantErr: true,
+		},
+		{
+			name:    "length past the end",
+			feed:    message(protowire.AppendVarint(protowire.AppendTag(nil, 2, protowire.BytesType), 50), []byte("short")),
+			wantErr: true,
+		},
+		{
+			name:    "malformed nested message",
+			feed:    feedMessage(1677661200, message(bytesField(3, message(bytesField(2, []byte{0x08, 0x80}))))),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed, err := DecodeGTFSRealtime(tt.feed)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("got error %v, want error %v", err, tt.wantErr)
+			}
+			if tt.check != nil {
+				tt.check(t, feed)
+			}
+		})
+	}
+}
@@ -0,0 +1,85 @@
+package datasource
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
+
+// naptanStopTypes maps the NaPTAN stop types to the place types, the other stop types are points of interest
+var naptanStopTypes = map[string]string{
+	"BCT": "bus_stop",      // on-street bus or coach stop
+	"BCS": "bus_stop",      // bus or coach station bay
+	"BCQ": "bus_stop",      // bus or coach station variable bay
+	"BST": "bus_stop",      // bus or coach station access area
+	"BCE": "bus_stop",      // bus or coach station entrance
+	"RLY": "train_station", // rail station access area
+	"RSE": "train_station", // rail station entrance
+	"RPL": "train_station", // rail platform
+	"MET": "tube_station",  // metro or tram station access area
+	"PLT": "tube_station",  // metro or tram platform
+	"TMU": "tube_station",  // metro or tram station entrance
+}
+
+// NaptanStop is a stop read from the NaPTAN Stops.csv file
+// a stop that is not active has been removed from service or is not in service yet
+type NaptanStop struct {
+	Place  dao.Place
+	Active bool
+}
+
+// ReadNaptanStops reads the stops of a NaPTAN Stops.csv file and calls the handler with each stop.
+// The ATCO code and the SMS code are read from the ATCOCode and NaptanCode columns and the coordinate
+// from the Latitude and Longitude columns. The rows without an ATCO code or a coordinate are left out
+// and counted
+func ReadNaptanStops(path string, handle func(stop NaptanStop) error) (int, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open NaPTAN stops: %v", err)
+	}
+	defer f.Close()
+
+	skipped := 0
+	err = readCSV(f, path, func(row csvRow) error {
+		atcoCode := strings.ToUpper(row.get("ATCOCode"))
+		lat, latErr := strconv.ParseFloat(row.get("Latitude"), 64)
+		lon, lonErr := strconv.ParseFloat(row.get("Longitude"), 64)
+		if atcoCode == "" || row.get("CommonName") == "" || latErr != nil || lonErr != nil ||
+			lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+			skipped++
+			return nil
+		}
+
+		placeType, ok := naptanStopTypes[strings.ToUpper(row.get("StopType"))]
+		if !ok {
+			placeType = "poi"
+		}
+
+		place := dao.NewPlace(placeType, row.get("CommonName"), naptanDescription(row), "", atcoCode, "", "",
+			row.get("NaptanCode"), nil, &lat, &lon)
+		place.Source = dao.PlaceSourceNaptan
+
+		// older files use act, del and pen, newer ones active, inactive and pending
+		status := strings.ToLower(row.get("Status"))
+		return handle(NaptanStop{Place: *place, Active: status == "" || status == "act" || status == "active"})
+	})
+	if err != nil {
+		return skipped, err
+	}
+
+	return skipped, nil
+}
+
+// naptanDescription describes where a stop is from its indicator, street and locality, e.g. "Stop B, Whitehall, Westminster"
+func naptanDescription(row csvRow) string {
+	var parts []string
+	for _, column := range []string{"Indicator", "Street", "LocalityName"} {
+		if v := row.get(column); v != "" && v != "-" {
+			parts = append(parts, v)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,76 @@
+package datasource
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
+
+func TestReadNaptanStops(t *testing.T) {
+	var stops []NaptanStop
+	skipped, err := ReadNaptanStops(filepath.Join("testdata", "naptan", "Stops.csv"), func(stop NaptanStop) error {
+		stops = append(stops, stop)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ReadNaptanStops returned an error: %v", err)
+	}
+
+	// the rows without an ATCO code, a name or a valid coordinate are skipped
+	if skipped != 4 {
+		t.Errorf("got %d skipped rows, want 4", skipped)
+	}
+
+	want := []struct {
+		key         string
+		placeType   string
+		name        string
+		description string
+		smsCode     string
+		active      bool
+	}{
+		{"atco:490000077E", "bus_stop", "Charing Cross Station", "Stop E, Strand, Charing Cross", "75463", true},
+		{"atco:9100CHRX", "train_station", "Charing Cross Rail Station", "Charing Cross", "", true},
+		{"atco:940GZZLUEMB", "tube_station", "Embankment Underground Station", "Villiers Street, Embankment", "", true},
+		{"atco:490000077D", "bus_stop", "Charing Cross Station", "Stop D, Strand, Charing Cross", "75462", false},
+		{"atco:490000077F", "bus_stop", "Charing Cross Station", "Stop F, Strand, Charing Cross", "", false},
+		{"atco:490000077G", "bus_stop", "Charing Cross Station", "Stop G, Strand, Charing Cross", "", false},
+		{"atco:490000077H", "bus_stop", "Trafalgar Square", "Stop H, Cockspur Street, Charing Cross", "75465", true},
+		{"atco:490000077N", "poi", "Charing Cross Taxi Rank", "Strand, Charing Cross", "", true},
+		// a repeated ATCO code is read again, the import keeps the first one
+		{"atco:490000077E", "bus_stop", "Charing Cross Station Repeated", "Stop E, Strand, Charing Cross", "75463", true},
+	}
+	if len(stops) != len(want) {
+		t.Fatalf("got %d stops, want %d", len(stops), len(want))
+	}
+	for i, w := range want {
+		got := stops[i]
+		p := got.Place
+		if p.Key != w.key || p.Type != w.placeType || p.Name != w.name || p.Description != w.description ||
+			p.SMSCode != w.smsCode || got.Active != w.active {
+			t.Errorf("stop %d: got %s %q of type %s at %q with SMS code %q and active %v, want %+v",
+				i, p.Key, p.Name, p.Type, p.Description, p.SMSCode, got.Active, w)
+		}
+		if p.Source != dao.PlaceSourceNaptan || p.Latitude == nil || p.Longitude == nil || p.Location == nil {
+			t.Errorf("stop %d: got place %+v, want a NaPTAN place with a coordinate", i, p)
+		}
+	}
+}
+
+func TestReadNaptanStopsHandlerError(t *testing.T) {
+	stop := errors.New("stop reading")
+	read := 0
+	_, err := ReadNaptanStops(filepath.Join("testdata", "naptan", "Stops.csv"), func(NaptanStop) error {
+		read++
+		return stop
+	})
+	if !errors.Is(err, stop) || read != 1 {
+		t.Errorf("got error %v after %d stops, want the handler error after the first stop", err, read)
+	}
+
+	if _, err := ReadNaptanStops(filepath.Join("testdata", "naptan", "missing.csv"), func(NaptanStop) error { return nil }); err == nil {
+		t.Error("ReadNaptanStops of a missing file returned no error")
+	}
+}
@@ -0,0 +1,51 @@
+package datasource
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
+
+// onspdNoLocation is the latitude the ONS Postcode Directory gives postcodes without a grid reference
+const onspdNoLocation = 99.999999
+
+// ReadPostcodeDirectory reads the coordinates of the postcodes in an ONS Postcode Directory style csv file.
+// The postcode is read from the pcds, pcd or postcode column and the coordinates from the lat and long,
+// or latitude and longitude columns. Terminated postcodes and postcodes without coordinates are left out.
+// The postcodes are upper case and without spaces
+func ReadPostcodeDirectory(path string) (map[string]dao.PostcodeLocation, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open postcode directory: %v", err)
+	}
+	defer f.Close()
+
+	locations := make(map[string]dao.PostcodeLocation)
+	err = readCSV(f, path, func(row csvRow) error {
+		postcode := row.first("pcds", "pcd", "postcode")
+		if postcode == "" || row.get("doterm") != "" {
+			return nil
+		}
+
+		lat, err := strconv.ParseFloat(row.first("lat", "latitude"), 64)
+		if err != nil || lat == onspdNoLocation {
+			return nil
+		}
+		lon, err := strconv.ParseFloat(row.first("long", "longitude"), 64)
+		if err != nil {
+			return nil
+		}
+
+		postcode = strings.ToUpper(strings.Join(strings.Fields(postcode), ""))
+		locations[postcode] = dao.PostcodeLocation{Latitude: lat, Longitude: lon}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return locations, nil
+}
@@ -54,6 +54,16 @@ func ErrInternalServerError(message string, data interface{}) *RestError {
 	}
 }
 
+// ErrNotImplemented returns a RestError for a request that is not supported
+func ErrNotImplemented(message string, data interface{}) *RestError {
+	return &RestError{
+		Status:  http.StatusNotImplemented,
+		Message: message,
+		Err:     "Not Implemented",
+		Data:    data,
+	}
+}
+
 // ErrUnauthorized returns a RestError for an unauthorized request
 func ErrUnauthorized(message string, data interface{}) *RestError {
 	return &RestError{
@@ -64,6 +74,26 @@ func ErrUnauthorized(message string, data interface{}) *RestError {
 	}
 }
 
+// ErrForbidden returns a RestError for a request the user is not allowed to make
+func ErrForbidden(message string, data interface{}) *RestError {
+	return &RestError{
+		Status:  http.StatusForbidden,
+		Message: message,
+		Err:     "Forbidden",
+		Data:    data,
+	}
+}
+
+// ErrConflict returns a RestError for a request that conflicts with the current state of the data
+func ErrConflict(message string, data interface{}) *RestError {
+	return &RestError{
+		Status:  http.StatusConflict,
+		Message: message,
+		Err:     "Conflict",
+		Data:    data,
+	}
+}
+
 // ErrorToStringSlice converts a slice of errors to a slice of string
 func ErrorToStringSlice(errs []error) []string {
 	var errStrings []string
@@ -0,0 +1,349 @@
+package handler
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/metrics"
+	"github.com/leonardchinonso/lokate-go/middlewares"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+	"github.com/leonardchinonso/lokate-go/utils"
+)
+
+// placeImportMaxBytes is the largest file an admin can upload to import places
+const placeImportMaxBytes = 10 << 20
+
+// AdminHandler handles the requests for maintaining the application data
+type AdminHandler struct {
+	defaultCountry string
+	placeService   interfaces.PlaceServiceInterface
+	naptanService  interfaces.NaptanServiceInterface
+	userService    interfaces.UserServiceInterface
+	tokenService   interfaces.TokenServiceInterface
+}
+
+// InitAdminHandler initializes and sets up the admin handler, every endpoint is for admins only
+func InitAdminHandler(router *gin.Engine, version, adminEmails, defaultCountry string,
+	placeService interfaces.PlaceServiceInterface,
+	naptanService interfaces.NaptanServiceInterface,
+	userService interfaces.UserServiceInterface,
+	tokenService interfaces.TokenServiceInterface,
+) {
+	h := &AdminHandler{
+		defaultCountry: defaultCountry,
+		placeService:   placeService,
+		naptanService:  naptanService,
+		userService:    userService,
+		tokenService:   tokenService,
+	}
+
+	// group routes according to paths
+	path := fmt.Sprintf("%s%s", version, "/admin")
+	g := router.Group(path, middlewares.AuthorizeUser(h.tokenService), middlewares.AuthorizeAdmin(adminEmails))
+
+	// register endpoints for places
+	g.GET("/places/duplicates", h.GetDuplicatePlaces)
+	g.GET("/places/search", h.SearchPlacesAsUser)
+	g.POST("/places/:id/merge", h.MergePlaces)
+	g.PATCH("/places/:id", h.UpdatePlace)
+	g.DELETE("/places/:id", h.DeletePlace)
+	g.POST("/places/import", h.ImportPlaces)
+	g.POST("/places/naptan", h.ImportNaptanStops)
+
+	// register endpoints for the metrics
+	g.GET("/metrics", gin.WrapH(metrics.Handler()))
+}
+
+// GetDuplicatePlaces handles the request to get the groups of stored places that look like the same place
+func (h *AdminHandler) GetDuplicatePlaces(c *gin.Context) {
+	var req dto.DuplicatePlacesRequest
+
+	// fill the duplicate places request by binding the query parameters
+	if err := c.ShouldBindQuery(&req); err != nil {
+		log.Printf("Failed to bind query with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the radius to look for duplicates in
+	radius, err := req.ToRadius(h.placeService.DuplicateMetres())
+	if err != nil {
+		log.Printf("Failed to validate duplicate places request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	duplicates, err := h.placeService.FindDuplicatePlaces(c, radius)
+	if err != nil {
+		log.Printf("Error finding duplicate places with placeService. Error: %v\n", err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("duplicate places retrieved successfully", duplicates)
+	c.JSON(resp.Status, resp)
+}
+
+// SearchPlacesAsUser handles the request to search for places as a user, with the ranking of each
+// place explained, so an admin can see why the search of a user ranks the places as it does
+func (h *AdminHandler) SearchPlacesAsUser(c *gin.Context) {
+	country, ok := countryFromRequest(c, h.defaultCountry)
+	if !ok {
+		return
+	}
+
+	var req dto.AdminPlaceSearchRequest
+
+	// fill the admin place search request by binding the query parameters
+	if err := c.ShouldBindQuery(&req); err != nil {
+		log.Printf("Failed to bind query with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the request and convert it to a query
+	userId, query, errs := req.ToAdminPlaceSearchQuery()
+	if len(errs) > 0 {
+		log.Printf("Failed to validate admin place search request. Errors: %v\n", errs)
+		resErr := errors.ErrBadRequest("invalid place search request", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+	canonicalSearchText(&query, country)
+
+	// the user must exist, a search as an unknown user would look like one that is not personalized
+	if _, err := h.userService.GetUserByID(c, userId); err != nil {
+		log.Printf("Error getting user with userService. Error: %v\n", err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	places, err := h.placeService.SearchPlaces(c, userId, query)
+	if err != nil {
+		log.Printf("Error searching for places in the place service. Error: %v\n", err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("places retrieved successfully", places)
+	c.JSON(resp.Status, resp)
+}
+
+// MergePlaces handles the request to merge duplicate places into a place
+func (h *AdminHandler) MergePlaces(c *gin.Context) {
+	// get the place id from the path parameter
+	placeId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		log.Printf("Failed to convert hex string to place id. Error: %v\n", err)
+		resErr := errors.ErrBadRequest("invalid place id", nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	var req dto.MergePlacesRequest
+
+	// fill the merge places request by binding the JSON
+	if err = c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Failed to bind JSON with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the ids of the duplicate places
+	duplicateIds, errs := req.ToObjectIDs(placeId)
+	if len(errs) > 0 {
+		log.Printf("Failed to validate merge places request. Errors: %v\n", errs)
+		resErr := errors.ErrBadRequest("invalid merge places request", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	merged, err := h.placeService.MergePlaces(c, placeId, duplicateIds)
+	if err != nil {
+		log.Printf("Error merging places with placeService. Error: %v\n", err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("places merged successfully", merged)
+	c.JSON(resp.Status, resp)
+}
+
+// UpdatePlace handles the request to change the fields of a place
+func (h *AdminHandler) UpdatePlace(c *gin.Context) {
+	// get the place id from the path parameter
+	placeId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		log.Printf("Failed to convert hex string to place id. Error: %v\n", err)
+		resErr := errors.ErrBadRequest("invalid place id", nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	var req dto.UpdatePlaceRequest
+
+	// fill the update place request by binding the JSON
+	if err = c.ShouldBindJSON(&req); err != nil {
+		log.Printf("Failed to bind JSON with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the fields to change
+	if errs := req.Validate(); len(errs) > 0 {
+		log.Printf("Failed to validate update place request. Errors: %v\n", errs)
+		resErr := errors.ErrBadRequest("invalid update place request", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	place, err := h.placeService.UpdatePlace(c, placeId, req)
+	if err != nil {
+		log.Printf("Error updating place with placeService. Error: %v\n", err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("place updated successfully", place)
+	c.JSON(resp.Status, resp)
+}
+
+// DeletePlace handles the request to delete a place that no user has saved or visited
+func (h *AdminHandler) DeletePlace(c *gin.Context) {
+	// get the place id from the path parameter
+	placeId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		log.Printf("Failed to convert hex string to place id. Error: %v\n", err)
+		resErr := errors.ErrBadRequest("invalid place id", nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	if err = h.placeService.DeletePlace(c, placeId); err != nil {
+		log.Printf("Error deleting place with placeService. Error: %v\n", err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("place deleted successfully", nil)
+	c.JSON(resp.Status, resp)
+}
+
+// ImportPlaces handles the request to import places from a csv file or a GeoJSON FeatureCollection
+// the file is sent as the body or in the "file" field of a form, and a dry run stores nothing
+func (h *AdminHandler) ImportPlaces(c *gin.Context) {
+	var req dto.PlaceImportRequest
+
+	// fill the place import request by binding the query parameters
+	if err := c.ShouldBindQuery(&req); err != nil {
+		log.Printf("Failed to bind query with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	dryRun, err := req.ToDryRun()
+	if err != nil {
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, placeImportMaxBytes)
+
+	rows, err := readPlaceImport(c)
+	if err != nil {
+		log.Printf("Failed to read place import. Error: %v\n", err)
+		resErr := errors.ErrBadRequest("invalid place import", []string{err.Error()})
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	report, err := h.placeService.ImportPlaces(c, rows, dryRun)
+	if err != nil {
+		log.Printf("Error importing places with placeService. Error: %v\n", err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("places imported successfully", report)
+	c.JSON(resp.Status, resp)
+}
+
+// readPlaceImport reads the rows of a place import by the content type of the request, or by
+// the extension of the uploaded file when the file is sent in a form
+func readPlaceImport(c *gin.Context) ([]dto.PlaceImportRow, error) {
+	switch c.ContentType() {
+	case "text/csv":
+		return dto.ParsePlaceImportCSV(c.Request.Body)
+	case "application/geo+json", "application/json":
+		return dto.ParsePlaceImportGeoJSON(c.Request.Body)
+	case "multipart/form-data":
+		file, err := c.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("the form must have a file in the file field: %v", err)
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open the file: %v", err)
+		}
+		defer f.Close()
+
+		var parse func(r io.Reader) ([]dto.PlaceImportRow, error)
+		switch strings.ToLower(filepath.Ext(file.Filename)) {
+		case ".csv":
+			parse = dto.ParsePlaceImportCSV
+		case ".geojson", ".json":
+			parse = dto.ParsePlaceImportGeoJSON
+		default:
+			return nil, fmt.Errorf("the file must be a .csv, .geojson or .json file")
+		}
+		return parse(f)
+	default:
+		return nil, fmt.Errorf("the content type must be text/csv, application/geo+json or multipart/form-data")
+	}
+}
+
+// ImportNaptanStops handles the request to import the configured NaPTAN stops file into the places
+func (h *AdminHandler) ImportNaptanStops(c *gin.Context) {
+	var req dto.PlaceImportRequest
+
+	// fill the import request by binding the query parameters
+	if err := c.ShouldBindQuery(&req); err != nil {
+		log.Printf("Failed to bind query with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	dryRun, err := req.ToDryRun()
+	if err != nil {
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	report, err := h.naptanService.Import(c, "", dryRun)
+	if err != nil {
+		log.Printf("Error importing NaPTAN stops with naptanService. Error: %v\n", err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("NaPTAN stops imported successfully", report)
+	c.JSON(resp.Status, resp)
+}
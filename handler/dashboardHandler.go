@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/middlewares"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+	"github.com/leonardchinonso/lokate-go/utils"
+)
+
+// DashboardHandler handles the requests for the user's departures dashboard
+type DashboardHandler struct {
+	dashboardService interfaces.DashboardServiceInterface
+	tokenService     interfaces.TokenServiceInterface
+}
+
+// InitDashboardHandler initializes and sets up the dashboard handler
+func InitDashboardHandler(router *gin.Engine, version string, dashboardService interfaces.DashboardServiceInterface, tokenService interfaces.TokenServiceInterface) {
+	h := &DashboardHandler{
+		dashboardService: dashboardService,
+		tokenService:     tokenService,
+	}
+
+	// group routes according to paths
+	path := fmt.Sprintf("%s%s", version, "/dashboard")
+	g := router.Group(path)
+
+	// register endpoints
+	g.GET("/departures", middlewares.AuthorizeUser(h.tokenService), h.GetDepartures)
+}
+
+// GetDepartures handles the request to get the departures from all the stops the user pinned
+func (h *DashboardHandler) GetDepartures(c *gin.Context) {
+	// retrieve the logged-in user from the authenticated request
+	user, ok := UserFromRequest(c)
+	if !ok {
+		log.Printf("Failed to retrieve user from authenticated request")
+		resErr := errors.ErrUnauthorized("you are not logged in", nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	dashboard, err := h.dashboardService.Departures(c, user.Id)
+	if err != nil {
+		log.Printf("Error getting the dashboard departures. Error: %v\n", err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("dashboard departures retrieved successfully", dashboard)
+	c.JSON(resp.Status, resp)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/middlewares"
 	"github.com/leonardchinonso/lokate-go/models/dto"
 	"github.com/leonardchinonso/lokate-go/models/interfaces"
 	"github.com/leonardchinonso/lokate-go/utils"
@@ -12,25 +13,77 @@ import (
 
 // JourneyHandler represents the router handler object for journey requests
 type JourneyHandler struct {
-	tapiService  interfaces.TAPIServiceInterface
-	tokenService interfaces.TokenServiceInterface
+	defaultCountry string
+	journeyService interfaces.JourneyServiceInterface
+	transitService interfaces.TransitServiceInterface
+	tokenService   interfaces.TokenServiceInterface
 }
 
 // InitJourneyHandler initializes the journey handler
-func InitJourneyHandler(router *gin.Engine, version string, tapiService interfaces.TAPIServiceInterface, tokenService interfaces.TokenServiceInterface) {
+func InitJourneyHandler(router *gin.Engine, version, defaultCountry string, journeyService interfaces.JourneyServiceInterface, transitService interfaces.TransitServiceInterface, tokenService interfaces.TokenServiceInterface) {
 	h := &JourneyHandler{
-		tapiService:  tapiService,
-		tokenService: tokenService,
+		defaultCountry: defaultCountry,
+		journeyService: journeyService,
+		transitService: transitService,
+		tokenService:   tokenService,
 	}
 
 	// group routes according to paths
 	path := fmt.Sprintf("%s%s", version, "/journey")
 	g := router.Group(path)
 
+	g.GET("/", middlewares.OptionalAuthorizeUser(h.tokenService), h.PlanJourney)
 	g.GET("/lonlat", h.PublicJourneyLonLat)
 	g.GET("/postcode", h.PublicJourneyPostcode)
 }
 
+// PlanJourney handles the request to get journeys between any two places known to the application
+// each end can be a place id, a saved place id, a saved place alias, a postcode or a lat/lon pair
+func (h *JourneyHandler) PlanJourney(c *gin.Context) {
+	// the postcodes are read in the format of the country
+	country, ok := countryFromRequest(c, h.defaultCountry)
+	if !ok {
+		return
+	}
+
+	// read the from and to values from the query parameters
+	from, err := dto.ParseJourneyEndpoint(c.Query("from"), country)
+	if err != nil {
+		log.Printf("Error parsing journey start. Error: %v", err)
+		resErr := errors.ErrBadRequest(fmt.Sprintf("invalid from value: %v", err), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	to, err := dto.ParseJourneyEndpoint(c.Query("to"), country)
+	if err != nil {
+		log.Printf("Error parsing journey destination. Error: %v", err)
+		resErr := errors.ErrBadRequest(fmt.Sprintf("invalid to value: %v", err), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// read the journey options from the query parameters
+	opts, ok := journeyOptionsFromRequest(c)
+	if !ok {
+		return
+	}
+
+	// the user is only present when the request is authenticated
+	user, _ := UserFromRequest(c)
+
+	// resolve both ends and get the journey from the journey service
+	journeyResp, err := h.journeyService.PlanJourney(c, user, from, to, opts)
+	if err != nil {
+		log.Printf("Error planning journey. Error: %v", err)
+		c.JSON(errors.Status(err), gin.H{"errors": err})
+		return
+	}
+
+	resp := utils.ResponseStatusOK("routes retrieved successfully", journeyResp)
+	c.JSON(resp.Status, resp)
+}
+
 // PublicJourneyLonLat handles the request to get journeys using lonlat format
 func (h *JourneyHandler) PublicJourneyLonLat(c *gin.Context) {
 	// read the from and to values from the path parameter
@@ -43,14 +96,23 @@ func (h *JourneyHandler) PublicJourneyLonLat(c *gin.Context) {
 	fromLoc := dto.NewLocation(fromLat, fromLon)
 	toLoc := dto.NewLocation(toLat, toLon)
 
-	// get the journey from the TAPI service
-	journeyResp, err := h.tapiService.PublicJourneyLonLat(fromLoc, toLoc)
+	// read the journey options from the query parameters
+	opts, ok := journeyOptionsFromRequest(c)
+	if !ok {
+		return
+	}
+
+	// get the journey from the transit service
+	journeyResp, err := h.transitService.PublicJourney(dto.LocationToJourneyPoint(fromLoc), dto.LocationToJourneyPoint(toLoc), opts)
 	if err != nil {
 		log.Printf("Error getting public journey for latitude and longitude values. Error: %v", err)
 		c.JSON(errors.Status(err), gin.H{"errors": err})
 		return
 	}
 
+	// filter and sort the routes with the journey options
+	h.journeyService.RankRoutes(journeyResp, opts)
+
 	resp := utils.ResponseStatusOK("routes retrieved successfully", journeyResp)
 	c.JSON(resp.Status, resp)
 }
@@ -61,8 +123,13 @@ func (h *JourneyHandler) PublicJourneyPostcode(c *gin.Context) {
 	fromPostcode := c.Query("from")
 	toPostcode := c.Query("to")
 
+	country, ok := countryFromRequest(c, h.defaultCountry)
+	if !ok {
+		return
+	}
+
 	// convert the values to a postcode type
-	from, err := dto.NewPostcode(fromPostcode)
+	from, err := dto.NewPostalCode(country, fromPostcode)
 	if err != nil {
 		log.Printf("Error getting public journey for postcode values. Error: %v", err)
 		resErr := errors.ErrBadRequest("invalid postcode", nil)
@@ -70,7 +137,7 @@ func (h *JourneyHandler) PublicJourneyPostcode(c *gin.Context) {
 		return
 	}
 
-	to, err := dto.NewPostcode(toPostcode)
+	to, err := dto.NewPostalCode(country, toPostcode)
 	if err != nil {
 		log.Printf("Error getting public journey for postcode values. Error: %v", err)
 		resErr := errors.ErrBadRequest("invalid postcode", nil)
@@ -78,14 +145,62 @@ func (h *JourneyHandler) PublicJourneyPostcode(c *gin.Context) {
 		return
 	}
 
-	// get the journey from the TAPI service
-	journeyResp, err := h.tapiService.PublicJourneyPostcode(from, to)
+	// read the journey options from the query parameters
+	opts, ok := journeyOptionsFromRequest(c)
+	if !ok {
+		return
+	}
+
+	// resolve the postcodes to their coordinates when they are known locally
+	fromPoint, err := h.journeyService.ResolveEndpoint(c, nil, dto.JourneyEndpoint{Kind: dto.PostcodeEndpoint, Postcode: from})
+	if err != nil {
+		log.Printf("Error resolving postcode. Error: %v", err)
+		c.JSON(errors.Status(err), gin.H{"errors": err})
+		return
+	}
+
+	toPoint, err := h.journeyService.ResolveEndpoint(c, nil, dto.JourneyEndpoint{Kind: dto.PostcodeEndpoint, Postcode: to})
+	if err != nil {
+		log.Printf("Error resolving postcode. Error: %v", err)
+		c.JSON(errors.Status(err), gin.H{"errors": err})
+		return
+	}
+
+	// get the journey from the transit service
+	journeyResp, err := h.transitService.PublicJourney(fromPoint, toPoint, opts)
 	if err != nil {
 		log.Printf("Error getting public journey for postcode values. Error: %v", err)
 		c.JSON(errors.Status(err), gin.H{"errors": err})
 		return
 	}
 
+	// filter and sort the routes with the journey options
+	h.journeyService.RankRoutes(journeyResp, opts)
+
 	resp := utils.ResponseStatusOK("routes retrieved successfully", journeyResp)
 	c.JSON(resp.Status, resp)
 }
+
+// journeyOptionsFromRequest binds and validates the journey options in the query parameters
+// it writes the error response and returns false when the options are invalid
+func journeyOptionsFromRequest(c *gin.Context) (dto.JourneyOptions, bool) {
+	var jor dto.JourneyOptionsRequest
+
+	// fill the journey options request by binding the query
+	if err := c.ShouldBindQuery(&jor); err != nil {
+		log.Printf("Failed to bind query with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return dto.JourneyOptions{}, false
+	}
+
+	// validate the journey options for invalid fields
+	opts, errs := jor.ToJourneyOptions()
+	if len(errs) > 0 {
+		resErr := errors.ErrBadRequest("invalid journey options", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return dto.JourneyOptions{}, false
+	}
+
+	return opts, true
+}
@@ -3,9 +3,10 @@ package handler
 import (
 	"fmt"
 	"github.com/leonardchinonso/lokate-go/middlewares"
+	"io"
 	"log"
-	"net/url"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -17,28 +18,42 @@ import (
 	"github.com/leonardchinonso/lokate-go/utils"
 )
 
+// streamHeartbeatInterval is how often an event is sent on an idle departure stream
+const streamHeartbeatInterval = 30 * time.Second
+
 // PlaceHandler represents the router handler object for place requests
 type PlaceHandler struct {
+	defaultCountry          string
 	placeService            interfaces.PlaceServiceInterface
 	savedPlaceService       interfaces.SavedPlaceServiceInterface
 	lastVisitedPlaceService interfaces.LastVisitedPlaceServiceInterface
-	tapiService             interfaces.TAPIServiceInterface
+	transitService          interfaces.TransitServiceInterface
+	departureService        interfaces.DepartureServiceInterface
+	departureStreamService  interfaces.DepartureStreamServiceInterface
+	autocompleteService     interfaces.AutocompleteServiceInterface
 	tokenService            interfaces.TokenServiceInterface
 }
 
 // InitPlaceHandler initializes and sets up the saved places handler
-func InitPlaceHandler(router *gin.Engine, version string,
+func InitPlaceHandler(router *gin.Engine, version, defaultCountry string,
 	placeService interfaces.PlaceServiceInterface,
 	savedPlaceService interfaces.SavedPlaceServiceInterface,
 	lastVisitedPlace interfaces.LastVisitedPlaceServiceInterface,
-	tapiService interfaces.TAPIServiceInterface,
+	transitService interfaces.TransitServiceInterface,
+	departureService interfaces.DepartureServiceInterface,
+	departureStreamService interfaces.DepartureStreamServiceInterface,
+	autocompleteService interfaces.AutocompleteServiceInterface,
 	tokenService interfaces.TokenServiceInterface,
 ) {
 	h := &PlaceHandler{
+		defaultCountry:          defaultCountry,
 		placeService:            placeService,
 		savedPlaceService:       savedPlaceService,
 		lastVisitedPlaceService: lastVisitedPlace,
-		tapiService:             tapiService,
+		transitService:          transitService,
+		departureService:        departureService,
+		departureStreamService:  departureStreamService,
+		autocompleteService:     autocompleteService,
 		tokenService:            tokenService,
 	}
 
@@ -49,13 +64,19 @@ func InitPlaceHandler(router *gin.Engine, version string,
 	// register endpoints for places
 	g.POST("/", h.AddPlace)
 	g.GET("/:id", h.GetPlace)
+	g.GET("/:id/departures", h.GetPlaceDepartures)
+	g.GET("/:id/departures/stream", h.StreamPlaceDepartures)
 
 	// register endpoints for last visited places
 	g.POST("/:id/last", middlewares.AuthorizeUser(h.tokenService), h.AddLastVisitedPlace)
 	g.GET("/last/:num", middlewares.AuthorizeUser(h.tokenService), h.GetLastNVisitedPlaces)
 
 	// register endpoints for search
-	g.GET("/search", h.Search)
+	g.GET("/search", middlewares.OptionalAuthorizeUser(h.tokenService), h.Search)
+	g.GET("/autocomplete", middlewares.OptionalAuthorizeUser(h.tokenService), h.Autocomplete)
+	g.GET("/nearby", h.GetNearbyPlaces)
+	g.GET("/nearest", h.GetNearestPlaces)
+	g.GET("/reverse", h.ReversePlace)
 }
 
 // AddPlace handles the request to add a place to the application
@@ -107,10 +128,101 @@ func (h *PlaceHandler) GetPlace(c *gin.Context) {
 		return
 	}
 
-	resp := utils.ResponseStatusOK("place retrieved successfully", place)
+	resp := utils.ResponseStatusOK("place retrieved successfully", h.placeService.DescribePlace(place))
+	c.JSON(resp.Status, resp)
+}
+
+// GetPlaceDepartures handles the request to get the live departures from a bus stop or a train station
+func (h *PlaceHandler) GetPlaceDepartures(c *gin.Context) {
+	// get the place id from the path parameter
+	placeId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		log.Printf("Failed to convert hex string to place id. Error: %v\n", err)
+		resErr := errors.ErrBadRequest("invalid place id", nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// create a place object for retrieving the place
+	place := &dao.Place{Id: placeId}
+
+	// retrieve the place from the database
+	err = h.placeService.GetPlace(c, place)
+	if err != nil {
+		log.Printf("Error getting place document from the database. Error: %v\n", err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	// get the departures from the stop the place is
+	board, err := h.departureService.PlaceDepartures(place)
+	if err != nil {
+		log.Printf("Error getting departures from the departure service. Error: %v\n", err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("departures retrieved successfully", board)
 	c.JSON(resp.Status, resp)
 }
 
+// StreamPlaceDepartures handles the request to stream the departures from a bus stop or a train station
+// with Server-Sent Events. The whole board is sent first, then only the departures that changed
+func (h *PlaceHandler) StreamPlaceDepartures(c *gin.Context) {
+	// get the place id from the path parameter
+	placeId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		log.Printf("Failed to convert hex string to place id. Error: %v\n", err)
+		resErr := errors.ErrBadRequest("invalid place id", nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// create a place object for retrieving the place
+	place := &dao.Place{Id: placeId}
+
+	// retrieve the place from the database
+	err = h.placeService.GetPlace(c, place)
+	if err != nil {
+		log.Printf("Error getting place document from the database. Error: %v\n", err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	// subscribe to the departures of the stop, the polling is shared with the other subscribers
+	events, unsubscribe, err := h.departureStreamService.Subscribe(place)
+	if err != nil {
+		log.Printf("Error subscribing to departures. Error: %v\n", err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+	defer unsubscribe()
+
+	// keep idle connections open through proxies
+	heartbeat := time.NewTicker(streamHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	// the stream ends when the client disconnects or the events are closed on shutdown
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case event, ok := <-events:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Name, event.Data)
+			return true
+		case <-heartbeat.C:
+			c.SSEvent("ping", time.Now().Unix())
+			return true
+		}
+	})
+}
+
 // AddLastVisitedPlace handles the request to add a place to the last visited places
 func (h *PlaceHandler) AddLastVisitedPlace(c *gin.Context) {
 	// get the place id from the path parameter
@@ -185,17 +297,152 @@ func (h *PlaceHandler) GetLastNVisitedPlaces(c *gin.Context) {
 }
 
 // Search handles the request to search for a place with text
+// the stored places are searched first and the transit provider when too few of them match, the places
+// a logged-in user saved and visited rank higher
 func (h *PlaceHandler) Search(c *gin.Context) {
-	// read the search value from the path parameter and escape non-alphanumeric characters
-	searchStr := url.QueryEscape(c.Query("query"))
+	country, ok := countryFromRequest(c, h.defaultCountry)
+	if !ok {
+		return
+	}
+
+	var req dto.PlaceSearchRequest
+
+	// fill the place search request by binding the query parameters
+	if err := c.ShouldBindQuery(&req); err != nil {
+		log.Printf("Failed to bind query with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the request and convert it to a query
+	query, errs := req.ToPlaceSearchQuery()
+	if len(errs) > 0 {
+		log.Printf("Failed to validate place search request. Errors: %v\n", errs)
+		resErr := errors.ErrBadRequest("invalid place search request", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	canonicalSearchText(&query, country)
+
+	// anonymous requests are not personalized
+	var userId primitive.ObjectID
+	if user, ok := UserFromRequest(c); ok {
+		userId = user.Id
+	}
+
+	places, err := h.placeService.SearchPlaces(c, userId, query)
+	if err != nil {
+		log.Printf("Error searching for places in the place service. Error: %v\n", err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("places retrieved successfully", places)
+	c.JSON(resp.Status, resp)
+}
+
+// Autocomplete handles the request to suggest places as a search is typed
+// the saved places of a logged-in user are suggested first
+func (h *PlaceHandler) Autocomplete(c *gin.Context) {
+	var req dto.AutocompleteRequest
+
+	// fill the autocomplete request by binding the query parameters
+	if err := c.ShouldBindQuery(&req); err != nil {
+		log.Printf("Failed to bind query with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the request and convert it to a query
+	query, errs := req.ToAutocompleteQuery()
+	if len(errs) > 0 {
+		log.Printf("Failed to validate autocomplete request. Errors: %v\n", errs)
+		resErr := errors.ErrBadRequest("invalid autocomplete request", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// anonymous requests get no saved places
+	var userId primitive.ObjectID
+	if user, ok := UserFromRequest(c); ok {
+		userId = user.Id
+	}
+
+	suggestions, err := h.autocompleteService.Suggest(c, userId, query)
+	if err != nil {
+		log.Printf("Error suggesting places with the autocomplete service. Error: %v\n", err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("places suggested successfully", suggestions)
+	c.JSON(resp.Status, resp)
+}
+
+// GetNearbyPlaces handles the request to get the stored places around a point, nearest first
+func (h *PlaceHandler) GetNearbyPlaces(c *gin.Context) {
+	var req dto.NearbyPlacesRequest
+
+	// fill the nearby places request by binding the query parameters
+	if err := c.ShouldBindQuery(&req); err != nil {
+		log.Printf("Failed to bind query with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the query parameters
+	query, errs := req.ToNearbyPlacesQuery()
+	if len(errs) > 0 {
+		log.Printf("Failed to validate nearby places request. Errors: %v\n", errs)
+		resErr := errors.ErrBadRequest("invalid nearby places request", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// make a call to the place service to find the places
+	placesResp, err := h.placeService.GetNearbyPlaces(c, query)
+	if err != nil {
+		log.Printf("Error getting nearby places from the place service. Error: %v\n", err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("places retrieved successfully", placesResp)
+	c.JSON(resp.Status, resp)
+}
+
+// GetNearestPlaces handles the request to get the places nearest to a point from the transit provider
+func (h *PlaceHandler) GetNearestPlaces(c *gin.Context) {
+	var req dto.NearestPlacesRequest
+
+	// fill the nearest places request by binding the query parameters
+	if err := c.ShouldBindQuery(&req); err != nil {
+		log.Printf("Failed to bind query with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the query parameters
+	query, errs := req.ToNearestPlacesQuery()
+	if len(errs) > 0 {
+		log.Printf("Failed to validate nearest places request. Errors: %v\n", errs)
+		resErr := errors.ErrBadRequest("invalid nearest places request", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
 
 	// create list of places object to put data into
 	var places []dao.Place
 
-	// make a call to the place service to search for the data
-	placesResp, err := h.tapiService.SearchPlace(searchStr, &places)
+	// make a call to the transit service to find the places
+	placesResp, err := h.transitService.NearestPlaces(query, &places)
 	if err != nil {
-		log.Printf("Error searching for places in the place service. Error: %v\n", err)
+		log.Printf("Error getting nearest places from the transit service. Error: %v\n", err)
 		c.JSON(errors.Status(err), err)
 		return
 	}
@@ -203,3 +450,43 @@ func (h *PlaceHandler) Search(c *gin.Context) {
 	resp := utils.ResponseStatusOK("places retrieved successfully", placesResp)
 	c.JSON(resp.Status, resp)
 }
+
+// ReversePlace handles the request to get the place at a point, such as a pin dropped on the map
+func (h *PlaceHandler) ReversePlace(c *gin.Context) {
+	var req dto.ReversePlaceRequest
+
+	// fill the reverse place request by binding the query parameters
+	if err := c.ShouldBindQuery(&req); err != nil {
+		log.Printf("Failed to bind query with request. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// validate the query parameters
+	query, errs := req.ToReversePlaceQuery()
+	if len(errs) > 0 {
+		log.Printf("Failed to validate reverse place request. Errors: %v\n", errs)
+		resErr := errors.ErrBadRequest("invalid reverse place request", errors.ErrorToStringSlice(errs))
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// make a call to the place service to find the place
+	placeResp, err := h.placeService.ReversePlace(c, query)
+	if err != nil {
+		log.Printf("Error getting the place at a location from the place service. Error: %v\n", err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("place retrieved successfully", placeResp)
+	c.JSON(resp.Status, resp)
+}
+
+// canonicalSearchText searches for a postal code of the country in its canonical format
+func canonicalSearchText(query *dto.PlaceSearchQuery, country string) {
+	if postcode, err := dto.NewPostalCode(country, query.Text); err == nil {
+		query.Text = postcode.String()
+	}
+}
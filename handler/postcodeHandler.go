@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+	"github.com/leonardchinonso/lokate-go/utils"
+)
+
+// postcodeBatchMaxBytes is the largest body a client can upload to geocode a batch of postcodes
+const postcodeBatchMaxBytes = 1 << 20
+
+// PostcodeHandler represents the router handler object for postcode requests
+type PostcodeHandler struct {
+	defaultCountry  string
+	postcodeService interfaces.PostcodeServiceInterface
+}
+
+// InitPostcodeHandler initializes and sets up the postcode handler
+func InitPostcodeHandler(router *gin.Engine, version, defaultCountry string, postcodeService interfaces.PostcodeServiceInterface) {
+	h := &PostcodeHandler{
+		defaultCountry:  defaultCountry,
+		postcodeService: postcodeService,
+	}
+
+	// group routes according to paths
+	path := fmt.Sprintf("%s%s", version, "/postcodes")
+	g := router.Group(path)
+
+	// register endpoints
+	g.GET("/:postcode", h.GetPostcode)
+	g.POST("/batch", h.GeocodePostcodeBatch)
+}
+
+// GetPostcode handles the request to get the coordinate of a postcode
+func (h *PostcodeHandler) GetPostcode(c *gin.Context) {
+	country, ok := countryFromRequest(c, h.defaultCountry)
+	if !ok {
+		return
+	}
+
+	// validate the postcode from the path parameter
+	postcode, err := dto.NewPostalCode(country, c.Param("postcode"))
+	if err != nil {
+		log.Printf("Failed to validate postcode. Error: %v\n", err)
+		resErr := errors.ErrBadRequest("invalid postcode", nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	postcodeResp, err := h.postcodeService.Geocode(postcode)
+	if err != nil {
+		log.Printf("Error geocoding postcode with postcodeService. Error: %v\n", err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("postcode retrieved successfully", postcodeResp)
+	c.JSON(resp.Status, resp)
+}
+
+// GeocodePostcodeBatch handles the request to get the coordinates of a batch of postcodes
+// the postcodes are sent as a JSON array, a csv body or a csv file in the "file" field of a form
+func (h *PostcodeHandler) GeocodePostcodeBatch(c *gin.Context) {
+	country, ok := countryFromRequest(c, h.defaultCountry)
+	if !ok {
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, postcodeBatchMaxBytes)
+
+	postcodes, err := readPostcodeBatch(c)
+	if err != nil {
+		log.Printf("Failed to read postcode batch. Error: %v\n", err)
+		resErr := errors.ErrBadRequest("invalid postcode batch", []string{err.Error()})
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	if len(postcodes) == 0 {
+		resErr := errors.ErrBadRequest("the batch has no postcodes", nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	batchResp, err := h.postcodeService.GeocodeBatch(country, postcodes)
+	if err != nil {
+		log.Printf("Error geocoding postcode batch with postcodeService. Error: %v\n", err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("postcodes retrieved successfully", batchResp)
+	c.JSON(resp.Status, resp)
+}
+
+// readPostcodeBatch reads the postcodes of a batch from the request body by its content type
+func readPostcodeBatch(c *gin.Context) ([]dto.PostcodeBatchRow, error) {
+	switch c.ContentType() {
+	case "application/json":
+		return dto.ParsePostcodeBatchJSON(c.Request.Body)
+	case "multipart/form-data":
+		file, err := c.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("the form must have a csv file in the file field: %v", err)
+		}
+		f, err := file.Open()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open the csv file: %v", err)
+		}
+		defer f.Close()
+		return dto.ParsePostcodeBatchCSV(f)
+	case "text/csv", "text/plain":
+		return dto.ParsePostcodeBatchCSV(c.Request.Body)
+	default:
+		return nil, fmt.Errorf("the content type must be application/json, text/csv or multipart/form-data")
+	}
+}
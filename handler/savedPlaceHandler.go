@@ -38,6 +38,10 @@ func InitSavedPlaceHandler(router *gin.Engine, version string, placeService inte
 	g.GET("/", middlewares.AuthorizeUser(h.tokenService), h.GetSavedPlaces)
 	g.PUT("/:id", middlewares.AuthorizeUser(h.tokenService), h.EditSavedPlace)
 	g.DELETE("/:id", middlewares.AuthorizeUser(h.tokenService), h.DeleteSavedPlace)
+
+	// register endpoints for pinning stops to the departures dashboard
+	g.PUT("/:id/pin", middlewares.AuthorizeUser(h.tokenService), h.PinSavedPlace)
+	g.DELETE("/:id/pin", middlewares.AuthorizeUser(h.tokenService), h.UnpinSavedPlace)
 }
 
 // AddToSavedPlaces handles the request to save a place to the application
@@ -235,3 +239,48 @@ func (h *SavedPlaceHandler) DeleteSavedPlace(c *gin.Context) {
 	resp := utils.ResponseStatusOK("deleted saved place successfully", nil)
 	c.JSON(resp.Status, resp)
 }
+
+// PinSavedPlace handles the request to pin a saved stop to the departures dashboard
+func (h *SavedPlaceHandler) PinSavedPlace(c *gin.Context) {
+	h.setPinned(c, true)
+}
+
+// UnpinSavedPlace handles the request to remove a saved stop from the departures dashboard
+func (h *SavedPlaceHandler) UnpinSavedPlace(c *gin.Context) {
+	h.setPinned(c, false)
+}
+
+// setPinned pins or unpins the saved place in the path
+func (h *SavedPlaceHandler) setPinned(c *gin.Context, pinned bool) {
+	// get the place id from the path parameter
+	savedPlaceId, err := primitive.ObjectIDFromHex(c.Param("id"))
+	if err != nil {
+		log.Printf("Failed to convert hex string to saved_place id. Error: %v\n", err)
+		resErr := errors.ErrBadRequest("invalid saved_place id", nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// retrieve the logged-in user from the authenticated request
+	user, ok := UserFromRequest(c)
+	if !ok {
+		log.Printf("Failed to retrieve user from authenticated request")
+		resErr := errors.ErrUnauthorized("you are not logged in", nil)
+		c.JSON(resErr.Status, resErr)
+		return
+	}
+
+	// create a savedPlace object to get the data with
+	savedPlace := &dao.SavedPlace{Id: savedPlaceId, UserId: user.Id}
+
+	// call the savedPlaceService to handle it
+	err = h.savedPlaceService.PinSavedPlace(c, savedPlace, pinned)
+	if err != nil {
+		log.Printf("Error pinning a place in the savedPlaceService: %v", err)
+		c.JSON(errors.Status(err), err)
+		return
+	}
+
+	resp := utils.ResponseStatusOK("saved place updated successfully", savedPlace)
+	c.JSON(resp.Status, resp)
+}
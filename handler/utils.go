@@ -1,8 +1,12 @@
 package handler
 
 import (
+	"log"
+
 	"github.com/gin-gonic/gin"
+	"github.com/leonardchinonso/lokate-go/errors"
 	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/dto"
 )
 
 // UserFromRequest gets a user set by the authentication middleware
@@ -16,3 +20,17 @@ func UserFromRequest(c *gin.Context) (*dao.User, bool) {
 	user := u.(*dao.User)
 	return user, true
 }
+
+// countryFromRequest reads the country the postal codes of a request are in from the country query
+// parameter, falling back to the default country. It writes the error response and returns false
+// when the country is not supported
+func countryFromRequest(c *gin.Context, defaultCountry string) (string, bool) {
+	country, err := dto.ParseCountry(c.Query("country"), defaultCountry)
+	if err != nil {
+		log.Printf("Failed to validate country. Error: %v\n", err)
+		resErr := errors.ErrBadRequest(err.Error(), nil)
+		c.JSON(resErr.Status, resErr)
+		return "", false
+	}
+	return country, true
+}
@@ -15,9 +15,13 @@ func injectHandlers(router *gin.Engine, cfg *map[string]string, handlerCfg *Hand
 	// initialize the handlers
 	handler.InitAuthHandler(router, version, handlerCfg.UserService, handlerCfg.TokenService)
 	handler.InitCommsHandler(router, version, handlerCfg.CommsService, handlerCfg.TokenService)
-	handler.InitPlaceHandler(router, version, handlerCfg.PlaceService, handlerCfg.SavedPlaceService,
-		handlerCfg.LastVisitedPlaceService, handlerCfg.TAPIService, handlerCfg.TokenService)
+	handler.InitPlaceHandler(router, version, (*cfg)[config.DefaultCountry], handlerCfg.PlaceService, handlerCfg.SavedPlaceService,
+		handlerCfg.LastVisitedPlaceService, handlerCfg.TransitService, handlerCfg.DepartureService, handlerCfg.DepartureStreamService, handlerCfg.AutocompleteService, handlerCfg.TokenService)
 	handler.InitSavedPlaceHandler(router, version, handlerCfg.PlaceService, handlerCfg.SavedPlaceService, handlerCfg.TokenService)
-	handler.InitJourneyHandler(router, version, handlerCfg.TAPIService, handlerCfg.TokenService)
+	handler.InitJourneyHandler(router, version, (*cfg)[config.DefaultCountry], handlerCfg.JourneyService, handlerCfg.TransitService, handlerCfg.TokenService)
+	handler.InitDashboardHandler(router, version, handlerCfg.DashboardService, handlerCfg.TokenService)
 	handler.InitUserHandler(router, version, handlerCfg.UserService, handlerCfg.TokenService)
+	handler.InitPostcodeHandler(router, version, (*cfg)[config.DefaultCountry], handlerCfg.PostcodeService)
+	handler.InitAdminHandler(router, version, (*cfg)[config.AdminEmails], (*cfg)[config.DefaultCountry], handlerCfg.PlaceService,
+		handlerCfg.NaptanService, handlerCfg.UserService, handlerCfg.TokenService)
 }
@@ -1,6 +1,7 @@
 package injection
 
 import (
+	"context"
 	"fmt"
 	"log"
 
@@ -9,17 +10,29 @@ import (
 	"github.com/leonardchinonso/lokate-go/datasource"
 )
 
+// Shutdown releases the services that outlive a request
+type Shutdown struct {
+	// Stop stops the background services and ends the open departure streams, it is called when the server starts shutting down
+	Stop func()
+	// Drain waits for the background writes, it is called once the server has shut down and before the database is closed
+	Drain func(ctx context.Context) error
+}
+
 // Inject injects all the repos and services necessary
-func Inject(ds *datasource.DataSource) (*gin.Engine, error) {
+func Inject(ds *datasource.DataSource) (*gin.Engine, *Shutdown, error) {
 	log.Printf("Injecting Data Sources...\n")
 
 	// load repositories
 	servCfg := injectRepositories(ds.Database)
  
+	// the background services run until the server shuts down
+	ctx, cancel := context.WithCancel(context.Background())
+
 	// load services
-	handCfg, err := injectServices(ds.Cfg, servCfg)
+	handCfg, err := injectServices(ctx, ds.Cfg, servCfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to inject services: %v", err)
+		cancel()
+		return nil, nil, fmt.Errorf("failed to inject services: %v", err)
 	}
 
 	// load router
@@ -28,8 +41,19 @@ func Inject(ds *datasource.DataSource) (*gin.Engine, error) {
 	// load handlers
 	injectHandlers(router, ds.Cfg, handCfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to inject handlers: %v", err)
+		cancel()
+		return nil, nil, fmt.Errorf("failed to inject handlers: %v", err)
+	}
+
+	shutdown := &Shutdown{
+		// stop the background services and end the open departure streams so the server can shut down gracefully
+		Stop: func() {
+			cancel()
+			handCfg.DepartureStreamService.Close()
+		},
+		// write the places found by the last searches
+		Drain: handCfg.PlaceWriter.Close,
 	}
 
-	return router, nil
+	return router, shutdown, nil
 }
@@ -16,6 +16,8 @@ type ServicesConfig struct {
 	SavedPlaceRepo       interfaces.SavedPlaceRepositoryInterface
 	LastVisitedPlaceRepo interfaces.LastVisitedPlaceRepositoryInterface
 	AboutRepo            interfaces.AboutRepositoryInterface
+	GTFSRepo             interfaces.GTFSRepositoryInterface
+	MigrationRepo        interfaces.MigrationRepositoryInterface
 }
 
 // injectRepositories initializes the dependencies and creates them as a config for services injection
@@ -28,5 +30,7 @@ func injectRepositories(db *mongo.Database) *ServicesConfig {
 		SavedPlaceRepo:       repository.NewSavedPlaceRepository(db),
 		LastVisitedPlaceRepo: repository.NewLastVisitedPlaceRepository(db),
 		AboutRepo:            repository.NewAboutRepository(db),
+		GTFSRepo:             repository.NewGTFSRepository(db),
+		MigrationRepo:        repository.NewMigrationRepository(db),
 	}
 }
@@ -1,6 +1,12 @@
 package injection
 
 import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
 	"github.com/leonardchinonso/lokate-go/models/interfaces"
 	"github.com/leonardchinonso/lokate-go/service"
 )
@@ -14,11 +20,20 @@ type HandlerConfig struct {
 	PlaceService            interfaces.PlaceServiceInterface
 	SavedPlaceService       interfaces.SavedPlaceServiceInterface
 	LastVisitedPlaceService interfaces.LastVisitedPlaceServiceInterface
-	TAPIService             interfaces.TAPIServiceInterface
+	TransitService          interfaces.TransitServiceInterface
+	JourneyService          interfaces.JourneyServiceInterface
+	DepartureService        interfaces.DepartureServiceInterface
+	DashboardService        interfaces.DashboardServiceInterface
+	DepartureStreamService  interfaces.DepartureStreamServiceInterface
+	PostcodeService         interfaces.PostcodeServiceInterface
+	NaptanService           interfaces.NaptanServiceInterface
+	AutocompleteService     interfaces.AutocompleteServiceInterface
+	PlaceWriter             interfaces.PlaceWriterInterface
 }
 
 // injectServices initializes the dependencies and creates them as a config for handler injection
-func injectServices(cfg *map[string]string, servCfg *ServicesConfig) (*HandlerConfig, error) {
+// the background services run until ctx is cancelled
+func injectServices(ctx context.Context, cfg *map[string]string, servCfg *ServicesConfig) (*HandlerConfig, error) {
 	// initialize the user service with the needed config
 	userService := service.NewUserService(servCfg.UserRepo, servCfg.TokenRepo)
 
@@ -34,17 +49,99 @@ func injectServices(cfg *map[string]string, servCfg *ServicesConfig) (*HandlerCo
 	// initialize the external requests service with the needed config
 	reqService := service.NewRequestService()
 
-	// initialize the place service with the needed config
-	placeService := service.NewPlaceService(servCfg.PlaceRepo)
+	// keep the pins of each user within the dashboard limit
+	if err := servCfg.SavedPlaceRepo.EnsureIndexes(ctx); err != nil {
+		return nil, err
+	}
+
+	// initialize the writer of the places found by the searches
+	placeWriter := service.NewPlaceWriter(servCfg.PlaceRepo)
 
 	// initialize the place service with the needed config
-	savedPlaceService := service.NewSavedPlaceService(servCfg.PlaceRepo, servCfg.SavedPlaceRepo)
+	savedPlaceService := service.NewSavedPlaceService(servCfg.PlaceRepo, servCfg.SavedPlaceRepo, placeWriter)
 
 	// initialize the last visited place service with the needed config
-	lastVisitedPlaceService := service.NewLastVisitedPlaceService(servCfg.LastVisitedPlaceRepo, servCfg.PlaceRepo)
+	lastVisitedPlaceService := service.NewLastVisitedPlaceService(servCfg.LastVisitedPlaceRepo, servCfg.PlaceRepo, placeWriter)
+
+	// initialize the GTFS provider and import the configured feeds
+	gtfsService, err := injectGTFSService(cfg, servCfg)
+	if err != nil {
+		return nil, err
+	}
+
+	// initialize the transit service with the configured providers
+	transitService, err := service.NewTransitService(cfg, gtfsService)
+	if err != nil {
+		return nil, err
+	}
+
+	// annotate the transit results with the live feeds when any are configured
+	if len(service.GTFSRealtimeSources(cfg)) > 0 {
+		realtimeService, err := service.NewRealtimeService(cfg)
+		if err != nil {
+			return nil, err
+		}
+		realtimeService.Start(ctx)
+		transitService = service.NewRealtimeTransitService(transitService, realtimeService)
+	}
+
+	// initialize the bike share ingester and import the stations when any systems are configured
+	bikeShareService, err := service.NewBikeShareService(cfg, servCfg.PlaceRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	// initialize the place service with the needed config
+	placeService, err := service.NewPlaceService(cfg, servCfg.PlaceRepo, servCfg.SavedPlaceRepo, servCfg.LastVisitedPlaceRepo, transitService, bikeShareService, placeWriter)
+	if err != nil {
+		return nil, err
+	}
+
+	// make the stored places searchable by location and findable by their key, before anything
+	// in the background stores places
+	if err := preparePlaces(ctx, servCfg.PlaceRepo, placeService, servCfg.MigrationRepo); err != nil {
+		return nil, err
+	}
+	if len(service.GBFSSources(cfg)) > 0 {
+		bikeShareService.Start(ctx)
+	}
+
+	// initialize the departure service with the needed config
+	departureService, err := service.NewDepartureService(cfg, transitService)
+	if err != nil {
+		return nil, err
+	}
+
+	// initialize the departure stream service with the needed config
+	departureStreamService, err := service.NewDepartureStreamService(cfg, departureService)
+	if err != nil {
+		return nil, err
+	}
 
-	// initialize the TAPI service with the config
-	tapiService := service.NewTAPIService(cfg)
+	// initialize the dashboard service with the needed config
+	dashboardService, err := service.NewDashboardService(cfg, servCfg.PlaceRepo, servCfg.SavedPlaceRepo, departureService)
+	if err != nil {
+		return nil, err
+	}
+
+	// initialize the postcode service and load the postcode directory
+	postcodeService, err := service.NewPostcodeService(cfg, transitService)
+	if err != nil {
+		return nil, err
+	}
+
+	// initialize the journey service with the needed config
+	journeyService := service.NewJourneyService(servCfg.PlaceRepo, servCfg.SavedPlaceRepo, transitService, postcodeService)
+
+	// initialize the NaPTAN import service with the needed config
+	naptanService := service.NewNaptanService(cfg, servCfg.PlaceRepo)
+
+	// initialize the autocomplete service and build its index in the background
+	autocompleteService, err := service.NewAutocompleteService(cfg, servCfg.PlaceRepo, servCfg.SavedPlaceRepo, servCfg.LastVisitedPlaceRepo)
+	if err != nil {
+		return nil, err
+	}
+	autocompleteService.Start(ctx)
 
 	return &HandlerConfig{
 		UserService:             userService,
@@ -54,6 +151,114 @@ func injectServices(cfg *map[string]string, servCfg *ServicesConfig) (*HandlerCo
 		PlaceService:            placeService,
 		SavedPlaceService:       savedPlaceService,
 		LastVisitedPlaceService: lastVisitedPlaceService,
-		TAPIService:             tapiService,
+		TransitService:          transitService,
+		JourneyService:          journeyService,
+		DepartureService:        departureService,
+		DashboardService:        dashboardService,
+		DepartureStreamService:  departureStreamService,
+		PostcodeService:         postcodeService,
+		NaptanService:           naptanService,
+		AutocompleteService:     autocompleteService,
+		PlaceWriter:             placeWriter,
 	}, nil
 }
+
+// injectGTFSService creates the GTFS provider, imports the configured feeds that changed
+// since the last start and loads the imported feeds into the router
+func injectGTFSService(cfg *map[string]string, servCfg *ServicesConfig) (interfaces.GTFSServiceInterface, error) {
+	gtfsService, err := service.NewGTFSService(cfg, servCfg.GTFSRepo)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := service.GTFSFeedPaths(cfg)
+	if len(paths) == 0 {
+		return gtfsService, nil
+	}
+
+	ctx := context.Background()
+	if err := servCfg.GTFSRepo.EnsureIndexes(ctx); err != nil {
+		return nil, err
+	}
+
+	for feedId, path := range paths {
+		// a feed that fails to import keeps the version imported before
+		if err := gtfsService.ImportFeed(ctx, feedId, path); err != nil {
+			log.Printf("Failed to import GTFS feed %s. Error: %v\n", feedId, err)
+		}
+	}
+
+	if err := gtfsService.LoadFeeds(ctx); err != nil {
+		return nil, err
+	}
+
+	return gtfsService, nil
+}
+
+// preparePlacesTimeout bounds the index creation of the places
+const preparePlacesTimeout = 2 * time.Minute
+
+// placeMigrationsCollection is the collection the version of the place migrations is kept for
+const placeMigrationsCollection = "places"
+
+// placeMigration is a one-off migration of the stored places, it returns the number of places it updated
+type placeMigration struct {
+	done string
+	run  func(ctx context.Context, placeRepo interfaces.PlaceRepositoryInterface, placeService interfaces.PlaceServiceInterface) (int64, error)
+}
+
+// placeMigrations are run in order and once each, a change to the key or to the search fields of
+// the places adds a migration at the end that runs Rekey or Reindex again
+var placeMigrations = []placeMigration{
+	{
+		done: "Backfilled the location of %d places",
+		run: func(ctx context.Context, placeRepo interfaces.PlaceRepositoryInterface, placeService interfaces.PlaceServiceInterface) (int64, error) {
+			return placeRepo.BackfillLocations(ctx)
+		},
+	},
+	{
+		done: "Updated the key of %d places",
+		run: func(ctx context.Context, placeRepo interfaces.PlaceRepositoryInterface, placeService interfaces.PlaceServiceInterface) (int64, error) {
+			return placeRepo.Rekey(ctx, dao.PlaceKey)
+		},
+	},
+	{
+		done: "Updated the search fields of %d places",
+		run: func(ctx context.Context, placeRepo interfaces.PlaceRepositoryInterface, placeService interfaces.PlaceServiceInterface) (int64, error) {
+			return placeRepo.Reindex(ctx)
+		},
+	},
+	{
+		done: "Merged %d places with the same key before the keys are made unique",
+		run: func(ctx context.Context, placeRepo interfaces.PlaceRepositoryInterface, placeService interfaces.PlaceServiceInterface) (int64, error) {
+			return placeService.MergeSameKeyPlaces(ctx)
+		},
+	},
+}
+
+// preparePlaces runs the place migrations that were not run yet, then creates the indexes the
+// nearby search, the key lookups and the local search need. A migration goes over every place,
+// so it is not bounded by a timeout and the version is recorded after each one
+func preparePlaces(ctx context.Context, placeRepo interfaces.PlaceRepositoryInterface, placeService interfaces.PlaceServiceInterface, migrationRepo interfaces.MigrationRepositoryInterface) error {
+	version, err := migrationRepo.Version(ctx, placeMigrationsCollection)
+	if err != nil {
+		return err
+	}
+
+	for ; version < len(placeMigrations); version++ {
+		m := placeMigrations[version]
+		updated, err := m.run(ctx, placeRepo, placeService)
+		if err != nil {
+			return fmt.Errorf("failed to run place migration %d: %v", version+1, err)
+		}
+		log.Printf(m.done+"\n", updated)
+
+		if err := migrationRepo.SetVersion(ctx, placeMigrationsCollection, version+1); err != nil {
+			return err
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, preparePlacesTimeout)
+	defer cancel()
+	return placeRepo.EnsureIndexes(ctx)
+}
@@ -24,7 +24,7 @@ func main() {
 	// release resource when the main function is returned
 	defer dataSource.Close()
 
-	router, err := injection.Inject(dataSource)
+	router, shutdown, err := injection.Inject(dataSource)
 	if err != nil {
 		log.Fatalf("Failed to inject data sources: %v", err)
 	}
@@ -34,6 +34,9 @@ func main() {
 		Handler: router,
 	}
 
+	// long-lived requests such as the departure streams never go idle, so they are ended when the shutdown starts
+	srv.RegisterOnShutdown(shutdown.Stop)
+
 	// Graceful server shutdown - https://github.com/gin-gonic/examples/blob/master/graceful-shutdown/graceful-shutdown/server.go
 	go func() {
 		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
@@ -61,4 +64,9 @@ func main() {
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v\n", err)
 	}
+
+	// no request is left to queue a write, so the queued ones are finished before the database is closed
+	if err := shutdown.Drain(ctx); err != nil {
+		log.Printf("Failed to finish the background writes: %v\n", err)
+	}
 }
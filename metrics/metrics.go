@@ -0,0 +1,15 @@
+package metrics
+
+import (
+	"expvar"
+	"net/http"
+)
+
+// RejectedPlaces counts the places from upstream that were skipped because they could not be
+// decoded or were not valid, by the name of the provider
+var RejectedPlaces = expvar.NewMap("rejected_places")
+
+// Handler returns the handler that serves all the metrics as JSON
+func Handler() http.Handler {
+	return expvar.Handler()
+}
@@ -0,0 +1,34 @@
+package middlewares
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
+
+// AuthorizeAdmin lets the request through when the logged-in user is an admin
+// the admins are the comma separated emails, and it runs after AuthorizeUser
+func AuthorizeAdmin(adminEmails string) gin.HandlerFunc {
+	admins := make(map[string]bool)
+	for _, email := range strings.Split(adminEmails, ",") {
+		if email = strings.ToLower(strings.TrimSpace(email)); email != "" {
+			admins[email] = true
+		}
+	}
+
+	return func(c *gin.Context) {
+		u, ok := c.Get("user")
+		user, isUser := u.(*dao.User)
+		if !ok || !isUser || !admins[strings.ToLower(user.Email)] {
+			resErr := errors.ErrForbidden("sorry, only admins are allowed to make this request", nil)
+			c.JSON(resErr.Status, resErr)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
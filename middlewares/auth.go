@@ -44,3 +44,20 @@ func AuthorizeUser(ts interfaces.TokenServiceInterface) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// OptionalAuthorizeUser gets the logged-in user when the request carries a token
+// requests without a token are passed through anonymously
+func OptionalAuthorizeUser(ts interfaces.TokenServiceInterface) gin.HandlerFunc {
+	authorize := AuthorizeUser(ts)
+
+	return func(c *gin.Context) {
+		tokenArr := c.Request.Header["Token"]
+		if len(tokenArr) == 0 || tokenArr[0] == "" {
+			c.Next()
+			return
+		}
+
+		// a token that was sent must still be valid
+		authorize(c)
+	}
+}
@@ -0,0 +1,24 @@
+package api
+
+// DashboardStopResponse is a struct for the API Response of a stop pinned to the dashboard
+// the error is set instead of the stop name when the departures of the stop could not be fetched
+type DashboardStopResponse struct {
+	SavedPlaceId string `json:"saved_place_id"`
+	Name         string `json:"name"`
+	StopCode     string `json:"stop_code"`
+	StopName     string `json:"stop_name"`
+	Error        string `json:"error,omitempty"`
+}
+
+// DashboardDepartureResponse is a struct for the API Response of a departure from a pinned stop
+type DashboardDepartureResponse struct {
+	DepartureResponse
+	SavedPlaceId string `json:"saved_place_id"`
+	StopName     string `json:"stop_name"`
+}
+
+// DashboardResponse is a struct for the API Response of the departures from all the pinned stops
+type DashboardResponse struct {
+	Stops      []DashboardStopResponse      `json:"stops"`
+	Departures []DashboardDepartureResponse `json:"departures"`
+}
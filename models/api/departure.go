@@ -0,0 +1,129 @@
+package api
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
+
+// DepartureResponse is a struct for the API Response of a departure from a stop
+type DepartureResponse struct {
+	Mode          JourneyMode `json:"mode"`
+	Line          string      `json:"line"`
+	Destination   string      `json:"destination"`
+	ScheduledTime time.Time   `json:"scheduled_time"`
+	ExpectedTime  *time.Time  `json:"expected_time"`
+	Platform      string      `json:"platform"`
+	Operator      string      `json:"operator"`
+	Cancelled     bool        `json:"cancelled"`
+	TripId        string      `json:"trip_id,omitempty"`
+	// ServiceDate and StopSequence are the day the trip runs on as YYYYMMDD and the position of the
+	// stop on the trip, when the provider knows them
+	ServiceDate  string `json:"-"`
+	StopSequence *int   `json:"-"`
+}
+
+// DepartureBoardResponse is a struct for the API Response of the departures from a stop
+type DepartureBoardResponse struct {
+	StopCode   string              `json:"stop_code"`
+	StopName   string              `json:"stop_name"`
+	Departures []DepartureResponse `json:"departures"`
+}
+
+// NewBusDepartureBoardResponse converts the upstream bus departures to the api response type
+func NewBusDepartureBoardResponse(d *dao.BusDeparturesResp) (*DepartureBoardResponse, error) {
+	departures := make([]DepartureResponse, 0, len(d.Departures.All))
+	for _, bd := range d.Departures.All {
+		scheduled, err := parseJourneyTime(bd.Date, bd.AimedDepartureTime)
+		if err != nil {
+			return nil, err
+		}
+
+		departure := DepartureResponse{
+			Mode:          ToJourneyMode(bd.Mode),
+			Line:          bd.LineName,
+			Destination:   bd.Direction,
+			ScheduledTime: scheduled,
+			Operator:      bd.OperatorName,
+		}
+		if departure.Line == "" {
+			departure.Line = bd.Line
+		}
+
+		// the expected time is only present for buses that are tracked live
+		if bd.ExpectedDepartureTime != "" {
+			date := bd.ExpectedDepartureDate
+			if date == "" {
+				date = bd.Date
+			}
+			expected, err := parseJourneyTime(date, bd.ExpectedDepartureTime)
+			if err != nil {
+				return nil, err
+			}
+			departure.ExpectedTime = &expected
+		}
+
+		departures = append(departures, departure)
+	}
+
+	return &DepartureBoardResponse{
+		StopCode:   d.ATCOCode,
+		StopName:   d.Name,
+		Departures: departures,
+	}, nil
+}
+
+// NewTrainDepartureBoardResponse converts the upstream train departures to the api response type
+func NewTrainDepartureBoardResponse(d *dao.TrainDeparturesResp) (*DepartureBoardResponse, error) {
+	departures := make([]DepartureResponse, 0, len(d.Departures.All))
+	for _, td := range d.Departures.All {
+		scheduled, err := parseJourneyTime(d.Date, td.AimedDepartureTime)
+		if err != nil {
+			return nil, err
+		}
+
+		departure := DepartureResponse{
+			Mode:          ModeTrain,
+			Line:          td.Service,
+			Destination:   td.DestinationName,
+			ScheduledTime: scheduled,
+			Platform:      td.Platform,
+			Operator:      td.OperatorName,
+			Cancelled:     strings.EqualFold(td.Status, "CANCELLED"),
+			TripId:        td.TrainUid,
+		}
+
+		if td.ExpectedDepartureTime != "" && !departure.Cancelled {
+			expected, err := clockTimeAfter(td.ExpectedDepartureTime, scheduled)
+			if err != nil {
+				return nil, err
+			}
+			departure.ExpectedTime = &expected
+		}
+
+		departures = append(departures, departure)
+	}
+
+	return &DepartureBoardResponse{
+		StopCode:   d.StationCode,
+		StopName:   d.StationName,
+		Departures: departures,
+	}, nil
+}
+
+// SortDepartures orders departures by the time they are expected to leave
+func SortDepartures(departures []DepartureResponse) {
+	sort.SliceStable(departures, func(i, j int) bool {
+		return departures[i].BestTime().Before(departures[j].BestTime())
+	})
+}
+
+// BestTime returns the expected time of a departure when it is known and the scheduled time otherwise
+func (dr *DepartureResponse) BestTime() time.Time {
+	if dr.ExpectedTime != nil {
+		return *dr.ExpectedTime
+	}
+	return dr.ScheduledTime
+}
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// DepartureSnapshotEvent is the name of the event with the whole departure board
+	DepartureSnapshotEvent = "snapshot"
+	// DepartureUpdateEvent is the name of the event with the changes to the departure board
+	DepartureUpdateEvent = "update"
+	// DepartureErrorEvent is the name of the event sent when the departures could not be fetched
+	DepartureErrorEvent = "error"
+)
+
+// DepartureStreamEvent is an event sent to the clients streaming a departure board
+type DepartureStreamEvent struct {
+	Name string
+	Data interface{}
+}
+
+// DepartureDiffResponse is a struct for the API Response of the changes to a departure board
+type DepartureDiffResponse struct {
+	StopCode string              `json:"stop_code"`
+	Added    []DepartureResponse `json:"added"`
+	Changed  []DepartureResponse `json:"changed"`
+	Removed  []DepartureResponse `json:"removed"`
+}
+
+// IsEmpty determines if the departure board did not change
+func (dd *DepartureDiffResponse) IsEmpty() bool {
+	return len(dd.Added) == 0 && len(dd.Changed) == 0 && len(dd.Removed) == 0
+}
+
+// Key identifies a departure across updates of the board, by its trip when it is known, and by
+// its scheduled time since a trip can call at a stop more than once
+func (dr *DepartureResponse) Key() string {
+	if dr.TripId != "" {
+		return fmt.Sprintf("%s|%s", dr.TripId, dr.ScheduledTime.Format(time.RFC3339))
+	}
+	return fmt.Sprintf("%s|%s|%s", dr.Line, dr.Destination, dr.ScheduledTime.Format(time.RFC3339))
+}
+
+// departureKeys returns the keys of the departures of a board, a key repeated on the board, such
+// as two departures without a trip of a line at the same time, is told apart by the number of
+// times it was seen before
+func departureKeys(departures []DepartureResponse) []string {
+	seen := make(map[string]int, len(departures))
+	keys := make([]string, len(departures))
+	for i := range departures {
+		key := departures[i].Key()
+		keys[i] = fmt.Sprintf("%s#%d", key, seen[key])
+		seen[key]++
+	}
+	return keys
+}
+
+// DiffDepartureBoards returns the departures added to, removed from, and whose predictions changed between two boards
+func DiffDepartureBoards(prev, next *DepartureBoardResponse) *DepartureDiffResponse {
+	diff := &DepartureDiffResponse{StopCode: next.StopCode}
+
+	prevKeys := departureKeys(prev.Departures)
+	previous := make(map[string]*DepartureResponse, len(prev.Departures))
+	for i, key := range prevKeys {
+		previous[key] = &prev.Departures[i]
+	}
+
+	for i, key := range departureKeys(next.Departures) {
+		d := &next.Departures[i]
+		p, ok := previous[key]
+		delete(previous, key)
+
+		switch {
+		case !ok:
+			diff.Added = append(diff.Added, *d)
+		case p.Cancelled != d.Cancelled || p.Platform != d.Platform || !sameTime(p.ExpectedTime, d.ExpectedTime):
+			diff.Changed = append(diff.Changed, *d)
+		}
+	}
+
+	for i, key := range prevKeys {
+		if _, ok := previous[key]; ok {
+			diff.Removed = append(diff.Removed, prev.Departures[i])
+		}
+	}
+
+	return diff
+}
+
+// sameTime determines if two optional times are equal
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
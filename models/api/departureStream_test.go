@@ -0,0 +1,122 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffDepartureBoards(t *testing.T) {
+	at := func(minutes int) time.Time {
+		return time.Date(2023, 3, 1, 8, minutes, 0, 0, time.UTC)
+	}
+	expected := func(minutes int) *time.Time {
+		e := at(minutes)
+		return &e
+	}
+	trip := func(id string, minutes int) DepartureResponse {
+		return DepartureResponse{Line: "12", Destination: "Dulwich", ScheduledTime: at(minutes), TripId: id}
+	}
+	// departures without a trip of a line at the same time have the same key
+	noTrip := func(destination string, minutes int) DepartureResponse {
+		return DepartureResponse{Line: "N12", Destination: destination, ScheduledTime: at(minutes)}
+	}
+	with := func(d DepartureResponse, change func(*DepartureResponse)) DepartureResponse {
+		change(&d)
+		return d
+	}
+
+	tests := []struct {
+		name                    string
+		prev, next              []DepartureResponse
+		added, changed, removed []DepartureResponse
+	}{
+		{
+			name: "unchanged",
+			prev: []DepartureResponse{trip("a", 1), noTrip("Dulwich", 2)},
+			next: []DepartureResponse{trip("a", 1), noTrip("Dulwich", 2)},
+		},
+		{
+			name:    "added and removed",
+			prev:    []DepartureResponse{trip("a", 1), trip("b", 5)},
+			next:    []DepartureResponse{trip("b", 5), trip("c", 9)},
+			added:   []DepartureResponse{trip("c", 9)},
+			removed: []DepartureResponse{trip("a", 1)},
+		},
+		{
+			name: "changed predictions",
+			prev: []DepartureResponse{trip("a", 1), trip("b", 5), trip("c", 9), with(trip("d", 12), func(d *DepartureResponse) { d.ExpectedTime = expected(13) })},
+			next: []DepartureResponse{
+				with(trip("a", 1), func(d *DepartureResponse) { d.ExpectedTime = expected(3) }),
+				with(trip("b", 5), func(d *DepartureResponse) { d.Platform = "2" }),
+				with(trip("c", 9), func(d *DepartureResponse) { d.Cancelled = true }),
+				with(trip("d", 12), func(d *DepartureResponse) { d.ExpectedTime = expected(13) }),
+			},
+			changed: []DepartureResponse{
+				with(trip("a", 1), func(d *DepartureResponse) { d.ExpectedTime = expected(3) }),
+				with(trip("b", 5), func(d *DepartureResponse) { d.Platform = "2" }),
+				with(trip("c", 9), func(d *DepartureResponse) { d.Cancelled = true }),
+			},
+		},
+		{
+			name:  "a second departure without a trip at the same time is added",
+			prev:  []DepartureResponse{noTrip("Dulwich", 2)},
+			next:  []DepartureResponse{noTrip("Dulwich", 2), noTrip("Dulwich", 2)},
+			added: []DepartureResponse{noTrip("Dulwich", 2)},
+		},
+		{
+			name:    "one of two departures without a trip at the same time is removed",
+			prev:    []DepartureResponse{noTrip("Dulwich", 2), noTrip("Dulwich", 2)},
+			next:    []DepartureResponse{noTrip("Dulwich", 2)},
+			removed: []DepartureResponse{noTrip("Dulwich", 2)},
+		},
+		{
+			name: "each of two departures without a trip at the same time changes",
+			prev: []DepartureResponse{noTrip("Dulwich", 2), noTrip("Dulwich", 2)},
+			next: []DepartureResponse{
+				with(noTrip("Dulwich", 2), func(d *DepartureResponse) { d.ExpectedTime = expected(3) }),
+				with(noTrip("Dulwich", 2), func(d *DepartureResponse) { d.ExpectedTime = expected(6) }),
+			},
+			changed: []DepartureResponse{
+				with(noTrip("Dulwich", 2), func(d *DepartureResponse) { d.ExpectedTime = expected(3) }),
+				with(noTrip("Dulwich", 2), func(d *DepartureResponse) { d.ExpectedTime = expected(6) }),
+			},
+		},
+		{
+			name:    "departures without a trip to other destinations are told apart",
+			prev:    []DepartureResponse{noTrip("Dulwich", 2), noTrip("Oxford Circus", 2)},
+			next:    []DepartureResponse{noTrip("Oxford Circus", 2)},
+			removed: []DepartureResponse{noTrip("Dulwich", 2)},
+		},
+		{
+			name:    "a trip calling twice at a stop",
+			prev:    []DepartureResponse{trip("loop", 1), trip("loop", 20)},
+			next:    []DepartureResponse{trip("loop", 20), trip("loop", 40)},
+			added:   []DepartureResponse{trip("loop", 40)},
+			removed: []DepartureResponse{trip("loop", 1)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			diff := DiffDepartureBoards(&DepartureBoardResponse{StopCode: "490000077E", Departures: tt.prev},
+				&DepartureBoardResponse{StopCode: "490000077E", Departures: tt.next})
+
+			if diff.StopCode != "490000077E" {
+				t.Errorf("got stop code %q", diff.StopCode)
+			}
+			if !reflect.DeepEqual(diff.Added, tt.added) {
+				t.Errorf("got added %+v, want %+v", diff.Added, tt.added)
+			}
+			if !reflect.DeepEqual(diff.Changed, tt.changed) {
+				t.Errorf("got changed %+v, want %+v", diff.Changed, tt.changed)
+			}
+			if !reflect.DeepEqual(diff.Removed, tt.removed) {
+				t.Errorf("got removed %+v, want %+v", diff.Removed, tt.removed)
+			}
+			if diff.IsEmpty() != (tt.added == nil && tt.changed == nil && tt.removed == nil) {
+				t.Errorf("IsEmpty() = %v for %+v", diff.IsEmpty(), diff)
+			}
+		})
+	}
+}
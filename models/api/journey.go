@@ -0,0 +1,290 @@
+package api
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	// the timezone database is embedded so the journey times do not depend on the host having one
+	_ "time/tzdata"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
+
+// JourneyMode is the travel mode of a part of a route
+type JourneyMode string
+
+const (
+	ModeWalk  JourneyMode = "walk"
+	ModeBus   JourneyMode = "bus"
+	ModeTrain JourneyMode = "train"
+	ModeTube  JourneyMode = "tube"
+	ModeTram  JourneyMode = "tram"
+	ModeBoat  JourneyMode = "boat"
+	ModeOther JourneyMode = "other"
+)
+
+// upstreamModes maps the upstream mode names to the modes exposed by the API
+var upstreamModes = map[string]JourneyMode{
+	"foot":       ModeWalk,
+	"walk":       ModeWalk,
+	"bus":        ModeBus,
+	"coach":      ModeBus,
+	"train":      ModeTrain,
+	"overground": ModeTrain,
+	"tube":       ModeTube,
+	"dlr":        ModeTube,
+	"tram":       ModeTram,
+	"rail":       ModeTrain,
+	"subway":     ModeTube,
+	"boat":       ModeBoat,
+	"ferry":      ModeBoat,
+}
+
+// ToJourneyMode converts an upstream mode name to a JourneyMode
+func ToJourneyMode(mode string) JourneyMode {
+	if m, ok := upstreamModes[strings.ToLower(mode)]; ok {
+		return m
+	}
+	return ModeOther
+}
+
+// journeyLocation is the timezone the upstream journey times are expressed in
+var journeyLocation = func() *time.Location {
+	loc, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		log.Fatalf("failed to load the journey timezone: %v", err)
+	}
+	return loc
+}()
+
+// CoordinateResponse is a struct for the API Response of a point on a route
+type CoordinateResponse struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// StopResponse is a struct for the API Response of a stop on a route
+type StopResponse struct {
+	Name      string   `json:"name"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+	StopId    string   `json:"stop_id,omitempty"`
+	// StopSequence is the position of the stop on the trip of a ride, when the provider knows it
+	StopSequence *int `json:"-"`
+}
+
+// RoutePartResponse is a struct for the API Response of a leg of a route
+type RoutePartResponse struct {
+	Mode            JourneyMode          `json:"mode"`
+	LineName        string               `json:"line_name"`
+	Destination     string               `json:"destination"`
+	From            StopResponse         `json:"from"`
+	To              StopResponse         `json:"to"`
+	DurationMinutes int                  `json:"duration_minutes"`
+	DepartureTime   time.Time            `json:"departure_time"`
+	ArrivalTime     time.Time            `json:"arrival_time"`
+	Coordinates     []CoordinateResponse `json:"coordinates"`
+	TripId          string               `json:"trip_id,omitempty"`
+	// ServiceDate is the day the trip runs on as YYYYMMDD, when the provider knows it
+	ServiceDate string `json:"-"`
+
+	// the realtime fields are set when a live feed has news of the trip
+	ExpectedDepartureTime *time.Time       `json:"expected_departure_time,omitempty"`
+	ExpectedArrivalTime   *time.Time       `json:"expected_arrival_time,omitempty"`
+	Cancelled             bool             `json:"cancelled"`
+	Vehicle               *VehicleResponse `json:"vehicle,omitempty"`
+	Alerts                []AlertResponse  `json:"alerts,omitempty"`
+}
+
+// RouteResponse is a struct for the API Response of a route
+type RouteResponse struct {
+	DurationMinutes int                 `json:"duration_minutes"`
+	DepartureTime   time.Time           `json:"departure_time"`
+	ArrivalTime     time.Time           `json:"arrival_time"`
+	WalkingMinutes  int                 `json:"walking_minutes"`
+	Interchanges    int                 `json:"interchanges"`
+	Modes           []JourneyMode       `json:"modes"`
+	Parts           []RoutePartResponse `json:"parts"`
+
+	// the realtime fields are set when a live feed has news of the trips of the route
+	ExpectedArrivalTime *time.Time `json:"expected_arrival_time,omitempty"`
+	Cancelled           bool       `json:"cancelled"`
+}
+
+// Summarize computes the summary fields of a route from its parts
+func (rr *RouteResponse) Summarize() {
+	rr.WalkingMinutes, rr.Interchanges, rr.Modes = 0, 0, nil
+
+	rides := 0
+	seen := make(map[JourneyMode]bool)
+	for _, part := range rr.Parts {
+		if part.Mode == ModeWalk {
+			rr.WalkingMinutes += part.DurationMinutes
+		} else {
+			rides++
+		}
+
+		if !seen[part.Mode] {
+			seen[part.Mode] = true
+			rr.Modes = append(rr.Modes, part.Mode)
+		}
+	}
+
+	// every ride after the first one is a change
+	if rides > 1 {
+		rr.Interchanges = rides - 1
+	}
+}
+
+// HasMode determines if any part of the route uses the mode
+func (rr *RouteResponse) HasMode(mode JourneyMode) bool {
+	for _, m := range rr.Modes {
+		if m == mode {
+			return true
+		}
+	}
+	return false
+}
+
+// WalkOnly determines if the route is walked all the way
+func (rr *RouteResponse) WalkOnly() bool {
+	return len(rr.Modes) == 1 && rr.Modes[0] == ModeWalk
+}
+
+// JourneyResponse is a struct for the API Response of a journey
+type JourneyResponse struct {
+	RequestTime string          `json:"request_time"`
+	Routes      []RouteResponse `json:"routes"`
+}
+
+// NewJourneyResponse converts the upstream journey to the api response type. A route that cannot
+// be converted is logged and left out, the journey only fails when none of its routes convert
+func NewJourneyResponse(j *dao.PublicJourneyResp) (*JourneyResponse, error) {
+	routes := make([]RouteResponse, 0, len(j.Routes))
+	var lastErr error
+	for i := range j.Routes {
+		route, err := newRouteResponse(&j.Routes[i])
+		if err != nil {
+			lastErr = fmt.Errorf("failed to convert route %d: %v", i, err)
+			log.Printf("Skipping a journey route. Error: %v\n", lastErr)
+			continue
+		}
+		routes = append(routes, *route)
+	}
+	if len(routes) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+
+	return &JourneyResponse{
+		RequestTime: j.RequestTime,
+		Routes:      routes,
+	}, nil
+}
+
+// newRouteResponse converts an upstream route to the api response type
+func newRouteResponse(r *dao.JourneyRoute) (*RouteResponse, error) {
+	duration, err := dao.ParseJourneyDuration(r.Duration)
+	if err != nil {
+		return nil, err
+	}
+
+	departure, err := parseJourneyTime(r.DepartureDate, r.DepartureTime)
+	if err != nil {
+		return nil, err
+	}
+
+	arrival, err := parseJourneyTime(r.ArrivalDate, r.ArrivalTime)
+	if err != nil {
+		return nil, err
+	}
+
+	// the parts only carry a clock time, so they are anchored to the route departure
+	// and rolled over to the next day whenever the clock wraps around midnight
+	parts := make([]RoutePartResponse, 0, len(r.RouteParts))
+	cursor := departure
+	for i := range r.RouteParts {
+		part, err := newRoutePartResponse(&r.RouteParts[i], cursor)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert route part %d: %v", i, err)
+		}
+		parts = append(parts, *part)
+		cursor = part.ArrivalTime
+	}
+
+	route := &RouteResponse{
+		DurationMinutes: int(duration.Minutes()),
+		DepartureTime:   departure,
+		ArrivalTime:     arrival,
+		Parts:           parts,
+	}
+	route.Summarize()
+
+	return route, nil
+}
+
+// newRoutePartResponse converts an upstream route part to the api response type
+func newRoutePartResponse(rp *dao.JourneyRoutePart, after time.Time) (*RoutePartResponse, error) {
+	duration, err := dao.ParseJourneyDuration(rp.Duration)
+	if err != nil {
+		return nil, err
+	}
+
+	departure, err := clockTimeAfter(rp.DepartureTime, after)
+	if err != nil {
+		return nil, err
+	}
+
+	arrival, err := clockTimeAfter(rp.ArrivalTime, departure)
+	if err != nil {
+		return nil, err
+	}
+
+	coordinates := make([]CoordinateResponse, len(rp.Coordinates))
+	for i, c := range rp.Coordinates {
+		// the upstream coordinates are in the [longitude, latitude] order
+		coordinates[i] = CoordinateResponse{Latitude: c[1], Longitude: c[0]}
+	}
+
+	from := StopResponse{Name: rp.FromPointName}
+	to := StopResponse{Name: rp.ToPointName}
+	if n := len(coordinates); n > 0 {
+		from.Latitude, from.Longitude = &coordinates[0].Latitude, &coordinates[0].Longitude
+		to.Latitude, to.Longitude = &coordinates[n-1].Latitude, &coordinates[n-1].Longitude
+	}
+
+	return &RoutePartResponse{
+		Mode:            ToJourneyMode(rp.Mode),
+		LineName:        rp.LineName,
+		Destination:     rp.Destination,
+		From:            from,
+		To:              to,
+		DurationMinutes: int(duration.Minutes()),
+		DepartureTime:   departure,
+		ArrivalTime:     arrival,
+		Coordinates:     coordinates,
+	}, nil
+}
+
+// parseJourneyTime parses an upstream date and clock time pair
+func parseJourneyTime(date, clock string) (time.Time, error) {
+	t, err := time.ParseInLocation("2006-01-02 15:04", fmt.Sprintf("%s %s", date, clock), journeyLocation)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid journey time: %q %q", date, clock)
+	}
+	return t, nil
+}
+
+// clockTimeAfter places the clock time on the day of the given time, or on the next day when
+// the clock has wrapped around midnight. Small backward steps are kept on the same day since
+// the upstream occasionally reports a leg starting a minute before the previous one ends
+func clockTimeAfter(clock string, after time.Time) (time.Time, error) {
+	t, err := parseJourneyTime(after.Format("2006-01-02"), clock)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if t.Before(after.Add(-12 * time.Hour)) {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
@@ -0,0 +1,21 @@
+package api
+
+// NaptanImportResponse is a struct for the API Response of an import of the NaPTAN stops
+// the inserted and updated counts are left at zero by a dry run, which stores nothing
+type NaptanImportResponse struct {
+	DryRun       bool                        `json:"dry_run"`
+	Stops        int                         `json:"stops"`
+	Inserted     int64                       `json:"inserted"`
+	Updated      int64                       `json:"updated"`
+	Inactive     int                         `json:"inactive"`
+	Skipped      int                         `json:"skipped"`
+	Removed      int                         `json:"removed"`
+	RemovedStops []NaptanRemovedStopResponse `json:"removed_stops"`
+}
+
+// NaptanRemovedStopResponse is a struct for the API Response of a stop imported before that is no
+// longer an active stop in the NaPTAN stops
+type NaptanRemovedStopResponse struct {
+	Id       string `json:"id"`
+	ATCOCode string `json:"atcocode"`
+}
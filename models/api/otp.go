@@ -0,0 +1,137 @@
+package api
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
+
+// NewOTPJourneyResponse converts an OpenTripPlanner plan to the api response type
+func NewOTPJourneyResponse(plan *dao.OTPPlan) (*JourneyResponse, error) {
+	routes := make([]RouteResponse, 0, len(plan.Itineraries))
+	for i, itinerary := range plan.Itineraries {
+		parts := make([]RoutePartResponse, 0, len(itinerary.Legs))
+		for j, leg := range itinerary.Legs {
+			coordinates, err := decodePolyline(leg.LegGeometry.Points)
+			if err != nil {
+				return nil, fmt.Errorf("failed to convert leg %d of itinerary %d: %v", j, i, err)
+			}
+
+			from, to := leg.From, leg.To
+			line := leg.RouteShortName
+			if line == "" {
+				line = leg.Route
+			}
+
+			parts = append(parts, RoutePartResponse{
+				Mode:            ToJourneyMode(leg.Mode),
+				LineName:        line,
+				Destination:     leg.Headsign,
+				From:            StopResponse{Name: from.Name, Latitude: &from.Lat, Longitude: &from.Lon, StopId: from.StopId, StopSequence: from.StopSequence},
+				To:              StopResponse{Name: to.Name, Latitude: &to.Lat, Longitude: &to.Lon, StopId: to.StopId, StopSequence: to.StopSequence},
+				DurationMinutes: int(leg.Duration / 60),
+				DepartureTime:   unixMilliToTime(leg.StartTime),
+				ArrivalTime:     unixMilliToTime(leg.EndTime),
+				Coordinates:     coordinates,
+				TripId:          leg.TripId,
+				ServiceDate:     leg.ServiceDate,
+			})
+		}
+
+		route := RouteResponse{
+			DurationMinutes: int(itinerary.Duration / 60),
+			DepartureTime:   unixMilliToTime(itinerary.StartTime),
+			ArrivalTime:     unixMilliToTime(itinerary.EndTime),
+			Parts:           parts,
+		}
+		route.Summarize()
+
+		routes = append(routes, route)
+	}
+
+	return &JourneyResponse{
+		RequestTime: unixMilliToTime(plan.Date).Format(time.RFC3339),
+		Routes:      routes,
+	}, nil
+}
+
+// NewOTPDepartureBoardResponse converts the OpenTripPlanner stop times to the api response type
+func NewOTPDepartureBoardResponse(stop *dao.OTPStop, patterns []dao.OTPPatternStopTimes) *DepartureBoardResponse {
+	var departures []DepartureResponse
+	for _, pattern := range patterns {
+		for _, st := range pattern.Times {
+			departure := DepartureResponse{
+				Mode:          ModeOther,
+				Line:          pattern.Pattern.Desc,
+				Destination:   st.Headsign,
+				ScheduledTime: time.Unix(st.ServiceDay+st.ScheduledDeparture, 0).In(journeyLocation),
+				Cancelled:     st.RealtimeState == "CANCELED",
+				TripId:        st.TripId,
+				// the service day is the midnight the times count from, noon is always on the same date
+				ServiceDate: time.Unix(st.ServiceDay+12*60*60, 0).In(journeyLocation).Format("20060102"),
+			}
+
+			if st.Realtime && !departure.Cancelled {
+				expected := time.Unix(st.ServiceDay+st.RealtimeDeparture, 0).In(journeyLocation)
+				departure.ExpectedTime = &expected
+			}
+
+			departures = append(departures, departure)
+		}
+	}
+
+	SortDepartures(departures)
+
+	return &DepartureBoardResponse{
+		StopCode:   stop.Code,
+		StopName:   stop.Name,
+		Departures: departures,
+	}
+}
+
+// unixMilliToTime converts the milliseconds since the epoch used by OpenTripPlanner to a time
+func unixMilliToTime(ms int64) time.Time {
+	return time.UnixMilli(ms).In(journeyLocation)
+}
+
+// decodePolyline decodes a route geometry in the encoded polyline algorithm format
+func decodePolyline(encoded string) ([]CoordinateResponse, error) {
+	coordinates := make([]CoordinateResponse, 0)
+
+	var lat, lon int64
+	for i := 0; i < len(encoded); {
+		// each point holds the latitude and longitude offsets from the previous point
+		var deltas [2]int64
+		for k := range deltas {
+			var result int64
+			var shift uint
+			for {
+				if i >= len(encoded) {
+					return nil, fmt.Errorf("invalid polyline: unexpected end")
+				}
+				b := int64(encoded[i]) - 63
+				i++
+				if b < 0 || shift > 60 {
+					return nil, fmt.Errorf("invalid polyline: bad character")
+				}
+				result |= (b & 0x1f) << shift
+				shift += 5
+				if b < 0x20 {
+					break
+				}
+			}
+			if result&1 != 0 {
+				deltas[k] = ^(result >> 1)
+			} else {
+				deltas[k] = result >> 1
+			}
+		}
+
+		lat += deltas[0]
+		lon += deltas[1]
+		coordinates = append(coordinates, CoordinateResponse{Latitude: float64(lat) / 1e5, Longitude: float64(lon) / 1e5})
+	}
+
+	return coordinates, nil
+}
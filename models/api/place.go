@@ -1,9 +1,14 @@
 package api
 
-import "github.com/leonardchinonso/lokate-go/models/dao"
+import (
+	"time"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
 
 // PlaceResponse is a struct for the API Response of a place
 type PlaceResponse struct {
+	Id          string   `json:"id,omitempty"`
 	Type        string   `json:"type"`
 	Name        string   `json:"name"`
 	Latitude    *float64 `json:"latitude"`
@@ -16,24 +21,79 @@ type PlaceResponse struct {
 	TiplocCode  string   `json:"tiploc_code"`
 	SMSCode     string   `json:"smscode"`
 	Distance    *int     `json:"distance"`
+	// Source is only set on the places found by a search
+	Source PlaceSearchSource `json:"source,omitempty"`
+	// Ranking is only set on the places found by a search that asked for its ranking to be explained
+	Ranking *PlaceRankingResponse `json:"ranking,omitempty"`
+	// OTPStopId is only set on the stops found by OpenTripPlanner
+	OTPStopId string `json:"otp_stop_id,omitempty"`
+	// GTFSStopId is only set on the stops of the imported GTFS feeds
+	GTFSStopId string `json:"gtfs_stop_id,omitempty"`
+	// BikeStationId and Availability are only set on the bike share stations
+	BikeStationId string                    `json:"bike_station_id,omitempty"`
+	Availability  *BikeAvailabilityResponse `json:"availability,omitempty"`
+}
+
+// PlaceSearchSource is where a place found by a search was found
+type PlaceSearchSource string
+
+const (
+	PlaceSearchSourceLocal    PlaceSearchSource = "local"
+	PlaceSearchSourceUpstream PlaceSearchSource = "upstream"
+	PlaceSearchSourceSaved    PlaceSearchSource = "saved"
+)
+
+// PlaceRankingResponse is a struct for the API Response of why a place found by a search ranked where it did
+// the score is the relevance of the place to the search text with the boosts added
+type PlaceRankingResponse struct {
+	Score     float64                     `json:"score"`
+	Relevance float64                     `json:"relevance"`
+	Match     string                      `json:"match"`
+	Boosts    []PlaceRankingBoostResponse `json:"boosts"`
+}
+
+// PlaceRankingBoostResponse is a struct for the API Response of a boost to the score of a place found by a search
+type PlaceRankingBoostResponse struct {
+	Reason string  `json:"reason"`
+	Score  float64 `json:"score"`
+	Detail string  `json:"detail"`
+}
+
+// BikeAvailabilityResponse is a struct for the API Response of the live availability of a bike share station
+type BikeAvailabilityResponse struct {
+	BikesAvailable int        `json:"bikes_available"`
+	DocksAvailable int        `json:"docks_available"`
+	IsRenting      bool       `json:"is_renting"`
+	IsReturning    bool       `json:"is_returning"`
+	LastReported   *time.Time `json:"last_reported,omitempty"`
 }
 
 // NewPlaceResponse returns a new place response
 func NewPlaceResponse(p *dao.Place) *PlaceResponse {
-	return &PlaceResponse{
-		Type:        p.Type,
-		Name:        p.Name,
-		Latitude:    p.Latitude,
-		Longitude:   p.Longitude,
-		Accuracy:    p.Accuracy,
-		Description: p.Description,
-		OSMId:       p.OSMId,
-		ATCOCode:    p.ATCOCode,
-		StationCode: p.StationCode,
-		TiplocCode:  p.TiplocCode,
-		SMSCode:     p.SMSCode,
-		Distance:    p.Distance,
+	resp := &PlaceResponse{
+		Type:          p.Type,
+		Name:          p.Name,
+		Latitude:      p.Latitude,
+		Longitude:     p.Longitude,
+		Accuracy:      p.Accuracy,
+		Description:   p.Description,
+		OSMId:         p.OSMId,
+		ATCOCode:      p.ATCOCode,
+		StationCode:   p.StationCode,
+		TiplocCode:    p.TiplocCode,
+		SMSCode:       p.SMSCode,
+		Distance:      p.Distance,
+		OTPStopId:     p.OTPStopId,
+		GTFSStopId:    p.GTFSStopId,
+		BikeStationId: p.BikeStationId,
+	}
+
+	// places found upstream are not stored, so they have no id
+	if !p.Id.IsZero() {
+		resp.Id = p.Id.Hex()
 	}
+
+	return resp
 }
 
 // ToPlacesResponses converts a data access place to the api response type
@@ -47,3 +107,83 @@ func ToPlacesResponses(places *[]dao.Place) []PlaceResponse {
 
 	return placeResponses
 }
+
+// NearbyPlacesResponse is a struct for the API Response of a page of the places around a point
+// the places are ordered by their distance in metres from the point, NextPage is left out on the last page
+type NearbyPlacesResponse struct {
+	Places   []PlaceResponse `json:"places"`
+	Page     int             `json:"page"`
+	PerPage  int             `json:"per_page"`
+	NextPage int             `json:"next_page,omitempty"`
+}
+
+// NewNearbyPlacesResponse returns a new page of nearby places
+// places past the page size mean there is a next page and are left out
+func NewNearbyPlacesResponse(places []dao.Place, page, perPage int) *NearbyPlacesResponse {
+	resp := &NearbyPlacesResponse{
+		Places:  make([]PlaceResponse, 0, len(places)),
+		Page:    page,
+		PerPage: perPage,
+	}
+	if len(places) > perPage {
+		places = places[:perPage]
+		resp.NextPage = page + 1
+	}
+	for i := range places {
+		resp.Places = append(resp.Places, *NewPlaceResponse(&places[i]))
+	}
+	return resp
+}
+
+// DuplicatePlacesResponse is a struct for the API Response of the groups of stored places that look like the same place
+type DuplicatePlacesResponse struct {
+	Radius int                           `json:"radius"`
+	Groups []DuplicatePlaceGroupResponse `json:"groups"`
+}
+
+// DuplicatePlaceGroupResponse is a struct for the API Response of places that look like the same place
+type DuplicatePlaceGroupResponse struct {
+	Places []PlaceResponse `json:"places"`
+}
+
+// MergePlacesResponse is a struct for the API Response of merging duplicate places into a place
+type MergePlacesResponse struct {
+	PlaceId           string `json:"place_id"`
+	MergedPlaces      int64  `json:"merged_places"`
+	SavedPlaces       int64  `json:"saved_places"`
+	LastVisitedPlaces int64  `json:"last_visited_places"`
+}
+
+// PlaceImportStatus is what an import did or would do with a row
+type PlaceImportStatus string
+
+const (
+	// PlaceImportCreated is a row whose place was stored
+	PlaceImportCreated PlaceImportStatus = "created"
+	// PlaceImportValid is a row whose place would be stored, when the import is a dry run
+	PlaceImportValid PlaceImportStatus = "valid"
+	// PlaceImportExists is a row whose place is stored already or is a repeat of an earlier row
+	PlaceImportExists PlaceImportStatus = "exists"
+	// PlaceImportInvalid is a row that is not a valid place
+	PlaceImportInvalid PlaceImportStatus = "invalid"
+)
+
+// PlaceImportRowResponse is a struct for the API Response of a row of a place import
+type PlaceImportRowResponse struct {
+	Row    int               `json:"row"`
+	Status PlaceImportStatus `json:"status"`
+	Id     string            `json:"id,omitempty"`
+	Key    string            `json:"key,omitempty"`
+	Errors []string          `json:"errors,omitempty"`
+}
+
+// PlaceImportResponse is a struct for the API Response of a place import
+// a dry run validates the rows without storing any place
+type PlaceImportResponse struct {
+	DryRun  bool                     `json:"dry_run"`
+	Created int                      `json:"created"`
+	Valid   int                      `json:"valid"`
+	Exists  int                      `json:"exists"`
+	Invalid int                      `json:"invalid"`
+	Rows    []PlaceImportRowResponse `json:"rows"`
+}
@@ -0,0 +1,50 @@
+package api
+
+import "github.com/leonardchinonso/lokate-go/models/dao"
+
+// PostcodeResponse is a struct for the API Response of the coordinate of a postcode
+type PostcodeResponse struct {
+	Country   string  `json:"country"`
+	Postcode  string  `json:"postcode"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// NewPostcodeResponse returns a new postcode response for a postcode in its canonical format
+func NewPostcodeResponse(country, postcode string, loc dao.PostcodeLocation) *PostcodeResponse {
+	return &PostcodeResponse{
+		Country:   country,
+		Postcode:  postcode,
+		Latitude:  loc.Latitude,
+		Longitude: loc.Longitude,
+	}
+}
+
+// PostcodeSource is where the coordinate of a postcode was found
+type PostcodeSource string
+
+const (
+	PostcodeSourceLocal    PostcodeSource = "local"
+	PostcodeSourceUpstream PostcodeSource = "upstream"
+)
+
+// PostcodeBatchRowResponse is a struct for the API Response of a postcode in a batch
+// the error is set instead of the coordinate when the postcode is invalid or could not be found
+type PostcodeBatchRowResponse struct {
+	Row       int            `json:"row"`
+	Input     string         `json:"input"`
+	Postcode  string         `json:"postcode,omitempty"`
+	Latitude  *float64       `json:"latitude,omitempty"`
+	Longitude *float64       `json:"longitude,omitempty"`
+	Source    PostcodeSource `json:"source,omitempty"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// PostcodeBatchResponse is a struct for the API Response of geocoding a batch of postcodes
+// the rows are in the order of the request, numbered by their line in a csv file or their position in a JSON array
+type PostcodeBatchResponse struct {
+	Country string                     `json:"country"`
+	Found   int                        `json:"found"`
+	Failed  int                        `json:"failed"`
+	Rows    []PostcodeBatchRowResponse `json:"rows"`
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"time"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
+
+// VehicleResponse is a struct for the API Response of the live position of a vehicle
+type VehicleResponse struct {
+	VehicleId string    `json:"vehicle_id"`
+	Label     string    `json:"label"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Bearing   *float64  `json:"bearing"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AlertResponse is a struct for the API Response of a service alert
+type AlertResponse struct {
+	Id          string `json:"id"`
+	Header      string `json:"header"`
+	Description string `json:"description"`
+}
+
+// NewVehicleResponse converts a realtime vehicle position to the api response type
+func NewVehicleResponse(vp *dao.GTFSRTVehiclePosition) *VehicleResponse {
+	return &VehicleResponse{
+		VehicleId: vp.VehicleId,
+		Label:     vp.Label,
+		Latitude:  vp.Latitude,
+		Longitude: vp.Longitude,
+		Bearing:   vp.Bearing,
+		UpdatedAt: vp.Timestamp,
+	}
+}
+
+// NewAlertResponse converts a realtime service alert to the api response type
+func NewAlertResponse(a *dao.GTFSRTAlert) AlertResponse {
+	return AlertResponse{
+		Id:          a.Id,
+		Header:      a.Header,
+		Description: a.Description,
+	}
+}
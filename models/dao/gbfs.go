@@ -0,0 +1,22 @@
+package dao
+
+import "time"
+
+// GBFSSystem is a bike share system and the locations of its GBFS feeds by the feed name
+// a location is either a url or a local file
+type GBFSSystem struct {
+	SystemId string
+	Feeds    map[string]string
+}
+
+// GBFSStationStatus is the availability of a bike share station. The station id is namespaced
+// by its system like the bike station id of a place
+type GBFSStationStatus struct {
+	StationId      string
+	BikesAvailable int
+	DocksAvailable int
+	IsInstalled    bool
+	IsRenting      bool
+	IsReturning    bool
+	LastReported   *time.Time
+}
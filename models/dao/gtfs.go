@@ -0,0 +1,109 @@
+package dao
+
+import "time"
+
+// GTFSFeed holds the contents of a GTFS static feed
+type GTFSFeed struct {
+	Info          GTFSFeedInfo
+	Stops         []GTFSStop
+	Routes        []GTFSRoute
+	Trips         []GTFSTrip
+	StopTimes     []GTFSStopTime
+	Calendars     []GTFSCalendar
+	CalendarDates []GTFSCalendarDate
+	Transfers     []GTFSTransfer
+	// RejectedStops is the number of stops left out of an import because they had no id or no valid coordinate
+	RejectedStops int
+}
+
+// GTFSFeedInfo records an imported GTFS feed. The data of each import is stored under its own
+// data id, so an import is only swapped in by the feed record once all its data is stored
+type GTFSFeedInfo struct {
+	FeedId     string    `json:"feed_id" bson:"_id"`
+	DataId     string    `json:"data_id" bson:"data_id,omitempty"`
+	Path       string    `json:"path" bson:"path"`
+	Checksum   string    `json:"checksum" bson:"checksum"`
+	Timezone   string    `json:"timezone" bson:"timezone"`
+	ImportedAt time.Time `json:"imported_at" bson:"imported_at"`
+}
+
+// DataFeedId returns the feed id the data of the feed is stored under, the feeds imported before
+// the data ids were added store their data under their own id
+func (i *GTFSFeedInfo) DataFeedId() string {
+	if i.DataId == "" {
+		return i.FeedId
+	}
+	return i.DataId
+}
+
+// GTFSStop represents a row of the GTFS stops.txt file
+type GTFSStop struct {
+	FeedId    string  `json:"feed_id" bson:"feed_id"`
+	StopId    string  `json:"stop_id" bson:"stop_id"`
+	StopCode  string  `json:"stop_code" bson:"stop_code"`
+	Name      string  `json:"name" bson:"name"`
+	Latitude  float64 `json:"latitude" bson:"latitude"`
+	Longitude float64 `json:"longitude" bson:"longitude"`
+}
+
+// GTFSRoute represents a row of the GTFS routes.txt file
+type GTFSRoute struct {
+	FeedId    string `json:"feed_id" bson:"feed_id"`
+	RouteId   string `json:"route_id" bson:"route_id"`
+	ShortName string `json:"short_name" bson:"short_name"`
+	LongName  string `json:"long_name" bson:"long_name"`
+	Type      int    `json:"type" bson:"type"`
+}
+
+// GTFSTrip represents a row of the GTFS trips.txt file
+type GTFSTrip struct {
+	FeedId    string `json:"feed_id" bson:"feed_id"`
+	TripId    string `json:"trip_id" bson:"trip_id"`
+	RouteId   string `json:"route_id" bson:"route_id"`
+	ServiceId string `json:"service_id" bson:"service_id"`
+	Headsign  string `json:"headsign" bson:"headsign"`
+}
+
+// GTFSStopTime represents a row of the GTFS stop_times.txt file
+// the times are in seconds after midnight of the service day, so they can go past 24:00:00
+type GTFSStopTime struct {
+	FeedId        string `json:"feed_id" bson:"feed_id"`
+	TripId        string `json:"trip_id" bson:"trip_id"`
+	StopId        string `json:"stop_id" bson:"stop_id"`
+	StopSequence  int    `json:"stop_sequence" bson:"stop_sequence"`
+	ArrivalTime   int    `json:"arrival_time" bson:"arrival_time"`
+	DepartureTime int    `json:"departure_time" bson:"departure_time"`
+}
+
+// GTFSCalendar represents a row of the GTFS calendar.txt file
+// the days are indexed by time.Weekday, starting on Sunday
+type GTFSCalendar struct {
+	FeedId    string  `json:"feed_id" bson:"feed_id"`
+	ServiceId string  `json:"service_id" bson:"service_id"`
+	Days      [7]bool `json:"days" bson:"days"`
+	StartDate string  `json:"start_date" bson:"start_date"`
+	EndDate   string  `json:"end_date" bson:"end_date"`
+}
+
+// GTFSCalendarDate represents a row of the GTFS calendar_dates.txt file
+type GTFSCalendarDate struct {
+	FeedId        string `json:"feed_id" bson:"feed_id"`
+	ServiceId     string `json:"service_id" bson:"service_id"`
+	Date          string `json:"date" bson:"date"`
+	ExceptionType int    `json:"exception_type" bson:"exception_type"`
+}
+
+// GTFSTransfer represents a row of the GTFS transfers.txt file
+type GTFSTransfer struct {
+	FeedId          string `json:"feed_id" bson:"feed_id"`
+	FromStopId      string `json:"from_stop_id" bson:"from_stop_id"`
+	ToStopId        string `json:"to_stop_id" bson:"to_stop_id"`
+	MinTransferTime int    `json:"min_transfer_time" bson:"min_transfer_time"`
+}
+
+const (
+	// GTFSServiceAdded is the calendar_dates exception type for a service added on a date
+	GTFSServiceAdded = 1
+	// GTFSServiceRemoved is the calendar_dates exception type for a service removed on a date
+	GTFSServiceRemoved = 2
+)
@@ -0,0 +1,69 @@
+package dao
+
+import "time"
+
+// GTFSRTFeed holds the entities of a decoded GTFS-Realtime feed message
+type GTFSRTFeed struct {
+	Timestamp        time.Time
+	TripUpdates      []GTFSRTTripUpdate
+	VehiclePositions []GTFSRTVehiclePosition
+	Alerts           []GTFSRTAlert
+}
+
+// GTFSRTTripUpdate is the realtime progress of a trip
+type GTFSRTTripUpdate struct {
+	TripId          string
+	RouteId         string
+	StartDate       string
+	Cancelled       bool
+	Delay           *int
+	StopTimeUpdates []GTFSRTStopTimeUpdate
+	Timestamp       time.Time
+}
+
+// GTFSRTStopTimeUpdate is the realtime arrival and departure at a stop of a trip
+// the delays are in seconds and the times are only set when the feed gives an absolute time,
+// the stop sequence is only set when the feed gives it
+type GTFSRTStopTimeUpdate struct {
+	StopSequence   *int
+	StopId         string
+	Skipped        bool
+	ArrivalDelay   *int
+	ArrivalTime    *time.Time
+	DepartureDelay *int
+	DepartureTime  *time.Time
+}
+
+// GTFSRTVehiclePosition is the last known position of the vehicle running a trip
+type GTFSRTVehiclePosition struct {
+	TripId    string
+	RouteId   string
+	VehicleId string
+	Label     string
+	Latitude  float64
+	Longitude float64
+	Bearing   *float64
+	StopId    string
+	Timestamp time.Time
+}
+
+// GTFSRTAlert is a service alert with the trips, routes and stops it affects
+type GTFSRTAlert struct {
+	Id          string
+	Header      string
+	Description string
+	Cause       int
+	Effect      int
+	TripIds     []string
+	RouteIds    []string
+	StopIds     []string
+	Start       *time.Time
+	End         *time.Time
+}
+
+const (
+	// GTFSRTTripCancelled is the trip schedule relationship of a cancelled trip
+	GTFSRTTripCancelled = 3
+	// GTFSRTStopSkipped is the stop time schedule relationship of a stop the trip does not call at
+	GTFSRTStopSkipped = 1
+)
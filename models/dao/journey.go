@@ -0,0 +1,50 @@
+package dao
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// JourneyRoute represents a single route in the TAPI public journey response
+type JourneyRoute struct {
+	Duration      string             `json:"duration"`
+	RouteParts    []JourneyRoutePart `json:"route_parts"`
+	DepartureTime string             `json:"departure_time"`
+	DepartureDate string             `json:"departure_date"`
+	ArrivalTime   string             `json:"arrival_time"`
+	ArrivalDate   string             `json:"arrival_date"`
+}
+
+// JourneyRoutePart represents a leg of a route in the TAPI public journey response
+type JourneyRoutePart struct {
+	Mode          string       `json:"mode"`
+	FromPointName string       `json:"from_point_name"`
+	ToPointName   string       `json:"to_point_name"`
+	Destination   string       `json:"destination"`
+	LineName      string       `json:"line_name"`
+	Duration      string       `json:"duration"`
+	DepartureTime string       `json:"departure_time"`
+	ArrivalTime   string       `json:"arrival_time"`
+	Coordinates   [][2]float64 `json:"coordinates"`
+}
+
+// ParseJourneyDuration converts a TAPI duration in the "HH:MM:SS" format to a time.Duration
+func ParseJourneyDuration(s string) (time.Duration, error) {
+	parts := strings.Split(s, ":")
+	if len(parts) != 3 {
+		return 0, fmt.Errorf("invalid journey duration: %q", s)
+	}
+
+	var values [3]int
+	for i, part := range parts {
+		v, err := strconv.Atoi(part)
+		if err != nil || v < 0 {
+			return 0, fmt.Errorf("invalid journey duration: %q", s)
+		}
+		values[i] = v
+	}
+
+	return time.Duration(values[0])*time.Hour + time.Duration(values[1])*time.Minute + time.Duration(values[2])*time.Second, nil
+}
@@ -0,0 +1,118 @@
+package dao
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// OTPPlanResp represents the OpenTripPlanner response for the plan API
+type OTPPlanResp struct {
+	Plan  *OTPPlan  `json:"plan"`
+	Error *OTPError `json:"error"`
+}
+
+// OTPError represents the error reported by OpenTripPlanner when no plan could be made
+type OTPError struct {
+	Id  int    `json:"id"`
+	Msg string `json:"msg"`
+}
+
+// OTPPlan represents the plan in the OpenTripPlanner plan response
+type OTPPlan struct {
+	Date        int64          `json:"date"`
+	Itineraries []OTPItinerary `json:"itineraries"`
+}
+
+// OTPItinerary represents a single itinerary in the OpenTripPlanner plan response
+type OTPItinerary struct {
+	Duration  int64    `json:"duration"`
+	StartTime int64    `json:"startTime"`
+	EndTime   int64    `json:"endTime"`
+	WalkTime  int64    `json:"walkTime"`
+	Transfers int      `json:"transfers"`
+	Legs      []OTPLeg `json:"legs"`
+}
+
+// OTPLeg represents a leg of an itinerary in the OpenTripPlanner plan response
+type OTPLeg struct {
+	Mode           string      `json:"mode"`
+	Route          string      `json:"route"`
+	RouteShortName string      `json:"routeShortName"`
+	Headsign       string      `json:"headsign"`
+	StartTime      int64       `json:"startTime"`
+	EndTime        int64       `json:"endTime"`
+	Duration       float64     `json:"duration"`
+	From           OTPPlace    `json:"from"`
+	To             OTPPlace    `json:"to"`
+	LegGeometry    OTPGeometry `json:"legGeometry"`
+	TripId         string      `json:"tripId"`
+	ServiceDate    string      `json:"serviceDate"`
+}
+
+// OTPPlace represents the start or end of a leg in the OpenTripPlanner plan response
+type OTPPlace struct {
+	Name   string  `json:"name"`
+	Lat    float64 `json:"lat"`
+	Lon    float64 `json:"lon"`
+	StopId string  `json:"stopId"`
+	// StopSequence is only set on the stops of the transit legs
+	StopSequence *int `json:"stopSequence"`
+}
+
+// OTPGeometry represents the encoded polyline of a leg in the OpenTripPlanner plan response
+type OTPGeometry struct {
+	Points string `json:"points"`
+}
+
+// OTPGeocodeResult represents a result of the OpenTripPlanner geocode API
+// the modes of a stop are only returned by the versions that know them
+type OTPGeocodeResult struct {
+	Lat         *float64 `json:"lat"`
+	Lng         *float64 `json:"lng"`
+	Description string   `json:"description"`
+	Id          string   `json:"id"`
+	Modes       []string `json:"modes"`
+}
+
+// Validate makes sure a geocode result has an id, a name and a coordinate in range
+func (r *OTPGeocodeResult) Validate() error {
+	switch {
+	case r.Id == "":
+		return fmt.Errorf("missing id")
+	case strings.TrimSpace(r.Description) == "":
+		return fmt.Errorf("missing description")
+	case r.Lat == nil || math.IsNaN(*r.Lat) || *r.Lat < -90 || *r.Lat > 90:
+		return fmt.Errorf("missing or invalid latitude")
+	case r.Lng == nil || math.IsNaN(*r.Lng) || *r.Lng < -180 || *r.Lng > 180:
+		return fmt.Errorf("missing or invalid longitude")
+	}
+	return nil
+}
+
+// OTPStop represents the OpenTripPlanner response for the stop index API
+type OTPStop struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	Code string `json:"code"`
+}
+
+// OTPPatternStopTimes represents the stop times of a pattern in the OpenTripPlanner stop times API
+type OTPPatternStopTimes struct {
+	Pattern struct {
+		Id   string `json:"id"`
+		Desc string `json:"desc"`
+	} `json:"pattern"`
+	Times []OTPStopTime `json:"times"`
+}
+
+// OTPStopTime represents a departure in the OpenTripPlanner stop times API
+type OTPStopTime struct {
+	ScheduledDeparture int64  `json:"scheduledDeparture"`
+	RealtimeDeparture  int64  `json:"realtimeDeparture"`
+	ServiceDay         int64  `json:"serviceDay"`
+	Realtime           bool   `json:"realtime"`
+	RealtimeState      string `json:"realtimeState"`
+	Headsign           string `json:"headsign"`
+	TripId             string `json:"tripId"`
+}
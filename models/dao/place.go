@@ -1,8 +1,14 @@
 package dao
 
 import (
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/leonardchinonso/lokate-go/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
@@ -20,13 +26,51 @@ type Place struct {
 	StationCode string             `json:"station_code,omitempty" bson:"station_code"`
 	TiplocCode  string             `json:"tiploc_code,omitempty" bson:"tiploc_code"`
 	SMSCode     string             `json:"smscode,omitempty" bson:"smscode"`
-	Distance    *int               `json:"distance,omitempty" bson:"distance"`
-	Key         string             `json:"key" bson:"key"`
+	// OTPStopId is the id of a stop found by OpenTripPlanner, namespaced by its feed as "{feed}:{stop}"
+	OTPStopId string `json:"otp_stop_id,omitempty" bson:"otp_stop_id,omitempty"`
+	// GTFSStopId is the id of a stop of an imported GTFS feed, namespaced by the feed as "{feed}:{stop}"
+	GTFSStopId string `json:"gtfs_stop_id,omitempty" bson:"gtfs_stop_id,omitempty"`
+	// BikeStationId is the id of a bike share station, namespaced by its system as "{system}:{station}"
+	BikeStationId string    `json:"bike_station_id,omitempty" bson:"bike_station_id,omitempty"`
+	Distance      *int      `json:"distance,omitempty" bson:"distance"`
+	Location      *GeoPoint `json:"-" bson:"location,omitempty"`
+	Key           string    `json:"key" bson:"key"`
+	Source        string    `json:"-" bson:"source,omitempty"`
+	// SearchName and SearchGrams are the normalized name and its trigrams the local search matches against
+	SearchName  string   `json:"-" bson:"search_name,omitempty"`
+	SearchGrams []string `json:"-" bson:"search_grams,omitempty"`
+	// UpdatedAt is when the place was last written, the autocomplete index reads the places written since its last refresh
+	UpdatedAt *time.Time `json:"-" bson:"updated_at,omitempty"`
+}
+
+const (
+	// PlaceSourceNaptan is the source of the places imported from the NaPTAN stops
+	PlaceSourceNaptan = "naptan"
+	// PlaceSourceGBFS is the source of the bike share stations imported from the GBFS feeds
+	PlaceSourceGBFS = "gbfs"
+)
+
+const (
+	// PlaceTypeBikeDock is the type of the places that are bike share stations
+	PlaceTypeBikeDock = "bike_dock"
+	// PlaceTypeStop is the type of the stops whose mode is not known, or is not a bus, a train or a tube
+	PlaceTypeStop = "stop"
+)
+
+// GeoPoint is a GeoJSON point, the coordinates are in the [longitude, latitude] order
+type GeoPoint struct {
+	Type        string     `json:"type" bson:"type"`
+	Coordinates [2]float64 `json:"coordinates" bson:"coordinates"`
+}
+
+// NewGeoPoint returns a GeoJSON point for a latitude and longitude pair
+func NewGeoPoint(lat, lon float64) *GeoPoint {
+	return &GeoPoint{Type: "Point", Coordinates: [2]float64{lon, lat}}
 }
 
 // NewPlace returns a new Place object
 func NewPlace(placeType, name, desc, osmid, atcocode, stationCode, tiplocCode, smsCode string, acc *int, lat, lon *float64) *Place {
-	return &Place{
+	p := &Place{
 		Type:        placeType,
 		Name:        name,
 		Latitude:    lat,
@@ -38,70 +82,164 @@ func NewPlace(placeType, name, desc, osmid, atcocode, stationCode, tiplocCode, s
 		StationCode: stationCode,
 		TiplocCode:  tiplocCode,
 		SMSCode:     smsCode,
-		Key:         fmt.Sprintf("%f%f", *lat, *lon),
+		Location:    NewGeoPoint(*lat, *lon),
 	}
+	p.Key = PlaceKey(p)
+	return p
 }
 
-// UnmarshalJSON is the  method for unmarshalling the Place data with JSON
-// it represents the interface for the unmarshalling
-func (p *Place) UnmarshalJSON(data []byte) error {
-	var m map[string]interface{}
-	if err := json.Unmarshal(data, &m); err != nil {
-		return err
+// placeKeyGeohashPrecision is the geohash length in the key of a place without an upstream id, about 38m by 19m
+const placeKeyGeohashPrecision = 8
+
+// PlaceKey returns the key a place is found by when it is stored. The upstream identifiers are
+// preferred since they do not change when the coordinates drift, then the ATCO code of a stop,
+// the CRS code and the TIPLOC code of a station and the OpenStreetMap id. Any other place is
+// keyed by the geohash of its coordinates and its normalized name, so a stop and a point of
+// interest at the same spot do not collide
+func PlaceKey(p *Place) string {
+	switch {
+	case p.ATCOCode != "":
+		return "atco:" + strings.ToUpper(p.ATCOCode)
+	case p.StationCode != "":
+		return "crs:" + strings.ToUpper(p.StationCode)
+	case p.TiplocCode != "":
+		return "tiploc:" + strings.ToUpper(p.TiplocCode)
+	case p.OSMId != "":
+		return "osm:" + p.OSMId
+	case p.OTPStopId != "":
+		return "otp:" + p.OTPStopId
+	case p.GTFSStopId != "":
+		return "gtfs:" + p.GTFSStopId
+	case p.BikeStationId != "":
+		return "gbfs:" + p.BikeStationId
+	case p.Latitude != nil && p.Longitude != nil:
+		return fmt.Sprintf("geo:%s:%s", utils.Geohash(*p.Latitude, *p.Longitude, placeKeyGeohashPrecision), utils.NormalizeName(p.Name))
 	}
+	return "name:" + utils.NormalizeName(p.Name)
+}
 
-	p.Name = m["name"].(string)
-
-	// check type existence
-	if m["type"] != nil {
-		p.Type = m["type"].(string)
+// SetSearchFields sets the normalized name and the name trigrams the local search matches a place by
+// it returns false when the fields were already up to date
+func (p *Place) SetSearchFields() bool {
+	name, grams := utils.NormalizeName(p.Name), utils.NameTrigrams(p.Name)
+	if name == p.SearchName && len(grams) == len(p.SearchGrams) {
+		same := true
+		for i := range grams {
+			if grams[i] != p.SearchGrams[i] {
+				same = false
+				break
+			}
+		}
+		if same {
+			return false
+		}
 	}
+	p.SearchName, p.SearchGrams = name, grams
+	return true
+}
 
-	// check accuracy existence
-	if m["accuracy"] != nil {
-		p.Accuracy = m["accuracy"].(*int)
+// SameUpstreamPlace determines if two places could be the same place by their upstream identifiers
+// places with different codes of the same kind are different places, like the stops on both sides of a road
+func SameUpstreamPlace(a, b *Place) bool {
+	differ := func(x, y string) bool {
+		return x != "" && y != "" && !strings.EqualFold(x, y)
 	}
+	return !differ(a.ATCOCode, b.ATCOCode) && !differ(a.StationCode, b.StationCode) &&
+		!differ(a.TiplocCode, b.TiplocCode) && !differ(a.OSMId, b.OSMId) && !differ(a.OTPStopId, b.OTPStopId) && !differ(a.GTFSStopId, b.GTFSStopId) &&
+		!differ(a.BikeStationId, b.BikeStationId)
+}
 
-	// check description existence
-	if m["description"] != nil {
-		p.Description = m["description"].(string)
-	}
+// PlaceIdFromKey returns the id a place with the key is stored with when it is stored from a search
+// the id only depends on the key, so it is known before the place is written and concurrent writes agree on it
+func PlaceIdFromKey(key string) primitive.ObjectID {
+	var id primitive.ObjectID
+	sum := sha256.Sum256([]byte(key))
+	copy(id[:], sum[:])
+	return id
+}
 
-	// check osm_id existence
-	if m["osm_id"] != nil {
-		p.OSMId = m["osm_id"].(string)
-	}
+// IsStop determines if a place is a bus stop, a train station, an OpenTripPlanner stop or a stop
+// of an imported GTFS feed that departures can be fetched for
+func (p *Place) IsStop() bool {
+	return p.ATCOCode != "" || p.StationCode != "" || p.OTPStopId != "" || p.GTFSStopId != ""
+}
 
-	// check atcocode existence
-	if m["atcocode"] != nil {
-		p.ATCOCode = m["atcocode"].(string)
-	}
+// placeMember is the schema of a place in an upstream response
+// the fields that are not required are pointers so a missing field is told apart from an empty one
+type placeMember struct {
+	Type        *string  `json:"type"`
+	Name        *string  `json:"name"`
+	Latitude    *float64 `json:"latitude"`
+	Longitude   *float64 `json:"longitude"`
+	Accuracy    *float64 `json:"accuracy"`
+	Description *string  `json:"description"`
+	OSMId       *string  `json:"osm_id"`
+	ATCOCode    *string  `json:"atcocode"`
+	StationCode *string  `json:"station_code"`
+	TiplocCode  *string  `json:"tiploc_code"`
+	SMSCode     *string  `json:"smscode"`
+	Distance    *float64 `json:"distance"`
+}
 
-	// check station_code existence
-	if m["station_code"] != nil {
-		p.StationCode = m["station_code"].(string)
+// validate makes sure a place member has a name and a coordinate, and that its numbers are in range
+func (m *placeMember) validate() error {
+	switch {
+	case m.Name == nil || strings.TrimSpace(*m.Name) == "":
+		return fmt.Errorf("missing name")
+	case m.Latitude == nil || math.IsNaN(*m.Latitude) || *m.Latitude < -90 || *m.Latitude > 90:
+		return fmt.Errorf("missing or invalid latitude")
+	case m.Longitude == nil || math.IsNaN(*m.Longitude) || *m.Longitude < -180 || *m.Longitude > 180:
+		return fmt.Errorf("missing or invalid longitude")
+	case m.Accuracy != nil && (math.IsNaN(*m.Accuracy) || *m.Accuracy < 0):
+		return fmt.Errorf("invalid accuracy")
+	case m.Distance != nil && (math.IsNaN(*m.Distance) || *m.Distance < 0):
+		return fmt.Errorf("invalid distance")
 	}
+	return nil
+}
 
-	// check tiploc_code existence
-	if m["tiploc_code"] != nil {
-		p.TiplocCode = m["tiploc_code"].(string)
+// UnmarshalJSON decodes a place from an upstream response. The place is checked against the
+// place schema, so a field of an unexpected shape or a place without a name or a coordinate is
+// an error instead of a panic
+func (p *Place) UnmarshalJSON(data []byte) error {
+	var m placeMember
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("invalid place: %v", err)
 	}
-
-	// check smscode existence
-	if m["smscode"] != nil {
-		p.SMSCode = m["smscode"].(string)
+	if err := m.validate(); err != nil {
+		return fmt.Errorf("invalid place: %v", err)
 	}
 
-	// check distance existence
-	if m["distance"] != nil {
-		p.Distance = m["distance"].(*int)
+	text := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+	metres := func(f *float64) *int {
+		if f == nil {
+			return nil
+		}
+		v := int(*f)
+		return &v
 	}
 
-	lat := m["latitude"].(float64)
-	p.Latitude = &lat
-
-	lon := m["longitude"].(float64)
-	p.Longitude = &lon
+	*p = Place{
+		Type:        text(m.Type),
+		Name:        *m.Name,
+		Latitude:    m.Latitude,
+		Longitude:   m.Longitude,
+		Accuracy:    metres(m.Accuracy),
+		Description: text(m.Description),
+		OSMId:       text(m.OSMId),
+		ATCOCode:    text(m.ATCOCode),
+		StationCode: text(m.StationCode),
+		TiplocCode:  text(m.TiplocCode),
+		SMSCode:     text(m.SMSCode),
+		Distance:    metres(m.Distance),
+		Location:    NewGeoPoint(*m.Latitude, *m.Longitude),
+	}
+	p.Key = PlaceKey(p)
 
 	return nil
 }
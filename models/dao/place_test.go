@@ -0,0 +1,100 @@
+package dao
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+// recordedPlaceMembers are members of the responses of the upstream place APIs
+var recordedPlaceMembers = []string{
+	// TAPI places.json
+	`{"type":"bus_stop","name":"Trafalgar Square (Stop S)","latitude":51.50703,"longitude":-0.12805,"accuracy":20,"atcocode":"490000077S","smscode":"73241","description":"Charing Cross","distance":40}`,
+	`{"type":"train_station","name":"London Charing Cross","latitude":51.50826,"longitude":-0.12475,"accuracy":100,"station_code":"CHX","tiploc_code":"CHRX"}`,
+	`{"type":"tube_station","name":"Embankment","latitude":51.50717,"longitude":-0.12237,"accuracy":100,"station_code":"EMB"}`,
+	`{"type":"postcode","name":"WC2N 5DN","latitude":51.50728,"longitude":-0.12763,"accuracy":100}`,
+	`{"type":"poi","name":"National Gallery","latitude":51.50894,"longitude":-0.12834,"accuracy":20,"osm_id":"way:26373469","description":"Trafalgar Square"}`,
+	// OpenTripPlanner geocode
+	`{"lat":51.50703,"lng":-0.12805,"description":"Trafalgar Square","id":"1:490000077S","modes":["BUS"]}`,
+}
+
+func TestPlaceUnmarshalJSON(t *testing.T) {
+	var place Place
+	if err := json.Unmarshal([]byte(recordedPlaceMembers[0]), &place); err != nil {
+		t.Fatalf("Unmarshal returned an error: %v", err)
+	}
+	if place.Name != "Trafalgar Square (Stop S)" || place.ATCOCode != "490000077S" || place.Key != "atco:490000077S" {
+		t.Errorf("unexpected place: %+v", place)
+	}
+	if place.Accuracy == nil || *place.Accuracy != 20 || place.Distance == nil || *place.Distance != 40 {
+		t.Errorf("unexpected accuracy and distance: %v, %v", place.Accuracy, place.Distance)
+	}
+	if place.Location == nil || place.Location.Coordinates[0] != -0.12805 || place.Location.Coordinates[1] != 51.50703 {
+		t.Errorf("unexpected location: %+v", place.Location)
+	}
+}
+
+func TestPlaceUnmarshalJSONRejectsMalformedMembers(t *testing.T) {
+	tests := []struct {
+		name   string
+		member string
+	}{
+		{"null", `null`},
+		{"array", `[]`},
+		{"string", `"Trafalgar Square"`},
+		{"empty object", `{}`},
+		{"missing name", `{"latitude":51.5,"longitude":-0.1}`},
+		{"blank name", `{"name":"  ","latitude":51.5,"longitude":-0.1}`},
+		{"name as a number", `{"name":42,"latitude":51.5,"longitude":-0.1}`},
+		{"missing latitude", `{"name":"Stop","longitude":-0.1}`},
+		{"null latitude", `{"name":"Stop","latitude":null,"longitude":-0.1}`},
+		{"latitude as a string", `{"name":"Stop","latitude":"51.5","longitude":-0.1}`},
+		{"latitude out of range", `{"name":"Stop","latitude":91,"longitude":-0.1}`},
+		{"latitude that overflows", `{"name":"Stop","latitude":1e400,"longitude":-0.1}`},
+		{"longitude out of range", `{"name":"Stop","latitude":51.5,"longitude":-181}`},
+		{"longitude as an object", `{"name":"Stop","latitude":51.5,"longitude":{"value":-0.1}}`},
+		{"negative accuracy", `{"name":"Stop","latitude":51.5,"longitude":-0.1,"accuracy":-1}`},
+		{"negative distance", `{"name":"Stop","latitude":51.5,"longitude":-0.1,"distance":-5}`},
+		{"code as an array", `{"name":"Stop","latitude":51.5,"longitude":-0.1,"atcocode":["490000077S"]}`},
+		{"type as a boolean", `{"type":true,"name":"Stop","latitude":51.5,"longitude":-0.1}`},
+		{"truncated", `{"name":"Stop","latitude":51.5,`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var place Place
+			if err := json.Unmarshal([]byte(tt.member), &place); err == nil {
+				t.Errorf("Unmarshal of %s returned no error: %+v", tt.member, place)
+			}
+		})
+	}
+}
+
+func FuzzPlaceUnmarshalJSON(f *testing.F) {
+	for _, member := range recordedPlaceMembers {
+		f.Add([]byte(member))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var place Place
+		if err := place.UnmarshalJSON(data); err != nil {
+			return
+		}
+
+		// a decoded place is always usable by the search and the nearby search
+		if strings.TrimSpace(place.Name) == "" {
+			t.Errorf("decoded a place without a name from %q", data)
+		}
+		lat, lon := *place.Latitude, *place.Longitude
+		if math.IsNaN(lat) || lat < -90 || lat > 90 || math.IsNaN(lon) || lon < -180 || lon > 180 {
+			t.Errorf("decoded a place at %v,%v from %q", lat, lon, data)
+		}
+		if place.Location == nil || place.Location.Coordinates[0] != lon || place.Location.Coordinates[1] != lat {
+			t.Errorf("decoded a place with location %+v from %q", place.Location, data)
+		}
+		if place.Key != PlaceKey(&place) {
+			t.Errorf("decoded a place with key %q, want %q", place.Key, PlaceKey(&place))
+		}
+	})
+}
@@ -0,0 +1,7 @@
+package dao
+
+// PostcodeLocation is the coordinate of the centre of a postcode
+type PostcodeLocation struct {
+	Latitude  float64
+	Longitude float64
+}
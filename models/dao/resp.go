@@ -1,18 +1,65 @@
 package dao
 
+import "encoding/json"
+
 // PlaceResp represents the TAPI response for place API
 type PlaceResp struct {
-	ID               string        `json:"id"`
-	Member           []interface{} `json:"member"`
-	View             interface{}   `json:"view"`
-	Source           string        `json:"source"`
-	Acknowledgements string        `json:"acknowledgements"`
+	ID               string            `json:"id"`
+	Member           []json.RawMessage `json:"member"`
+	View             interface{}       `json:"view"`
+	Source           string            `json:"source"`
+	Acknowledgements string            `json:"acknowledgements"`
 }
 
 // PublicJourneyResp represents the TAPI response for public journey API
 type PublicJourneyResp struct {
-	RequestTime      string        `json:"request_time"`
-	Source           string        `json:"source"`
-	Acknowledgements string        `json:"acknowledgements"`
-	Routes           []interface{} `json:"routes"`
+	RequestTime      string         `json:"request_time"`
+	Source           string         `json:"source"`
+	Acknowledgements string         `json:"acknowledgements"`
+	Routes           []JourneyRoute `json:"routes"`
+}
+
+// BusDeparturesResp represents the TAPI response for the live bus departures API
+type BusDeparturesResp struct {
+	ATCOCode   string `json:"atcocode"`
+	Name       string `json:"name"`
+	Departures struct {
+		All []BusDeparture `json:"all"`
+	} `json:"departures"`
+}
+
+// BusDeparture represents a departure in the TAPI live bus departures response
+type BusDeparture struct {
+	Mode                  string `json:"mode"`
+	Line                  string `json:"line"`
+	LineName              string `json:"line_name"`
+	Direction             string `json:"direction"`
+	OperatorName          string `json:"operator_name"`
+	Date                  string `json:"date"`
+	AimedDepartureTime    string `json:"aimed_departure_time"`
+	ExpectedDepartureDate string `json:"expected_departure_date"`
+	ExpectedDepartureTime string `json:"expected_departure_time"`
+}
+
+// TrainDeparturesResp represents the TAPI response for the live train departures API
+type TrainDeparturesResp struct {
+	StationCode string `json:"station_code"`
+	StationName string `json:"station_name"`
+	Date        string `json:"date"`
+	Departures  struct {
+		All []TrainDeparture `json:"all"`
+	} `json:"departures"`
+}
+
+// TrainDeparture represents a departure in the TAPI live train departures response
+type TrainDeparture struct {
+	Mode                  string `json:"mode"`
+	Service               string `json:"service"`
+	TrainUid              string `json:"train_uid"`
+	Platform              string `json:"platform"`
+	OperatorName          string `json:"operator_name"`
+	DestinationName       string `json:"destination_name"`
+	Status                string `json:"status"`
+	AimedDepartureTime    string `json:"aimed_departure_time"`
+	ExpectedDepartureTime string `json:"expected_departure_time"`
 }
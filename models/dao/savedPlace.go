@@ -57,6 +57,8 @@ type SavedPlace struct {
 	UserId     primitive.ObjectID  `json:"user_id" bson:"user_id"`
 	Name       string              `json:"name" bson:"name"`
 	PlaceAlias PlaceAlias          `json:"place_alias" bson:"place_alias"`
+	Pinned     bool                `json:"pinned" bson:"pinned"`
+	PinSlot    int                 `json:"-" bson:"pin_slot,omitempty"`
 	CreatedAt  primitive.Timestamp `json:"created_at" bson:"created_at"`
 	UpdatedAt  primitive.Timestamp `json:"updated_at" bson:"updated_at"`
 }
@@ -0,0 +1,69 @@
+package dto
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// defaultAutocompleteLimit is the number of suggestions when a client does not give one
+	defaultAutocompleteLimit = 8
+	// maxAutocompleteLimit is the largest number of suggestions a client can ask for
+	maxAutocompleteLimit = 20
+)
+
+// AutocompleteRequest holds the query parameters for suggesting places as a search is typed
+type AutocompleteRequest struct {
+	Query     string `form:"q"`
+	Latitude  string `form:"lat"`
+	Longitude string `form:"lon"`
+	Limit     string `form:"limit"`
+}
+
+// AutocompleteQuery holds the validated query for suggesting places as a search is typed
+// the point is only set when Near is true
+type AutocompleteQuery struct {
+	Text      string
+	Near      bool
+	Latitude  float64
+	Longitude float64
+	Limit     int
+}
+
+// ToAutocompleteQuery validates the autocomplete request and converts it to an AutocompleteQuery
+func (r *AutocompleteRequest) ToAutocompleteQuery() (AutocompleteQuery, []error) {
+	var errs []error
+	q := AutocompleteQuery{
+		Text:  strings.TrimSpace(r.Query),
+		Limit: defaultAutocompleteLimit,
+	}
+
+	// validate the typed text
+	if q.Text == "" || utf8.RuneCountInString(q.Text) > maxPlaceSearchLength {
+		errs = append(errs, fmt.Errorf("q must be between 1 and %d characters", maxPlaceSearchLength))
+	}
+
+	// validate the point to rank the suggestions around, which is optional
+	if r.Latitude != "" || r.Longitude != "" {
+		if _, err := ParseLocation(r.Latitude, r.Longitude); err != nil {
+			errs = append(errs, err)
+		} else {
+			q.Near = true
+			q.Latitude, _ = strconv.ParseFloat(r.Latitude, 64)
+			q.Longitude, _ = strconv.ParseFloat(r.Longitude, 64)
+		}
+	}
+
+	// validate the number of suggestions
+	if r.Limit != "" {
+		limit, err := strconv.Atoi(r.Limit)
+		if err != nil || limit < 1 || limit > maxAutocompleteLimit {
+			errs = append(errs, fmt.Errorf("limit must be a number between 1 and %d", maxAutocompleteLimit))
+		}
+		q.Limit = limit
+	}
+
+	return q, errs
+}
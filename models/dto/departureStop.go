@@ -0,0 +1,36 @@
+package dto
+
+import "fmt"
+
+// DepartureStopType is the kind of stop to get departures for
+type DepartureStopType string
+
+const (
+	BusStop      DepartureStopType = "bus_stop"
+	TrainStation DepartureStopType = "train_station"
+	OTPStop      DepartureStopType = "otp_stop"
+	GTFSStop     DepartureStopType = "gtfs_stop"
+)
+
+// DepartureStop identifies a stop by its ATCO code for bus stops, its CRS code for train stations,
+// its OpenTripPlanner id, with the feed id, for the stops found by OpenTripPlanner or its stop id,
+// with the feed id, for the stops of the imported GTFS feeds
+type DepartureStop struct {
+	Type DepartureStopType
+	Code string
+}
+
+// Validate makes sure a DepartureStop is valid
+func (ds DepartureStop) Validate() error {
+	switch ds.Type {
+	case BusStop, TrainStation, OTPStop, GTFSStop:
+	default:
+		return fmt.Errorf("invalid departure stop type: %v", ds.Type)
+	}
+
+	if ds.Code == "" {
+		return fmt.Errorf("departure stop code is required")
+	}
+
+	return nil
+}
@@ -0,0 +1,165 @@
+package dto
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leonardchinonso/lokate-go/models/api"
+)
+
+// journeyTimeLayout is the layout of the depart_at and arrive_by query parameters
+const journeyTimeLayout = "2006-01-02T15:04"
+
+// maxWalkDistanceLimit is the largest walking distance in metres a client can ask for
+const maxWalkDistanceLimit = 5000
+
+// JourneyTimeType determines if the journey time is the departure or the arrival time
+type JourneyTimeType string
+
+const (
+	DepartAt JourneyTimeType = "depart_at"
+	ArriveBy JourneyTimeType = "arrive_by"
+)
+
+// selectableModes holds the modes a client can include or exclude from a journey
+var selectableModes = map[api.JourneyMode]bool{
+	api.ModeBus:   true,
+	api.ModeTrain: true,
+	api.ModeTube:  true,
+	api.ModeWalk:  true,
+}
+
+// JourneySort is the order in which the routes of a journey are returned
+type JourneySort string
+
+const (
+	SortByDuration JourneySort = "duration"
+	SortByChanges  JourneySort = "changes"
+	SortByWalking  JourneySort = "walking"
+	SortByArrival  JourneySort = "arrival"
+)
+
+// Validate makes sure a JourneySort type is valid
+func (js JourneySort) Validate() error {
+	switch js {
+	case SortByDuration, SortByChanges, SortByWalking, SortByArrival:
+		return nil
+	}
+
+	return fmt.Errorf("sort must be one of duration, changes, walking or arrival")
+}
+
+// JourneyOptionsRequest holds the query parameters for planning a journey
+type JourneyOptionsRequest struct {
+	DepartAt        string `form:"depart_at"`
+	ArriveBy        string `form:"arrive_by"`
+	Modes           string `form:"modes"`
+	NotModes        string `form:"not_modes"`
+	MaxWalkDistance string `form:"max_walk_distance"`
+	Sort            string `form:"sort"`
+	MaxChanges      string `form:"max_changes"`
+}
+
+// JourneyOptions holds the validated options for planning a journey
+// a zero value plans a journey departing now with every mode and keeps the upstream order
+// excluding walking only leaves out the routes that are walked all the way
+type JourneyOptions struct {
+	TimeType        JourneyTimeType
+	Time            time.Time
+	Modes           []api.JourneyMode
+	NotModes        []api.JourneyMode
+	MaxWalkDistance int
+	Sort            JourneySort
+	MaxChanges      *int
+}
+
+// HasTime determines if the journey should be planned for a time other than now
+func (jo JourneyOptions) HasTime() bool {
+	return jo.TimeType != ""
+}
+
+// ToJourneyOptions validates the journey options request and converts it to JourneyOptions
+func (r *JourneyOptionsRequest) ToJourneyOptions() (JourneyOptions, []error) {
+	var errs []error
+	var opts JourneyOptions
+
+	// validate the journey time
+	switch {
+	case r.DepartAt != "" && r.ArriveBy != "":
+		errs = append(errs, fmt.Errorf("only one of depart_at and arrive_by can be set"))
+	case r.DepartAt != "":
+		opts.TimeType = DepartAt
+		opts.Time = parseJourneyOptionTime(r.DepartAt, string(DepartAt), &errs)
+	case r.ArriveBy != "":
+		opts.TimeType = ArriveBy
+		opts.Time = parseJourneyOptionTime(r.ArriveBy, string(ArriveBy), &errs)
+	}
+
+	// validate the included and excluded modes
+	opts.Modes = parseJourneyOptionModes(r.Modes, "modes", &errs)
+	opts.NotModes = parseJourneyOptionModes(r.NotModes, "not_modes", &errs)
+	for _, m := range opts.Modes {
+		for _, nm := range opts.NotModes {
+			if m == nm {
+				errs = append(errs, fmt.Errorf("mode %s cannot be both included and excluded", m))
+			}
+		}
+	}
+
+	// validate the maximum walking distance
+	if r.MaxWalkDistance != "" {
+		distance, err := strconv.Atoi(r.MaxWalkDistance)
+		if err != nil || distance <= 0 || distance > maxWalkDistanceLimit {
+			errs = append(errs, fmt.Errorf("max_walk_distance must be a number of metres between 1 and %d", maxWalkDistanceLimit))
+		}
+		opts.MaxWalkDistance = distance
+	}
+
+	// validate the order of the routes
+	if r.Sort != "" {
+		opts.Sort = JourneySort(strings.ToLower(r.Sort))
+		if err := opts.Sort.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	// validate the maximum number of changes
+	if r.MaxChanges != "" {
+		changes, err := strconv.Atoi(r.MaxChanges)
+		if err != nil || changes < 0 {
+			errs = append(errs, fmt.Errorf("max_changes must be a number that is not negative"))
+		}
+		opts.MaxChanges = &changes
+	}
+
+	return opts, errs
+}
+
+// parseJourneyOptionTime parses a journey time in the journeyTimeLayout format
+func parseJourneyOptionTime(value, name string, errs *[]error) time.Time {
+	t, err := time.Parse(journeyTimeLayout, value)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("%s must be in the format YYYY-MM-DDTHH:MM", name))
+	}
+	return t
+}
+
+// parseJourneyOptionModes parses a comma separated list of modes
+func parseJourneyOptionModes(value, name string, errs *[]error) []api.JourneyMode {
+	if value == "" {
+		return nil
+	}
+
+	var modes []api.JourneyMode
+	for _, m := range strings.Split(value, ",") {
+		mode := api.JourneyMode(strings.ToLower(strings.TrimSpace(m)))
+		if !selectableModes[mode] {
+			*errs = append(*errs, fmt.Errorf("%s contains an invalid mode: %s", name, m))
+			continue
+		}
+		modes = append(modes, mode)
+	}
+	return modes
+}
@@ -0,0 +1,78 @@
+package dto
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/leonardchinonso/lokate-go/models/api"
+)
+
+func TestToJourneyOptions(t *testing.T) {
+	at := time.Date(2023, 3, 1, 8, 30, 0, 0, time.UTC)
+	intPtr := func(i int) *int { return &i }
+
+	tests := []struct {
+		name string
+		req  JourneyOptionsRequest
+		want JourneyOptions
+		errs int
+	}{
+		{"no options", JourneyOptionsRequest{}, JourneyOptions{}, 0},
+
+		// the journey time
+		{"depart at", JourneyOptionsRequest{DepartAt: "2023-03-01T08:30"}, JourneyOptions{TimeType: DepartAt, Time: at}, 0},
+		{"arrive by", JourneyOptionsRequest{ArriveBy: "2023-03-01T08:30"}, JourneyOptions{TimeType: ArriveBy, Time: at}, 0},
+		{"depart at and arrive by", JourneyOptionsRequest{DepartAt: "2023-03-01T08:30", ArriveBy: "2023-03-01T09:30"}, JourneyOptions{}, 1},
+		{"time with seconds", JourneyOptionsRequest{DepartAt: "2023-03-01T08:30:00"}, JourneyOptions{}, 1},
+		{"time with a zone", JourneyOptionsRequest{ArriveBy: "2023-03-01T08:30Z"}, JourneyOptions{}, 1},
+		{"date only", JourneyOptionsRequest{DepartAt: "2023-03-01"}, JourneyOptions{}, 1},
+		{"day first", JourneyOptionsRequest{DepartAt: "01-03-2023T08:30"}, JourneyOptions{}, 1},
+		{"hour past the day", JourneyOptionsRequest{DepartAt: "2023-03-01T24:30"}, JourneyOptions{}, 1},
+
+		// the included and excluded modes
+		{"modes", JourneyOptionsRequest{Modes: "Bus, train"}, JourneyOptions{Modes: []api.JourneyMode{api.ModeBus, api.ModeTrain}}, 0},
+		{"not modes", JourneyOptionsRequest{NotModes: "tube,walk"}, JourneyOptions{NotModes: []api.JourneyMode{api.ModeTube, api.ModeWalk}}, 0},
+		{"modes and other not modes", JourneyOptionsRequest{Modes: "bus", NotModes: "tube"},
+			JourneyOptions{Modes: []api.JourneyMode{api.ModeBus}, NotModes: []api.JourneyMode{api.ModeTube}}, 0},
+		{"invalid mode", JourneyOptionsRequest{Modes: "bus,ferry"}, JourneyOptions{}, 1},
+		{"empty mode", JourneyOptionsRequest{NotModes: "bus,"}, JourneyOptions{}, 1},
+		{"mode included and excluded", JourneyOptionsRequest{Modes: "bus,train", NotModes: "TRAIN"}, JourneyOptions{}, 1},
+		{"every mode included and excluded", JourneyOptionsRequest{Modes: "bus,train", NotModes: "train,bus"}, JourneyOptions{}, 2},
+
+		// the bounds of the walking distance
+		{"shortest walk", JourneyOptionsRequest{MaxWalkDistance: "1"}, JourneyOptions{MaxWalkDistance: 1}, 0},
+		{"longest walk", JourneyOptionsRequest{MaxWalkDistance: "5000"}, JourneyOptions{MaxWalkDistance: 5000}, 0},
+		{"no walk", JourneyOptionsRequest{MaxWalkDistance: "0"}, JourneyOptions{}, 1},
+		{"negative walk", JourneyOptionsRequest{MaxWalkDistance: "-100"}, JourneyOptions{}, 1},
+		{"walk too long", JourneyOptionsRequest{MaxWalkDistance: "5001"}, JourneyOptions{}, 1},
+		{"walk in kilometres", JourneyOptionsRequest{MaxWalkDistance: "1.5"}, JourneyOptions{}, 1},
+
+		// the order and the changes
+		{"sort", JourneyOptionsRequest{Sort: "Walking"}, JourneyOptions{Sort: SortByWalking}, 0},
+		{"invalid sort", JourneyOptionsRequest{Sort: "price"}, JourneyOptions{}, 1},
+		{"no changes", JourneyOptionsRequest{MaxChanges: "0"}, JourneyOptions{MaxChanges: intPtr(0)}, 0},
+		{"negative changes", JourneyOptionsRequest{MaxChanges: "-1"}, JourneyOptions{}, 1},
+
+		// every error is reported at once
+		{"every option invalid", JourneyOptionsRequest{DepartAt: "now", Modes: "ferry", MaxWalkDistance: "far", Sort: "price", MaxChanges: "few"}, JourneyOptions{}, 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, errs := tt.req.ToJourneyOptions()
+			if len(errs) != tt.errs {
+				t.Fatalf("got errors %v, want %d", errs, tt.errs)
+			}
+			if tt.errs > 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+			if got.HasTime() != (tt.want.TimeType != "") {
+				t.Errorf("HasTime() = %v for %+v", got.HasTime(), got)
+			}
+		})
+	}
+}
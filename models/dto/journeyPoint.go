@@ -0,0 +1,127 @@
+package dto
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// JourneyPointKind is the notation used by the upstream to describe a journey point
+type JourneyPointKind string
+
+const (
+	LonLatPoint   JourneyPointKind = "lonlat"
+	PostcodePoint JourneyPointKind = "postcode"
+)
+
+// JourneyPoint is a resolved start or end of a journey
+type JourneyPoint struct {
+	Kind  JourneyPointKind
+	Value string
+}
+
+// LocationToJourneyPoint returns a JourneyPoint for a location
+func LocationToJourneyPoint(loc Location) JourneyPoint {
+	return JourneyPoint{Kind: LonLatPoint, Value: loc.Notation}
+}
+
+// PostcodeToJourneyPoint returns a JourneyPoint for a postcode
+func PostcodeToJourneyPoint(postcode Postcode) JourneyPoint {
+	return JourneyPoint{Kind: PostcodePoint, Value: postcode.StrVal}
+}
+
+// Notation returns the upstream notation for a journey point, e.g. "lonlat:-0.12,51.5"
+func (jp JourneyPoint) Notation() string {
+	return fmt.Sprintf("%s:%s", jp.Kind, jp.Value)
+}
+
+// JourneyEndpointKind is the kind of reference a client used for a journey endpoint
+type JourneyEndpointKind string
+
+const (
+	PlaceEndpoint      JourneyEndpointKind = "place"
+	SavedPlaceEndpoint JourneyEndpointKind = "saved"
+	AliasEndpoint      JourneyEndpointKind = "alias"
+	PostcodeEndpoint   JourneyEndpointKind = "postcode"
+	LocationEndpoint   JourneyEndpointKind = "location"
+)
+
+// JourneyEndpoint is an unresolved start or end of a journey as sent by the client
+type JourneyEndpoint struct {
+	Kind     JourneyEndpointKind
+	Id       primitive.ObjectID
+	Alias    string
+	Postcode Postcode
+	Location Location
+}
+
+// ParseJourneyEndpoint parses a journey endpoint from one of the following forms:
+// - "place:{id}" for a place in the application
+// - "saved:{id}" for one of the user's saved places
+// - "home" or "work" for the user's saved place with that alias
+// - "{lat},{lon}" for a coordinate
+// - a postal code of the country
+func ParseJourneyEndpoint(str, country string) (JourneyEndpoint, error) {
+	str = strings.TrimSpace(str)
+	if str == "" {
+		return JourneyEndpoint{}, fmt.Errorf("journey endpoint is required")
+	}
+
+	// check for place and saved place ids
+	if prefix, hex, found := strings.Cut(str, ":"); found {
+		var kind JourneyEndpointKind
+		switch strings.ToLower(prefix) {
+		case string(PlaceEndpoint):
+			kind = PlaceEndpoint
+		case string(SavedPlaceEndpoint):
+			kind = SavedPlaceEndpoint
+		default:
+			return JourneyEndpoint{}, fmt.Errorf("invalid journey endpoint: %s", str)
+		}
+
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			return JourneyEndpoint{}, fmt.Errorf("invalid %s id: %s", kind, hex)
+		}
+		return JourneyEndpoint{Kind: kind, Id: id}, nil
+	}
+
+	// check for saved place aliases
+	switch strings.ToUpper(str) {
+	case "HOME", "WORK":
+		return JourneyEndpoint{Kind: AliasEndpoint, Alias: strings.ToUpper(str)}, nil
+	}
+
+	// check for a latitude and longitude pair
+	if lat, lon, found := strings.Cut(str, ","); found {
+		loc, err := ParseLocation(strings.TrimSpace(lat), strings.TrimSpace(lon))
+		if err != nil {
+			return JourneyEndpoint{}, err
+		}
+		return JourneyEndpoint{Kind: LocationEndpoint, Location: loc}, nil
+	}
+
+	// fall back to a postcode
+	postcode, err := NewPostalCode(country, str)
+	if err != nil {
+		return JourneyEndpoint{}, fmt.Errorf("invalid journey endpoint: %s", str)
+	}
+	return JourneyEndpoint{Kind: PostcodeEndpoint, Postcode: postcode}, nil
+}
+
+// ParseLocation validates a latitude and longitude pair and returns a Location object
+func ParseLocation(latitude, longitude string) (Location, error) {
+	lat, err := strconv.ParseFloat(latitude, 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return Location{}, fmt.Errorf("invalid latitude: %s", latitude)
+	}
+
+	lon, err := strconv.ParseFloat(longitude, 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return Location{}, fmt.Errorf("invalid longitude: %s", longitude)
+	}
+
+	return NewLocation(latitude, longitude), nil
+}
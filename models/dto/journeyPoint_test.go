@@ -0,0 +1,61 @@
+package dto
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestParseJourneyEndpoint(t *testing.T) {
+	id := primitive.NewObjectID()
+
+	tests := []struct {
+		name    string
+		str     string
+		country string
+		want    JourneyEndpoint
+		valid   bool
+	}{
+		// the place and saved place ids, the prefix in any case
+		{"place", "place:" + id.Hex(), CountryGB, JourneyEndpoint{Kind: PlaceEndpoint, Id: id}, true},
+		{"saved place", "saved:" + id.Hex(), CountryGB, JourneyEndpoint{Kind: SavedPlaceEndpoint, Id: id}, true},
+		{"upper case prefix", " PLACE:" + id.Hex() + " ", CountryGB, JourneyEndpoint{Kind: PlaceEndpoint, Id: id}, true},
+
+		// the aliases of the saved places
+		{"home", "home", CountryGB, JourneyEndpoint{Kind: AliasEndpoint, Alias: "HOME"}, true},
+		{"work", "Work", CountryGB, JourneyEndpoint{Kind: AliasEndpoint, Alias: "WORK"}, true},
+
+		// a latitude and longitude pair, noted as longitude first for the upstream
+		{"coordinate", "51.5074,-0.1278", CountryGB, JourneyEndpoint{Kind: LocationEndpoint, Location: NewLocation("51.5074", "-0.1278")}, true},
+		{"coordinate with spaces", "51.5074, -0.1278", CountryGB, JourneyEndpoint{Kind: LocationEndpoint, Location: NewLocation("51.5074", "-0.1278")}, true},
+		{"coordinate at the limits", "-90,180", CountryGB, JourneyEndpoint{Kind: LocationEndpoint, Location: NewLocation("-90", "180")}, true},
+
+		// a postal code of the country
+		{"postcode", "sw1a1aa", CountryGB, JourneyEndpoint{Kind: PostcodeEndpoint, Postcode: Postcode{Country: CountryGB, StrVal: "SW1A1AA", Outward: "SW1A", Inward: "1AA", Len: 7}}, true},
+		{"postal code of another country", "1012 AB", "NL", JourneyEndpoint{Kind: PostcodeEndpoint, Postcode: Postcode{Country: "NL", StrVal: "1012AB", Outward: "1012", Inward: "AB", Len: 6}}, true},
+
+		// malformed endpoints
+		{"empty", "  ", CountryGB, JourneyEndpoint{}, false},
+		{"unknown prefix", "stop:" + id.Hex(), CountryGB, JourneyEndpoint{}, false},
+		{"invalid place id", "place:waterloo", CountryGB, JourneyEndpoint{}, false},
+		{"no saved place id", "saved:", CountryGB, JourneyEndpoint{}, false},
+		{"latitude out of range", "91,0", CountryGB, JourneyEndpoint{}, false},
+		{"longitude out of range", "51.5,-180.1", CountryGB, JourneyEndpoint{}, false},
+		{"not a number", "north,west", CountryGB, JourneyEndpoint{}, false},
+		{"missing longitude", "51.5,", CountryGB, JourneyEndpoint{}, false},
+		{"postcode of another country", "SW1A 1AA", "NL", JourneyEndpoint{}, false},
+		{"not a postcode", "waterloo", CountryGB, JourneyEndpoint{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseJourneyEndpoint(tt.str, tt.country)
+			if (err == nil) != tt.valid {
+				t.Fatalf("got error %v, want valid %v", err, tt.valid)
+			}
+			if got != tt.want {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
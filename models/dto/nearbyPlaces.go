@@ -0,0 +1,106 @@
+package dto
+
+import (
+	"fmt"
+	"strconv"
+)
+
+const (
+	// defaultNearbyRadius is the search radius in metres when a client does not give one
+	defaultNearbyRadius = 500
+	// maxNearbyRadius is the largest search radius in metres a client can ask for
+	maxNearbyRadius = 5000
+	// defaultNearbyPerPage is the number of places in a page when a client does not give one
+	defaultNearbyPerPage = 20
+	// maxNearbyPerPage is the largest number of places in a page a client can ask for
+	maxNearbyPerPage = 100
+	// maxNearbyPage is the last page a client can ask for, the places further away are never returned
+	maxNearbyPage = 100
+)
+
+// NearbyPlacesRequest holds the query parameters for finding the places around a point
+type NearbyPlacesRequest struct {
+	Latitude  string `form:"lat"`
+	Longitude string `form:"lon"`
+	Radius    string `form:"radius"`
+	Type      string `form:"type"`
+	Page      string `form:"page"`
+	PerPage   string `form:"per_page"`
+}
+
+// NearbyPlacesQuery holds the validated query for finding the places around a point
+type NearbyPlacesQuery struct {
+	Latitude  float64
+	Longitude float64
+	Radius    int
+	Type      string
+	Page      int
+	PerPage   int
+}
+
+// Skip returns the number of places before the requested page
+func (q NearbyPlacesQuery) Skip() int {
+	return (q.Page - 1) * q.PerPage
+}
+
+// Limit returns the number of places to read for the requested page, one more than the page
+// size tells whether there is a next page. The last page a client can ask for has no next page
+func (q NearbyPlacesQuery) Limit() int {
+	if q.Page >= maxNearbyPage {
+		return q.PerPage
+	}
+	return q.PerPage + 1
+}
+
+// ToNearbyPlacesQuery validates the nearby places request and converts it to a NearbyPlacesQuery
+func (r *NearbyPlacesRequest) ToNearbyPlacesQuery() (NearbyPlacesQuery, []error) {
+	var errs []error
+	q := NearbyPlacesQuery{
+		Radius:  defaultNearbyRadius,
+		Type:    r.Type,
+		Page:    1,
+		PerPage: defaultNearbyPerPage,
+	}
+
+	// validate the point to search around
+	if _, err := ParseLocation(r.Latitude, r.Longitude); err != nil {
+		errs = append(errs, err)
+	} else {
+		q.Latitude, _ = strconv.ParseFloat(r.Latitude, 64)
+		q.Longitude, _ = strconv.ParseFloat(r.Longitude, 64)
+	}
+
+	// validate the search radius
+	if r.Radius != "" {
+		radius, err := strconv.Atoi(r.Radius)
+		if err != nil || radius <= 0 || radius > maxNearbyRadius {
+			errs = append(errs, fmt.Errorf("radius must be a number of metres between 1 and %d", maxNearbyRadius))
+		}
+		q.Radius = radius
+	}
+
+	// validate the place type
+	if r.Type != "" && !storedPlaceTypes[r.Type] {
+		errs = append(errs, fmt.Errorf("invalid place type: %s", r.Type))
+	}
+
+	// validate the page
+	if r.Page != "" {
+		page, err := strconv.Atoi(r.Page)
+		if err != nil || page < 1 || page > maxNearbyPage {
+			errs = append(errs, fmt.Errorf("page must be a number between 1 and %d", maxNearbyPage))
+		}
+		q.Page = page
+	}
+
+	// validate the page size
+	if r.PerPage != "" {
+		perPage, err := strconv.Atoi(r.PerPage)
+		if err != nil || perPage < 1 || perPage > maxNearbyPerPage {
+			errs = append(errs, fmt.Errorf("per_page must be a number between 1 and %d", maxNearbyPerPage))
+		}
+		q.PerPage = perPage
+	}
+
+	return q, errs
+}
@@ -0,0 +1,31 @@
+package dto
+
+import "testing"
+
+func TestToNearbyPlacesQuery(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     NearbyPlacesRequest
+		valid   bool
+		wantLim int
+	}{
+		{name: "defaults", req: NearbyPlacesRequest{Latitude: "51.5", Longitude: "-0.1"}, valid: true, wantLim: defaultNearbyPerPage + 1},
+		{name: "bike docks", req: NearbyPlacesRequest{Latitude: "51.5", Longitude: "-0.1", Type: "bike_dock"}, valid: true, wantLim: defaultNearbyPerPage + 1},
+		{name: "unknown type", req: NearbyPlacesRequest{Latitude: "51.5", Longitude: "-0.1", Type: "$gt"}},
+		{name: "last page", req: NearbyPlacesRequest{Latitude: "51.5", Longitude: "-0.1", Page: "100", PerPage: "10"}, valid: true, wantLim: 10},
+		{name: "page past the last", req: NearbyPlacesRequest{Latitude: "51.5", Longitude: "-0.1", Page: "101"}},
+		{name: "page that overflows", req: NearbyPlacesRequest{Latitude: "51.5", Longitude: "-0.1", Page: "9223372036854775807"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			q, errs := tt.req.ToNearbyPlacesQuery()
+			if valid := len(errs) == 0; valid != tt.valid {
+				t.Fatalf("got errors %v, want valid %v", errs, tt.valid)
+			}
+			if tt.valid && q.Limit() != tt.wantLim {
+				t.Errorf("got limit %d, want %d", q.Limit(), tt.wantLim)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package dto
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
+
+// nearestPlaceTypes holds the place types a client can filter the nearest places by
+var nearestPlaceTypes = map[string]bool{
+	"bus_stop":      true,
+	"train_station": true,
+	"tube_station":  true,
+	"postcode":      true,
+	"poi":           true,
+}
+
+// storedPlaceTypes holds the types of the places lokate stores, the types of the transit provider
+// places, the bike share stations and the stops whose mode is not known
+var storedPlaceTypes = map[string]bool{
+	"bus_stop":            true,
+	"train_station":       true,
+	"tube_station":        true,
+	"postcode":            true,
+	"poi":                 true,
+	dao.PlaceTypeBikeDock: true,
+	dao.PlaceTypeStop:     true,
+}
+
+// NearestPlacesRequest holds the query parameters for finding the nearest places from the transit provider
+type NearestPlacesRequest struct {
+	Latitude  string `form:"lat"`
+	Longitude string `form:"lon"`
+	Types     string `form:"type"`
+}
+
+// NearestPlacesQuery holds the validated query for finding the nearest places from the transit provider
+// no types means places of every type
+type NearestPlacesQuery struct {
+	Latitude  float64
+	Longitude float64
+	Types     []string
+}
+
+// HasType determines if places of a type are asked for
+func (q NearestPlacesQuery) HasType(placeType string) bool {
+	if len(q.Types) == 0 {
+		return true
+	}
+	for _, t := range q.Types {
+		if t == placeType {
+			return true
+		}
+	}
+	return false
+}
+
+// ToNearestPlacesQuery validates the nearest places request and converts it to a NearestPlacesQuery
+func (r *NearestPlacesRequest) ToNearestPlacesQuery() (NearestPlacesQuery, []error) {
+	var errs []error
+	var q NearestPlacesQuery
+
+	// validate the point to search around
+	if _, err := ParseLocation(r.Latitude, r.Longitude); err != nil {
+		errs = append(errs, err)
+	} else {
+		q.Latitude, _ = strconv.ParseFloat(r.Latitude, 64)
+		q.Longitude, _ = strconv.ParseFloat(r.Longitude, 64)
+	}
+
+	// validate the comma separated list of types
+	if r.Types != "" {
+		for _, t := range strings.Split(r.Types, ",") {
+			placeType := strings.ToLower(strings.TrimSpace(t))
+			if !nearestPlaceTypes[placeType] {
+				errs = append(errs, fmt.Errorf("type must be one of bus_stop, train_station, tube_station, postcode or poi: %s", t))
+				continue
+			}
+			q.Types = append(q.Types, placeType)
+		}
+	}
+
+	return q, errs
+}
@@ -0,0 +1,145 @@
+package dto
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxDuplicateRadius is the largest radius in metres duplicate places can be looked for in
+const maxDuplicateRadius = 1000
+
+// DuplicatePlacesRequest holds the query parameters for finding the duplicate places
+type DuplicatePlacesRequest struct {
+	Radius string `form:"radius"`
+}
+
+// ToRadius validates the duplicate places request and returns the radius to look for duplicates in
+// the default radius is used when the request does not set one
+func (r *DuplicatePlacesRequest) ToRadius(defaultRadius int) (int, error) {
+	if r.Radius == "" {
+		return defaultRadius, nil
+	}
+
+	radius, err := strconv.Atoi(r.Radius)
+	if err != nil || radius <= 0 || radius > maxDuplicateRadius {
+		return 0, fmt.Errorf("radius must be a number of metres between 1 and %d", maxDuplicateRadius)
+	}
+	return radius, nil
+}
+
+// MergePlacesRequest holds the places to merge into another place
+type MergePlacesRequest struct {
+	DuplicateIds []string `json:"duplicate_ids" binding:"required"`
+}
+
+// ToObjectIDs validates the ids of the places to merge and converts them to object ids
+func (r *MergePlacesRequest) ToObjectIDs(placeId primitive.ObjectID) ([]primitive.ObjectID, []error) {
+	var errs []error
+	var ids []primitive.ObjectID
+
+	if len(r.DuplicateIds) == 0 {
+		errs = append(errs, fmt.Errorf("duplicate_ids must not be empty"))
+	}
+
+	seen := make(map[primitive.ObjectID]bool)
+	for _, hex := range r.DuplicateIds {
+		id, err := primitive.ObjectIDFromHex(hex)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid place id: %s", hex))
+			continue
+		}
+		if id == placeId {
+			errs = append(errs, fmt.Errorf("a place cannot be merged into itself: %s", hex))
+			continue
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	return ids, errs
+}
+
+// UpdatePlaceRequest holds the fields of a place to change, the fields that are not set are kept
+type UpdatePlaceRequest struct {
+	Type        *string  `json:"type"`
+	Name        *string  `json:"name"`
+	Latitude    *float64 `json:"latitude"`
+	Longitude   *float64 `json:"longitude"`
+	Description *string  `json:"description"`
+	OSMId       *string  `json:"osm_id"`
+	ATCOCode    *string  `json:"atcocode"`
+	StationCode *string  `json:"station_code"`
+	TiplocCode  *string  `json:"tiploc_code"`
+	SMSCode     *string  `json:"smscode"`
+}
+
+// Validate validates the fields of the update place request that are set
+func (r *UpdatePlaceRequest) Validate() []error {
+	var errs []error
+
+	if r.Type == nil && r.Name == nil && r.Latitude == nil && r.Longitude == nil && r.Description == nil &&
+		r.OSMId == nil && r.ATCOCode == nil && r.StationCode == nil && r.TiplocCode == nil && r.SMSCode == nil {
+		errs = append(errs, fmt.Errorf("at least one field must be set"))
+	}
+
+	if r.Type != nil && !storedPlaceTypes[*r.Type] {
+		errs = append(errs, fmt.Errorf("invalid place type: %s", *r.Type))
+	}
+
+	if r.Name != nil && strings.TrimSpace(*r.Name) == "" {
+		errs = append(errs, fmt.Errorf("name must not be empty"))
+	}
+
+	if r.Latitude != nil && (*r.Latitude < -90 || *r.Latitude > 90) {
+		errs = append(errs, fmt.Errorf("invalid latitude: %v", *r.Latitude))
+	}
+
+	if r.Longitude != nil && (*r.Longitude < -180 || *r.Longitude > 180) {
+		errs = append(errs, fmt.Errorf("invalid longitude: %v", *r.Longitude))
+	}
+
+	return errs
+}
+
+// PlaceImportRequest holds the query parameters for importing places
+type PlaceImportRequest struct {
+	DryRun string `form:"dry_run"`
+}
+
+// ToDryRun validates the place import request and returns if the import is a dry run
+func (r *PlaceImportRequest) ToDryRun() (bool, error) {
+	if r.DryRun == "" {
+		return false, nil
+	}
+
+	dryRun, err := strconv.ParseBool(r.DryRun)
+	if err != nil {
+		return false, fmt.Errorf("dry_run must be true or false")
+	}
+	return dryRun, nil
+}
+
+// AdminPlaceSearchRequest holds the query parameters for an admin searching for places as a user
+type AdminPlaceSearchRequest struct {
+	PlaceSearchRequest
+	UserId string `form:"user_id"`
+}
+
+// ToAdminPlaceSearchQuery validates the admin place search request and converts it to the id of the
+// user to search as and a PlaceSearchQuery, whose ranking is always explained
+func (r *AdminPlaceSearchRequest) ToAdminPlaceSearchQuery() (primitive.ObjectID, PlaceSearchQuery, []error) {
+	query, errs := r.ToPlaceSearchQuery()
+	query.Debug = true
+
+	userId, err := primitive.ObjectIDFromHex(r.UserId)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("invalid user id: %s", r.UserId))
+	}
+
+	return userId, query, errs
+}
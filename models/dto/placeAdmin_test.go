@@ -0,0 +1,38 @@
+package dto
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestAdminPlaceSearchRequest(t *testing.T) {
+	userId := primitive.NewObjectID()
+
+	tests := []struct {
+		name  string
+		req   AdminPlaceSearchRequest
+		valid bool
+	}{
+		{"search as a user", AdminPlaceSearchRequest{PlaceSearchRequest{Query: "waterloo"}, userId.Hex()}, true},
+		{"debug cannot be turned off", AdminPlaceSearchRequest{PlaceSearchRequest{Query: "waterloo", Debug: "false"}, userId.Hex()}, true},
+		{"no user", AdminPlaceSearchRequest{PlaceSearchRequest{Query: "waterloo"}, ""}, false},
+		{"invalid user", AdminPlaceSearchRequest{PlaceSearchRequest{Query: "waterloo"}, "waterloo"}, false},
+		{"no query", AdminPlaceSearchRequest{PlaceSearchRequest{}, userId.Hex()}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, query, errs := tt.req.ToAdminPlaceSearchQuery()
+			if (len(errs) == 0) != tt.valid {
+				t.Fatalf("got errors %v, want valid %v", errs, tt.valid)
+			}
+			if !tt.valid {
+				return
+			}
+			if id != userId || query.Text != "waterloo" || !query.Debug {
+				t.Errorf("got user %v and query %+v", id, query)
+			}
+		})
+	}
+}
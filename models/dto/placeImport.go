@@ -0,0 +1,204 @@
+package dto
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/utils"
+)
+
+// placeImportColumns maps the accepted csv headers and GeoJSON property names to the place field they set
+var placeImportColumns = map[string]string{
+	"type":         "type",
+	"name":         "name",
+	"latitude":     "latitude",
+	"lat":          "latitude",
+	"longitude":    "longitude",
+	"lon":          "longitude",
+	"lng":          "longitude",
+	"description":  "description",
+	"osm_id":       "osm_id",
+	"atcocode":     "atcocode",
+	"atco_code":    "atcocode",
+	"station_code": "station_code",
+	"crs":          "station_code",
+	"tiploc_code":  "tiploc_code",
+	"tiploc":       "tiploc_code",
+	"smscode":      "smscode",
+	"sms_code":     "smscode",
+}
+
+// PlaceImportRow is a place read from an import file before it is validated
+// the fields are keyed by the place field they set and Err is set when the row could not be read
+type PlaceImportRow struct {
+	Row    int
+	Fields map[string]string
+	Err    error
+}
+
+// ToPlace validates a place import row and converts it to a place
+// the type defaults to a point of interest
+func (r *PlaceImportRow) ToPlace() (*dao.Place, []error) {
+	if r.Err != nil {
+		return nil, []error{r.Err}
+	}
+
+	var errs []error
+	get := func(field string) string {
+		return strings.TrimSpace(r.Fields[field])
+	}
+
+	placeType := get("type")
+	if placeType == "" {
+		placeType = "poi"
+	}
+	if !storedPlaceTypes[placeType] {
+		errs = append(errs, fmt.Errorf("invalid place type: %s", placeType))
+	}
+
+	name := get("name")
+	if name == "" {
+		errs = append(errs, fmt.Errorf("name is required"))
+	}
+
+	var lat, lon float64
+	if _, err := ParseLocation(get("latitude"), get("longitude")); err != nil {
+		errs = append(errs, err)
+	} else {
+		lat, _ = strconv.ParseFloat(get("latitude"), 64)
+		lon, _ = strconv.ParseFloat(get("longitude"), 64)
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	return dao.NewPlace(placeType, name, get("description"), get("osm_id"), get("atcocode"), get("station_code"),
+		get("tiploc_code"), get("smscode"), nil, &lat, &lon), nil
+}
+
+// ParsePlaceImportCSV reads the places of an import from a csv file with a header row
+// the rows are numbered from 1 after the header
+func ParsePlaceImportCSV(r io.Reader) ([]PlaceImportRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	header, err := cr.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("the csv file is empty")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid csv file: %v", err)
+	}
+
+	// find the place field of each column, the columns that are not known are ignored
+	fields := make([]string, len(header))
+	for i, h := range utils.CSVHeader(header) {
+		fields[i] = placeImportColumns[strings.ToLower(h)]
+	}
+
+	var rows []PlaceImportRow
+	for n := 1; ; n++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// a malformed line fails its row only, unless the reader cannot go on
+			if _, ok := err.(*csv.ParseError); !ok {
+				return nil, fmt.Errorf("invalid csv file: %v", err)
+			}
+			rows = append(rows, PlaceImportRow{Row: n, Err: fmt.Errorf("invalid csv row: %v", err)})
+			continue
+		}
+
+		row := PlaceImportRow{Row: n, Fields: make(map[string]string)}
+		for i, v := range record {
+			if i < len(fields) && fields[i] != "" {
+				row.Fields[fields[i]] = v
+			}
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// placeImportFeatureCollection is a GeoJSON FeatureCollection of the places of an import
+type placeImportFeatureCollection struct {
+	Type     string            `json:"type"`
+	Features []json.RawMessage `json:"features"`
+}
+
+// placeImportFeature is a GeoJSON Feature of a place, the geometry must be a Point
+type placeImportFeature struct {
+	Type     string `json:"type"`
+	Geometry *struct {
+		Type        string          `json:"type"`
+		Coordinates json.RawMessage `json:"coordinates"`
+	} `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// ParsePlaceImportGeoJSON reads the places of an import from a GeoJSON FeatureCollection of Points
+// the coordinates of a feature set the latitude and longitude, its properties set the other fields
+// and the rows are numbered from 1 in the order of the features
+func ParsePlaceImportGeoJSON(r io.Reader) ([]PlaceImportRow, error) {
+	var fc placeImportFeatureCollection
+	if err := json.NewDecoder(r).Decode(&fc); err != nil {
+		return nil, fmt.Errorf("invalid GeoJSON file: %v", err)
+	}
+	if fc.Type != "FeatureCollection" {
+		return nil, fmt.Errorf("the GeoJSON file must be a FeatureCollection")
+	}
+
+	rows := make([]PlaceImportRow, len(fc.Features))
+	for i, raw := range fc.Features {
+		rows[i] = parsePlaceImportFeature(raw)
+		rows[i].Row = i + 1
+	}
+	return rows, nil
+}
+
+// parsePlaceImportFeature reads a place from a GeoJSON Feature, a feature that is not a Point fails its row
+func parsePlaceImportFeature(raw json.RawMessage) PlaceImportRow {
+	var f placeImportFeature
+	if err := json.Unmarshal(raw, &f); err != nil {
+		return PlaceImportRow{Err: fmt.Errorf("invalid feature: %v", err)}
+	}
+	if f.Type != "Feature" {
+		return PlaceImportRow{Err: fmt.Errorf("invalid feature type: %s", f.Type)}
+	}
+	if f.Geometry == nil || f.Geometry.Type != "Point" {
+		return PlaceImportRow{Err: fmt.Errorf("the geometry of a feature must be a Point")}
+	}
+	var coordinates []float64
+	if err := json.Unmarshal(f.Geometry.Coordinates, &coordinates); err != nil || len(coordinates) < 2 {
+		return PlaceImportRow{Err: fmt.Errorf("invalid Point coordinates")}
+	}
+
+	row := PlaceImportRow{Fields: make(map[string]string)}
+	for k, v := range f.Properties {
+		field := placeImportColumns[strings.ToLower(k)]
+		if field == "" || field == "latitude" || field == "longitude" {
+			continue
+		}
+		switch v := v.(type) {
+		case string:
+			row.Fields[field] = v
+		case float64:
+			// the codes of a place can be written as numbers
+			row.Fields[field] = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+	}
+
+	// GeoJSON coordinates are in the [longitude, latitude] order
+	row.Fields["longitude"] = strconv.FormatFloat(coordinates[0], 'f', -1, 64)
+	row.Fields["latitude"] = strconv.FormatFloat(coordinates[1], 'f', -1, 64)
+	return row
+}
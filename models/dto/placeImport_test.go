@@ -0,0 +1,54 @@
+package dto
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
+
+func TestParsePlaceImportCSV(t *testing.T) {
+	csv := "\ufeff Name ,Type,LAT,lon\n" +
+		"Waterloo Dock,bike_dock,51.5033,-0.1145\n" +
+		"Charing Cross,stop,51.5080,-0.1247\n" +
+		"Nowhere,bus_shelter,51.5,-0.1\n"
+
+	rows, err := ParsePlaceImportCSV(strings.NewReader(csv))
+	if err != nil {
+		t.Fatalf("ParsePlaceImportCSV returned an error: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+
+	dock, errs := rows[0].ToPlace()
+	if len(errs) > 0 {
+		t.Fatalf("ToPlace of a bike dock returned errors: %v", errs)
+	}
+	if dock.Type != dao.PlaceTypeBikeDock || dock.Name != "Waterloo Dock" || *dock.Latitude != 51.5033 {
+		t.Errorf("unexpected bike dock: %+v", dock)
+	}
+
+	if _, errs := rows[1].ToPlace(); len(errs) > 0 {
+		t.Errorf("ToPlace of a stop returned errors: %v", errs)
+	}
+	if _, errs := rows[2].ToPlace(); len(errs) == 0 {
+		t.Error("ToPlace of an unknown place type returned no error")
+	}
+}
+
+func TestUpdatePlaceRequestType(t *testing.T) {
+	for _, placeType := range []string{"bus_stop", "poi", dao.PlaceTypeBikeDock, dao.PlaceTypeStop} {
+		placeType := placeType
+		r := UpdatePlaceRequest{Type: &placeType}
+		if errs := r.Validate(); len(errs) > 0 {
+			t.Errorf("Validate of type %s returned errors: %v", placeType, errs)
+		}
+	}
+
+	unknown := "bus_shelter"
+	r := UpdatePlaceRequest{Type: &unknown}
+	if errs := r.Validate(); len(errs) == 0 {
+		t.Error("Validate of an unknown place type returned no errors")
+	}
+}
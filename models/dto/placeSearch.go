@@ -0,0 +1,66 @@
+package dto
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// defaultPlaceSearchLimit is the number of places found by a search when a client does not give one
+	defaultPlaceSearchLimit = 10
+	// maxPlaceSearchLimit is the largest number of places a client can ask a search for
+	maxPlaceSearchLimit = 50
+	// maxPlaceSearchLength is the longest search text in characters
+	maxPlaceSearchLength = 100
+)
+
+// PlaceSearchRequest holds the query parameters for searching for a place with text
+type PlaceSearchRequest struct {
+	Query string `form:"query"`
+	Limit string `form:"limit"`
+	Debug string `form:"debug"`
+}
+
+// PlaceSearchQuery holds the validated query for searching for a place with text
+// the ranking of each place is explained when Debug is true
+type PlaceSearchQuery struct {
+	Text  string
+	Limit int
+	Debug bool
+}
+
+// ToPlaceSearchQuery validates the place search request and converts it to a PlaceSearchQuery
+func (r *PlaceSearchRequest) ToPlaceSearchQuery() (PlaceSearchQuery, []error) {
+	var errs []error
+	q := PlaceSearchQuery{
+		Text:  strings.TrimSpace(r.Query),
+		Limit: defaultPlaceSearchLimit,
+	}
+
+	// validate the search text
+	if q.Text == "" || utf8.RuneCountInString(q.Text) > maxPlaceSearchLength {
+		errs = append(errs, fmt.Errorf("query must be between 1 and %d characters", maxPlaceSearchLength))
+	}
+
+	// validate the number of places
+	if r.Limit != "" {
+		limit, err := strconv.Atoi(r.Limit)
+		if err != nil || limit < 1 || limit > maxPlaceSearchLimit {
+			errs = append(errs, fmt.Errorf("limit must be a number between 1 and %d", maxPlaceSearchLimit))
+		}
+		q.Limit = limit
+	}
+
+	// validate the debug flag
+	if r.Debug != "" {
+		debug, err := strconv.ParseBool(r.Debug)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("debug must be true or false"))
+		}
+		q.Debug = debug
+	}
+
+	return q, errs
+}
@@ -2,56 +2,178 @@ package dto
 
 import (
 	"fmt"
+	"regexp"
 	"strings"
 )
 
-// Postcode represents a UK postcode
-type Postcode struct {
-	StrVal  string
-	byteVal []byte
-	Len     int
+// ukPostcodePattern matches a UK postcode without its space. The outward code is one of the
+// A9, A99, A9A, AA9, AA99 and AA9A forms and the inward code is a digit and two letters
+// the letters that are never used in each position are left out
+var ukPostcodePattern = regexp.MustCompile(
+	`^(?:` +
+		`[A-PR-UWYZ][0-9]{1,2}` + // A9, A99
+		`|[A-PR-UWYZ][0-9][A-HJKPSTUW]` + // A9A
+		`|[A-PR-UWYZ][A-HK-Y][0-9]{1,2}` + // AA9, AA99
+		`|[A-PR-UWYZ][A-HK-Y][0-9][ABEHMNPRVWXY]` + // AA9A
+		`)[0-9][ABD-HJLNP-UW-Z]{2}$`,
+)
+
+// specialPostcodes holds the postcodes that do not follow the UK pattern but are in use
+// the Girobank postcode and the postcodes of the British Overseas Territories
+var specialPostcodes = map[string]bool{
+	"GIR0AA":  true,
+	"ASCN1ZZ": true,
+	"BBND1ZZ": true,
+	"BIQQ1ZZ": true,
+	"FIQQ1ZZ": true,
+	"PCRN1ZZ": true,
+	"SIQQ1ZZ": true,
+	"STHL1ZZ": true,
+	"TDCU1ZZ": true,
+	"TKCA1ZZ": true,
 }
 
-// ValidatePostcode validates a postcode
-func ValidatePostcode(str string) (*Postcode, error) {
-	// clean up the postcode string format
-	str = strings.ToUpper(strings.Replace(str, " ", "", -1))
+// eircodePattern matches an Irish Eircode without its space. The routing key is a letter and two
+// digits, or D6W for Dublin 6W, and the unique identifier is four of the letters and digits in use
+var eircodePattern = regexp.MustCompile(`^(?:[AC-FHKNPRTV-Y][0-9]{2}|D6W)[0-9AC-FHKNPRTV-Y]{4}$`)
 
-	// begin validation
-	if len(str) < 5 || len(str) > 7 {
-		return nil, fmt.Errorf("invalid postcode: %s", str)
-	}
+// nlPostcodePattern matches a Dutch postcode without its space, four digits not starting with 0
+// and two letters. SA, SD and SS are not used as letters and are checked separately
+var nlPostcodePattern = regexp.MustCompile(`^[1-9][0-9]{3}[A-Z]{2}$`)
 
-	var p = &Postcode{
-		StrVal: str,
-		Len:    len(str),
+// CountryGB is the country code of the United Kingdom, whose postcodes the upstream knows
+const CountryGB = "GB"
+
+// PostalCodeFormat validates and formats the postal codes of a country
+// the postal codes are given to both functions in upper case and without spaces
+type PostalCodeFormat struct {
+	// Valid checks if a postal code is in use in the country
+	Valid func(compact string) bool
+	// Split splits a valid postal code into the parts that are written apart, e.g. "SW1A" and "1AA"
+	Split func(compact string) (string, string)
+}
+
+// postalCodeFormats holds the postal code formats of the supported countries by their ISO 3166 code
+var postalCodeFormats = map[string]PostalCodeFormat{
+	CountryGB: {
+		Valid: func(compact string) bool {
+			return ukPostcodePattern.MatchString(compact) || specialPostcodes[compact]
+		},
+		// the inward code is always the last three characters
+		Split: func(compact string) (string, string) {
+			return compact[:len(compact)-3], compact[len(compact)-3:]
+		},
+	},
+	"IE": {
+		Valid: eircodePattern.MatchString,
+		// the routing key is always the first three characters
+		Split: func(compact string) (string, string) {
+			return compact[:3], compact[3:]
+		},
+	},
+	"NL": {
+		Valid: func(compact string) bool {
+			if !nlPostcodePattern.MatchString(compact) {
+				return false
+			}
+			switch compact[4:] {
+			case "SA", "SD", "SS":
+				return false
+			}
+			return true
+		},
+		Split: func(compact string) (string, string) {
+			return compact[:4], compact[4:]
+		},
+	},
+}
+
+// countryAliases holds the other names clients use for the supported countries
+var countryAliases = map[string]string{
+	"UK": CountryGB,
+}
+
+// RegisterPostalCodeFormat adds the postal code format of a country, or replaces the one it has
+// it is meant to be called when the application starts, before any request is handled
+func RegisterPostalCodeFormat(country string, format PostalCodeFormat) {
+	postalCodeFormats[strings.ToUpper(country)] = format
+}
+
+// ParseCountry validates a country code against the countries with a postal code format
+// the default country is returned when no country is given
+func ParseCountry(country, defaultCountry string) (string, error) {
+	if strings.TrimSpace(country) == "" {
+		country = defaultCountry
 	}
-	p.byteVal = []byte(p.StrVal)
 
-	// check that last three characters of postcode conform to UK postcode
-	lastThree := p.byteVal[p.Len-3:]
+	code := strings.ToUpper(strings.TrimSpace(country))
+	if alias, ok := countryAliases[code]; ok {
+		code = alias
+	}
 
-	// check that first character of the last three is numeric
-	if lastThree[0] < 48 || lastThree[0] > 57 {
-		return nil, fmt.Errorf("invalid postcode: %s", str)
+	if _, ok := postalCodeFormats[code]; !ok {
+		return "", fmt.Errorf("unsupported country: %s", country)
 	}
+	return code, nil
+}
 
-	// check that the last two characters are non-numeric
-	if lastThree[1] < 65 || lastThree[1] > 122 {
-		return nil, fmt.Errorf("invalid postcode: %s", str)
+// Postcode represents a postal code of a supported country
+// StrVal holds the postal code without the space, as used by the upstream
+// Outward and Inward hold the parts of the postal code that are written apart
+type Postcode struct {
+	Country string
+	StrVal  string
+	Outward string
+	Inward  string
+	Len     int
+}
+
+// ValidatePostalCode validates a postal code against the format of a country
+// the postal code can be in any case and with or without the space
+func ValidatePostalCode(country, str string) (*Postcode, error) {
+	format, ok := postalCodeFormats[country]
+	if !ok {
+		return nil, fmt.Errorf("unsupported country: %s", country)
 	}
-	if lastThree[2] < 65 || lastThree[2] > 122 {
+
+	// clean up the postal code string format
+	compact := strings.ToUpper(strings.Join(strings.Fields(str), ""))
+
+	if !format.Valid(compact) {
 		return nil, fmt.Errorf("invalid postcode: %s", str)
 	}
 
-	return p, nil
+	outward, inward := format.Split(compact)
+	return &Postcode{
+		Country: country,
+		StrVal:  compact,
+		Outward: outward,
+		Inward:  inward,
+		Len:     len(compact),
+	}, nil
 }
 
-// NewPostcode validates and returns a Postcode object
-func NewPostcode(str string) (Postcode, error) {
-	p, err := ValidatePostcode(str)
+// NewPostalCode validates and returns a Postcode object for a postal code of a country
+func NewPostalCode(country, str string) (Postcode, error) {
+	p, err := ValidatePostalCode(country, str)
 	if err != nil {
 		return Postcode{}, err
 	}
 	return *p, nil
 }
+
+// ValidatePostcode validates a postcode against the UK outward and inward code format
+// the postcode can be in any case and with or without the space
+func ValidatePostcode(str string) (*Postcode, error) {
+	return ValidatePostalCode(CountryGB, str)
+}
+
+// NewPostcode validates and returns a Postcode object for a UK postcode
+func NewPostcode(str string) (Postcode, error) {
+	return NewPostalCode(CountryGB, str)
+}
+
+// String returns the postcode in its canonical format, e.g. "SW1A 1AA", "D02 X285" or "1012 AB"
+func (p Postcode) String() string {
+	return p.Outward + " " + p.Inward
+}
@@ -0,0 +1,72 @@
+package dto
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/leonardchinonso/lokate-go/utils"
+)
+
+// PostcodeBatchRow is a postcode of a batch and the row it is reported on, the line of a csv file
+// or the position in a JSON array, counted from 1
+type PostcodeBatchRow struct {
+	Row      int
+	Postcode string
+}
+
+// ParsePostcodeBatchJSON reads the postcodes of a batch from a JSON array of strings
+func ParsePostcodeBatchJSON(r io.Reader) ([]PostcodeBatchRow, error) {
+	var postcodes []string
+	if err := json.NewDecoder(r).Decode(&postcodes); err != nil {
+		return nil, fmt.Errorf("the body must be a JSON array of postcodes: %v", err)
+	}
+
+	rows := make([]PostcodeBatchRow, len(postcodes))
+	for i, postcode := range postcodes {
+		rows[i] = PostcodeBatchRow{Row: i + 1, Postcode: postcode}
+	}
+	return rows, nil
+}
+
+// ParsePostcodeBatchCSV reads the postcodes of a batch from a csv file. The postcodes are read
+// from the column with a "postcode" header, or from the first column when no header has that name
+// each postcode is reported on the line of the file it starts on
+func ParsePostcodeBatchCSV(r io.Reader) ([]PostcodeBatchRow, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+
+	var rows []PostcodeBatchRow
+	column := 0
+	for first := true; ; first = false {
+		record, err := cr.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid csv file: %v", err)
+		}
+
+		if first {
+			header := false
+			for i, h := range utils.CSVHeader(record) {
+				if strings.EqualFold(h, "postcode") {
+					column, header = i, true
+					break
+				}
+			}
+			if header {
+				continue
+			}
+		}
+
+		line, _ := cr.FieldPos(0)
+		row := PostcodeBatchRow{Row: line}
+		if column < len(record) {
+			row.Postcode = record[column]
+		}
+		rows = append(rows, row)
+	}
+}
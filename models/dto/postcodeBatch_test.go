@@ -0,0 +1,64 @@
+package dto
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePostcodeBatchCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		csv  string
+		want []PostcodeBatchRow
+	}{
+		{
+			name: "no header",
+			csv:  "SW1A 1AA\nM1 1AE\n",
+			want: []PostcodeBatchRow{{1, "SW1A 1AA"}, {2, "M1 1AE"}},
+		},
+		{
+			name: "postcode header",
+			csv:  "postcode\nSW1A 1AA\nM1 1AE\n",
+			want: []PostcodeBatchRow{{2, "SW1A 1AA"}, {3, "M1 1AE"}},
+		},
+		{
+			name: "postcode header after a byte order mark in another column",
+			csv:  "\ufeffname,Postcode\nPalace,SW1A 1AA\nPiccadilly,M1 1AE\n",
+			want: []PostcodeBatchRow{{2, "SW1A 1AA"}, {3, "M1 1AE"}},
+		},
+		{
+			name: "blank lines and a field over two lines",
+			csv:  "name,postcode\n\n\"Buckingham\nPalace\",SW1A 1AA\n\nPiccadilly,M1 1AE\n",
+			want: []PostcodeBatchRow{{3, "SW1A 1AA"}, {6, "M1 1AE"}},
+		},
+		{
+			name: "short row",
+			csv:  "name,postcode\nPalace\n",
+			want: []PostcodeBatchRow{{2, ""}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePostcodeBatchCSV(strings.NewReader(tt.csv))
+			if err != nil {
+				t.Fatalf("ParsePostcodeBatchCSV returned an error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePostcodeBatchJSON(t *testing.T) {
+	got, err := ParsePostcodeBatchJSON(strings.NewReader(`["SW1A 1AA", "M1 1AE"]`))
+	if err != nil {
+		t.Fatalf("ParsePostcodeBatchJSON returned an error: %v", err)
+	}
+	want := []PostcodeBatchRow{{1, "SW1A 1AA"}, {2, "M1 1AE"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
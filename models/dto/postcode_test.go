@@ -0,0 +1,132 @@
+package dto
+
+import "testing"
+
+func TestValidatePostalCode(t *testing.T) {
+	tests := []struct {
+		name    string
+		country string
+		code    string
+		valid   bool
+	}{
+		// the UK outward code forms
+		{"A9", CountryGB, "M1 1AE", true},
+		{"A99", CountryGB, "M60 1NW", true},
+		{"A9A", CountryGB, "W1A 1HQ", true},
+		{"AA9", CountryGB, "CR2 6XH", true},
+		{"AA99", CountryGB, "DN55 1PT", true},
+		{"AA9A", CountryGB, "EC1A 1BB", true},
+		{"no space and lower case", CountryGB, "sw1a1aa", true},
+		{"extra spaces", CountryGB, " SW1A  1AA ", true},
+
+		// the letters never used in each position of a UK postcode
+		{"Q first", CountryGB, "Q1 1AA", false},
+		{"V first", CountryGB, "V1 1AA", false},
+		{"X first", CountryGB, "X1 1AA", false},
+		{"I second", CountryGB, "BI1 1AA", false},
+		{"J second", CountryGB, "BJ1 1AA", false},
+		{"Z second", CountryGB, "BZ1 1AA", false},
+		{"I third of A9A", CountryGB, "W1I 1AA", false},
+		{"C fourth of AA9A", CountryGB, "EC1C 1BB", false},
+		{"C in the inward code", CountryGB, "M1 1CA", false},
+		{"V in the inward code", CountryGB, "M1 1AV", false},
+		{"letter first in the inward code", CountryGB, "M1 AAA", false},
+		{"outward code only", CountryGB, "SW1A", false},
+		{"too long", CountryGB, "SW1AA 1AA", false},
+		{"empty", CountryGB, "", false},
+
+		// the special UK postcodes
+		{"Girobank", CountryGB, "GIR 0AA", true},
+		{"Ascension Island", CountryGB, "ASCN 1ZZ", true},
+		{"British Indian Ocean Territory", CountryGB, "BBND 1ZZ", true},
+		{"British Antarctic Territory", CountryGB, "BIQQ 1ZZ", true},
+		{"Falkland Islands", CountryGB, "FIQQ 1ZZ", true},
+		{"Pitcairn Islands", CountryGB, "PCRN 1ZZ", true},
+		{"South Georgia", CountryGB, "SIQQ 1ZZ", true},
+		{"Saint Helena", CountryGB, "STHL 1ZZ", true},
+		{"Tristan da Cunha", CountryGB, "TDCU 1ZZ", true},
+		{"Turks and Caicos Islands", CountryGB, "TKCA 1ZZ", true},
+		{"not a special postcode", CountryGB, "GIR 1AA", false},
+
+		// Irish Eircodes
+		{"Eircode", "IE", "D02 X285", true},
+		{"Eircode of Dublin 6W", "IE", "D6W FX25", true},
+		{"Eircode in lower case", "IE", "a65f4e2", true},
+		{"Eircode routing key of an unused letter", "IE", "B12 3456", false},
+		{"Eircode routing key like Dublin 6W", "IE", "D6X 1234", false},
+		{"Eircode with an O", "IE", "D02 XO85", false},
+		{"Eircode too short", "IE", "D02 X28", false},
+
+		// Dutch postcodes
+		{"Dutch postcode", "NL", "1012 AB", true},
+		{"Dutch postcode with SB", "NL", "1012 SB", true},
+		{"Dutch postcode starting with 0", "NL", "0123 AB", false},
+		{"Dutch postcode with SA", "NL", "1012 SA", false},
+		{"Dutch postcode with SD", "NL", "1012 SD", false},
+		{"Dutch postcode with SS", "NL", "1012 SS", false},
+		{"Dutch postcode with one letter", "NL", "1012 A", false},
+
+		{"unsupported country", "FR", "75001", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ValidatePostalCode(tt.country, tt.code)
+			if valid := err == nil; valid != tt.valid {
+				t.Errorf("ValidatePostalCode(%q, %q) returned %v, want valid %v", tt.country, tt.code, err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestPostcodeString(t *testing.T) {
+	tests := []struct {
+		country string
+		code    string
+		want    string
+		compact string
+	}{
+		{CountryGB, "sw1a1aa", "SW1A 1AA", "SW1A1AA"},
+		{CountryGB, "m1 1ae", "M1 1AE", "M11AE"},
+		{CountryGB, "DN551PT", "DN55 1PT", "DN551PT"},
+		{CountryGB, "gir0aa", "GIR 0AA", "GIR0AA"},
+		{CountryGB, "ascn1zz", "ASCN 1ZZ", "ASCN1ZZ"},
+		{"IE", "d02x285", "D02 X285", "D02X285"},
+		{"IE", "D6W FX25", "D6W FX25", "D6WFX25"},
+		{"NL", " 1012ab ", "1012 AB", "1012AB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			p, err := NewPostalCode(tt.country, tt.code)
+			if err != nil {
+				t.Fatalf("NewPostalCode(%q, %q) returned an error: %v", tt.country, tt.code, err)
+			}
+			if p.String() != tt.want || p.StrVal != tt.compact || p.Country != tt.country {
+				t.Errorf("got %q, %q in %s, want %q, %q in %s", p.String(), p.StrVal, p.Country, tt.want, tt.compact, tt.country)
+			}
+		})
+	}
+}
+
+func TestParseCountry(t *testing.T) {
+	tests := []struct {
+		country string
+		want    string
+		valid   bool
+	}{
+		{"", CountryGB, true},
+		{"gb", CountryGB, true},
+		{"UK", CountryGB, true},
+		{" ie ", "IE", true},
+		{"NL", "NL", true},
+		{"FR", "", false},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseCountry(tt.country, CountryGB)
+		if (err == nil) != tt.valid || got != tt.want {
+			t.Errorf("ParseCountry(%q) returned %q, %v, want %q and valid %v", tt.country, got, err, tt.want, tt.valid)
+		}
+	}
+}
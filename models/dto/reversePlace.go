@@ -0,0 +1,46 @@
+package dto
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// ReversePlaceRequest holds the query parameters for finding the place at a point
+type ReversePlaceRequest struct {
+	Latitude  string `form:"lat"`
+	Longitude string `form:"lon"`
+	Save      string `form:"save"`
+}
+
+// ReversePlaceQuery holds the validated query for finding the place at a point
+// a place found upstream is stored when Save is set, so it has an id to be saved with
+type ReversePlaceQuery struct {
+	Latitude  float64
+	Longitude float64
+	Save      bool
+}
+
+// ToReversePlaceQuery validates the reverse place request and converts it to a ReversePlaceQuery
+func (r *ReversePlaceRequest) ToReversePlaceQuery() (ReversePlaceQuery, []error) {
+	var errs []error
+	var q ReversePlaceQuery
+
+	// validate the point to find the place at
+	if _, err := ParseLocation(r.Latitude, r.Longitude); err != nil {
+		errs = append(errs, err)
+	} else {
+		q.Latitude, _ = strconv.ParseFloat(r.Latitude, 64)
+		q.Longitude, _ = strconv.ParseFloat(r.Longitude, 64)
+	}
+
+	// validate the save flag
+	if r.Save != "" {
+		save, err := strconv.ParseBool(r.Save)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("save must be true or false"))
+		}
+		q.Save = save
+	}
+
+	return q, errs
+}
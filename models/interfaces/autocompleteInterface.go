@@ -0,0 +1,17 @@
+package interfaces
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+)
+
+// AutocompleteServiceInterface defines methods that are applicable to the place autocomplete service
+type AutocompleteServiceInterface interface {
+	Start(ctx context.Context)
+	Refresh(ctx context.Context) error
+	Suggest(ctx context.Context, userId primitive.ObjectID, query dto.AutocompleteQuery) ([]api.PlaceResponse, error)
+}
@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/leonardchinonso/lokate-go/models/api"
+)
+
+// BikeShareServiceInterface defines methods that are applicable to the GBFS bike share ingester
+type BikeShareServiceInterface interface {
+	Start(ctx context.Context)
+	Poll(ctx context.Context) error
+	AnnotatePlaces(places []api.PlaceResponse)
+}
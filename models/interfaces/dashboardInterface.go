@@ -0,0 +1,14 @@
+package interfaces
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/lokate-go/models/api"
+)
+
+// DashboardServiceInterface defines methods that are applicable to the dashboard service
+type DashboardServiceInterface interface {
+	Departures(ctx context.Context, userId primitive.ObjectID) (*api.DashboardResponse, error)
+}
@@ -0,0 +1,13 @@
+package interfaces
+
+import (
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+)
+
+// DepartureServiceInterface defines methods that are applicable to the departure service
+type DepartureServiceInterface interface {
+	PlaceDepartures(place *dao.Place) (*api.DepartureBoardResponse, error)
+	Departures(stop dto.DepartureStop) (*api.DepartureBoardResponse, error)
+}
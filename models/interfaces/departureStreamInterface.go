@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
+
+// DepartureStreamServiceInterface defines methods that are applicable to the departure stream service
+type DepartureStreamServiceInterface interface {
+	Subscribe(place *dao.Place) (<-chan api.DepartureStreamEvent, func(), error)
+	Close()
+}
@@ -0,0 +1,23 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
+
+// GTFSRepositoryInterface defines methods that are applicable to the GTFS repository
+type GTFSRepositoryInterface interface {
+	FindFeedInfo(ctx context.Context, info *dao.GTFSFeedInfo) (bool, error)
+	FindFeedInfos(ctx context.Context, infos *[]dao.GTFSFeedInfo) error
+	ReplaceFeed(ctx context.Context, feed *dao.GTFSFeed) error
+	LoadFeed(ctx context.Context, feed *dao.GTFSFeed) error
+	EnsureIndexes(ctx context.Context) error
+}
+
+// GTFSServiceInterface is the transit provider backed by the imported GTFS feeds
+type GTFSServiceInterface interface {
+	TransitServiceInterface
+	ImportFeed(ctx context.Context, feedId, path string) error
+	LoadFeeds(ctx context.Context) error
+}
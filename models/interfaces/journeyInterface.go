@@ -0,0 +1,16 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+)
+
+// JourneyServiceInterface defines methods that are applicable to the journey service
+type JourneyServiceInterface interface {
+	ResolveEndpoint(ctx context.Context, user *dao.User, endpoint dto.JourneyEndpoint) (dto.JourneyPoint, error)
+	PlanJourney(ctx context.Context, user *dao.User, from dto.JourneyEndpoint, to dto.JourneyEndpoint, opts dto.JourneyOptions) (*api.JourneyResponse, error)
+	RankRoutes(journey *api.JourneyResponse, opts dto.JourneyOptions)
+}
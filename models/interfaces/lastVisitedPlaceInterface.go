@@ -2,6 +2,7 @@ package interfaces
 
 import (
 	"context"
+	"time"
 
 	"github.com/leonardchinonso/lokate-go/models/dao"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -11,6 +12,9 @@ import (
 type LastVisitedPlaceRepositoryInterface interface {
 	Create(ctx context.Context, lastVisitedPlace *dao.LastVisitedPlace) error
 	FindLastNVisitedPlaces(ctx context.Context, UserId primitive.ObjectID, lastVisitedPlace *[]dao.LastVisitedPlace, N int64) (bool, error)
+	RepointPlace(ctx context.Context, oldIds []primitive.ObjectID, newId primitive.ObjectID) (int64, error)
+	CountByPlaceID(ctx context.Context, placeId primitive.ObjectID) (int64, error)
+	CountByPlace(ctx context.Context, from, to time.Time) (map[primitive.ObjectID]int64, error)
 }
 
 // LastVisitedPlaceServiceInterface holds the methods for accessing the last visited place service
@@ -0,0 +1,11 @@
+package interfaces
+
+import (
+	"context"
+)
+
+// MigrationRepositoryInterface defines the methods for the repository of the versions of the one-off migrations
+type MigrationRepositoryInterface interface {
+	Version(ctx context.Context, collection string) (int, error)
+	SetVersion(ctx context.Context, collection string, version int) error
+}
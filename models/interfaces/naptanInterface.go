@@ -0,0 +1,12 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/leonardchinonso/lokate-go/models/api"
+)
+
+// NaptanServiceInterface defines methods that are applicable to the NaPTAN import service
+type NaptanServiceInterface interface {
+	Import(ctx context.Context, path string, dryRun bool) (*api.NaptanImportResponse, error)
+}
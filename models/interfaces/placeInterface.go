@@ -2,21 +2,63 @@ package interfaces
 
 import (
 	"context"
+	"time"
 
+	"github.com/leonardchinonso/lokate-go/models/api"
 	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
 // PlaceRepositoryInterface defines methods that are applicable to the place repository
 type PlaceRepositoryInterface interface {
 	Create(ctx context.Context, place *dao.Place) error
+	Update(ctx context.Context, place *dao.Place) error
 	FindByID(ctx context.Context, place *dao.Place) (bool, error)
 	FindByKey(ctx context.Context, place *dao.Place) (bool, error)
+	FindByKeys(ctx context.Context, keys []string, places *[]dao.Place) error
+	FindByIDs(ctx context.Context, ids []primitive.ObjectID, places *[]dao.Place) error
+	FindAllForDuplicates(ctx context.Context, places *[]dao.Place) error
+	FindUpdatedSince(ctx context.Context, since time.Time, places *[]dao.Place) error
+	DeleteByIDs(ctx context.Context, ids []primitive.ObjectID) (int64, error)
+	FindSameKeyPlaces(ctx context.Context) (map[string][]primitive.ObjectID, error)
+	UnsetKeys(ctx context.Context, ids []primitive.ObjectID) (int64, error)
+	Rekey(ctx context.Context, key func(place *dao.Place) string) (int64, error)
+	Reindex(ctx context.Context) (int64, error)
+	SearchText(ctx context.Context, text string, limit int, places *[]dao.Place) error
+	SearchPrefix(ctx context.Context, prefix string, limit int, places *[]dao.Place) error
+	SearchGrams(ctx context.Context, grams []string, minShared, limit int, places *[]dao.Place) error
+	UpsertByKey(ctx context.Context, places []dao.Place) error
+	ImportByKey(ctx context.Context, places []dao.Place) (int64, int64, error)
+	FindIDsBySource(ctx context.Context, source string) (map[string]primitive.ObjectID, error)
 	PopulatePlacesInLastVisited(ctx context.Context, lastVisitedPlaces *[]dao.LastVisitedPlace) (bool, error)
 	PopulatePlacesInSavedPlaces(ctx context.Context, savedPlaces *[]dao.SavedPlace) (bool, error)
+	EnsureIndexes(ctx context.Context) error
+	BackfillLocations(ctx context.Context) (int64, error)
+	FindNearby(ctx context.Context, query dto.NearbyPlacesQuery, places *[]dao.Place) error
 }
 
 // PlaceServiceInterface defines methods that are applicable to the place service
 type PlaceServiceInterface interface {
 	Create(ctx context.Context, place *dao.Place) error
 	GetPlace(ctx context.Context, place *dao.Place) error
+	DescribePlace(place *dao.Place) *api.PlaceResponse
+	SearchPlaces(ctx context.Context, userId primitive.ObjectID, query dto.PlaceSearchQuery) ([]api.PlaceResponse, error)
+	GetNearbyPlaces(ctx context.Context, query dto.NearbyPlacesQuery) (*api.NearbyPlacesResponse, error)
+	ReversePlace(ctx context.Context, query dto.ReversePlaceQuery) (*api.PlaceResponse, error)
+	PersistPlaces(ctx context.Context, places []dao.Place) error
+	DuplicateMetres() int
+	FindDuplicatePlaces(ctx context.Context, radius int) (*api.DuplicatePlacesResponse, error)
+	MergePlaces(ctx context.Context, placeId primitive.ObjectID, duplicateIds []primitive.ObjectID) (*api.MergePlacesResponse, error)
+	MergeSameKeyPlaces(ctx context.Context) (int64, error)
+	UpdatePlace(ctx context.Context, placeId primitive.ObjectID, req dto.UpdatePlaceRequest) (*api.PlaceResponse, error)
+	DeletePlace(ctx context.Context, placeId primitive.ObjectID) error
+	ImportPlaces(ctx context.Context, rows []dto.PlaceImportRow, dryRun bool) (*api.PlaceImportResponse, error)
+}
+
+// PlaceWriterInterface defines methods that are applicable to the background writer of the places found by the searches
+type PlaceWriterInterface interface {
+	Write(ctx context.Context, places []dao.Place) error
+	Wait(ctx context.Context, id primitive.ObjectID) error
+	Close(ctx context.Context) error
 }
@@ -0,0 +1,13 @@
+package interfaces
+
+import (
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+)
+
+// PostcodeServiceInterface defines methods that are applicable to the postcode service
+type PostcodeServiceInterface interface {
+	Geocode(postcode dto.Postcode) (*api.PostcodeResponse, error)
+	GeocodeBatch(country string, inputs []dto.PostcodeBatchRow) (*api.PostcodeBatchResponse, error)
+	JourneyPoint(postcode dto.Postcode) (dto.JourneyPoint, error)
+}
@@ -0,0 +1,15 @@
+package interfaces
+
+import (
+	"context"
+
+	"github.com/leonardchinonso/lokate-go/models/api"
+)
+
+// RealtimeServiceInterface defines methods that are applicable to the GTFS-Realtime ingester
+type RealtimeServiceInterface interface {
+	Start(ctx context.Context)
+	Poll() error
+	AnnotateJourney(journey *api.JourneyResponse)
+	AnnotateDepartures(board *api.DepartureBoardResponse)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"github.com/leonardchinonso/lokate-go/models/dao"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"time"
 )
 
 // SavedPlaceRepositoryInterface defines methods that are applicable to the savedPlace repository
@@ -12,10 +13,19 @@ type SavedPlaceRepositoryInterface interface {
 	FindByID(ctx context.Context, savedPlace *dao.SavedPlace) (bool, error)
 	FindOneByIDAndUserID(ctx context.Context, savedPlace *dao.SavedPlace) (bool, error)
 	FindOneByPlaceIDAndUserID(ctx context.Context, savedPlace *dao.SavedPlace) (bool, error)
+	FindOneByAliasAndUserID(ctx context.Context, savedPlace *dao.SavedPlace) (bool, error)
 	Find(ctx context.Context, userId primitive.ObjectID, savedPlaces *[]dao.SavedPlace) (bool, error)
+	FindPinned(ctx context.Context, userId primitive.ObjectID, savedPlaces *[]dao.SavedPlace) error
+	CountByPlaceID(ctx context.Context, placeId primitive.ObjectID) (int64, error)
+	CountByPlace(ctx context.Context, from, to time.Time) (map[primitive.ObjectID]int64, error)
+	EnsureIndexes(ctx context.Context) error
+	Pin(ctx context.Context, savedPlace *dao.SavedPlace, maxPinned int) (bool, error)
+	Unpin(ctx context.Context, savedPlace *dao.SavedPlace) error
 	Update(ctx context.Context, savedPlace *dao.SavedPlace) error
 	SetAlias(ctx context.Context, savedPlace *dao.SavedPlace, newAlias dao.PlaceAlias) error
 	Delete(ctx context.Context, savedPlace *dao.SavedPlace) error
+	FindByPlaceIDs(ctx context.Context, placeIds []primitive.ObjectID, savedPlaces *[]dao.SavedPlace) error
+	Merge(ctx context.Context, kept *dao.SavedPlace, removedIds []primitive.ObjectID) error
 }
 
 // SavedPlaceServiceInterface defines methods that are applicable to the savedPlace service
@@ -25,4 +35,5 @@ type SavedPlaceServiceInterface interface {
 	GetSavedPlaces(ctx context.Context, userId primitive.ObjectID, savedPlaces *[]dao.SavedPlace) error
 	EditSavedPlace(ctx context.Context, savedPlace *dao.SavedPlace) error
 	DeleteSavedPlace(ctx context.Context, savedPlace *dao.SavedPlace) error
+	PinSavedPlace(ctx context.Context, savedPlace *dao.SavedPlace, pinned bool) error
 }
@@ -0,0 +1,17 @@
+package interfaces
+
+import (
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+)
+
+// TransitServiceInterface is the provider neutral interface for transit data
+// it is implemented by every transit provider adapter and by the provider fallback chain
+type TransitServiceInterface interface {
+	Name() string
+	SearchPlace(searchStr string, places *[]dao.Place) ([]api.PlaceResponse, error)
+	NearestPlaces(query dto.NearestPlacesQuery, places *[]dao.Place) ([]api.PlaceResponse, error)
+	PublicJourney(from dto.JourneyPoint, to dto.JourneyPoint, opts dto.JourneyOptions) (*api.JourneyResponse, error)
+	Departures(stop dto.DepartureStop) (*api.DepartureBoardResponse, error)
+}
@@ -0,0 +1,224 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+)
+
+const (
+	// gtfsInsertBatchSize is the number of documents inserted at once when storing a feed
+	gtfsInsertBatchSize = 10000
+	// gtfsDataIdSeparator separates the feed id from the import id in the id the data of an import is stored under
+	gtfsDataIdSeparator = "@"
+)
+
+type gtfsRepo struct {
+	feeds         *mongo.Collection
+	stops         *mongo.Collection
+	routes        *mongo.Collection
+	trips         *mongo.Collection
+	stopTimes     *mongo.Collection
+	calendars     *mongo.Collection
+	calendarDates *mongo.Collection
+	transfers     *mongo.Collection
+}
+
+// NewGTFSRepository returns a GTFS interface with all the model repository methods
+func NewGTFSRepository(db *mongo.Database) interfaces.GTFSRepositoryInterface {
+	return &gtfsRepo{
+		feeds:         db.Collection("gtfs_feeds"),
+		stops:         db.Collection("gtfs_stops"),
+		routes:        db.Collection("gtfs_routes"),
+		trips:         db.Collection("gtfs_trips"),
+		stopTimes:     db.Collection("gtfs_stop_times"),
+		calendars:     db.Collection("gtfs_calendars"),
+		calendarDates: db.Collection("gtfs_calendar_dates"),
+		transfers:     db.Collection("gtfs_transfers"),
+	}
+}
+
+// FindFeedInfo finds the record of an imported feed by the feed id
+func (g *gtfsRepo) FindFeedInfo(ctx context.Context, info *dao.GTFSFeedInfo) (bool, error) {
+	err := g.feeds.FindOne(ctx, bson.M{"_id": info.FeedId}).Decode(info)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to find gtfs feed: %w", err)
+	}
+	return true, nil
+}
+
+// FindFeedInfos finds the records of all the imported feeds
+func (g *gtfsRepo) FindFeedInfos(ctx context.Context, infos *[]dao.GTFSFeedInfo) error {
+	return findAll(ctx, g.feeds, bson.M{}, infos)
+}
+
+// EnsureIndexes creates the indexes on the feed id of the feed data, which every load and every
+// delete of a feed filters by
+func (g *gtfsRepo) EnsureIndexes(ctx context.Context) error {
+	for _, c := range g.dataCollections() {
+		if _, err := c.Indexes().CreateOne(ctx, mongo.IndexModel{Keys: bson.M{"feed_id": 1}}); err != nil {
+			return fmt.Errorf("failed to create %s indexes: %v", c.Name(), err)
+		}
+	}
+	return nil
+}
+
+// dataCollections returns the collections that hold the data of the feeds
+func (g *gtfsRepo) dataCollections() []*mongo.Collection {
+	return []*mongo.Collection{g.stops, g.routes, g.trips, g.stopTimes, g.calendars, g.calendarDates, g.transfers}
+}
+
+// ReplaceFeed replaces all the stored data of a feed with the new feed. The new data is stored
+// under a staging id and the feed record is only pointed at it once every insert succeeded, so
+// a failed import keeps the data imported before. The data of the replaced import is deleted last
+func (g *gtfsRepo) ReplaceFeed(ctx context.Context, feed *dao.GTFSFeed) error {
+	feedId := feed.Info.FeedId
+	dataId := feedId + gtfsDataIdSeparator + primitive.NewObjectID().Hex()
+
+	previous := dao.GTFSFeedInfo{FeedId: feedId}
+	exists, err := g.FindFeedInfo(ctx, &previous)
+	if err != nil {
+		return err
+	}
+
+	// the data of the imports that failed before their swap is not used by any feed record
+	if err := g.deleteStagedData(ctx, feedId, previous.DataFeedId()); err != nil {
+		return err
+	}
+
+	collections := []struct {
+		c    *mongo.Collection
+		docs []interface{}
+	}{
+		{g.stops, toDocuments(feed.Stops, func(s *dao.GTFSStop) { s.FeedId = dataId })},
+		{g.routes, toDocuments(feed.Routes, func(r *dao.GTFSRoute) { r.FeedId = dataId })},
+		{g.trips, toDocuments(feed.Trips, func(t *dao.GTFSTrip) { t.FeedId = dataId })},
+		{g.stopTimes, toDocuments(feed.StopTimes, func(st *dao.GTFSStopTime) { st.FeedId = dataId })},
+		{g.calendars, toDocuments(feed.Calendars, func(c *dao.GTFSCalendar) { c.FeedId = dataId })},
+		{g.calendarDates, toDocuments(feed.CalendarDates, func(cd *dao.GTFSCalendarDate) { cd.FeedId = dataId })},
+		{g.transfers, toDocuments(feed.Transfers, func(t *dao.GTFSTransfer) { t.FeedId = dataId })},
+	}
+
+	for _, col := range collections {
+		for start := 0; start < len(col.docs); start += gtfsInsertBatchSize {
+			end := start + gtfsInsertBatchSize
+			if end > len(col.docs) {
+				end = len(col.docs)
+			}
+			if _, err := col.c.InsertMany(ctx, col.docs[start:end], options.InsertMany().SetOrdered(false)); err != nil {
+				g.deleteData(ctx, dataId)
+				return fmt.Errorf("failed to insert gtfs %s: %v", col.c.Name(), err)
+			}
+		}
+	}
+
+	// swap the new data in, a single document write either happens or does not
+	info := feed.Info
+	info.DataId = dataId
+	if _, err := g.feeds.ReplaceOne(ctx, bson.M{"_id": feedId}, info, options.Replace().SetUpsert(true)); err != nil {
+		g.deleteData(ctx, dataId)
+		return fmt.Errorf("failed to write gtfs feed: %v", err)
+	}
+
+	if exists {
+		g.deleteData(ctx, previous.DataFeedId())
+	}
+	return nil
+}
+
+// deleteStagedData deletes the staged data of a feed that is not the data in use
+func (g *gtfsRepo) deleteStagedData(ctx context.Context, feedId, inUse string) error {
+	filter := bson.M{
+		"feed_id": bson.M{
+			"$regex": "^" + regexp.QuoteMeta(feedId+gtfsDataIdSeparator),
+			"$ne":    inUse,
+		},
+	}
+	for _, c := range g.dataCollections() {
+		if _, err := c.DeleteMany(ctx, filter); err != nil {
+			return fmt.Errorf("failed to delete staged gtfs %s: %v", c.Name(), err)
+		}
+	}
+	return nil
+}
+
+// deleteData deletes the data stored under a feed id. The data is no longer used by the time it
+// is deleted, so a failure is only logged and the data is deleted by the next import of the feed
+func (g *gtfsRepo) deleteData(ctx context.Context, dataId string) {
+	for _, c := range g.dataCollections() {
+		if _, err := c.DeleteMany(ctx, bson.M{"feed_id": dataId}); err != nil {
+			log.Printf("Failed to delete gtfs %s of %s. Error: %v\n", c.Name(), dataId, err)
+		}
+	}
+}
+
+// LoadFeed loads all the stored data of a feed
+func (g *gtfsRepo) LoadFeed(ctx context.Context, feed *dao.GTFSFeed) error {
+	exists, err := g.FindFeedInfo(ctx, &feed.Info)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("gtfs feed %s not found", feed.Info.FeedId)
+	}
+
+	filter := bson.M{"feed_id": feed.Info.DataFeedId()}
+	if err := findAll(ctx, g.stops, filter, &feed.Stops); err != nil {
+		return err
+	}
+	if err := findAll(ctx, g.routes, filter, &feed.Routes); err != nil {
+		return err
+	}
+	if err := findAll(ctx, g.trips, filter, &feed.Trips); err != nil {
+		return err
+	}
+	if err := findAll(ctx, g.stopTimes, filter, &feed.StopTimes); err != nil {
+		return err
+	}
+	if err := findAll(ctx, g.calendars, filter, &feed.Calendars); err != nil {
+		return err
+	}
+	if err := findAll(ctx, g.calendarDates, filter, &feed.CalendarDates); err != nil {
+		return err
+	}
+	return findAll(ctx, g.transfers, filter, &feed.Transfers)
+}
+
+// findAll decodes all the documents matching a filter into results
+func findAll(ctx context.Context, c *mongo.Collection, filter primitive.M, results interface{}) error {
+	cursor, err := c.Find(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("failed to find %s: %v", c.Name(), err)
+	}
+
+	if err = cursor.All(ctx, results); err != nil {
+		return fmt.Errorf("failed to decode %s: %v", c.Name(), err)
+	}
+
+	return nil
+}
+
+// toDocuments converts a slice of documents to the slice type taken by InsertMany, with the
+// feed id of each copy set to the id the data is stored under
+func toDocuments[T any](items []T, setFeedId func(item *T)) []interface{} {
+	docs := make([]interface{}, len(items))
+	for i := range items {
+		item := items[i]
+		setFeedId(&item)
+		docs[i] = item
+	}
+	return docs
+}
@@ -10,6 +10,7 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
 )
 
 type lastVisitedPlaceRepo struct {
@@ -113,3 +114,53 @@ func (l *lastVisitedPlaceRepo) FindLastNVisitedPlacesWithAggregate(ctx context.C
 
 	return true, nil
 }
+
+// RepointPlace moves the visits to the places with the old ids to the place with the new id
+func (l *lastVisitedPlaceRepo) RepointPlace(ctx context.Context, oldIds []primitive.ObjectID, newId primitive.ObjectID) (int64, error) {
+	res, err := l.c.UpdateMany(ctx, bson.M{"place_id": bson.M{"$in": oldIds}}, bson.M{"$set": bson.M{"place_id": newId}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to update last visited places: %v", err)
+	}
+	return res.ModifiedCount, nil
+}
+
+// CountByPlaceID counts the visits to a place
+func (l *lastVisitedPlaceRepo) CountByPlaceID(ctx context.Context, placeId primitive.ObjectID) (int64, error) {
+	count, err := l.c.CountDocuments(ctx, bson.M{"place_id": placeId})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count last visited places: %v", err)
+	}
+	return count, nil
+}
+
+// CountByPlace counts the visits of every place by the place id that were made from a time until
+// before another, all the visits before the second time are counted when the first is zero
+func (l *lastVisitedPlaceRepo) CountByPlace(ctx context.Context, from, to time.Time) (map[primitive.ObjectID]int64, error) {
+	created := bson.M{"$lt": to}
+	if !from.IsZero() {
+		created["$gte"] = from
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"created_at": created}}},
+		{{Key: "$group", Value: bson.M{"_id": "$place_id", "count": bson.M{"$sum": 1}}}},
+	}
+
+	cursor, err := l.c.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count last visited places: %v", err)
+	}
+
+	var rows []struct {
+		PlaceId primitive.ObjectID `bson:"_id"`
+		Count   int64              `bson:"count"`
+	}
+	if err = cursor.All(ctx, &rows); err != nil {
+		return nil, fmt.Errorf("failed to decode last visited places counts: %v", err)
+	}
+
+	counts := make(map[primitive.ObjectID]int64, len(rows))
+	for _, row := range rows {
+		counts[row.PlaceId] = row.Count
+	}
+	return counts, nil
+}
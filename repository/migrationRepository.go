@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+)
+
+// migrationRepo holds the collection of the versions of the one-off migrations of the data
+type migrationRepo struct {
+	c *mongo.Collection
+}
+
+const migrationCollectionName = "migrations"
+
+// NewMigrationRepository returns an interface for the migration repository methods
+func NewMigrationRepository(db *mongo.Database) interfaces.MigrationRepositoryInterface {
+	return &migrationRepo{
+		c: db.Collection(migrationCollectionName),
+	}
+}
+
+// Version returns the number of the migrations run on the data of a collection
+func (m *migrationRepo) Version(ctx context.Context, collection string) (int, error) {
+	var doc struct {
+		Version int `bson:"version"`
+	}
+	err := m.c.FindOne(ctx, bson.M{"_id": collection}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to find the migration version of %s: %v", collection, err)
+	}
+	return doc.Version, nil
+}
+
+// SetVersion records the number of the migrations run on the data of a collection
+func (m *migrationRepo) SetVersion(ctx context.Context, collection string, version int) error {
+	_, err := m.c.UpdateOne(ctx,
+		bson.M{"_id": collection},
+		bson.M{"$set": bson.M{"version": version}},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set the migration version of %s: %v", collection, err)
+	}
+	return nil
+}
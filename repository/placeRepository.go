@@ -5,12 +5,31 @@ import (
 	"errors"
 	"fmt"
 	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/dto"
 	"github.com/leonardchinonso/lokate-go/models/interfaces"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"regexp"
+	"sort"
+	"time"
 )
 
+// placeRekeyBatchSize is the number of key updates written at once when the places are rekeyed
+const placeRekeyBatchSize = 1000
+
+// placeTextNameWeight is how much more a word of the name of a place counts in the text search than
+// a word of its description
+const placeTextNameWeight = 10
+
+// placeGramCountLimit is the number of places with a trigram from which the trigram counts as
+// common, the places with a trigram are only counted up to it
+const placeGramCountLimit = 10000
+
+// placeGramScanLimit is the most places a trigram search reads from the index before it scores them
+const placeGramScanLimit = 5000
+
 type placeRepo struct {
 	c *mongo.Collection
 }
@@ -37,10 +56,26 @@ func (p *placeRepo) findByQuery(ctx context.Context, filter primitive.M, place *
 
 // Create creates a new place document in the database
 func (p *placeRepo) Create(ctx context.Context, place *dao.Place) error {
-	_, err := p.c.InsertOne(ctx, place)
+	place.SetSearchFields()
+	now := time.Now()
+	place.UpdatedAt = &now
+	result, err := p.c.InsertOne(ctx, place)
 	if err != nil {
 		return err
 	}
+	place.Id = result.InsertedID.(primitive.ObjectID)
+	return nil
+}
+
+// Update replaces a place by id in the database
+func (p *placeRepo) Update(ctx context.Context, place *dao.Place) error {
+	place.SetSearchFields()
+	now := time.Now()
+	place.UpdatedAt = &now
+	_, err := p.c.ReplaceOne(ctx, bson.M{"_id": place.Id}, place)
+	if err != nil {
+		return fmt.Errorf("failed to update place: %v", err)
+	}
 	return nil
 }
 
@@ -81,3 +116,558 @@ func (p *placeRepo) PopulatePlacesInSavedPlaces(ctx context.Context, savedPlaces
 	}
 	return true, nil
 }
+
+// EnsureIndexes creates the geospatial index on the place locations, the indexes on the place
+// keys and sources and the indexes the local search uses if they do not exist. The keys are
+// unique, so the places with the same key must be merged first
+func (p *placeRepo) EnsureIndexes(ctx context.Context) error {
+	if err := p.dropNonUniqueKeyIndex(ctx); err != nil {
+		return err
+	}
+
+	_, err := p.c.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.M{"location": "2dsphere"}},
+		// the places whose key was taken away when they could not be merged have none
+		{
+			Keys: bson.M{"key": 1},
+			Options: options.Index().SetUnique(true).
+				SetPartialFilterExpression(bson.M{"key": bson.M{"$exists": true}}),
+		},
+		{Keys: bson.M{"source": 1}},
+		{Keys: bson.M{"search_name": 1}},
+		{Keys: bson.M{"search_grams": 1}},
+		{Keys: bson.M{"updated_at": 1}},
+		// a match on the name counts more than a match on the description
+		{
+			Keys:    bson.D{{Key: "name", Value: "text"}, {Key: "description", Value: "text"}},
+			Options: options.Index().SetWeights(bson.M{"name": placeTextNameWeight, "description": 1}),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create places indexes: %v", err)
+	}
+	return nil
+}
+
+// dropNonUniqueKeyIndex drops the index on the place keys when it is the one from before the keys
+// were unique, which has the same name as the unique one
+func (p *placeRepo) dropNonUniqueKeyIndex(ctx context.Context) error {
+	cursor, err := p.c.Indexes().List(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list places indexes: %v", err)
+	}
+
+	var indexes []struct {
+		Name   string `bson:"name"`
+		Unique bool   `bson:"unique"`
+	}
+	if err = cursor.All(ctx, &indexes); err != nil {
+		return fmt.Errorf("failed to decode places indexes: %v", err)
+	}
+
+	for _, index := range indexes {
+		if index.Name == "key_1" && !index.Unique {
+			if _, err := p.c.Indexes().DropOne(ctx, index.Name); err != nil {
+				return fmt.Errorf("failed to drop the places key index: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// FindSameKeyPlaces finds the ids of the places that share their key with another place, by key
+func (p *placeRepo) FindSameKeyPlaces(ctx context.Context) (map[string][]primitive.ObjectID, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"key": bson.M{"$exists": true}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$key", "ids": bson.M{"$push": "$_id"}}}},
+		{{Key: "$match", Value: bson.M{"ids.1": bson.M{"$exists": true}}}},
+	}
+
+	cursor, err := p.c.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find places with the same key: %v", err)
+	}
+
+	var rows []struct {
+		Key string               `bson:"_id"`
+		Ids []primitive.ObjectID `bson:"ids"`
+	}
+	if err = cursor.All(ctx, &rows); err != nil {
+		return nil, fmt.Errorf("failed to decode places with the same key: %v", err)
+	}
+
+	groups := make(map[string][]primitive.ObjectID, len(rows))
+	for _, row := range rows {
+		groups[row.Key] = row.Ids
+	}
+	return groups, nil
+}
+
+// UnsetKeys takes the key away from the places with any of the ids, it returns the number of places updated
+func (p *placeRepo) UnsetKeys(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	res, err := p.c.UpdateMany(ctx,
+		bson.M{"_id": bson.M{"$in": ids}},
+		bson.M{"$unset": bson.M{"key": ""}, "$currentDate": bson.M{"updated_at": true}},
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to unset the keys of places: %v", err)
+	}
+	return res.ModifiedCount, nil
+}
+
+// FindByKeys finds the places with any of the keys in the database
+func (p *placeRepo) FindByKeys(ctx context.Context, keys []string, places *[]dao.Place) error {
+	cursor, err := p.c.Find(ctx, bson.M{"key": bson.M{"$in": keys}})
+	if err != nil {
+		return fmt.Errorf("failed to find places: %v", err)
+	}
+
+	if err = cursor.All(ctx, places); err != nil {
+		return fmt.Errorf("failed to decode places: %v", err)
+	}
+
+	return nil
+}
+
+// UpsertByKey stores the places whose keys are not stored yet in one bulk write
+// places that are stored already are left as they are
+func (p *placeRepo) UpsertByKey(ctx context.Context, places []dao.Place) error {
+	if len(places) == 0 {
+		return nil
+	}
+
+	models := make([]mongo.WriteModel, len(places))
+	now := time.Now()
+	for i := range places {
+		places[i].SetSearchFields()
+		places[i].UpdatedAt = &now
+		models[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"key": places[i].Key}).
+			SetUpdate(bson.M{"$setOnInsert": places[i]}).
+			SetUpsert(true)
+	}
+
+	_, err := p.c.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return fmt.Errorf("failed to upsert places: %v", err)
+	}
+	return nil
+}
+
+// BackfillLocations sets the location of the places stored before locations were added
+// from their latitude and longitude, it returns the number of places updated
+func (p *placeRepo) BackfillLocations(ctx context.Context) (int64, error) {
+	filter := bson.M{
+		"location":  bson.M{"$exists": false},
+		"latitude":  bson.M{"$type": "number"},
+		"longitude": bson.M{"$type": "number"},
+	}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"location": bson.M{
+				"type":        "Point",
+				"coordinates": bson.A{"$longitude", "$latitude"},
+			},
+			"updated_at": "$$NOW",
+		}}},
+	}
+
+	res, err := p.c.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, fmt.Errorf("failed to backfill place locations: %v", err)
+	}
+	return res.ModifiedCount, nil
+}
+
+// FindNearby finds a page of the places within the radius of a point ordered by distance
+// the distance of each place is set in metres, and the first place of the next page is found
+// after the page when there is one
+func (p *placeRepo) FindNearby(ctx context.Context, query dto.NearbyPlacesQuery, places *[]dao.Place) error {
+	filter := bson.M{}
+	if query.Type != "" {
+		filter["type"] = query.Type
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$geoNear", Value: bson.M{
+			"near":          dao.NewGeoPoint(query.Latitude, query.Longitude),
+			"distanceField": "distance",
+			"maxDistance":   query.Radius,
+			"query":         filter,
+			"spherical":     true,
+		}}},
+		{{Key: "$skip", Value: query.Skip()}},
+		{{Key: "$limit", Value: query.Limit()}},
+		// the distance is stored in whole metres
+		{{Key: "$set", Value: bson.M{"distance": bson.M{"$toInt": bson.M{"$round": bson.A{"$distance", 0}}}}}},
+	}
+
+	cursor, err := p.c.Aggregate(ctx, pipeline)
+	if err != nil {
+		return fmt.Errorf("failed to find nearby places: %v", err)
+	}
+
+	if err = cursor.All(ctx, places); err != nil {
+		return fmt.Errorf("failed to decode nearby places: %v", err)
+	}
+
+	return nil
+}
+
+// placeDuplicateFields are the fields of a place that tell whether it duplicates another place
+var placeDuplicateFields = bson.M{
+	"_id": 1, "key": 1, "name": 1, "latitude": 1, "longitude": 1,
+	"atcocode": 1, "station_code": 1, "tiploc_code": 1, "osm_id": 1, "otp_stop_id": 1, "gtfs_stop_id": 1,
+	"bike_station_id": 1,
+}
+
+// FindAllForDuplicates finds all the places in the database with only the fields that tell whether
+// a place duplicates another one, so the search fields of the places are not read
+func (p *placeRepo) FindAllForDuplicates(ctx context.Context, places *[]dao.Place) error {
+	cursor, err := p.c.Find(ctx, bson.M{}, options.Find().SetProjection(placeDuplicateFields))
+	if err != nil {
+		return fmt.Errorf("failed to find places: %v", err)
+	}
+
+	if err = cursor.All(ctx, places); err != nil {
+		return fmt.Errorf("failed to decode places: %v", err)
+	}
+
+	return nil
+}
+
+// FindByIDs finds the places with any of the ids in the database
+func (p *placeRepo) FindByIDs(ctx context.Context, ids []primitive.ObjectID, places *[]dao.Place) error {
+	return p.findMany(ctx, bson.M{"_id": bson.M{"$in": ids}}, places)
+}
+
+// findMany finds the documents that match a filter query
+func (p *placeRepo) findMany(ctx context.Context, filter primitive.M, places *[]dao.Place) error {
+	cursor, err := p.c.Find(ctx, filter)
+	if err != nil {
+		return fmt.Errorf("failed to find places: %v", err)
+	}
+
+	if err = cursor.All(ctx, places); err != nil {
+		return fmt.Errorf("failed to decode places: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteByIDs deletes the places with any of the ids from the database
+func (p *placeRepo) DeleteByIDs(ctx context.Context, ids []primitive.ObjectID) (int64, error) {
+	res, err := p.c.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete places: %v", err)
+	}
+	return res.DeletedCount, nil
+}
+
+// Rekey sets the key of every place whose key is not the one returned by the key function
+// it returns the number of places updated
+func (p *placeRepo) Rekey(ctx context.Context, key func(place *dao.Place) string) (int64, error) {
+	cursor, err := p.c.Find(ctx, bson.M{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to find places: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var updated int64
+	var models []mongo.WriteModel
+	write := func() error {
+		if len(models) == 0 {
+			return nil
+		}
+		res, err := p.c.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return fmt.Errorf("failed to rekey places: %v", err)
+		}
+		updated += res.ModifiedCount
+		models = models[:0]
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var place dao.Place
+		if err = cursor.Decode(&place); err != nil {
+			return updated, fmt.Errorf("failed to decode place: %v", err)
+		}
+
+		if k := key(&place); k != place.Key {
+			models = append(models, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": place.Id}).
+				SetUpdate(bson.M{"$set": bson.M{"key": k}}))
+		}
+
+		if len(models) == placeRekeyBatchSize {
+			if err = write(); err != nil {
+				return updated, err
+			}
+		}
+	}
+	if err = cursor.Err(); err != nil {
+		return updated, fmt.Errorf("failed to find places: %v", err)
+	}
+
+	return updated, write()
+}
+
+// Reindex sets the search fields of every place whose search fields are not up to date with its name
+// it returns the number of places updated
+func (p *placeRepo) Reindex(ctx context.Context) (int64, error) {
+	cursor, err := p.c.Find(ctx, bson.M{})
+	if err != nil {
+		return 0, fmt.Errorf("failed to find places: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	var updated int64
+	var models []mongo.WriteModel
+	write := func() error {
+		if len(models) == 0 {
+			return nil
+		}
+		res, err := p.c.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+		if err != nil {
+			return fmt.Errorf("failed to reindex places: %v", err)
+		}
+		updated += res.ModifiedCount
+		models = models[:0]
+		return nil
+	}
+
+	for cursor.Next(ctx) {
+		var place dao.Place
+		if err = cursor.Decode(&place); err != nil {
+			return updated, fmt.Errorf("failed to decode place: %v", err)
+		}
+
+		if place.SetSearchFields() {
+			models = append(models, mongo.NewUpdateOneModel().
+				SetFilter(bson.M{"_id": place.Id}).
+				SetUpdate(bson.M{"$set": bson.M{"search_name": place.SearchName, "search_grams": place.SearchGrams}}))
+		}
+
+		if len(models) == placeRekeyBatchSize {
+			if err = write(); err != nil {
+				return updated, err
+			}
+		}
+	}
+	if err = cursor.Err(); err != nil {
+		return updated, fmt.Errorf("failed to find places: %v", err)
+	}
+
+	return updated, write()
+}
+
+// FindUpdatedSince finds the places written since a time, or all the places when the time is zero
+// the search trigrams are left out since they are only needed by the local search
+func (p *placeRepo) FindUpdatedSince(ctx context.Context, since time.Time, places *[]dao.Place) error {
+	filter := bson.M{}
+	if !since.IsZero() {
+		filter["updated_at"] = bson.M{"$gte": since}
+	}
+
+	cursor, err := p.c.Find(ctx, filter, options.Find().SetProjection(bson.M{"search_grams": 0}))
+	if err != nil {
+		return fmt.Errorf("failed to find places: %v", err)
+	}
+
+	if err = cursor.All(ctx, places); err != nil {
+		return fmt.Errorf("failed to decode places: %v", err)
+	}
+
+	return nil
+}
+
+// SearchText finds the places whose name or description have the words of a text, the best
+// matches first
+func (p *placeRepo) SearchText(ctx context.Context, text string, limit int, places *[]dao.Place) error {
+	opts := options.Find().
+		SetProjection(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		SetSort(bson.M{"score": bson.M{"$meta": "textScore"}}).
+		SetLimit(int64(limit))
+
+	cursor, err := p.c.Find(ctx, bson.M{"$text": bson.M{"$search": text}}, opts)
+	if err != nil {
+		return fmt.Errorf("failed to search places: %v", err)
+	}
+
+	if err = cursor.All(ctx, places); err != nil {
+		return fmt.Errorf("failed to decode places: %v", err)
+	}
+
+	return nil
+}
+
+// SearchPrefix finds the places whose normalized name starts with a normalized prefix, the shortest names first
+func (p *placeRepo) SearchPrefix(ctx context.Context, prefix string, limit int, places *[]dao.Place) error {
+	pipeline := mongo.Pipeline{
+		// an anchored regex on the indexed name only scans the names with the prefix
+		{{Key: "$match", Value: bson.M{"search_name": bson.M{"$regex": "^" + regexp.QuoteMeta(prefix)}}}},
+		{{Key: "$set", Value: bson.M{"search_length": bson.M{"$strLenCP": "$search_name"}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "search_length", Value: 1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+	}
+
+	cursor, err := p.c.Aggregate(ctx, pipeline)
+	if err != nil {
+		return fmt.Errorf("failed to search places: %v", err)
+	}
+
+	if err = cursor.All(ctx, places); err != nil {
+		return fmt.Errorf("failed to decode places: %v", err)
+	}
+
+	return nil
+}
+
+// SearchGrams finds the places whose name shares the most trigrams with the trigrams of a text and
+// at least minShared of them, the names with a typo still share most of their trigrams with the text.
+// A name sharing minShared of n trigrams has one of any n-minShared+1 of them, so the index is only
+// read for the rarest of them and a few common trigrams do not match most of the places
+func (p *placeRepo) SearchGrams(ctx context.Context, grams []string, minShared, limit int, places *[]dao.Place) error {
+	if len(grams) == 0 {
+		return nil
+	}
+	if minShared < 1 {
+		minShared = 1
+	}
+	if minShared > len(grams) {
+		minShared = len(grams)
+	}
+
+	rare := grams
+	if minShared > 1 {
+		counts := make([]int64, len(grams))
+		for i, g := range grams {
+			count, err := p.c.CountDocuments(ctx, bson.M{"search_grams": g}, options.Count().SetLimit(placeGramCountLimit))
+			if err != nil {
+				return fmt.Errorf("failed to count places: %v", err)
+			}
+			counts[i] = count
+		}
+		rare = rarestGrams(grams, counts, len(grams)-minShared+1)
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"search_grams": bson.M{"$in": rare}}}},
+		{{Key: "$limit", Value: placeGramScanLimit}},
+		{{Key: "$set", Value: bson.M{"search_shared": bson.M{"$size": bson.M{"$setIntersection": bson.A{"$search_grams", grams}}}}}},
+		{{Key: "$match", Value: bson.M{"search_shared": bson.M{"$gte": minShared}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "search_shared", Value: -1}, {Key: "_id", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+	}
+
+	cursor, err := p.c.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return fmt.Errorf("failed to search places: %v", err)
+	}
+
+	if err = cursor.All(ctx, places); err != nil {
+		return fmt.Errorf("failed to decode places: %v", err)
+	}
+
+	return nil
+}
+
+// rarestGrams returns the n trigrams found in the fewest places, in the order of the trigrams
+func rarestGrams(grams []string, counts []int64, n int) []string {
+	order := make([]int, len(grams))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return counts[order[i]] < counts[order[j]] })
+
+	keep := make([]bool, len(grams))
+	for _, i := range order[:n] {
+		keep[i] = true
+	}
+	rare := make([]string, 0, n)
+	for i, g := range grams {
+		if keep[i] {
+			rare = append(rare, g)
+		}
+	}
+	return rare
+}
+
+// ImportByKey stores imported places by their key in one bulk write. A stored place takes the
+// fields of the imported place and keeps its id, the other places are stored with the id from their key.
+// It returns the number of places inserted and updated
+func (p *placeRepo) ImportByKey(ctx context.Context, places []dao.Place) (int64, int64, error) {
+	if len(places) == 0 {
+		return 0, 0, nil
+	}
+
+	now := time.Now()
+	models := make([]mongo.WriteModel, len(places))
+	for i := range places {
+		place := &places[i]
+		place.SetSearchFields()
+		place.UpdatedAt = &now
+		models[i] = mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"key": place.Key}).
+			SetUpdate(bson.M{
+				"$set": bson.M{
+					"type":         place.Type,
+					"name":         place.Name,
+					"latitude":     place.Latitude,
+					"longitude":    place.Longitude,
+					"location":     place.Location,
+					"description":  place.Description,
+					"atcocode":     place.ATCOCode,
+					"smscode":      place.SMSCode,
+					"source":       place.Source,
+					"search_name":  place.SearchName,
+					"search_grams": place.SearchGrams,
+					"updated_at":   place.UpdatedAt,
+				},
+				"$setOnInsert": bson.M{
+					"_id":          dao.PlaceIdFromKey(place.Key),
+					"accuracy":     place.Accuracy,
+					"osm_id":       place.OSMId,
+					"station_code": place.StationCode,
+					"tiploc_code":  place.TiplocCode,
+					"distance":     nil,
+					// the bike station and the OTP and GTFS stop ids are part of the key, so they never change for a stored place
+					"bike_station_id": place.BikeStationId,
+					"otp_stop_id":     place.OTPStopId,
+					"gtfs_stop_id":    place.GTFSStopId,
+				},
+			}).
+			SetUpsert(true)
+	}
+
+	res, err := p.c.BulkWrite(ctx, models, options.BulkWrite().SetOrdered(false))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to import places: %v", err)
+	}
+	return res.UpsertedCount, res.ModifiedCount, nil
+}
+
+// FindIDsBySource finds the ids of the places from a source by their key
+func (p *placeRepo) FindIDsBySource(ctx context.Context, source string) (map[string]primitive.ObjectID, error) {
+	opts := options.Find().SetProjection(bson.M{"_id": 1, "key": 1})
+	cursor, err := p.c.Find(ctx, bson.M{"source": source}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find places: %v", err)
+	}
+	defer cursor.Close(ctx)
+
+	ids := make(map[string]primitive.ObjectID)
+	for cursor.Next(ctx) {
+		var place dao.Place
+		if err = cursor.Decode(&place); err != nil {
+			return nil, fmt.Errorf("failed to decode place: %v", err)
+		}
+		ids[place.Key] = place.Id
+	}
+	if err = cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to find places: %v", err)
+	}
+
+	return ids, nil
+}
@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/utils"
+)
+
+// naptanSizedPlaces is about the number of stops in the NaPTAN
+const naptanSizedPlaces = 435000
+
+func TestRarestGrams(t *testing.T) {
+	grams := []string{" ch", "chu", "hur", "urc", "rch", "ch "}
+	counts := []int64{10000, 800, 900, 650, 10000, 10000}
+
+	got := rarestGrams(grams, counts, 4)
+	want := []string{" ch", "chu", "hur", "urc"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+// testDatabase returns a database of the server at LOKATE_TEST_MONGO_URI, the test is skipped
+// when it is not set
+func testDatabase(tb testing.TB, name string) *mongo.Database {
+	uri := os.Getenv("LOKATE_TEST_MONGO_URI")
+	if uri == "" {
+		tb.Skip("LOKATE_TEST_MONGO_URI is not set")
+	}
+
+	ctx := context.Background()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { _ = client.Disconnect(ctx) })
+	return client.Database(name)
+}
+
+// benchmarkPlaceNames builds the names of the stops of a NaPTAN sized collection from the words
+// stop names are made of, so the common trigrams are in about as many names as in the NaPTAN
+func benchmarkPlaceNames(n int) []string {
+	localities := []string{"Ashford", "Barnet", "Chester", "Dover", "Ealing", "Frome", "Gillingham", "Hove",
+		"Ilford", "Jarrow", "Kendal", "Luton", "Morley", "Newport", "Oldham", "Preston", "Redhill", "Stockport",
+		"Thame", "Uxbridge", "Walton", "Yeovil"}
+	streets := []string{"Church", "Station", "High", "Mill", "School", "Park", "Victoria", "Manor", "Green",
+		"Kings", "Queens", "New", "London", "Chapel", "Bridge", "Market", "Castle", "Westfield"}
+	kinds := []string{"Road", "Street", "Lane", "Avenue", "Close", "Way", "Square", "Terrace"}
+
+	names := make([]string, n)
+	for i := range names {
+		names[i] = fmt.Sprintf("%s %s %s %d (Stop %c)", localities[i%len(localities)], streets[i/7%len(streets)],
+			kinds[i/3%len(kinds)], i%97, 'A'+rune(i%26))
+	}
+	return names
+}
+
+// benchmarkPlaceRepo returns a place repository over a NaPTAN sized collection in the database at
+// LOKATE_TEST_MONGO_URI, the collection is only filled the first time
+func benchmarkPlaceRepo(b *testing.B) *placeRepo {
+	ctx := context.Background()
+	p := &placeRepo{c: testDatabase(b, "lokate_benchmark").Collection(placeCollectionName)}
+	count, err := p.c.CountDocuments(ctx, bson.M{})
+	if err != nil {
+		b.Fatal(err)
+	}
+	if count == naptanSizedPlaces {
+		return p
+	}
+
+	if err := p.c.Drop(ctx); err != nil {
+		b.Fatal(err)
+	}
+	if err := p.EnsureIndexes(ctx); err != nil {
+		b.Fatal(err)
+	}
+	var batch []interface{}
+	for i, name := range benchmarkPlaceNames(naptanSizedPlaces) {
+		lat, lon := 50+float64(i%500)/100, -5+float64(i%700)/100
+		place := dao.NewPlace("bus_stop", name, "", "", fmt.Sprintf("%09d", i), "", "", "", nil, &lat, &lon)
+		place.SetSearchFields()
+		batch = append(batch, place)
+		if len(batch) == 10000 {
+			if _, err := p.c.InsertMany(ctx, batch); err != nil {
+				b.Fatal(err)
+			}
+			batch = batch[:0]
+		}
+	}
+	if _, err := p.c.InsertMany(ctx, batch); err != nil {
+		b.Fatal(err)
+	}
+	return p
+}
+
+func BenchmarkSearchGrams(b *testing.B) {
+	p := benchmarkPlaceRepo(b)
+	ctx := context.Background()
+
+	searches := []struct {
+		name      string
+		text      string
+		minShared int
+	}{
+		{"common words", "church road", 3},
+		{"typo", "stockprt station", 4},
+		{"long word", "westfieldd", 3},
+		{"every trigram", "victoria", 1},
+	}
+
+	for _, s := range searches {
+		grams := utils.NameTrigrams(s.text)
+		b.Run(s.name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				var places []dao.Place
+				if err := p.SearchGrams(ctx, grams, s.minShared, 50, &places); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
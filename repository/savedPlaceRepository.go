@@ -9,6 +9,8 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"time"
 )
 
 type savedPlaceRepo struct {
@@ -60,6 +62,11 @@ func (p *savedPlaceRepo) FindOneByPlaceIDAndUserID(ctx context.Context, savedPla
 	return p.findOneByQuery(ctx, bson.M{"user_id": savedPlace.UserId, "place_id": savedPlace.PlaceId}, savedPlace)
 }
 
+// FindOneByAliasAndUserID finds a saved place by its alias and the user id
+func (p *savedPlaceRepo) FindOneByAliasAndUserID(ctx context.Context, savedPlace *dao.SavedPlace) (bool, error) {
+	return p.findOneByQuery(ctx, bson.M{"user_id": savedPlace.UserId, "place_alias": savedPlace.PlaceAlias}, savedPlace)
+}
+
 // Find finds all saved places by the userId in the database
 func (p *savedPlaceRepo) Find(ctx context.Context, userId primitive.ObjectID, savedPlaces *[]dao.SavedPlace) (bool, error) {
 	filter := bson.M{"user_id": userId}
@@ -79,6 +86,157 @@ func (p *savedPlaceRepo) Find(ctx context.Context, userId primitive.ObjectID, sa
 	return true, nil
 }
 
+// FindPinned finds all saved places the user pinned to the dashboard
+func (p *savedPlaceRepo) FindPinned(ctx context.Context, userId primitive.ObjectID, savedPlaces *[]dao.SavedPlace) error {
+	cursor, err := p.c.Find(ctx, bson.M{"user_id": userId, "pinned": true})
+	if err != nil {
+		return fmt.Errorf("failed to find pinned saved places: %v", err)
+	}
+
+	if err = cursor.All(ctx, savedPlaces); err != nil {
+		return fmt.Errorf("failed to find pinned saved places: %v", err)
+	}
+
+	return nil
+}
+
+// CountByPlaceID counts the saved places of a place
+func (p *savedPlaceRepo) CountByPlaceID(ctx context.Context, placeId primitive.ObjectID) (int64, error) {
+	count, err := p.c.CountDocuments(ctx, bson.M{"place_id": placeId})
+	if err != nil {
+		return 0, fmt.Errorf("failed to count saved places: %v", err)
+	}
+	return count, nil
+}
+
+// CountByPlace counts the saved places of every place by the place id that were saved from a time
+// until before another, to the second. All the places saved before the second time are counted
+// when the first is zero
+func (p *savedPlaceRepo) CountByPlace(ctx context.Context, from, to time.Time) (map[primitive.ObjectID]int64, error) {
+	created := bson.M{"$lt": primitive.Timestamp{T: uint32(to.Unix())}}
+	if !from.IsZero() {
+		created["$gte"] = primitive.Timestamp{T: uint32(from.Unix())}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"created_at": created}}},
+		{{Key: "$group", Value: bson.M{"_id": "$place_id", "count": bson.M{"$sum": 1}}}},
+	}
+
+	cursor, err := p.c.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count saved places: %v", err)
+	}
+
+	var rows []struct {
+		PlaceId primitive.ObjectID `bson:"_id"`
+		Count   int64              `bson:"count"`
+	}
+	if err = cursor.All(ctx, &rows); err != nil {
+		return nil, fmt.Errorf("failed to decode saved places counts: %v", err)
+	}
+
+	counts := make(map[primitive.ObjectID]int64, len(rows))
+	for _, row := range rows {
+		counts[row.PlaceId] = row.Count
+	}
+	return counts, nil
+}
+
+// EnsureIndexes creates the unique index on the pin slots of the users if it does not exist
+func (p *savedPlaceRepo) EnsureIndexes(ctx context.Context) error {
+	_, err := p.c.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "pin_slot", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"pin_slot": bson.M{"$exists": true}}),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create saved places indexes: %v", err)
+	}
+	return nil
+}
+
+// Pin pins a saved place to the dashboard in a free pin slot of the user. The unique index on the
+// user and the slot keeps concurrent pins from taking more than maxPinned slots, a pin that loses
+// a slot to another one looks for a free slot again. It returns false when every slot is taken
+// the places pinned before the slots existed take up a slot without holding a numbered one
+func (p *savedPlaceRepo) Pin(ctx context.Context, savedPlace *dao.SavedPlace, maxPinned int) (bool, error) {
+	for attempt := 0; attempt < maxPinned; attempt++ {
+		var pinned []dao.SavedPlace
+		if err := p.FindPinned(ctx, savedPlace.UserId, &pinned); err != nil {
+			return false, err
+		}
+
+		slot, isPinned, full := freePinSlot(pinned, savedPlace.Id, maxPinned)
+		if isPinned {
+			return true, nil
+		}
+		if full {
+			return false, nil
+		}
+
+		// the saved places from before the pinned field existed do not have it, so they are
+		// matched as not pinned rather than by a false value
+		res, err := p.c.UpdateOne(ctx,
+			bson.M{"_id": savedPlace.Id, "user_id": savedPlace.UserId, "pinned": bson.M{"$ne": true}},
+			bson.M{"$set": bson.M{"pinned": true, "pin_slot": slot, "updated_at": savedPlace.UpdatedAt}},
+		)
+		if mongo.IsDuplicateKeyError(err) {
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to pin saved place: %v", err)
+		}
+		if res.MatchedCount == 0 {
+			// another request pinned the saved place, which the next attempt finds, or deleted it
+			found, err := p.findOneByQuery(ctx, bson.M{"_id": savedPlace.Id, "user_id": savedPlace.UserId}, &dao.SavedPlace{})
+			if err != nil {
+				return false, err
+			}
+			if !found {
+				return false, fmt.Errorf("failed to pin saved place: saved place %v of user %v not found",
+					savedPlace.Id.Hex(), savedPlace.UserId.Hex())
+			}
+			continue
+		}
+		return true, nil
+	}
+	return false, fmt.Errorf("failed to pin saved place: the pin slots of user %v kept changing", savedPlace.UserId.Hex())
+}
+
+// freePinSlot returns the lowest pin slot none of the pinned saved places holds, whether the
+// saved place is one of them and whether every slot is taken. The places pinned before the slots
+// existed count towards the slots taken
+func freePinSlot(pinned []dao.SavedPlace, id primitive.ObjectID, maxPinned int) (int, bool, bool) {
+	taken := make(map[int]bool, len(pinned))
+	for _, sp := range pinned {
+		if sp.Id == id {
+			return 0, true, false
+		}
+		taken[sp.PinSlot] = true
+	}
+	if len(pinned) >= maxPinned {
+		return 0, false, true
+	}
+
+	slot := 1
+	for taken[slot] {
+		slot++
+	}
+	return slot, false, false
+}
+
+// Unpin unpins a saved place from the dashboard and frees its pin slot
+func (p *savedPlaceRepo) Unpin(ctx context.Context, savedPlace *dao.SavedPlace) error {
+	_, err := p.c.UpdateOne(ctx,
+		bson.M{"_id": savedPlace.Id, "user_id": savedPlace.UserId},
+		bson.M{
+			"$set":   bson.M{"pinned": false, "updated_at": savedPlace.UpdatedAt},
+			"$unset": bson.M{"pin_slot": ""},
+		},
+	)
+	return err
+}
+
 // updateByQuery updates a savedPlace by a specified query
 func (p *savedPlaceRepo) updateByQuery(ctx context.Context, filter primitive.D, update primitive.D) error {
 	_, err := p.c.UpdateOne(ctx, filter, update)
@@ -115,3 +273,46 @@ func (p *savedPlaceRepo) Delete(ctx context.Context, savedPlace *dao.SavedPlace)
 	}
 	return nil
 }
+
+// FindByPlaceIDs finds the saved places of any of the places by every user
+func (p *savedPlaceRepo) FindByPlaceIDs(ctx context.Context, placeIds []primitive.ObjectID, savedPlaces *[]dao.SavedPlace) error {
+	cursor, err := p.c.Find(ctx, bson.M{"place_id": bson.M{"$in": placeIds}})
+	if err != nil {
+		return fmt.Errorf("failed to find saved places: %v", err)
+	}
+
+	if err = cursor.All(ctx, savedPlaces); err != nil {
+		return fmt.Errorf("failed to decode saved places: %v", err)
+	}
+
+	return nil
+}
+
+// Merge deletes the saved places with the removed ids and sets the place, the alias and the pin of
+// the saved place that is kept. The removed saved places are deleted first, so the kept saved place
+// can take the alias and the pin slot of one of them
+func (p *savedPlaceRepo) Merge(ctx context.Context, kept *dao.SavedPlace, removedIds []primitive.ObjectID) error {
+	if len(removedIds) > 0 {
+		_, err := p.c.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": removedIds}, "user_id": kept.UserId})
+		if err != nil {
+			return fmt.Errorf("failed to delete saved places: %v", err)
+		}
+	}
+
+	update := bson.M{"$set": bson.M{
+		"place_id":    kept.PlaceId,
+		"place_alias": kept.PlaceAlias,
+		"pinned":      kept.Pinned,
+		"updated_at":  kept.UpdatedAt,
+	}}
+	if kept.PinSlot > 0 {
+		update["$set"].(bson.M)["pin_slot"] = kept.PinSlot
+	} else {
+		update["$unset"] = bson.M{"pin_slot": ""}
+	}
+
+	if _, err := p.c.UpdateOne(ctx, bson.M{"_id": kept.Id, "user_id": kept.UserId}, update); err != nil {
+		return fmt.Errorf("failed to update saved place: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package repository
+
+import (
+	"context"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
+
+func TestFreePinSlot(t *testing.T) {
+	id := primitive.NewObjectID()
+	pinnedIn := func(slots ...int) []dao.SavedPlace {
+		pinned := make([]dao.SavedPlace, len(slots))
+		for i, slot := range slots {
+			pinned[i] = dao.SavedPlace{Id: primitive.NewObjectID(), Pinned: true, PinSlot: slot}
+		}
+		return pinned
+	}
+
+	tests := []struct {
+		name     string
+		pinned   []dao.SavedPlace
+		wantSlot int
+		isPinned bool
+		full     bool
+	}{
+		{"nothing pinned", nil, 1, false, false},
+		{"lowest free slot", pinnedIn(1, 3), 2, false, false},
+		{"slot freed by an unpin", pinnedIn(1, 3, 4), 2, false, false},
+		{"legacy pins hold no slot", pinnedIn(0, 0, 1), 2, false, false},
+		{"every slot taken", pinnedIn(1, 2, 3), 0, false, true},
+		{"legacy pins count towards the limit", pinnedIn(0, 0, 1), 0, false, true},
+		{"already pinned", append(pinnedIn(1, 2, 3), dao.SavedPlace{Id: id, Pinned: true, PinSlot: 4}), 0, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			maxPinned := 4
+			if tt.full {
+				maxPinned = len(tt.pinned)
+			}
+			slot, isPinned, full := freePinSlot(tt.pinned, id, maxPinned)
+			if slot != tt.wantSlot || isPinned != tt.isPinned || full != tt.full {
+				t.Errorf("got slot %d, pinned %v and full %v, want slot %d, pinned %v and full %v",
+					slot, isPinned, full, tt.wantSlot, tt.isPinned, tt.full)
+			}
+		})
+	}
+}
+
+func TestSavedPlacePin(t *testing.T) {
+	ctx := context.Background()
+	p := &savedPlaceRepo{c: testDatabase(t, "lokate_test").Collection(savedPlaceCollectionName)}
+	if err := p.c.Drop(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.EnsureIndexes(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	userId := primitive.NewObjectID()
+	var saved []*dao.SavedPlace
+	for i := 0; i < 4; i++ {
+		sp := &dao.SavedPlace{Id: primitive.NewObjectID(), UserId: userId}
+		if err := p.Create(ctx, sp); err != nil {
+			t.Fatal(err)
+		}
+		saved = append(saved, sp)
+	}
+	// a saved place from before the pinned field existed
+	legacy := &dao.SavedPlace{Id: primitive.NewObjectID(), UserId: userId}
+	if _, err := p.c.InsertOne(ctx, bson.M{"_id": legacy.Id, "user_id": userId}); err != nil {
+		t.Fatal(err)
+	}
+
+	pin := func(sp *dao.SavedPlace) bool {
+		t.Helper()
+		ok, err := p.Pin(ctx, sp, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	if !pin(legacy) {
+		t.Fatal("the saved place without a pinned field was not pinned")
+	}
+	if !pin(saved[0]) || !pin(saved[1]) {
+		t.Fatal("a saved place under the pin limit was not pinned")
+	}
+	if pin(saved[2]) {
+		t.Fatal("a saved place over the pin limit was pinned")
+	}
+	if !pin(saved[1]) {
+		t.Error("pinning a pinned saved place again failed")
+	}
+
+	if err := p.Unpin(ctx, saved[0]); err != nil {
+		t.Fatal(err)
+	}
+	if !pin(saved[2]) {
+		t.Fatal("the slot freed by an unpin was not reused")
+	}
+
+	var pinned []dao.SavedPlace
+	if err := p.FindPinned(ctx, userId, &pinned); err != nil {
+		t.Fatal(err)
+	}
+	slots := make(map[int]bool)
+	for _, sp := range pinned {
+		slots[sp.PinSlot] = true
+	}
+	if len(pinned) != 3 || len(slots) != 3 {
+		t.Errorf("got %d pinned saved places in slots %v, want 3 in different slots", len(pinned), slots)
+	}
+
+	if _, err := p.Pin(ctx, &dao.SavedPlace{Id: primitive.NewObjectID(), UserId: userId}, 4); err == nil {
+		t.Error("pinning a saved place that does not exist did not fail")
+	}
+}
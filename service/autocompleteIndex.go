@@ -0,0 +1,418 @@
+package service
+
+import (
+	"bytes"
+	"container/heap"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/utils"
+)
+
+// autocompleteEntry is a place in the autocomplete index with the words of its normalized name
+type autocompleteEntry struct {
+	place dao.Place
+	name  string
+	words []string
+}
+
+// autocompleteTerm is a word of the name of a place, the terms are kept sorted so the words that
+// start with a prefix are next to each other
+type autocompleteTerm struct {
+	word  string
+	entry *autocompleteEntry
+}
+
+const (
+	// autocompleteShortPrefix is the length in letters under which a typed word is looked up in
+	// the lists of the most popular places instead of the terms, which most places would match
+	autocompleteShortPrefix = 3
+	// autocompleteShortCandidates is the number of the most popular places kept for each short prefix
+	autocompleteShortCandidates = 500
+)
+
+// autocompleteIndex is a prefix index over the names of the stored places. An index is never
+// changed once it is built, a refresh builds a new index from the last one so the lookups
+// never wait for a refresh
+type autocompleteIndex struct {
+	entries map[primitive.ObjectID]*autocompleteEntry
+	terms   []autocompleteTerm
+	short   map[string][]*autocompleteEntry
+	// popularity holds the popularity of the places counted when the index was built and recent
+	// the popularity added since, so a refresh only copies the popularity of the recent places
+	popularity    map[primitive.ObjectID]int64
+	recent        map[primitive.ObjectID]int64
+	maxPopularity int64
+}
+
+// newAutocompleteIndex returns an empty autocomplete index
+func newAutocompleteIndex() *autocompleteIndex {
+	return &autocompleteIndex{
+		entries:    make(map[primitive.ObjectID]*autocompleteEntry),
+		short:      make(map[string][]*autocompleteEntry),
+		popularity: make(map[primitive.ObjectID]int64),
+		recent:     make(map[primitive.ObjectID]int64),
+	}
+}
+
+// buildAutocompleteIndex builds an index of the places with their popularity
+func buildAutocompleteIndex(places []dao.Place, popularity map[primitive.ObjectID]int64) *autocompleteIndex {
+	ix := newAutocompleteIndex().withPlaces(places)
+	ix.popularity = popularity
+	ix.recent = make(map[primitive.ObjectID]int64)
+	ix.maxPopularity = 0
+	for _, p := range popularity {
+		if p > ix.maxPopularity {
+			ix.maxPopularity = p
+		}
+	}
+	ix.short = ix.shortPrefixes(nil)
+	return ix
+}
+
+// with returns a copy of the index with the places added, or replacing the places with the same
+// id, and with the popularity added to the popularity of the places. Only the lists of the short
+// prefixes of the places that changed are ranked again
+func (ix *autocompleteIndex) with(places []dao.Place, added map[primitive.ObjectID]int64) *autocompleteIndex {
+	next := ix.withPlaces(places)
+	next.popularity = ix.popularity
+	next.maxPopularity = ix.maxPopularity
+	next.recent = make(map[primitive.ObjectID]int64, len(ix.recent)+len(added))
+	for id, p := range ix.recent {
+		next.recent[id] = p
+	}
+
+	changed := make(map[primitive.ObjectID]bool, len(places)+len(added))
+	for i := range places {
+		changed[places[i].Id] = true
+	}
+	for id, p := range added {
+		if p == 0 {
+			continue
+		}
+		next.recent[id] += p
+		if total := next.popularityOf(id); total > next.maxPopularity {
+			next.maxPopularity = total
+		}
+		changed[id] = true
+	}
+
+	next.short = next.withShortPrefixes(ix, changed)
+	return next
+}
+
+// popularityOf returns the popularity of a place
+func (ix *autocompleteIndex) popularityOf(id primitive.ObjectID) int64 {
+	return ix.popularity[id] + ix.recent[id]
+}
+
+// withPlaces returns a copy of the index with the places added, or replacing the places with
+// the same id. The terms of the new places are sorted on their own and merged into the terms of
+// the index, so a refresh with a few places does not sort the whole index again
+func (ix *autocompleteIndex) withPlaces(places []dao.Place) *autocompleteIndex {
+	if len(places) == 0 {
+		next := *ix
+		return &next
+	}
+
+	entries := make(map[primitive.ObjectID]*autocompleteEntry, len(ix.entries)+len(places))
+	for id, e := range ix.entries {
+		entries[id] = e
+	}
+
+	changed := make(map[primitive.ObjectID]bool, len(places))
+	var added []autocompleteTerm
+	for i := range places {
+		place := places[i]
+		place.SearchGrams = nil
+		changed[place.Id] = true
+
+		name := utils.NormalizeName(place.Name)
+		if name == "" {
+			delete(entries, place.Id)
+			continue
+		}
+		e := &autocompleteEntry{place: place, name: name, words: strings.Fields(name)}
+		entries[place.Id] = e
+
+		seen := make(map[string]bool, len(e.words))
+		for _, w := range e.words {
+			if !seen[w] {
+				seen[w] = true
+				added = append(added, autocompleteTerm{word: w, entry: e})
+			}
+		}
+	}
+	sort.Slice(added, func(i, j int) bool { return lessTerm(added[i], added[j]) })
+
+	// merge the terms of the places that did not change with the terms of the new places
+	terms := make([]autocompleteTerm, 0, len(ix.terms)+len(added))
+	j := 0
+	for _, t := range ix.terms {
+		if changed[t.entry.place.Id] {
+			continue
+		}
+		for j < len(added) && lessTerm(added[j], t) {
+			terms = append(terms, added[j])
+			j++
+		}
+		terms = append(terms, t)
+	}
+	terms = append(terms, added[j:]...)
+
+	return &autocompleteIndex{entries: entries, terms: terms}
+}
+
+// shortPrefixes finds the most popular places with a word starting with each prefix shorter than
+// the short prefix length, the most popular first. Only the prefixes given are found when there are any
+func (ix *autocompleteIndex) shortPrefixes(only map[string]bool) map[string][]*autocompleteEntry {
+	heaps := make(map[string]*autocompleteHeap)
+	for _, e := range ix.entries {
+		ranked := rankedEntry{entry: e, popularity: ix.popularityOf(e.place.Id)}
+		for _, prefix := range e.shortPrefixes() {
+			if only != nil && !only[prefix] {
+				continue
+			}
+			h, ok := heaps[prefix]
+			if !ok {
+				h = &autocompleteHeap{}
+				heaps[prefix] = h
+			}
+			h.offer(ranked)
+		}
+	}
+
+	short := make(map[string][]*autocompleteEntry, len(heaps))
+	for prefix, h := range heaps {
+		short[prefix] = h.sorted()
+	}
+	return short
+}
+
+// withShortPrefixes returns the lists of the short prefixes of the last index with the places that
+// changed ranked again. The places that did not change keep their popularity and so their order,
+// a changed place is taken out of the lists of its old prefixes and put in the lists of its new ones.
+// A full list whose end moved up is found again, a place that was past its end may now be in it
+func (ix *autocompleteIndex) withShortPrefixes(last *autocompleteIndex, changed map[primitive.ObjectID]bool) map[string][]*autocompleteEntry {
+	if len(changed) == 0 {
+		return last.short
+	}
+
+	// the changed places of each prefix they had or have
+	touched := make(map[string][]*autocompleteEntry)
+	for id := range changed {
+		if e, ok := last.entries[id]; ok {
+			for _, prefix := range e.shortPrefixes() {
+				if _, ok := touched[prefix]; !ok {
+					touched[prefix] = nil
+				}
+			}
+		}
+		if e, ok := ix.entries[id]; ok {
+			for _, prefix := range e.shortPrefixes() {
+				touched[prefix] = append(touched[prefix], e)
+			}
+		}
+	}
+
+	short := make(map[string][]*autocompleteEntry, len(last.short)+len(touched))
+	for prefix, entries := range last.short {
+		short[prefix] = entries
+	}
+
+	lost := make(map[string]bool)
+	for prefix, added := range touched {
+		ranked := make([]rankedEntry, 0, len(last.short[prefix])+len(added))
+		for _, e := range last.short[prefix] {
+			if !changed[e.place.Id] {
+				ranked = append(ranked, rankedEntry{entry: e, popularity: ix.popularityOf(e.place.Id)})
+			}
+		}
+		for _, e := range added {
+			ranked = append(ranked, rankedEntry{entry: e, popularity: ix.popularityOf(e.place.Id)})
+		}
+		sort.SliceStable(ranked, func(i, j int) bool { return ranked[i].morePopular(ranked[j]) })
+		if len(ranked) > autocompleteShortCandidates {
+			ranked = ranked[:autocompleteShortCandidates]
+		}
+
+		// the places past the end of a full list are less popular than its last place was
+		if old := last.short[prefix]; len(old) == autocompleteShortCandidates {
+			end := rankedEntry{entry: old[len(old)-1], popularity: last.popularityOf(old[len(old)-1].place.Id)}
+			if len(ranked) < autocompleteShortCandidates || end.morePopular(ranked[len(ranked)-1]) {
+				lost[prefix] = true
+				continue
+			}
+		}
+		if len(ranked) == 0 {
+			delete(short, prefix)
+			continue
+		}
+		entries := make([]*autocompleteEntry, len(ranked))
+		for i := range ranked {
+			entries[i] = ranked[i].entry
+		}
+		short[prefix] = entries
+	}
+
+	if len(lost) > 0 {
+		for prefix, entries := range ix.shortPrefixes(lost) {
+			short[prefix] = entries
+		}
+	}
+	return short
+}
+
+// shortPrefixes returns the distinct prefixes shorter than the short prefix length of the words of a place
+func (e *autocompleteEntry) shortPrefixes() []string {
+	var prefixes []string
+	for _, w := range e.words {
+		for n, end := 1, 0; n < autocompleteShortPrefix && end < len(w); n++ {
+			_, size := utf8.DecodeRuneInString(w[end:])
+			end += size
+			if prefix := w[:end]; !containsString(prefixes, prefix) {
+				prefixes = append(prefixes, prefix)
+			}
+		}
+	}
+	return prefixes
+}
+
+// rankedEntry is a place of the index with its popularity
+type rankedEntry struct {
+	entry      *autocompleteEntry
+	popularity int64
+}
+
+// morePopular orders the places by their popularity, then the shorter and then the alphabetically first names
+func (r rankedEntry) morePopular(o rankedEntry) bool {
+	if r.popularity != o.popularity {
+		return r.popularity > o.popularity
+	}
+	if len(r.entry.name) != len(o.entry.name) {
+		return len(r.entry.name) < len(o.entry.name)
+	}
+	return r.entry.name < o.entry.name
+}
+
+// autocompleteHeap keeps the most popular places offered to it, with the least popular of them on top
+type autocompleteHeap []rankedEntry
+
+func (h autocompleteHeap) Len() int            { return len(h) }
+func (h autocompleteHeap) Less(i, j int) bool  { return h[j].morePopular(h[i]) }
+func (h autocompleteHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *autocompleteHeap) Push(x interface{}) { *h = append(*h, x.(rankedEntry)) }
+func (h *autocompleteHeap) Pop() interface{} {
+	old := *h
+	last := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return last
+}
+
+// offer keeps a place when it is more popular than the least popular place kept
+func (h *autocompleteHeap) offer(r rankedEntry) {
+	if len(*h) < autocompleteShortCandidates {
+		heap.Push(h, r)
+		return
+	}
+	if r.morePopular((*h)[0]) {
+		(*h)[0] = r
+		heap.Fix(h, 0)
+	}
+}
+
+// sorted returns the places kept, the most popular first
+func (h autocompleteHeap) sorted() []*autocompleteEntry {
+	sort.Slice(h, func(i, j int) bool { return h[i].morePopular(h[j]) })
+	entries := make([]*autocompleteEntry, len(h))
+	for i := range h {
+		entries[i] = h[i].entry
+	}
+	return entries
+}
+
+// containsString determines if a string is one of the strings
+func containsString(strs []string, s string) bool {
+	for _, str := range strs {
+		if str == s {
+			return true
+		}
+	}
+	return false
+}
+
+// match calls the visit function with each place that has a word starting with each of the words
+func (ix *autocompleteIndex) match(words []string, visit func(e *autocompleteEntry)) {
+	if len(words) == 0 {
+		return
+	}
+
+	// the longest word has the fewest words of the names that start with it
+	longest := words[0]
+	for _, w := range words[1:] {
+		if len(w) > len(longest) {
+			longest = w
+		}
+	}
+
+	// the places with a short word are only looked for among the most popular places
+	if utf8.RuneCountInString(longest) < autocompleteShortPrefix {
+		for _, e := range ix.short[longest] {
+			if wordsHavePrefixes(e.words, words) {
+				visit(e)
+			}
+		}
+		return
+	}
+
+	start := sort.Search(len(ix.terms), func(i int) bool { return ix.terms[i].word >= longest })
+	for i := start; i < len(ix.terms) && strings.HasPrefix(ix.terms[i].word, longest); i++ {
+		e := ix.terms[i].entry
+		// a place with more than one word starting with the prefix is only visited from its first one
+		if firstWordWithPrefix(e.words, longest) != ix.terms[i].word {
+			continue
+		}
+		if wordsHavePrefixes(e.words, words) {
+			visit(e)
+		}
+	}
+}
+
+// firstWordWithPrefix returns the first of the words that starts with the prefix
+func firstWordWithPrefix(words []string, prefix string) string {
+	for _, w := range words {
+		if strings.HasPrefix(w, prefix) {
+			return w
+		}
+	}
+	return ""
+}
+
+// wordsHavePrefixes determines if each of the prefixes starts one of the words
+func wordsHavePrefixes(words, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		found := false
+		for _, w := range words {
+			if strings.HasPrefix(w, prefix) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// lessTerm orders the terms by their word, then by the id of their place
+func lessTerm(a, b autocompleteTerm) bool {
+	if a.word != b.word {
+		return a.word < b.word
+	}
+	return bytes.Compare(a.entry.place.Id[:], b.entry.place.Id[:]) < 0
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/lokate-go/config"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+)
+
+// autocompleteTestName returns a name of words from a few letters, so the short prefixes of a
+// letter have more places than their lists keep
+func autocompleteTestName(r *rand.Rand, n int) string {
+	word := func() string {
+		w := make([]byte, 2+r.Intn(4))
+		for i := range w {
+			w[i] = "abcde"[r.Intn(5)]
+		}
+		return string(w)
+	}
+	return fmt.Sprintf("%s %s %d", word(), word(), n)
+}
+
+func TestAutocompleteIndexRefreshMatchesBuild(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	places := make(map[primitive.ObjectID]dao.Place)
+	popularity := make(map[primitive.ObjectID]int64)
+	var ids []primitive.ObjectID
+
+	all := func() []dao.Place {
+		list := make([]dao.Place, 0, len(places))
+		for _, p := range places {
+			list = append(list, p)
+		}
+		return list
+	}
+	counted := func() map[primitive.ObjectID]int64 {
+		c := make(map[primitive.ObjectID]int64, len(popularity))
+		for id, p := range popularity {
+			c[id] = p
+		}
+		return c
+	}
+
+	named := 0
+	for i := 0; i < 3000; i++ {
+		id := primitive.NewObjectID()
+		ids = append(ids, id)
+		places[id] = dao.Place{Id: id, Name: autocompleteTestName(r, named)}
+		named++
+		if r.Intn(3) == 0 {
+			popularity[id] = r.Int63n(50)
+		}
+	}
+	ix := buildAutocompleteIndex(all(), counted())
+
+	for round := 0; round < 30; round++ {
+		// some places are renamed, added or deleted and some are visited or saved
+		var written []dao.Place
+		for k := r.Intn(40); k > 0; k-- {
+			var id primitive.ObjectID
+			if r.Intn(4) == 0 {
+				id = primitive.NewObjectID()
+				ids = append(ids, id)
+			} else {
+				id = ids[r.Intn(len(ids))]
+			}
+			p := dao.Place{Id: id, Name: autocompleteTestName(r, named)}
+			named++
+			if r.Intn(10) == 0 {
+				p.Name = "!"
+			}
+			places[id] = p
+			written = append(written, p)
+		}
+		added := make(map[primitive.ObjectID]int64)
+		for k := r.Intn(60); k > 0; k-- {
+			id := ids[r.Intn(len(ids))]
+			n := 1 + r.Int63n(30)
+			added[id] += n
+			popularity[id] += n
+		}
+
+		ix = ix.with(written, added)
+		want := buildAutocompleteIndex(all(), counted())
+
+		if ix.maxPopularity != want.maxPopularity {
+			t.Fatalf("round %d: got the most popularity %d, want %d", round, ix.maxPopularity, want.maxPopularity)
+		}
+		if len(ix.short) != len(want.short) {
+			t.Fatalf("round %d: got %d short prefixes, want %d", round, len(ix.short), len(want.short))
+		}
+		for prefix, wantEntries := range want.short {
+			got := ix.short[prefix]
+			if len(got) != len(wantEntries) {
+				t.Fatalf("round %d: got %d places for %q, want %d", round, len(got), prefix, len(wantEntries))
+			}
+			for i := range got {
+				if got[i].place.Id != wantEntries[i].place.Id || got[i].name != wantEntries[i].name {
+					t.Fatalf("round %d: place %d for %q is %q, want %q", round, i, prefix, got[i].name, wantEntries[i].name)
+				}
+			}
+		}
+	}
+}
+
+// popularityCounts counts the same visits or saves of the places whatever the times asked and
+// records the times they are counted from
+type popularityCounts struct {
+	counts map[primitive.ObjectID]int64
+	froms  []time.Time
+}
+
+func (p *popularityCounts) count(from time.Time) map[primitive.ObjectID]int64 {
+	p.froms = append(p.froms, from)
+	counts := make(map[primitive.ObjectID]int64, len(p.counts))
+	for id, n := range p.counts {
+		counts[id] = n
+	}
+	return counts
+}
+
+// onePlaceRepo returns its place when all the places are read, the other place repository methods are not used
+type onePlaceRepo struct {
+	interfaces.PlaceRepositoryInterface
+	place dao.Place
+}
+
+func (p *onePlaceRepo) FindUpdatedSince(ctx context.Context, since time.Time, places *[]dao.Place) error {
+	if since.IsZero() {
+		*places = []dao.Place{p.place}
+	}
+	return nil
+}
+
+// countingSavedPlaceRepo counts the saves, the other saved place repository methods are not used
+type countingSavedPlaceRepo struct {
+	interfaces.SavedPlaceRepositoryInterface
+	saves *popularityCounts
+}
+
+func (s *countingSavedPlaceRepo) CountByPlace(ctx context.Context, from, to time.Time) (map[primitive.ObjectID]int64, error) {
+	return s.saves.count(from), nil
+}
+
+// countingLastVisitedPlaceRepo counts the visits, the other last visited place repository methods are not used
+type countingLastVisitedPlaceRepo struct {
+	interfaces.LastVisitedPlaceRepositoryInterface
+	visits *popularityCounts
+}
+
+func (l *countingLastVisitedPlaceRepo) CountByPlace(ctx context.Context, from, to time.Time) (map[primitive.ObjectID]int64, error) {
+	return l.visits.count(from), nil
+}
+
+func TestAutocompleteRefreshAddsRecentPopularity(t *testing.T) {
+	place := dao.Place{Id: primitive.NewObjectID(), Name: "London Waterloo"}
+	counts := &popularityCounts{counts: map[primitive.ObjectID]int64{place.Id: 2}}
+	cfg := map[string]string{config.AutocompleteRefreshSeconds: "60"}
+	svc, err := NewAutocompleteService(&cfg, &onePlaceRepo{place: place},
+		&countingSavedPlaceRepo{saves: counts}, &countingLastVisitedPlaceRepo{visits: counts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	as := svc.(*autocompleteService)
+	ctx := context.Background()
+
+	if err := as.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	built := as.index
+	if err := as.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first refresh counts everything, the second only counts from where the first stopped
+	if len(counts.froms) != 4 || !counts.froms[0].IsZero() || !counts.froms[2].Equal(as.builtAt) {
+		t.Fatalf("counted from %v, want from the start and then from %v", counts.froms, as.builtAt)
+	}
+	// 2 visits and 2 saves counted twice
+	if got := as.index.popularityOf(place.Id); got != 2*(2+2*autocompleteSavedPopularity) {
+		t.Errorf("got popularity %d, want %d", got, 2*(2+2*autocompleteSavedPopularity))
+	}
+	if len(as.index.popularity) != len(built.popularity) || len(as.index.recent) != 1 {
+		t.Errorf("the refresh copied the popularity counted when the index was built")
+	}
+}
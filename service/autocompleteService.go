@@ -0,0 +1,337 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/lokate-go/config"
+	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+	"github.com/leonardchinonso/lokate-go/utils"
+)
+
+const (
+	// autocompleteRebuildEvery is how often the index is built again from all the places, which
+	// drops the places that were deleted since the last build
+	autocompleteRebuildEvery = time.Hour
+	// autocompleteRefreshOverlap is how far before the last refresh the next refresh reads the
+	// written places from, so a write from a clock a little behind is not missed
+	autocompleteRefreshOverlap = time.Minute
+	// autocompleteRefreshTimeout bounds a refresh of the index
+	autocompleteRefreshTimeout = 2 * time.Minute
+	// autocompleteSavedPopularity is how many visits a place being saved by a user counts for
+	autocompleteSavedPopularity = 3
+)
+
+const (
+	// autocompleteStartScore is the score of a place whose name starts with the typed text
+	autocompleteStartScore = 1
+	// autocompleteWordScore is the score of a place with words that start with the typed words
+	autocompleteWordScore = 0.8
+	// autocompletePopularityScore is the most the popularity of a place adds to its score
+	autocompletePopularityScore = 0.5
+	// autocompleteProximityScore is the most the proximity of a place to the point adds to its score
+	autocompleteProximityScore = 0.5
+	// autocompleteProximityMetres is the distance at which the proximity adds half its most
+	autocompleteProximityMetres = 2000
+)
+
+// autocompleteService holds the structure for the place autocomplete methods
+type autocompleteService struct {
+	placeRepository            interfaces.PlaceRepositoryInterface
+	savedPlaceRepository       interfaces.SavedPlaceRepositoryInterface
+	lastVisitedPlaceRepository interfaces.LastVisitedPlaceRepositoryInterface
+	interval                   time.Duration
+
+	// refreshMu makes the refreshes run one at a time, only a refresh reads and writes the times
+	refreshMu sync.Mutex
+	since     time.Time
+	countedAt time.Time
+	builtAt   time.Time
+
+	mu    sync.RWMutex
+	index *autocompleteIndex
+}
+
+// NewAutocompleteService returns an interface for the place autocomplete methods
+func NewAutocompleteService(cfg *map[string]string,
+	placeRepo interfaces.PlaceRepositoryInterface,
+	savedPlaceRepo interfaces.SavedPlaceRepositoryInterface,
+	lastVisitedPlaceRepo interfaces.LastVisitedPlaceRepositoryInterface,
+) (interfaces.AutocompleteServiceInterface, error) {
+	seconds, err := strconv.Atoi((*cfg)[config.AutocompleteRefreshSeconds])
+	if err != nil || seconds <= 0 {
+		return nil, fmt.Errorf("invalid %s: %q", config.AutocompleteRefreshSeconds, (*cfg)[config.AutocompleteRefreshSeconds])
+	}
+
+	return &autocompleteService{
+		placeRepository:            placeRepo,
+		savedPlaceRepository:       savedPlaceRepo,
+		lastVisitedPlaceRepository: lastVisitedPlaceRepo,
+		interval:                   time.Duration(seconds) * time.Second,
+		index:                      newAutocompleteIndex(),
+	}, nil
+}
+
+// Start refreshes the index in the background until the context is done
+func (as *autocompleteService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(as.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := as.Refresh(ctx); err != nil {
+				log.Printf("Failed to refresh the autocomplete index. Error: %v\n", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Refresh adds the places written since the last refresh to the index and adds the visits and saves
+// made since the last refresh to the popularity of the places. The index is built again from all the
+// places and their popularity is counted again when it is an hour old, which also drops the visits
+// and saves that were deleted. A refresh that fails keeps the last index
+func (as *autocompleteService) Refresh(ctx context.Context) error {
+	as.refreshMu.Lock()
+	defer as.refreshMu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, autocompleteRefreshTimeout)
+	defer cancel()
+
+	start := time.Now()
+	full := as.builtAt.IsZero() || start.Sub(as.builtAt) >= autocompleteRebuildEvery
+	since := as.since
+	if full {
+		since = time.Time{}
+	}
+
+	var places []dao.Place
+	if err := as.placeRepository.FindUpdatedSince(ctx, since, &places); err != nil {
+		return err
+	}
+
+	// the visits and saves are counted once each, from the time the last count ended. One written
+	// with a time before it is only counted when the index is built again
+	from := as.countedAt
+	if full {
+		from = time.Time{}
+	}
+	popularity, err := as.popularity(ctx, from, start)
+	if err != nil {
+		return err
+	}
+
+	as.since = start.Add(-autocompleteRefreshOverlap)
+	as.countedAt = start
+
+	var next *autocompleteIndex
+	if full {
+		next = buildAutocompleteIndex(places, popularity)
+	} else {
+		// the index is kept when nothing changed since the last refresh
+		if len(places) == 0 && len(popularity) == 0 {
+			return nil
+		}
+
+		as.mu.RLock()
+		base := as.index
+		as.mu.RUnlock()
+		next = base.with(places, popularity)
+	}
+
+	as.mu.Lock()
+	as.index = next
+	as.mu.Unlock()
+
+	if full {
+		as.builtAt = start
+		log.Printf("Built the autocomplete index with %d places\n", len(next.entries))
+	}
+	return nil
+}
+
+// popularity counts how often each place was saved and visited from a time until before another
+func (as *autocompleteService) popularity(ctx context.Context, from, to time.Time) (map[primitive.ObjectID]int64, error) {
+	visits, err := as.lastVisitedPlaceRepository.CountByPlace(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+	saves, err := as.savedPlaceRepository.CountByPlace(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	for id, count := range saves {
+		visits[id] += autocompleteSavedPopularity * count
+	}
+	return visits, nil
+}
+
+// Suggest suggests the places whose names start with the typed text, or that have words starting
+// with each of the typed words. The saved places of a logged-in user that match come first, then
+// the stored places ranked by how well they match, their popularity and their proximity to the point
+func (as *autocompleteService) Suggest(ctx context.Context, userId primitive.ObjectID, query dto.AutocompleteQuery) ([]api.PlaceResponse, error) {
+	text := utils.NormalizeName(query.Text)
+	words := strings.Fields(text)
+
+	as.mu.RLock()
+	index := as.index
+	as.mu.RUnlock()
+
+	suggestions := make([]api.PlaceResponse, 0, query.Limit)
+	suggested := make(map[primitive.ObjectID]bool)
+
+	if !userId.IsZero() && len(words) > 0 {
+		saved, err := as.savedSuggestions(ctx, index, userId, words)
+		if err != nil {
+			log.Printf("Error finding the saved places to suggest. Error: %v\n", err)
+			return nil, errors.ErrInternalServerError("failed to suggest places", nil)
+		}
+		for i := range saved {
+			if len(suggestions) == query.Limit {
+				break
+			}
+			p := api.NewPlaceResponse(&saved[i])
+			p.Source = api.PlaceSearchSourceSaved
+			suggestions = append(suggestions, *p)
+			suggested[saved[i].Id] = true
+		}
+	}
+
+	// only the best places are kept as they are found, the worst of them last
+	var best []scoredAutocompleteEntry
+	index.match(words, func(e *autocompleteEntry) {
+		if suggested[e.place.Id] {
+			return
+		}
+		s := scoredAutocompleteEntry{entry: e, score: index.score(e, text, query)}
+		keep := query.Limit - len(suggestions)
+		if keep <= 0 || (len(best) == keep && !s.better(best[len(best)-1])) {
+			return
+		}
+		if len(best) < keep {
+			best = append(best, s)
+		} else {
+			best[len(best)-1] = s
+		}
+		for i := len(best) - 1; i > 0 && best[i].better(best[i-1]); i-- {
+			best[i], best[i-1] = best[i-1], best[i]
+		}
+	})
+
+	for _, s := range best {
+		p := api.NewPlaceResponse(&s.entry.place)
+		p.Source = api.PlaceSearchSourceLocal
+		suggestions = append(suggestions, *p)
+	}
+
+	return suggestions, nil
+}
+
+// scoredAutocompleteEntry is a place of the index and its score for a query
+type scoredAutocompleteEntry struct {
+	entry *autocompleteEntry
+	score float64
+}
+
+// better orders the places by their score, then the shorter and then the alphabetically first names
+func (s scoredAutocompleteEntry) better(o scoredAutocompleteEntry) bool {
+	if s.score != o.score {
+		return s.score > o.score
+	}
+	if len(s.entry.name) != len(o.entry.name) {
+		return len(s.entry.name) < len(o.entry.name)
+	}
+	return s.entry.name < o.entry.name
+}
+
+// score ranks a place of the index for the typed text, from how it matches, how popular it is and
+// how close it is to the point of the query when there is one
+func (ix *autocompleteIndex) score(e *autocompleteEntry, text string, query dto.AutocompleteQuery) float64 {
+	score := float64(autocompleteWordScore)
+	if strings.HasPrefix(e.name, text) {
+		score = autocompleteStartScore
+	}
+
+	if ix.maxPopularity > 0 {
+		score += autocompletePopularityScore * math.Log1p(float64(ix.popularityOf(e.place.Id))) / math.Log1p(float64(ix.maxPopularity))
+	}
+
+	if query.Near && e.place.Latitude != nil && e.place.Longitude != nil {
+		d := utils.HaversineMetres(query.Latitude, query.Longitude, *e.place.Latitude, *e.place.Longitude)
+		score += autocompleteProximityScore / (1 + d/autocompleteProximityMetres)
+	}
+
+	return score
+}
+
+// savedSuggestions finds the saved places of a user whose name, alias or place name have words
+// starting with each of the typed words. The places are read from the index, and from the
+// database when they are not in the index yet
+func (as *autocompleteService) savedSuggestions(ctx context.Context, index *autocompleteIndex, userId primitive.ObjectID, words []string) ([]dao.Place, error) {
+	var savedPlaces []dao.SavedPlace
+	if _, err := as.savedPlaceRepository.Find(ctx, userId, &savedPlaces); err != nil {
+		return nil, err
+	}
+
+	places := make(map[primitive.ObjectID]dao.Place, len(savedPlaces))
+	var missing []primitive.ObjectID
+	for _, sp := range savedPlaces {
+		if e, ok := index.entries[sp.PlaceId]; ok {
+			places[sp.PlaceId] = e.place
+		} else {
+			missing = append(missing, sp.PlaceId)
+		}
+	}
+	if len(missing) > 0 {
+		var found []dao.Place
+		if err := as.placeRepository.FindByIDs(ctx, missing, &found); err != nil {
+			return nil, err
+		}
+		for _, p := range found {
+			places[p.Id] = p
+		}
+	}
+
+	// the saved places are suggested in the order they are named by the user
+	sort.SliceStable(savedPlaces, func(i, j int) bool {
+		return strings.ToLower(savedPlaces[i].Name) < strings.ToLower(savedPlaces[j].Name)
+	})
+
+	var matched []dao.Place
+	for _, sp := range savedPlaces {
+		place, ok := places[sp.PlaceId]
+		if !ok {
+			continue
+		}
+
+		var names []string
+		names = append(names, strings.Fields(utils.NormalizeName(sp.Name))...)
+		names = append(names, strings.Fields(utils.NormalizeName(place.Name))...)
+		if !sp.PlaceAlias.IsNone() {
+			names = append(names, strings.ToLower(string(sp.PlaceAlias)))
+		}
+		if wordsHavePrefixes(names, words) {
+			matched = append(matched, place)
+		}
+	}
+	return matched, nil
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leonardchinonso/lokate-go/config"
+	"github.com/leonardchinonso/lokate-go/datasource"
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+)
+
+// bikeShareStaleAfter is the number of poll intervals after which the availability of a system
+// that keeps failing is ignored
+const bikeShareStaleAfter = 5
+
+// bikeShareStationsRefresh is how often the stations of a system are imported into the places again
+const bikeShareStationsRefresh = time.Hour
+
+// bikeShareImportTimeout bounds the import of the stations of a system
+const bikeShareImportTimeout = 2 * time.Minute
+
+// bikeShareSystem is a system read from a source and the last time its stations were imported
+type bikeShareSystem struct {
+	system     *dao.GBFSSystem
+	importedAt time.Time
+}
+
+// bikeStationStatus is the last availability read for a station
+type bikeStationStatus struct {
+	status    dao.GBFSStationStatus
+	fetchedAt time.Time
+}
+
+// bikeShareService holds the structure for the GBFS bike share ingester
+type bikeShareService struct {
+	placeRepository interfaces.PlaceRepositoryInterface
+	sources         []string
+	interval        time.Duration
+
+	// pollMu makes the polls run one at a time, only a poll reads and writes the systems
+	pollMu  sync.Mutex
+	systems map[string]*bikeShareSystem
+
+	mu       sync.RWMutex
+	statuses map[string]bikeStationStatus
+}
+
+// NewBikeShareService returns an interface for the GBFS bike share ingester methods
+func NewBikeShareService(cfg *map[string]string, placeRepo interfaces.PlaceRepositoryInterface) (interfaces.BikeShareServiceInterface, error) {
+	seconds, err := strconv.Atoi((*cfg)[config.GBFSPollSeconds])
+	if err != nil || seconds <= 0 {
+		return nil, fmt.Errorf("invalid %s: %q", config.GBFSPollSeconds, (*cfg)[config.GBFSPollSeconds])
+	}
+
+	return &bikeShareService{
+		placeRepository: placeRepo,
+		sources:         GBFSSources(cfg),
+		interval:        time.Duration(seconds) * time.Second,
+		systems:         make(map[string]*bikeShareSystem),
+		statuses:        make(map[string]bikeStationStatus),
+	}, nil
+}
+
+// GBFSSources returns the configured GBFS discovery urls and feed directories
+func GBFSSources(cfg *map[string]string) []string {
+	var sources []string
+	for _, source := range strings.Split((*cfg)[config.GBFSSources], ",") {
+		if source = strings.TrimSpace(source); source != "" {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// Start polls the systems in the background until the context is done
+func (bs *bikeShareService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(bs.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := bs.Poll(ctx); err != nil {
+				log.Printf("Failed to poll GBFS feeds. Error: %v\n", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Poll reads the availability of the stations of every system once. A system is discovered and
+// its stations are imported into the places on its first poll and again when they are an hour old
+// a system that fails keeps its last availability until it goes stale
+func (bs *bikeShareService) Poll(ctx context.Context) error {
+	bs.pollMu.Lock()
+	defer bs.pollMu.Unlock()
+
+	var failed []string
+	for _, source := range bs.sources {
+		if err := bs.pollSource(ctx, source); err != nil {
+			log.Printf("Failed to poll GBFS system %s. Error: %v\n", source, err)
+			failed = append(failed, source)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to poll %d of %d systems: %s", len(failed), len(bs.sources), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// pollSource discovers a system and imports its stations when they are due and reads their availability
+// the system is discovered again with its stations since the feeds can move, and a system that
+// cannot be discovered again keeps its last feeds
+func (bs *bikeShareService) pollSource(ctx context.Context, source string) error {
+	sys, ok := bs.systems[source]
+	if !ok || time.Since(sys.importedAt) >= bikeShareStationsRefresh {
+		system, err := datasource.DiscoverGBFS(source)
+		switch {
+		case err != nil && !ok:
+			return err
+		case err != nil:
+			log.Printf("Failed to discover GBFS system %s again, keeping its last feeds. Error: %v\n", source, err)
+		case !ok:
+			sys = &bikeShareSystem{system: system}
+			bs.systems[source] = sys
+		default:
+			sys.system = system
+		}
+
+		if err := bs.importStations(ctx, sys.system); err != nil {
+			return err
+		}
+		sys.importedAt = time.Now()
+	}
+
+	statuses, err := datasource.FetchGBFSStationStatus(sys.system)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	bs.mu.Lock()
+	for _, status := range statuses {
+		bs.statuses[status.StationId] = bikeStationStatus{status: status, fetchedAt: now}
+	}
+	bs.mu.Unlock()
+
+	return nil
+}
+
+// importStations stores the stations of a system as bike dock places, updating the stations
+// that are already stored
+func (bs *bikeShareService) importStations(ctx context.Context, system *dao.GBFSSystem) error {
+	places, err := datasource.FetchGBFSStations(system)
+	if err != nil {
+		return err
+	}
+	if len(places) == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, bikeShareImportTimeout)
+	defer cancel()
+
+	inserted, updated, err := bs.placeRepository.ImportByKey(ctx, places)
+	if err != nil {
+		return fmt.Errorf("failed to import the stations of GBFS system %s: %v", system.SystemId, err)
+	}
+	log.Printf("Imported the stations of GBFS system %s: %d inserted, %d updated\n", system.SystemId, inserted, updated)
+
+	return nil
+}
+
+// AnnotatePlaces sets the live availability of the bike share stations among the places
+// a station that is not installed or whose availability is stale is left without one
+func (bs *bikeShareService) AnnotatePlaces(places []api.PlaceResponse) {
+	bs.mu.RLock()
+	defer bs.mu.RUnlock()
+
+	for i := range places {
+		if places[i].BikeStationId == "" {
+			continue
+		}
+
+		st, ok := bs.statuses[places[i].BikeStationId]
+		if !ok || !st.status.IsInstalled || time.Since(st.fetchedAt) > bikeShareStaleAfter*bs.interval {
+			continue
+		}
+
+		places[i].Availability = &api.BikeAvailabilityResponse{
+			BikesAvailable: st.status.BikesAvailable,
+			DocksAvailable: st.status.DocksAvailable,
+			IsRenting:      st.status.IsRenting,
+			IsReturning:    st.status.IsReturning,
+			LastReported:   st.status.LastReported,
+		}
+	}
+}
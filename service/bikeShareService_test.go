@@ -0,0 +1,208 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/leonardchinonso/lokate-go/config"
+	"github.com/leonardchinonso/lokate-go/datasource"
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+)
+
+// gbfsFixtures is the directory of the GBFS feeds the datasource is tested with
+var gbfsFixtures = filepath.Join("..", "datasource", "testdata", "gbfs")
+
+// movingGBFSServer serves the GBFS fixtures from a discovery file that keeps its url, the feeds
+// it lists are under /moved once they are moved and are no longer found at their old urls
+type movingGBFSServer struct {
+	*httptest.Server
+	moved         atomic.Bool
+	discoveryDown atomic.Bool
+}
+
+func newMovingGBFSServer(t *testing.T) *movingGBFSServer {
+	t.Helper()
+
+	s := &movingGBFSServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prefix := ""
+		if s.moved.Load() {
+			prefix = "/moved"
+		}
+
+		feed := r.URL.Path
+		switch {
+		case path.Base(feed) == "gbfs.json" && s.discoveryDown.Load():
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		case path.Base(feed) == "gbfs.json":
+		case !strings.HasPrefix(feed, prefix+"/"):
+			http.NotFound(w, r)
+			return
+		default:
+			feed = strings.TrimPrefix(feed, prefix)
+		}
+
+		body, err := os.ReadFile(filepath.Join(gbfsFixtures, filepath.FromSlash(feed)))
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		_, _ = w.Write([]byte(strings.ReplaceAll(string(body), "{{server}}", "http://"+r.Host+prefix)))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// importingPlaceRepo records the imported stations, the other place repository methods are not used
+type importingPlaceRepo struct {
+	interfaces.PlaceRepositoryInterface
+
+	mu      sync.Mutex
+	imports [][]dao.Place
+}
+
+func (p *importingPlaceRepo) ImportByKey(ctx context.Context, places []dao.Place) (int64, int64, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.imports = append(p.imports, places)
+	return int64(len(places)), 0, nil
+}
+
+func newTestBikeShareService(t *testing.T, sources ...string) (*bikeShareService, *importingPlaceRepo) {
+	t.Helper()
+
+	placeRepo := &importingPlaceRepo{}
+	cfg := map[string]string{config.GBFSPollSeconds: "60", config.GBFSSources: strings.Join(sources, ",")}
+	svc, err := NewBikeShareService(&cfg, placeRepo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return svc.(*bikeShareService), placeRepo
+}
+
+// bikesAvailable returns the bikes available at a station, or -1 when it has no availability
+func (bs *bikeShareService) bikesAvailable(stationId string) int {
+	places := []api.PlaceResponse{{BikeStationId: stationId}}
+	bs.AnnotatePlaces(places)
+	if places[0].Availability == nil {
+		return -1
+	}
+	return places[0].Availability.BikesAvailable
+}
+
+func TestBikeSharePollImportsEveryVersion(t *testing.T) {
+	server := newMovingGBFSServer(t)
+	bs, placeRepo := newTestBikeShareService(t, server.URL+"/v2/gbfs.json", server.URL+"/v3/gbfs.json",
+		"file://"+filepath.Join(gbfsFixtures, "v2"))
+	ctx := context.Background()
+
+	if err := bs.Poll(ctx); err != nil {
+		t.Fatalf("Poll returned an error: %v", err)
+	}
+	if err := bs.Poll(ctx); err != nil {
+		t.Fatalf("Poll returned an error: %v", err)
+	}
+
+	// the stations are imported on the first poll only, the directory is the same system as the v2 feeds
+	if len(placeRepo.imports) != 3 {
+		t.Fatalf("got %d imports, want one for each source", len(placeRepo.imports))
+	}
+	for stationId, want := range map[string]int{"london:101": 7, "london:103": 2, "edinburgh:st-1": 5, "edinburgh:st-2": 1} {
+		if got := bs.bikesAvailable(stationId); got != want {
+			t.Errorf("got %d bikes available at %s, want %d", got, stationId, want)
+		}
+	}
+	// a station that is not installed has no availability
+	if got := bs.bikesAvailable("london:102"); got != -1 {
+		t.Errorf("got %d bikes available at a station that is not installed, want none", got)
+	}
+}
+
+func TestBikeSharePollDiscoversMovedFeeds(t *testing.T) {
+	server := newMovingGBFSServer(t)
+	source := server.URL + "/v2/gbfs.json"
+	bs, placeRepo := newTestBikeShareService(t, source)
+	ctx := context.Background()
+
+	if err := bs.Poll(ctx); err != nil {
+		t.Fatalf("Poll returned an error: %v", err)
+	}
+
+	// the feeds move, the discovery file is only read again with the stations
+	server.moved.Store(true)
+	if err := bs.Poll(ctx); err == nil {
+		t.Fatal("Poll of the old feeds returned no error")
+	}
+
+	bs.systems[source].importedAt = time.Now().Add(-bikeShareStationsRefresh)
+	if err := bs.Poll(ctx); err != nil {
+		t.Fatalf("Poll after the stations were due returned an error: %v", err)
+	}
+	if got, want := bs.systems[source].system.Feeds[datasource.GBFSStationStatus], server.URL+"/moved/v2/station_status.json"; got != want {
+		t.Errorf("got the status feed %s, want the moved %s", got, want)
+	}
+	if len(placeRepo.imports) != 2 {
+		t.Errorf("got %d imports, want the stations imported again", len(placeRepo.imports))
+	}
+
+	// a system that cannot be discovered again keeps its last feeds
+	server.discoveryDown.Store(true)
+	bs.systems[source].importedAt = time.Now().Add(-bikeShareStationsRefresh)
+	if err := bs.Poll(ctx); err != nil {
+		t.Fatalf("Poll with the discovery down returned an error: %v", err)
+	}
+	if len(placeRepo.imports) != 3 || bs.bikesAvailable("london:101") != 7 {
+		t.Errorf("the stations and their availability were not read from the last feeds")
+	}
+}
+
+func TestBikeShareAnnotatePlacesSkipsStaleAvailability(t *testing.T) {
+	bs, _ := newTestBikeShareService(t)
+	now := time.Now()
+	reported := now.Add(-time.Minute)
+
+	bs.statuses = map[string]bikeStationStatus{
+		"london:1": {status: dao.GBFSStationStatus{StationId: "london:1", BikesAvailable: 3, DocksAvailable: 4,
+			IsInstalled: true, IsRenting: true, LastReported: &reported}, fetchedAt: now},
+		"london:2": {status: dao.GBFSStationStatus{StationId: "london:2", BikesAvailable: 3, IsInstalled: true},
+			fetchedAt: now.Add(-bikeShareStaleAfter*bs.interval + time.Second)},
+		"london:3": {status: dao.GBFSStationStatus{StationId: "london:3", BikesAvailable: 3, IsInstalled: true},
+			fetchedAt: now.Add(-bikeShareStaleAfter*bs.interval - time.Second)},
+		"london:4": {status: dao.GBFSStationStatus{StationId: "london:4", BikesAvailable: 3}, fetchedAt: now},
+	}
+
+	tests := []struct {
+		stationId string
+		want      *api.BikeAvailabilityResponse
+	}{
+		{"london:1", &api.BikeAvailabilityResponse{BikesAvailable: 3, DocksAvailable: 4, IsRenting: true, LastReported: &reported}},
+		{"london:2", &api.BikeAvailabilityResponse{BikesAvailable: 3}},
+		{"london:3", nil},
+		{"london:4", nil},
+		{"london:5", nil},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		places := []api.PlaceResponse{{Name: "Station", BikeStationId: tt.stationId}}
+		bs.AnnotatePlaces(places)
+
+		got := places[0].Availability
+		if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+			t.Errorf("got availability %+v for station %q, want %+v", got, tt.stationId, tt.want)
+		}
+	}
+}
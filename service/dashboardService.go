@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/lokate-go/config"
+	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+)
+
+// dashboardService holds the structure for services associated with the departures dashboard
+type dashboardService struct {
+	placeRepository      interfaces.PlaceRepositoryInterface
+	savedPlaceRepository interfaces.SavedPlaceRepositoryInterface
+	departureService     interfaces.DepartureServiceInterface
+	workers              int
+}
+
+// NewDashboardService returns an interface for the dashboard service methods
+func NewDashboardService(cfg *map[string]string, placeRepo interfaces.PlaceRepositoryInterface, savedPlaceRepo interfaces.SavedPlaceRepositoryInterface, departureService interfaces.DepartureServiceInterface) (interfaces.DashboardServiceInterface, error) {
+	workers, err := strconv.Atoi((*cfg)[config.DashboardWorkers])
+	if err != nil || workers <= 0 {
+		return nil, fmt.Errorf("invalid %s: %q", config.DashboardWorkers, (*cfg)[config.DashboardWorkers])
+	}
+
+	return &dashboardService{
+		placeRepository:      placeRepo,
+		savedPlaceRepository: savedPlaceRepo,
+		departureService:     departureService,
+		workers:              workers,
+	}, nil
+}
+
+// dashboardResult is the departure board of a pinned stop, or the reason it could not be fetched
+type dashboardResult struct {
+	board *api.DepartureBoardResponse
+	err   error
+}
+
+// Departures gets the departures from all the stops the user pinned, a few at a time, and merges them
+// into one list ordered by the time they leave. A stop that fails is reported on its own
+func (ds *dashboardService) Departures(ctx context.Context, userId primitive.ObjectID) (*api.DashboardResponse, error) {
+	var savedPlaces []dao.SavedPlace
+	if err := ds.savedPlaceRepository.FindPinned(ctx, userId, &savedPlaces); err != nil {
+		log.Printf("Error finding pinned places with userId: %s. Error: %v\n", userId, err)
+		return nil, errors.ErrInternalServerError("failed to retrieve pinned places", nil)
+	}
+
+	if _, err := ds.placeRepository.PopulatePlacesInSavedPlaces(ctx, &savedPlaces); err != nil {
+		log.Printf("Error populating places. Error: %v\n", err)
+		return nil, errors.ErrInternalServerError("failed to retrieve places", nil)
+	}
+
+	// fetch the boards with a bounded number of workers, each result is written to the index of its stop
+	results := make([]dashboardResult, len(savedPlaces))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < ds.workers && w < len(savedPlaces); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				board, err := ds.departureService.PlaceDepartures(&savedPlaces[i].Place)
+				results[i] = dashboardResult{board: board, err: err}
+			}
+		}()
+	}
+
+	for i := range savedPlaces {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			results[i] = dashboardResult{err: ctx.Err()}
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	resp := &api.DashboardResponse{
+		Stops:      make([]api.DashboardStopResponse, 0, len(savedPlaces)),
+		Departures: []api.DashboardDepartureResponse{},
+	}
+	for i, sp := range savedPlaces {
+		stop := api.DashboardStopResponse{SavedPlaceId: sp.Id.Hex(), Name: sp.Name}
+
+		r := results[i]
+		if r.err != nil {
+			log.Printf("Error getting departures for saved place: %v. Error: %v\n", sp.Id.Hex(), r.err)
+			stop.Error = r.err.Error()
+			resp.Stops = append(resp.Stops, stop)
+			continue
+		}
+
+		stop.StopCode, stop.StopName = r.board.StopCode, r.board.StopName
+		resp.Stops = append(resp.Stops, stop)
+
+		for _, d := range r.board.Departures {
+			resp.Departures = append(resp.Departures, api.DashboardDepartureResponse{
+				DepartureResponse: d,
+				SavedPlaceId:      stop.SavedPlaceId,
+				StopName:          stop.StopName,
+			})
+		}
+	}
+
+	sort.SliceStable(resp.Departures, func(i, j int) bool {
+		return resp.Departures[i].BestTime().Before(resp.Departures[j].BestTime())
+	})
+
+	return resp, nil
+}
@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/lokate-go/config"
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+)
+
+// pinnedSavedPlaceRepo returns its saved places as the pinned ones, the other saved place
+// repository methods are not used
+type pinnedSavedPlaceRepo struct {
+	interfaces.SavedPlaceRepositoryInterface
+	pinned []dao.SavedPlace
+}
+
+func (s *pinnedSavedPlaceRepo) FindPinned(ctx context.Context, userId primitive.ObjectID, savedPlaces *[]dao.SavedPlace) error {
+	*savedPlaces = append(*savedPlaces, s.pinned...)
+	return nil
+}
+
+// populatingPlaceRepo fills in the places of the saved places, the other place repository methods
+// are not used
+type populatingPlaceRepo struct {
+	interfaces.PlaceRepositoryInterface
+	places map[primitive.ObjectID]dao.Place
+}
+
+func (p *populatingPlaceRepo) PopulatePlacesInSavedPlaces(ctx context.Context, savedPlaces *[]dao.SavedPlace) (bool, error) {
+	for i := range *savedPlaces {
+		(*savedPlaces)[i].Place = p.places[(*savedPlaces)[i].PlaceId]
+	}
+	return true, nil
+}
+
+// boardsDepartureService answers with a fixed board for each ATCO code and fails the other stops
+type boardsDepartureService struct {
+	boards map[string]*api.DepartureBoardResponse
+}
+
+func (b *boardsDepartureService) PlaceDepartures(place *dao.Place) (*api.DepartureBoardResponse, error) {
+	if board, ok := b.boards[place.ATCOCode]; ok {
+		return board, nil
+	}
+	return nil, fmt.Errorf("no departures for %s", place.Name)
+}
+
+func (b *boardsDepartureService) Departures(stop dto.DepartureStop) (*api.DepartureBoardResponse, error) {
+	return nil, nil
+}
+
+func TestDashboardMergesPinnedStops(t *testing.T) {
+	now := time.Date(2023, 3, 1, 8, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time { return now.Add(time.Duration(minutes) * time.Minute) }
+	late := at(12)
+
+	places := map[primitive.ObjectID]dao.Place{}
+	var pinned []dao.SavedPlace
+	for _, code := range []string{"A", "B", "C"} {
+		place := dao.Place{Id: primitive.NewObjectID(), Name: "Stop " + code, ATCOCode: code}
+		if code == "C" {
+			place.ATCOCode = ""
+			place.StationCode = code
+		}
+		places[place.Id] = place
+		pinned = append(pinned, dao.SavedPlace{Id: primitive.NewObjectID(), PlaceId: place.Id, Name: "Pinned " + code, Pinned: true})
+	}
+
+	departures := &boardsDepartureService{boards: map[string]*api.DepartureBoardResponse{
+		"A": {StopCode: "A", StopName: "Stop A", Departures: []api.DepartureResponse{
+			{Line: "1", ScheduledTime: at(5)},
+			{Line: "2", ScheduledTime: at(10), ExpectedTime: &late},
+		}},
+		"B": {StopCode: "B", StopName: "Stop B", Departures: []api.DepartureResponse{
+			{Line: "3", ScheduledTime: at(8)},
+			{Line: "4", ScheduledTime: at(11)},
+		}},
+	}}
+
+	cfg := map[string]string{config.DashboardWorkers: "2"}
+	ds, err := NewDashboardService(&cfg, &populatingPlaceRepo{places: places}, &pinnedSavedPlaceRepo{pinned: pinned}, departures)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := ds.Departures(context.Background(), primitive.NewObjectID())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the departures are ordered by the time they are expected to leave
+	var lines string
+	for _, d := range resp.Departures {
+		lines += d.Line
+	}
+	if lines != "1342" {
+		t.Errorf("got the lines in the order %s, want 1342", lines)
+	}
+	if d := resp.Departures[0]; d.SavedPlaceId != pinned[0].Id.Hex() || d.StopName != "Stop A" {
+		t.Errorf("the first departure is from %s at %s, want the first pinned stop", d.SavedPlaceId, d.StopName)
+	}
+
+	// the stops keep the order they were pinned in and the one that failed reports its error
+	if len(resp.Stops) != 3 {
+		t.Fatalf("got %d stops, want 3", len(resp.Stops))
+	}
+	for i, stop := range resp.Stops {
+		if stop.SavedPlaceId != pinned[i].Id.Hex() || stop.Name != pinned[i].Name {
+			t.Errorf("stop %d is %+v, want the saved place %s", i, stop, pinned[i].Name)
+		}
+	}
+	if resp.Stops[0].Error != "" || resp.Stops[1].StopCode != "B" {
+		t.Errorf("the stops with departures got %+v and %+v", resp.Stops[0], resp.Stops[1])
+	}
+	if resp.Stops[2].Error == "" || resp.Stops[2].StopName != "" {
+		t.Errorf("the failed stop got %+v, want its error", resp.Stops[2])
+	}
+}
+
+func TestNewDashboardServiceRejectsWorkers(t *testing.T) {
+	for _, workers := range []string{"", "0", "-1", "two"} {
+		cfg := map[string]string{config.DashboardWorkers: workers}
+		if _, err := NewDashboardService(&cfg, nil, nil, nil); err == nil {
+			t.Errorf("%q workers were accepted", workers)
+		}
+	}
+}
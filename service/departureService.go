@@ -0,0 +1,125 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/leonardchinonso/lokate-go/config"
+	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+)
+
+// cachedDepartures is a departure board and the time it stops being served from the cache
+type cachedDepartures struct {
+	board     *api.DepartureBoardResponse
+	expiresAt time.Time
+}
+
+// departureService holds the structure for services associated with live departures
+type departureService struct {
+	transitService interfaces.TransitServiceInterface
+	cacheTTL       time.Duration
+
+	mu    sync.Mutex
+	cache map[dto.DepartureStop]cachedDepartures
+	// fetches makes the requests for a stop that is not cached share a single upstream call
+	fetches singleflight.Group
+}
+
+// NewDepartureService returns an interface for the departure service methods
+func NewDepartureService(cfg *map[string]string, transitService interfaces.TransitServiceInterface) (interfaces.DepartureServiceInterface, error) {
+	seconds, err := strconv.Atoi((*cfg)[config.DeparturesCacheSeconds])
+	if err != nil || seconds < 0 {
+		return nil, fmt.Errorf("invalid %s: %q", config.DeparturesCacheSeconds, (*cfg)[config.DeparturesCacheSeconds])
+	}
+
+	return &departureService{
+		transitService: transitService,
+		cacheTTL:       time.Duration(seconds) * time.Second,
+		cache:          make(map[dto.DepartureStop]cachedDepartures),
+	}, nil
+}
+
+// PlaceDepartures gets the live departures from a place
+func (ds *departureService) PlaceDepartures(place *dao.Place) (*api.DepartureBoardResponse, error) {
+	stop, err := placeDepartureStop(place)
+	if err != nil {
+		return nil, err
+	}
+
+	return ds.Departures(stop)
+}
+
+// placeDepartureStop returns the stop to get the departures of a place from, by its CRS code
+// for a train station, by its ATCO code for any other stop and by its id for a stop found by
+// OpenTripPlanner or in the GTFS feeds
+func placeDepartureStop(place *dao.Place) (dto.DepartureStop, error) {
+	switch {
+	case place.Type == string(dto.TrainStation) && place.StationCode != "":
+		return dto.DepartureStop{Type: dto.TrainStation, Code: place.StationCode}, nil
+	case place.ATCOCode != "":
+		return dto.DepartureStop{Type: dto.BusStop, Code: place.ATCOCode}, nil
+	case place.StationCode != "":
+		return dto.DepartureStop{Type: dto.TrainStation, Code: place.StationCode}, nil
+	case place.OTPStopId != "":
+		return dto.DepartureStop{Type: dto.OTPStop, Code: place.OTPStopId}, nil
+	case place.GTFSStopId != "":
+		return dto.DepartureStop{Type: dto.GTFSStop, Code: place.GTFSStopId}, nil
+	}
+
+	log.Printf("Failed to get departures for place: %v. Error: place has no stop code\n", place.Id.Hex())
+	return dto.DepartureStop{}, errors.ErrBadRequest("place is not a bus stop or a train station", nil)
+}
+
+// Departures gets the live departures from a stop, boards fetched in the last few seconds are served from the cache
+// and the requests for a stop that arrive while its board is being fetched wait for that fetch
+func (ds *departureService) Departures(stop dto.DepartureStop) (*api.DepartureBoardResponse, error) {
+	if err := stop.Validate(); err != nil {
+		return nil, errors.ErrBadRequest(err.Error(), nil)
+	}
+
+	ds.mu.Lock()
+	cached, ok := ds.cache[stop]
+	ds.mu.Unlock()
+	if ok && time.Now().Before(cached.expiresAt) {
+		return cached.board, nil
+	}
+
+	board, err, _ := ds.fetches.Do(string(stop.Type)+":"+stop.Code, func() (interface{}, error) {
+		return ds.fetch(stop)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return board.(*api.DepartureBoardResponse), nil
+}
+
+// fetch gets the live departures from a stop from the transit providers and caches them
+func (ds *departureService) fetch(stop dto.DepartureStop) (*api.DepartureBoardResponse, error) {
+	board, err := ds.transitService.Departures(stop)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	ds.mu.Lock()
+	// drop the expired boards so stops that are not asked for again do not stay in memory
+	for s, c := range ds.cache {
+		if !now.Before(c.expiresAt) {
+			delete(ds.cache, s)
+		}
+	}
+	ds.cache[stop] = cachedDepartures{board: board, expiresAt: now.Add(ds.cacheTTL)}
+	ds.mu.Unlock()
+
+	return board, nil
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/leonardchinonso/lokate-go/config"
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+)
+
+// blockingTransit answers the departure requests once it is released and counts them
+type blockingTransit struct {
+	release chan struct{}
+	calls   int32
+}
+
+func (b *blockingTransit) Name() string { return "blocking" }
+
+func (b *blockingTransit) SearchPlace(searchStr string, places *[]dao.Place) ([]api.PlaceResponse, error) {
+	return nil, nil
+}
+
+func (b *blockingTransit) NearestPlaces(query dto.NearestPlacesQuery, places *[]dao.Place) ([]api.PlaceResponse, error) {
+	return nil, nil
+}
+
+func (b *blockingTransit) PublicJourney(from dto.JourneyPoint, to dto.JourneyPoint, opts dto.JourneyOptions) (*api.JourneyResponse, error) {
+	return nil, nil
+}
+
+func (b *blockingTransit) Departures(stop dto.DepartureStop) (*api.DepartureBoardResponse, error) {
+	atomic.AddInt32(&b.calls, 1)
+	<-b.release
+	return &api.DepartureBoardResponse{StopCode: stop.Code}, nil
+}
+
+func TestDeparturesShareConcurrentFetches(t *testing.T) {
+	transit := &blockingTransit{release: make(chan struct{})}
+	cfg := map[string]string{config.DeparturesCacheSeconds: "30"}
+	ds, err := NewDepartureService(&cfg, transit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := dto.DepartureStop{Type: dto.BusStop, Code: "490000077E"}
+	boards := make([]*api.DepartureBoardResponse, 10)
+	var wg sync.WaitGroup
+	for i := range boards {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			board, err := ds.Departures(stop)
+			if err != nil {
+				t.Errorf("Departures returned an error: %v", err)
+			}
+			boards[i] = board
+		}(i)
+	}
+
+	// let the requests pile up behind the first fetch before it returns
+	for atomic.LoadInt32(&transit.calls) == 0 {
+		runtime.Gosched()
+	}
+	close(transit.release)
+	wg.Wait()
+
+	if calls := atomic.LoadInt32(&transit.calls); calls != 1 {
+		t.Errorf("the provider was called %d times, want once", calls)
+	}
+	for i, board := range boards {
+		if board == nil || board.StopCode != stop.Code {
+			t.Errorf("request %d got board %+v", i, board)
+		}
+	}
+
+	// the board is now served from the cache
+	if _, err := ds.Departures(stop); err != nil {
+		t.Fatal(err)
+	}
+	if calls := atomic.LoadInt32(&transit.calls); calls != 1 {
+		t.Errorf("the provider was called %d times after a cached request, want once", calls)
+	}
+}
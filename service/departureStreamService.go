@@ -0,0 +1,167 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/leonardchinonso/lokate-go/config"
+	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+)
+
+// departureStreamBuffer is the number of events a subscriber can fall behind before it is dropped
+const departureStreamBuffer = 8
+
+// departureStream polls the departures of a stop once for all of its subscribers
+type departureStream struct {
+	subscribers map[chan api.DepartureStreamEvent]bool
+	last        *api.DepartureBoardResponse
+	done        chan struct{}
+}
+
+// departureStreamService holds the structure for streaming departure boards to clients
+type departureStreamService struct {
+	departureService interfaces.DepartureServiceInterface
+	interval         time.Duration
+
+	mu      sync.Mutex
+	streams map[dto.DepartureStop]*departureStream
+	closed  bool
+}
+
+// NewDepartureStreamService returns an interface for the departure stream service methods
+func NewDepartureStreamService(cfg *map[string]string, departureService interfaces.DepartureServiceInterface) (interfaces.DepartureStreamServiceInterface, error) {
+	seconds, err := strconv.Atoi((*cfg)[config.DeparturesStreamSeconds])
+	if err != nil || seconds <= 0 {
+		return nil, fmt.Errorf("invalid %s: %q", config.DeparturesStreamSeconds, (*cfg)[config.DeparturesStreamSeconds])
+	}
+
+	return &departureStreamService{
+		departureService: departureService,
+		interval:         time.Duration(seconds) * time.Second,
+		streams:          make(map[dto.DepartureStop]*departureStream),
+	}, nil
+}
+
+// Subscribe returns the events of the departure board of a place and the function to stop receiving them
+// the channel is closed when the subscriber falls behind or the service is closed
+func (dss *departureStreamService) Subscribe(place *dao.Place) (<-chan api.DepartureStreamEvent, func(), error) {
+	stop, err := placeDepartureStop(place)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	dss.mu.Lock()
+	defer dss.mu.Unlock()
+
+	if dss.closed {
+		return nil, nil, errors.ErrInternalServerError("server is shutting down", nil)
+	}
+
+	stream, ok := dss.streams[stop]
+	if !ok {
+		stream = &departureStream{subscribers: make(map[chan api.DepartureStreamEvent]bool), done: make(chan struct{})}
+		dss.streams[stop] = stream
+		go dss.poll(stop, stream)
+	}
+
+	events := make(chan api.DepartureStreamEvent, departureStreamBuffer)
+	stream.subscribers[events] = true
+
+	// a new subscriber to a stream that is running starts from the last board
+	if stream.last != nil {
+		events <- api.DepartureStreamEvent{Name: api.DepartureSnapshotEvent, Data: stream.last}
+	}
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			dss.mu.Lock()
+			defer dss.mu.Unlock()
+			dss.removeSubscriber(stop, stream, events)
+		})
+	}
+
+	return events, unsubscribe, nil
+}
+
+// Close ends all the streams, which closes the channels of their subscribers
+func (dss *departureStreamService) Close() {
+	dss.mu.Lock()
+	defer dss.mu.Unlock()
+
+	dss.closed = true
+	for stop, stream := range dss.streams {
+		for events := range stream.subscribers {
+			dss.removeSubscriber(stop, stream, events)
+		}
+	}
+}
+
+// removeSubscriber closes the channel of a subscriber and stops polling the stop when it was the last one
+// the caller must hold the lock
+func (dss *departureStreamService) removeSubscriber(stop dto.DepartureStop, stream *departureStream, events chan api.DepartureStreamEvent) {
+	if !stream.subscribers[events] {
+		return
+	}
+
+	delete(stream.subscribers, events)
+	close(events)
+
+	if len(stream.subscribers) == 0 {
+		close(stream.done)
+		delete(dss.streams, stop)
+	}
+}
+
+// poll fetches the departures of a stop until it has no subscribers, and sends the whole board
+// the first time and only the departures that changed after that
+func (dss *departureStreamService) poll(stop dto.DepartureStop, stream *departureStream) {
+	ticker := time.NewTicker(dss.interval)
+	defer ticker.Stop()
+
+	for {
+		board, err := dss.departureService.Departures(stop)
+
+		dss.mu.Lock()
+		var event *api.DepartureStreamEvent
+		switch {
+		case err != nil:
+			log.Printf("Error streaming departures for stop: %v. Error: %v\n", stop.Code, err)
+			event = &api.DepartureStreamEvent{Name: api.DepartureErrorEvent, Data: err}
+		case stream.last == nil:
+			event = &api.DepartureStreamEvent{Name: api.DepartureSnapshotEvent, Data: board}
+		default:
+			if diff := api.DiffDepartureBoards(stream.last, board); !diff.IsEmpty() {
+				event = &api.DepartureStreamEvent{Name: api.DepartureUpdateEvent, Data: diff}
+			}
+		}
+		if err == nil {
+			stream.last = board
+		}
+
+		if event != nil {
+			for events := range stream.subscribers {
+				select {
+				case events <- *event:
+				default:
+					// a subscriber that stopped reading is dropped so it cannot hold up the others
+					dss.removeSubscriber(stop, stream, events)
+				}
+			}
+		}
+		dss.mu.Unlock()
+
+		select {
+		case <-stream.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
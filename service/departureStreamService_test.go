@@ -0,0 +1,242 @@
+package service
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leonardchinonso/lokate-go/config"
+	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+)
+
+// delayingDepartureService answers with one departure that is a minute later on every poll, so
+// every poll after the first changes the board, the other departure service methods are not used
+type delayingDepartureService struct {
+	interfaces.DepartureServiceInterface
+
+	mu    sync.Mutex
+	polls map[string]int
+}
+
+func (d *delayingDepartureService) Departures(stop dto.DepartureStop) (*api.DepartureBoardResponse, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.polls[stop.Code]++
+	scheduled := time.Date(2023, 3, 1, 8, 0, 0, 0, time.UTC)
+	expected := scheduled.Add(time.Duration(d.polls[stop.Code]) * time.Minute)
+	return &api.DepartureBoardResponse{StopCode: stop.Code, Departures: []api.DepartureResponse{
+		{Line: "12", Destination: "Dulwich", ScheduledTime: scheduled, ExpectedTime: &expected, TripId: "12-1"},
+	}}, nil
+}
+
+// newTestDepartureStreamService returns a departure stream service polling every few milliseconds
+func newTestDepartureStreamService(t *testing.T) *departureStreamService {
+	t.Helper()
+
+	cfg := map[string]string{config.DeparturesStreamSeconds: "1"}
+	svc, err := NewDepartureStreamService(&cfg, &delayingDepartureService{polls: make(map[string]int)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dss := svc.(*departureStreamService)
+	dss.interval = 5 * time.Millisecond
+	return dss
+}
+
+// receive returns the next event of a stream, or fails the test when none is sent in time
+func receive(t *testing.T, events <-chan api.DepartureStreamEvent) (api.DepartureStreamEvent, bool) {
+	t.Helper()
+
+	select {
+	case event, ok := <-events:
+		return event, ok
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event was sent")
+		return api.DepartureStreamEvent{}, false
+	}
+}
+
+// receiveUntilClosed returns the events of a stream until its channel is closed
+func receiveUntilClosed(t *testing.T, events <-chan api.DepartureStreamEvent) []api.DepartureStreamEvent {
+	t.Helper()
+
+	var received []api.DepartureStreamEvent
+	for {
+		event, ok := receive(t, events)
+		if !ok {
+			return received
+		}
+		received = append(received, event)
+	}
+}
+
+// subscribers returns the number of subscribers of the stream of a stop
+func (dss *departureStreamService) subscribers(stop dto.DepartureStop) int {
+	dss.mu.Lock()
+	defer dss.mu.Unlock()
+
+	if stream, ok := dss.streams[stop]; ok {
+		return len(stream.subscribers)
+	}
+	return 0
+}
+
+func TestDepartureStreamSendsSnapshotThenUpdates(t *testing.T) {
+	dss := newTestDepartureStreamService(t)
+	defer dss.Close()
+
+	events, unsubscribe, err := dss.Subscribe(&dao.Place{Name: "Stop A", ATCOCode: "A"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsubscribe()
+
+	if event, _ := receive(t, events); event.Name != api.DepartureSnapshotEvent {
+		t.Fatalf("got a first event %q, want a snapshot", event.Name)
+	}
+	event, _ := receive(t, events)
+	diff, ok := event.Data.(*api.DepartureDiffResponse)
+	if event.Name != api.DepartureUpdateEvent || !ok || len(diff.Changed) != 1 || len(diff.Added)+len(diff.Removed) != 0 {
+		t.Fatalf("got a second event %q with %+v, want an update changing the departure", event.Name, event.Data)
+	}
+}
+
+func TestDepartureStreamLateSubscriberGetsSnapshot(t *testing.T) {
+	dss := newTestDepartureStreamService(t)
+	defer dss.Close()
+	place := &dao.Place{Name: "Stop A", ATCOCode: "A"}
+
+	first, unsubscribeFirst, err := dss.Subscribe(place)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsubscribeFirst()
+	if event, _ := receive(t, first); event.Name != api.DepartureSnapshotEvent {
+		t.Fatalf("got a first event %q, want a snapshot", event.Name)
+	}
+
+	// the late subscriber joins the running stream and starts from its last board, not from an update
+	late, unsubscribeLate, err := dss.Subscribe(place)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsubscribeLate()
+	if n := dss.subscribers(dto.DepartureStop{Type: dto.BusStop, Code: "A"}); n != 2 {
+		t.Fatalf("got %d subscribers of the stream, want the late one to share it", n)
+	}
+	event, _ := receive(t, late)
+	board, ok := event.Data.(*api.DepartureBoardResponse)
+	if event.Name != api.DepartureSnapshotEvent || !ok || len(board.Departures) != 1 {
+		t.Fatalf("got a first event %q with %+v for the late subscriber, want the snapshot of the board", event.Name, event.Data)
+	}
+}
+
+func TestDepartureStreamDropsSlowSubscriber(t *testing.T) {
+	dss := newTestDepartureStreamService(t)
+	defer dss.Close()
+	place := &dao.Place{Name: "Stop A", ATCOCode: "A"}
+	stop := dto.DepartureStop{Type: dto.BusStop, Code: "A"}
+
+	slow, _, err := dss.Subscribe(place)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reading, unsubscribe, err := dss.Subscribe(place)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unsubscribe()
+
+	stopReading := make(chan struct{})
+	read := make(chan int)
+	go func() {
+		n := 0
+		for {
+			select {
+			case _, ok := <-reading:
+				if !ok {
+					read <- n
+					return
+				}
+				n++
+			case <-stopReading:
+				read <- n
+				return
+			}
+		}
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for dss.subscribers(stop) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatal("the subscriber that stopped reading was not dropped")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// the dropped subscriber keeps the events it was sent before its channel is closed
+	if got := receiveUntilClosed(t, slow); len(got) != departureStreamBuffer {
+		t.Errorf("got %d events for the dropped subscriber, want the %d of its buffer", len(got), departureStreamBuffer)
+	}
+
+	// the subscriber that reads is not held up by the dropped one
+	time.Sleep(10 * dss.interval)
+	close(stopReading)
+	if n := <-read; n <= departureStreamBuffer {
+		t.Errorf("the reading subscriber got %d events, want it to keep receiving updates", n)
+	}
+}
+
+func TestDepartureStreamCloseEndsEveryStream(t *testing.T) {
+	dss := newTestDepartureStreamService(t)
+
+	var streams []<-chan api.DepartureStreamEvent
+	for _, place := range []*dao.Place{
+		{Name: "Stop A", ATCOCode: "A"},
+		{Name: "Stop A", ATCOCode: "A"},
+		{Name: "Charing Cross", StationCode: "CHX"},
+	} {
+		events, _, err := dss.Subscribe(place)
+		if err != nil {
+			t.Fatal(err)
+		}
+		streams = append(streams, events)
+	}
+	dss.mu.Lock()
+	var done []chan struct{}
+	for _, stream := range dss.streams {
+		done = append(done, stream.done)
+	}
+	dss.mu.Unlock()
+	if len(done) != 2 {
+		t.Fatalf("got %d streams, want one for each stop", len(done))
+	}
+
+	dss.Close()
+
+	// the channels of the subscribers are closed, after the events they were already sent
+	for _, events := range streams {
+		receiveUntilClosed(t, events)
+	}
+	for _, d := range done {
+		select {
+		case <-d:
+		default:
+			t.Error("a stream was not told to stop polling")
+		}
+	}
+	if len(dss.streams) != 0 {
+		t.Errorf("got %d streams after Close, want none", len(dss.streams))
+	}
+
+	_, _, err := dss.Subscribe(&dao.Place{Name: "Stop A", ATCOCode: "A"})
+	if errors.Status(err) != http.StatusInternalServerError {
+		t.Errorf("got error %v subscribing after Close, want the server to be shutting down", err)
+	}
+}
@@ -0,0 +1,394 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leonardchinonso/lokate-go/config"
+	"github.com/leonardchinonso/lokate-go/datasource"
+	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/metrics"
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+)
+
+const (
+	// gtfsProviderName is the name the imported GTFS feeds are selected by in the transit provider config
+	gtfsProviderName = "gtfs"
+	// gtfsMaxSearchResults is the most stops returned when searching for a place
+	gtfsMaxSearchResults = 20
+	// gtfsMaxDepartures is the most departures returned for a stop
+	gtfsMaxDepartures = 20
+	// gtfsNearestRadiusMetres is how far from a point the nearest stops are looked for
+	gtfsNearestRadiusMetres = 1000
+)
+
+// gtfsService holds the structure for services associated with the imported GTFS feeds
+type gtfsService struct {
+	gtfsRepo      interfaces.GTFSRepositoryInterface
+	maxWalkMetres int
+
+	mu        sync.RWMutex
+	timetable *raptorTimetable
+}
+
+// NewGTFSService returns the transit provider backed by the imported GTFS feeds
+func NewGTFSService(cfg *map[string]string, gtfsRepo interfaces.GTFSRepositoryInterface) (interfaces.GTFSServiceInterface, error) {
+	maxWalkMetres, err := strconv.Atoi((*cfg)[config.GTFSMaxWalkMetres])
+	if err != nil || maxWalkMetres <= 0 {
+		return nil, fmt.Errorf("invalid %s: %q", config.GTFSMaxWalkMetres, (*cfg)[config.GTFSMaxWalkMetres])
+	}
+
+	return &gtfsService{
+		gtfsRepo:      gtfsRepo,
+		maxWalkMetres: maxWalkMetres,
+		timetable:     newRaptorTimetable(nil, maxWalkMetres),
+	}, nil
+}
+
+// GTFSFeedPaths returns the configured GTFS feed files by their feed id, which is the file name without the extension
+func GTFSFeedPaths(cfg *map[string]string) map[string]string {
+	paths := make(map[string]string)
+	for _, path := range strings.Split((*cfg)[config.GTFSFeedPaths], ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths[strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))] = path
+		}
+	}
+	return paths
+}
+
+// Name returns the name of the transit provider
+func (gs *gtfsService) Name() string {
+	return gtfsProviderName
+}
+
+// ImportFeed imports a GTFS static zip file into the store
+// the import is skipped when the file has not changed since it was last imported
+func (gs *gtfsService) ImportFeed(ctx context.Context, feedId, path string) error {
+	checksum, err := datasource.GTFSChecksum(path)
+	if err != nil {
+		return fmt.Errorf("failed to checksum GTFS feed %s: %v", path, err)
+	}
+
+	info := dao.GTFSFeedInfo{FeedId: feedId}
+	exists, err := gs.gtfsRepo.FindFeedInfo(ctx, &info)
+	if err != nil {
+		return err
+	}
+	if exists && info.Checksum == checksum {
+		log.Printf("GTFS feed %s is unchanged since %v, skipping the import\n", feedId, info.ImportedAt)
+		return nil
+	}
+
+	feed, err := datasource.ReadGTFSFeed(feedId, path)
+	if err != nil {
+		return err
+	}
+	feed.Info.ImportedAt = time.Now()
+	if feed.RejectedStops > 0 {
+		log.Printf("Skipped %d stops of GTFS feed %s without an id or a valid coordinate\n", feed.RejectedStops, feedId)
+		metrics.RejectedPlaces.Add(gtfsProviderName, int64(feed.RejectedStops))
+	}
+
+	if err := gs.gtfsRepo.ReplaceFeed(ctx, feed); err != nil {
+		return err
+	}
+
+	log.Printf("Imported GTFS feed %s with %d stops, %d trips and %d stop times\n",
+		feedId, len(feed.Stops), len(feed.Trips), len(feed.StopTimes))
+	return nil
+}
+
+// LoadFeeds loads the imported feeds from the store into the router
+func (gs *gtfsService) LoadFeeds(ctx context.Context) error {
+	var infos []dao.GTFSFeedInfo
+	if err := gs.gtfsRepo.FindFeedInfos(ctx, &infos); err != nil {
+		return err
+	}
+
+	feeds := make([]*dao.GTFSFeed, 0, len(infos))
+	for _, info := range infos {
+		feed := &dao.GTFSFeed{Info: dao.GTFSFeedInfo{FeedId: info.FeedId}}
+		if err := gs.gtfsRepo.LoadFeed(ctx, feed); err != nil {
+			return err
+		}
+		feeds = append(feeds, feed)
+	}
+
+	timetable := newRaptorTimetable(feeds, gs.maxWalkMetres)
+
+	gs.mu.Lock()
+	gs.timetable = timetable
+	gs.mu.Unlock()
+
+	log.Printf("Loaded %d GTFS feeds with %d stops and %d routes\n", len(feeds), len(timetable.stops), len(timetable.routes))
+	return nil
+}
+
+// currentTimetable returns the timetable of the feeds loaded last
+func (gs *gtfsService) currentTimetable() *raptorTimetable {
+	gs.mu.RLock()
+	defer gs.mu.RUnlock()
+	return gs.timetable
+}
+
+// SearchPlace searches for stops whose name contains the search string
+func (gs *gtfsService) SearchPlace(searchStr string, places *[]dao.Place) ([]api.PlaceResponse, error) {
+	// the search string is escaped by the caller already
+	query, err := url.QueryUnescape(searchStr)
+	if err != nil || strings.TrimSpace(query) == "" {
+		log.Printf("Failed to search GTFS stops. Error: invalid search query\n")
+		return nil, errors.ErrBadRequest("invalid search query", nil)
+	}
+	query = strings.ToLower(strings.TrimSpace(query))
+
+	tt := gs.currentTimetable()
+	for i := range tt.stops {
+		s := &tt.stops[i]
+		if !strings.Contains(strings.ToLower(s.name), query) {
+			continue
+		}
+
+		*places = append(*places, *gtfsStopPlace(tt.stopPlaceType(i), s))
+		if len(*places) == gtfsMaxSearchResults {
+			break
+		}
+	}
+
+	return api.ToPlacesResponses(places), nil
+}
+
+// NearestPlaces finds the stops nearest to a point, nearest first
+func (gs *gtfsService) NearestPlaces(query dto.NearestPlacesQuery, places *[]dao.Place) ([]api.PlaceResponse, error) {
+	tt := gs.currentTimetable()
+
+	paths := tt.nearbyStops(query.Latitude, query.Longitude, gtfsNearestRadiusMetres)
+	sort.Slice(paths, func(i, j int) bool {
+		return paths[i].metres < paths[j].metres
+	})
+
+	for _, p := range paths {
+		placeType := tt.stopPlaceType(p.to)
+		if !query.HasType(placeType) {
+			continue
+		}
+
+		s := &tt.stops[p.to]
+		metres := p.metres
+		place := gtfsStopPlace(placeType, s)
+		place.Distance = &metres
+
+		*places = append(*places, *place)
+		if len(*places) == gtfsMaxSearchResults {
+			break
+		}
+	}
+
+	return api.ToPlacesResponses(places), nil
+}
+
+// PublicJourney plans the journeys between two coordinates with the earliest arrival over the imported feeds
+func (gs *gtfsService) PublicJourney(from dto.JourneyPoint, to dto.JourneyPoint, opts dto.JourneyOptions) (*api.JourneyResponse, error) {
+	if opts.TimeType == dto.ArriveBy {
+		return nil, errors.ErrNotImplemented("the GTFS planner only plans journeys by departure time", nil)
+	}
+
+	fromLat, fromLon, err := gtfsCoordinates(from)
+	if err != nil {
+		return nil, err
+	}
+	toLat, toLon, err := gtfsCoordinates(to)
+	if err != nil {
+		return nil, err
+	}
+
+	tt := gs.currentTimetable()
+
+	departAt := time.Now().In(tt.location)
+	if opts.HasTime() {
+		// the client sends the local time of the journey
+		departAt = time.Date(opts.Time.Year(), opts.Time.Month(), opts.Time.Day(), opts.Time.Hour(), opts.Time.Minute(), 0, 0, tt.location)
+	}
+	serviceDay := tt.serviceDay(departAt)
+
+	q := raptorQuery{
+		fromLat: fromLat, fromLon: fromLon,
+		toLat: toLat, toLon: toLon,
+		date:          departAt,
+		departure:     int(departAt.Sub(serviceDay).Seconds()),
+		maxWalkMetres: gs.maxWalkMetres,
+		maxRounds:     raptorMaxRounds,
+		allowedModes:  gtfsAllowedModes(opts),
+	}
+	if opts.MaxWalkDistance > 0 && opts.MaxWalkDistance < q.maxWalkMetres {
+		q.maxWalkMetres = opts.MaxWalkDistance
+	}
+	if opts.MaxChanges != nil && *opts.MaxChanges+1 < q.maxRounds {
+		q.maxRounds = *opts.MaxChanges + 1
+	}
+
+	found := tt.search(q)
+	if len(found) == 0 {
+		return nil, errors.ErrBadRequest("failed to plan journey: no route found between the points", nil)
+	}
+
+	routes := make([]api.RouteResponse, 0, len(found))
+	for _, parts := range found {
+		route := api.RouteResponse{
+			DepartureTime: parts[0].DepartureTime,
+			ArrivalTime:   parts[len(parts)-1].ArrivalTime,
+			Parts:         parts,
+		}
+		route.DurationMinutes = int(route.ArrivalTime.Sub(route.DepartureTime).Minutes())
+		route.Summarize()
+		routes = append(routes, route)
+	}
+
+	return &api.JourneyResponse{
+		RequestTime: time.Now().In(tt.location).Format(time.RFC3339),
+		Routes:      routes,
+	}, nil
+}
+
+// Departures gets the scheduled departures from a stop, found by its feed-namespaced id for a
+// GTFS stop or by its stop id, which is the ATCO code or the CRS code in the UK feeds, for any other stop
+func (gs *gtfsService) Departures(stop dto.DepartureStop) (*api.DepartureBoardResponse, error) {
+	// the stops found by OpenTripPlanner are not known to the GTFS feeds, the next provider is tried instead
+	if stop.Type == dto.OTPStop {
+		return nil, errors.ErrNotImplemented("the GTFS provider does not get departures for OTP stops", nil)
+	}
+
+	tt := gs.currentTimetable()
+
+	stopIdx := -1
+	for i := range tt.stops {
+		s := &tt.stops[i]
+		if (stop.Type == dto.GTFSStop && gtfsStopId(s) == stop.Code) || (stop.Type != dto.GTFSStop && s.stopId == stop.Code) {
+			stopIdx = i
+			break
+		}
+	}
+	if stopIdx < 0 {
+		return nil, errors.ErrBadRequest(fmt.Sprintf("stop %s is not in the GTFS feeds", stop.Code), nil)
+	}
+
+	now := time.Now().In(tt.location)
+	serviceDay := tt.serviceDay(now)
+	after := int(now.Sub(serviceDay).Seconds())
+
+	var departures []api.DepartureResponse
+	for _, rs := range tt.routesByStop[stopIdx] {
+		route := &tt.routes[rs.route]
+
+		// nothing departs from the last stop of a route
+		if rs.position == len(route.stops)-1 {
+			continue
+		}
+
+		for _, day := range []int{-1, 0} {
+			date := now.AddDate(0, 0, day)
+			for _, trip := range route.trips {
+				departure := trip.departures[rs.position] + day*secondsPerDay
+				if departure < after || !tt.serviceRuns(trip.serviceKey, date) {
+					continue
+				}
+				departures = append(departures, api.DepartureResponse{
+					Mode:          route.mode,
+					Line:          route.lineName,
+					Destination:   trip.headsign,
+					ScheduledTime: serviceDay.Add(time.Duration(departure) * time.Second),
+					TripId:        trip.tripId,
+					ServiceDate:   date.Format("20060102"),
+					StopSequence:  &trip.sequences[rs.position],
+				})
+			}
+		}
+	}
+
+	sort.SliceStable(departures, func(i, j int) bool { return departures[i].ScheduledTime.Before(departures[j].ScheduledTime) })
+	if len(departures) > gtfsMaxDepartures {
+		departures = departures[:gtfsMaxDepartures]
+	}
+
+	return &api.DepartureBoardResponse{
+		StopCode:   stop.Code,
+		StopName:   tt.stops[stopIdx].name,
+		Departures: departures,
+	}, nil
+}
+
+// stopPlaceType returns the place type of a stop from the modes that serve it
+func (tt *raptorTimetable) stopPlaceType(stop int) string {
+	for _, rs := range tt.routesByStop[stop] {
+		if tt.routes[rs.route].mode == api.ModeTrain {
+			return string(dto.TrainStation)
+		}
+	}
+	return string(dto.BusStop)
+}
+
+// gtfsStopId returns the id of a stop namespaced by its feed, since the stop ids of two feeds can collide
+func gtfsStopId(s *raptorStop) string {
+	return s.feedId + ":" + s.stopId
+}
+
+// gtfsStopPlace returns the place of a stop, keyed by its feed-namespaced id. The stop code riders
+// text for departures is kept as the SMS code, it is not an ATCO code
+func gtfsStopPlace(placeType string, s *raptorStop) *dao.Place {
+	lat, lon := s.latitude, s.longitude
+	place := dao.NewPlace(placeType, s.name, "", "", "", "", "", s.code, nil, &lat, &lon)
+	place.GTFSStopId = gtfsStopId(s)
+	place.Key = dao.PlaceKey(place)
+	return place
+}
+
+// gtfsCoordinates converts a journey point to a latitude and longitude pair
+func gtfsCoordinates(point dto.JourneyPoint) (float64, float64, error) {
+	if point.Kind != dto.LonLatPoint {
+		log.Printf("Failed to convert journey point of kind: %v for GTFS\n", point.Kind)
+		return 0, 0, errors.ErrNotImplemented(fmt.Sprintf("the GTFS planner does not support %s journey points", point.Kind), nil)
+	}
+
+	lonStr, latStr, found := strings.Cut(point.Value, ",")
+	if !found {
+		return 0, 0, errors.ErrBadRequest("invalid journey point", nil)
+	}
+
+	lon, err := strconv.ParseFloat(lonStr, 64)
+	if err != nil {
+		return 0, 0, errors.ErrBadRequest("invalid journey point", nil)
+	}
+	lat, err := strconv.ParseFloat(latStr, 64)
+	if err != nil {
+		return 0, 0, errors.ErrBadRequest("invalid journey point", nil)
+	}
+
+	return lat, lon, nil
+}
+
+// gtfsAllowedModes returns the vehicle modes the router may use for a journey
+func gtfsAllowedModes(opts dto.JourneyOptions) map[api.JourneyMode]bool {
+	allowed := make(map[api.JourneyMode]bool)
+	if len(opts.Modes) == 0 {
+		for _, m := range []api.JourneyMode{api.ModeBus, api.ModeTrain, api.ModeTube, api.ModeTram, api.ModeBoat, api.ModeOther} {
+			allowed[m] = true
+		}
+	}
+	for _, m := range opts.Modes {
+		allowed[m] = true
+	}
+	for _, m := range opts.NotModes {
+		delete(allowed, m)
+	}
+	return allowed
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/leonardchinonso/lokate-go/config"
+	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+)
+
+// memoryGTFSRepo keeps the imported feeds in memory
+type memoryGTFSRepo struct {
+	feeds    map[string]dao.GTFSFeed
+	replaced int
+}
+
+func newMemoryGTFSRepo() *memoryGTFSRepo {
+	return &memoryGTFSRepo{feeds: make(map[string]dao.GTFSFeed)}
+}
+
+func (m *memoryGTFSRepo) FindFeedInfo(ctx context.Context, info *dao.GTFSFeedInfo) (bool, error) {
+	feed, ok := m.feeds[info.FeedId]
+	if ok {
+		*info = feed.Info
+	}
+	return ok, nil
+}
+
+func (m *memoryGTFSRepo) FindFeedInfos(ctx context.Context, infos *[]dao.GTFSFeedInfo) error {
+	for _, feed := range m.feeds {
+		*infos = append(*infos, feed.Info)
+	}
+	return nil
+}
+
+func (m *memoryGTFSRepo) ReplaceFeed(ctx context.Context, feed *dao.GTFSFeed) error {
+	m.feeds[feed.Info.FeedId] = *feed
+	m.replaced++
+	return nil
+}
+
+func (m *memoryGTFSRepo) LoadFeed(ctx context.Context, feed *dao.GTFSFeed) error {
+	stored, ok := m.feeds[feed.Info.FeedId]
+	if !ok {
+		return fmt.Errorf("gtfs feed %s not found", feed.Info.FeedId)
+	}
+	*feed = stored
+	return nil
+}
+
+func (m *memoryGTFSRepo) EnsureIndexes(ctx context.Context) error {
+	return nil
+}
+
+// zipGTFSFixture zips the files of the fixture feed in testdata/gtfs, leaving out the skipped files
+func zipGTFSFixture(t *testing.T, skip ...string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.zip")
+	out, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer out.Close()
+
+	files, err := filepath.Glob(filepath.Join("testdata", "gtfs", "*.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zw := zip.NewWriter(out)
+files:
+	for _, file := range files {
+		for _, s := range skip {
+			if filepath.Base(file) == s {
+				continue files
+			}
+		}
+		w, err := zw.Create(filepath.Base(file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		in, err := os.Open(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = io.Copy(w, in)
+		in.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// newFixtureGTFSService imports the fixture feed and loads it into a GTFS provider
+func newFixtureGTFSService(t *testing.T) *gtfsService {
+	t.Helper()
+
+	cfg := map[string]string{config.GTFSMaxWalkMetres: "500"}
+	svc, err := NewGTFSService(&cfg, newMemoryGTFSRepo())
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs := svc.(*gtfsService)
+
+	ctx := context.Background()
+	if err := gs.ImportFeed(ctx, "test", zipGTFSFixture(t)); err != nil {
+		t.Fatalf("ImportFeed returned an error: %v", err)
+	}
+	if err := gs.LoadFeeds(ctx); err != nil {
+		t.Fatalf("LoadFeeds returned an error: %v", err)
+	}
+	return gs
+}
+
+func TestGTFSImportFeed(t *testing.T) {
+	repo := newMemoryGTFSRepo()
+	cfg := map[string]string{config.GTFSMaxWalkMetres: "500"}
+	svc, err := NewGTFSService(&cfg, repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	path := zipGTFSFixture(t)
+	if err := svc.ImportFeed(ctx, "test", path); err != nil {
+		t.Fatalf("ImportFeed returned an error: %v", err)
+	}
+
+	feed := repo.feeds["test"]
+	if feed.Info.Timezone != "Europe/London" || feed.Info.Checksum == "" {
+		t.Errorf("unexpected feed info: %+v", feed.Info)
+	}
+	if len(feed.Stops) != 7 || len(feed.Trips) != 6 || len(feed.CalendarDates) != 2 {
+		t.Errorf("got %d stops, %d trips and %d calendar dates", len(feed.Stops), len(feed.Trips), len(feed.CalendarDates))
+	}
+	// the stop without a coordinate is left out instead of failing the import
+	if feed.RejectedStops != 1 {
+		t.Errorf("got %d rejected stops, want 1", feed.RejectedStops)
+	}
+	// the stop without times is not a timepoint and is skipped, the times past midnight are kept
+	if len(feed.StopTimes) != 13 {
+		t.Errorf("got %d stop times, want 13", len(feed.StopTimes))
+	}
+	for _, st := range feed.StopTimes {
+		if st.TripId == "N1" && st.StopId == "B" && st.ArrivalTime != 24*3600+20*60 {
+			t.Errorf("got the night bus arriving at %d, want %d", st.ArrivalTime, 24*3600+20*60)
+		}
+	}
+	// the transfer without a time is of no use to the router
+	if len(feed.Transfers) != 1 || feed.Transfers[0].MinTransferTime != 600 {
+		t.Errorf("unexpected transfers: %+v", feed.Transfers)
+	}
+
+	// an unchanged file is not imported again
+	if err := svc.ImportFeed(ctx, "test", path); err != nil {
+		t.Fatalf("ImportFeed returned an error: %v", err)
+	}
+	if repo.replaced != 1 {
+		t.Errorf("the feed was replaced %d times, want once", repo.replaced)
+	}
+}
+
+func TestGTFSImportFeedRejectsFeedWithoutCalendars(t *testing.T) {
+	cfg := map[string]string{config.GTFSMaxWalkMetres: "500"}
+	svc, err := NewGTFSService(&cfg, newMemoryGTFSRepo())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := zipGTFSFixture(t, "calendar.txt", "calendar_dates.txt")
+	if err := svc.ImportFeed(context.Background(), "test", path); err == nil {
+		t.Fatal("ImportFeed of a feed without calendars returned no error")
+	}
+}
+
+func TestGTFSSearchAndDepartures(t *testing.T) {
+	gs := newFixtureGTFSService(t)
+
+	var places []dao.Place
+	if _, err := gs.SearchPlace("char", &places); err != nil {
+		t.Fatalf("SearchPlace returned an error: %v", err)
+	}
+	if len(places) != 1 || places[0].Name != "Charlie" || places[0].Type != string(dto.TrainStation) {
+		t.Fatalf("unexpected places: %+v", places)
+	}
+	// the stop code is not an ATCO code, the stop is keyed by its id in the feed
+	charlie := places[0]
+	if charlie.ATCOCode != "" || charlie.SMSCode != "CCC" || charlie.GTFSStopId != "test:C" || charlie.Key != "gtfs:test:C" {
+		t.Errorf("got codes ATCO %q, SMS %q, GTFS %q and key %q, want the stop code as the SMS code and the key from test:C",
+			charlie.ATCOCode, charlie.SMSCode, charlie.GTFSStopId, charlie.Key)
+	}
+
+	stop, err := placeDepartureStop(&charlie)
+	if err != nil || stop.Type != dto.GTFSStop {
+		t.Fatalf("got departure stop %+v and error %v, want a GTFS stop", stop, err)
+	}
+	if _, err := gs.Departures(stop); err != nil {
+		t.Errorf("Departures of the stop found by SearchPlace returned an error: %v", err)
+	}
+	if _, err := gs.Departures(dto.DepartureStop{Type: dto.BusStop, Code: "C"}); err != nil {
+		t.Errorf("Departures of a stop by its stop id returned an error: %v", err)
+	}
+	for _, unknown := range []dto.DepartureStop{
+		{Type: dto.BusStop, Code: "ZZZ"},
+		{Type: dto.BusStop, Code: "CCC"},
+		{Type: dto.GTFSStop, Code: "other:C"},
+	} {
+		if _, err := gs.Departures(unknown); errors.Status(err) != http.StatusBadRequest {
+			t.Errorf("got error %v for unknown stop %+v, want a bad request", err, unknown)
+		}
+	}
+
+	_, err = gs.Departures(dto.DepartureStop{Type: dto.OTPStop, Code: "1:AAA"})
+	if errors.Status(err) != http.StatusNotImplemented {
+		t.Errorf("got error %v for an OTP stop, want not implemented", err)
+	}
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+
+	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+)
+
+// journeyService holds the structure for planning journeys between places known to the application
+type journeyService struct {
+	placeRepository      interfaces.PlaceRepositoryInterface
+	savedPlaceRepository interfaces.SavedPlaceRepositoryInterface
+	transitService       interfaces.TransitServiceInterface
+	postcodeService      interfaces.PostcodeServiceInterface
+}
+
+// NewJourneyService returns an interface for the journey service methods
+func NewJourneyService(placeRepo interfaces.PlaceRepositoryInterface, savedPlaceRepo interfaces.SavedPlaceRepositoryInterface, transitService interfaces.TransitServiceInterface, postcodeService interfaces.PostcodeServiceInterface) interfaces.JourneyServiceInterface {
+	return &journeyService{
+		placeRepository:      placeRepo,
+		savedPlaceRepository: savedPlaceRepo,
+		transitService:       transitService,
+		postcodeService:      postcodeService,
+	}
+}
+
+// PlanJourney resolves both ends of a journey and gets the routes between them
+func (js *journeyService) PlanJourney(ctx context.Context, user *dao.User, from dto.JourneyEndpoint, to dto.JourneyEndpoint, opts dto.JourneyOptions) (*api.JourneyResponse, error) {
+	fromPoint, err := js.ResolveEndpoint(ctx, user, from)
+	if err != nil {
+		return nil, err
+	}
+
+	toPoint, err := js.ResolveEndpoint(ctx, user, to)
+	if err != nil {
+		return nil, err
+	}
+
+	journey, err := js.transitService.PublicJourney(fromPoint, toPoint, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	js.RankRoutes(journey, opts)
+
+	return journey, nil
+}
+
+// RankRoutes filters the routes of a journey by the options and sorts the ones left
+// excluded modes are enforced here as well since the upstream treats them as a preference
+func (js *journeyService) RankRoutes(journey *api.JourneyResponse, opts dto.JourneyOptions) {
+	routes := journey.Routes[:0]
+	for _, route := range journey.Routes {
+		if keepRoute(&route, opts) {
+			routes = append(routes, route)
+		}
+	}
+	journey.Routes = routes
+
+	// the upstream order is kept when no sort was requested
+	if opts.Sort == "" {
+		return
+	}
+
+	sort.SliceStable(journey.Routes, func(i, j int) bool {
+		a, b := &journey.Routes[i], &journey.Routes[j]
+
+		var ka, kb int
+		switch opts.Sort {
+		case dto.SortByChanges:
+			ka, kb = a.Interchanges, b.Interchanges
+		case dto.SortByWalking:
+			ka, kb = a.WalkingMinutes, b.WalkingMinutes
+		case dto.SortByArrival:
+			if !a.ArrivalTime.Equal(b.ArrivalTime) {
+				return a.ArrivalTime.Before(b.ArrivalTime)
+			}
+		}
+		if ka != kb {
+			return ka < kb
+		}
+
+		// break ties with the quickest route
+		return a.DurationMinutes < b.DurationMinutes
+	})
+}
+
+// keepRoute determines if a route satisfies the filters in the journey options
+func keepRoute(route *api.RouteResponse, opts dto.JourneyOptions) bool {
+	if opts.MaxChanges != nil && route.Interchanges > *opts.MaxChanges {
+		return false
+	}
+
+	for _, mode := range opts.NotModes {
+		// nearly every route walks to and from its stops, so excluding walking only drops the
+		// routes that are walked all the way
+		if mode == api.ModeWalk {
+			if route.WalkOnly() {
+				return false
+			}
+			continue
+		}
+		if route.HasMode(mode) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ResolveEndpoint converts a journey endpoint sent by the client to a point the upstream understands
+func (js *journeyService) ResolveEndpoint(ctx context.Context, user *dao.User, endpoint dto.JourneyEndpoint) (dto.JourneyPoint, error) {
+	switch endpoint.Kind {
+	case dto.LocationEndpoint:
+		return dto.LocationToJourneyPoint(endpoint.Location), nil
+
+	case dto.PostcodeEndpoint:
+		return js.postcodeService.JourneyPoint(endpoint.Postcode)
+
+	case dto.PlaceEndpoint:
+		place := &dao.Place{Id: endpoint.Id}
+		if err := js.findPlace(ctx, place); err != nil {
+			return dto.JourneyPoint{}, err
+		}
+		return placeToJourneyPoint(place)
+
+	case dto.SavedPlaceEndpoint, dto.AliasEndpoint:
+		// saved places are private to a user so the request must be authenticated
+		if user == nil {
+			return dto.JourneyPoint{}, errors.ErrUnauthorized("you must be logged in to plan a journey with saved places", nil)
+		}
+
+		savedPlace, err := js.findSavedPlace(ctx, user, endpoint)
+		if err != nil {
+			return dto.JourneyPoint{}, err
+		}
+
+		place := &dao.Place{Id: savedPlace.PlaceId}
+		if err := js.findPlace(ctx, place); err != nil {
+			return dto.JourneyPoint{}, err
+		}
+		return placeToJourneyPoint(place)
+	}
+
+	log.Printf("Error resolving journey endpoint of unknown kind: %v\n", endpoint.Kind)
+	return dto.JourneyPoint{}, errors.ErrBadRequest("invalid journey endpoint", nil)
+}
+
+// findPlace retrieves a place by its id from the database
+func (js *journeyService) findPlace(ctx context.Context, place *dao.Place) error {
+	placeExists, err := js.placeRepository.FindByID(ctx, place)
+	if err != nil {
+		log.Printf("Error finding place with id: %v. Error: %v\n", place.Id, err)
+		return errors.ErrInternalServerError("failed to retrieve place", nil)
+	}
+
+	if !placeExists {
+		return errors.ErrBadRequest("place not found", nil)
+	}
+
+	return nil
+}
+
+// findSavedPlace retrieves a user's saved place by its id or by its alias
+func (js *journeyService) findSavedPlace(ctx context.Context, user *dao.User, endpoint dto.JourneyEndpoint) (*dao.SavedPlace, error) {
+	savedPlace := &dao.SavedPlace{UserId: user.Id}
+
+	var savedPlaceExists bool
+	var err error
+	if endpoint.Kind == dto.AliasEndpoint {
+		savedPlace.PlaceAlias, err = dao.StringToPlaceAlias(endpoint.Alias)
+		if err != nil {
+			return nil, errors.ErrBadRequest("invalid place alias", nil)
+		}
+		savedPlaceExists, err = js.savedPlaceRepository.FindOneByAliasAndUserID(ctx, savedPlace)
+	} else {
+		savedPlace.Id = endpoint.Id
+		savedPlaceExists, err = js.savedPlaceRepository.FindOneByIDAndUserID(ctx, savedPlace)
+	}
+
+	if err != nil {
+		log.Printf("Error finding saved place for userId: %v. Error: %v\n", user.Id, err)
+		return nil, errors.ErrInternalServerError("failed to retrieve saved place", nil)
+	}
+
+	if !savedPlaceExists {
+		return nil, errors.ErrBadRequest("saved place not found", nil)
+	}
+
+	return savedPlace, nil
+}
+
+// placeToJourneyPoint converts the coordinates of a place to a journey point
+func placeToJourneyPoint(place *dao.Place) (dto.JourneyPoint, error) {
+	if place.Latitude == nil || place.Longitude == nil {
+		log.Printf("Error converting place with id: %v to a journey point. Place has no coordinates\n", place.Id)
+		return dto.JourneyPoint{}, errors.ErrBadRequest("place has no coordinates", nil)
+	}
+
+	lat := strconv.FormatFloat(*place.Latitude, 'f', -1, 64)
+	lon := strconv.FormatFloat(*place.Longitude, 'f', -1, 64)
+
+	return dto.LocationToJourneyPoint(dto.NewLocation(lat, lon)), nil
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+)
+
+// rankingRoutes returns the routes the ranking is tested with, named by the line of their first part
+// and in the order the upstream returned them
+func rankingRoutes() []api.RouteResponse {
+	start := time.Date(2023, 3, 1, 8, 0, 0, 0, time.UTC)
+	part := func(mode api.JourneyMode, minutes int) api.RoutePartResponse {
+		return api.RoutePartResponse{Mode: mode, DurationMinutes: minutes}
+	}
+	route := func(name string, departs int, parts ...api.RoutePartResponse) api.RouteResponse {
+		parts[0].LineName = name
+		r := api.RouteResponse{DepartureTime: start.Add(time.Duration(departs) * time.Minute), Parts: parts}
+		for _, p := range parts {
+			r.DurationMinutes += p.DurationMinutes
+		}
+		r.ArrivalTime = r.DepartureTime.Add(time.Duration(r.DurationMinutes) * time.Minute)
+		r.Summarize()
+		return r
+	}
+
+	return []api.RouteResponse{
+		// 30 minutes, 5 walking, no changes, arrives at 8:35
+		route("direct", 5, part(api.ModeWalk, 2), part(api.ModeBus, 25), part(api.ModeWalk, 3)),
+		// 40 minutes, all walking, arrives at 8:40
+		route("walk", 0, part(api.ModeWalk, 40)),
+		// 20 minutes, 2 walking, 2 changes, arrives at 8:25
+		route("changes", 5, part(api.ModeWalk, 1), part(api.ModeTrain, 8), part(api.ModeTube, 6), part(api.ModeBus, 4), part(api.ModeWalk, 1)),
+		// 20 minutes, 10 walking, no changes, arrives at 8:20
+		route("quick", 0, part(api.ModeWalk, 5), part(api.ModeTube, 10), part(api.ModeWalk, 5)),
+		// 20 minutes, 5 walking, no changes, arrives at 8:50
+		route("late", 30, part(api.ModeWalk, 3), part(api.ModeBus, 15), part(api.ModeWalk, 2)),
+	}
+}
+
+func TestRankRoutes(t *testing.T) {
+	intPtr := func(i int) *int { return &i }
+
+	tests := []struct {
+		name string
+		opts dto.JourneyOptions
+		want []string
+	}{
+		{"upstream order", dto.JourneyOptions{}, []string{"direct", "walk", "changes", "quick", "late"}},
+
+		// the sort orders, the ties are broken by the duration and then keep the upstream order
+		{"by duration", dto.JourneyOptions{Sort: dto.SortByDuration}, []string{"changes", "quick", "late", "direct", "walk"}},
+		{"by changes", dto.JourneyOptions{Sort: dto.SortByChanges}, []string{"quick", "late", "direct", "walk", "changes"}},
+		{"by walking", dto.JourneyOptions{Sort: dto.SortByWalking}, []string{"changes", "late", "direct", "quick", "walk"}},
+		{"by arrival", dto.JourneyOptions{Sort: dto.SortByArrival}, []string{"quick", "changes", "direct", "walk", "late"}},
+
+		// the filters
+		{"no changes", dto.JourneyOptions{MaxChanges: intPtr(0)}, []string{"direct", "walk", "quick", "late"}},
+		{"up to two changes", dto.JourneyOptions{MaxChanges: intPtr(2)}, []string{"direct", "walk", "changes", "quick", "late"}},
+		{"no buses", dto.JourneyOptions{NotModes: []api.JourneyMode{api.ModeBus}}, []string{"walk", "quick"}},
+		{"no trains or tubes", dto.JourneyOptions{NotModes: []api.JourneyMode{api.ModeTrain, api.ModeTube}}, []string{"direct", "walk", "late"}},
+
+		// excluding walking only drops the routes walked all the way
+		{"no walking", dto.JourneyOptions{NotModes: []api.JourneyMode{api.ModeWalk}}, []string{"direct", "changes", "quick", "late"}},
+		{"no walking or tubes", dto.JourneyOptions{NotModes: []api.JourneyMode{api.ModeWalk, api.ModeTube}}, []string{"direct", "late"}},
+
+		// the filters and the sort together
+		{"no changes by walking", dto.JourneyOptions{MaxChanges: intPtr(0), Sort: dto.SortByWalking}, []string{"late", "direct", "quick", "walk"}},
+		{"every route filtered", dto.JourneyOptions{NotModes: []api.JourneyMode{api.ModeWalk, api.ModeBus, api.ModeTube}, Sort: dto.SortByArrival}, []string{}},
+	}
+
+	js := &journeyService{}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			journey := &api.JourneyResponse{Routes: rankingRoutes()}
+			js.RankRoutes(journey, tt.opts)
+
+			got := []string{}
+			for _, route := range journey.Routes {
+				got = append(got, route.Parts[0].LineName)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got the routes %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRankRoutesWalkOnly(t *testing.T) {
+	walk := api.RouteResponse{DurationMinutes: 12, Parts: []api.RoutePartResponse{{Mode: api.ModeWalk, DurationMinutes: 12}}}
+	walk.Summarize()
+	if !walk.WalkOnly() || walk.Interchanges != 0 || walk.WalkingMinutes != 12 {
+		t.Fatalf("got the summary %+v, want a walk with no changes", walk)
+	}
+
+	js := &journeyService{}
+	// a walk has no changes and is kept by the strictest limit, it is only dropped when walking is excluded
+	for _, opts := range []dto.JourneyOptions{
+		{MaxChanges: new(int)},
+		{NotModes: []api.JourneyMode{api.ModeBus, api.ModeTrain, api.ModeTube}},
+		{Sort: dto.SortByWalking},
+	} {
+		journey := &api.JourneyResponse{Routes: []api.RouteResponse{walk}}
+		js.RankRoutes(journey, opts)
+		if len(journey.Routes) != 1 {
+			t.Errorf("the walk was dropped with the options %+v", opts)
+		}
+	}
+
+	journey := &api.JourneyResponse{Routes: []api.RouteResponse{walk}}
+	js.RankRoutes(journey, dto.JourneyOptions{NotModes: []api.JourneyMode{api.ModeWalk}})
+	if len(journey.Routes) != 0 {
+		t.Errorf("got %d routes, want the walk dropped when walking is excluded", len(journey.Routes))
+	}
+}
@@ -12,13 +12,15 @@ import (
 type lastVisitedPlaceService struct {
 	lastVisitedPlaceRepository interfaces.LastVisitedPlaceRepositoryInterface
 	placeRepository            interfaces.PlaceRepositoryInterface
+	placeWriter                interfaces.PlaceWriterInterface
 }
 
 // NewLastVisitedPlaceService returns an interface for the last visited place service
-func NewLastVisitedPlaceService(lastVisitedPlaceRepo interfaces.LastVisitedPlaceRepositoryInterface, placeRepo interfaces.PlaceRepositoryInterface) interfaces.LastVisitedPlaceServiceInterface {
+func NewLastVisitedPlaceService(lastVisitedPlaceRepo interfaces.LastVisitedPlaceRepositoryInterface, placeRepo interfaces.PlaceRepositoryInterface, placeWriter interfaces.PlaceWriterInterface) interfaces.LastVisitedPlaceServiceInterface {
 	return &lastVisitedPlaceService{
 		lastVisitedPlaceRepository: lastVisitedPlaceRepo,
 		placeRepository:            placeRepo,
+		placeWriter:                placeWriter,
 	}
 }
 
@@ -36,6 +38,12 @@ func (l *lastVisitedPlaceService) AddLastVisitedPlace(ctx context.Context, lastV
 		return errors.ErrBadRequest("invalid place id", nil)
 	}
 
+	// a place found by a search moments ago may still be being written
+	if err := l.placeWriter.Wait(ctx, lastVisitedPlace.PlaceId); err != nil {
+		log.Printf("Error waiting for the write of place with id: %v. Error: %v\n", lastVisitedPlace.PlaceId, err)
+		return errors.ErrInternalServerError("failed to retrieve place", nil)
+	}
+
 	// check that the place exists
 	placeExists, err := l.placeRepository.FindByID(ctx, &dao.Place{Id: lastVisitedPlace.PlaceId})
 	if err != nil {
@@ -0,0 +1,128 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/leonardchinonso/lokate-go/config"
+	"github.com/leonardchinonso/lokate-go/datasource"
+	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+)
+
+// naptanImportBatchSize is the number of stops written at once when the NaPTAN stops are imported
+const naptanImportBatchSize = 1000
+
+// maxListedRemovedStops is the most removed stops listed in the report of an import, the others are only counted
+const maxListedRemovedStops = 1000
+
+// naptanService holds the structure for importing the NaPTAN stops into the places
+type naptanService struct {
+	placeRepository interfaces.PlaceRepositoryInterface
+	stopsPath       string
+	// importing is held by the running import, so two imports do not write the same stops
+	importing sync.Mutex
+}
+
+// NewNaptanService returns an interface for the NaPTAN import service methods
+func NewNaptanService(cfg *map[string]string, placeRepo interfaces.PlaceRepositoryInterface) interfaces.NaptanServiceInterface {
+	return &naptanService{
+		placeRepository: placeRepo,
+		stopsPath:       (*cfg)[config.NaptanStopsPath],
+	}
+}
+
+// Import imports the stops of a NaPTAN Stops.csv file into the places, the configured file is
+// imported when no path is given. The active stops are stored by their ATCO code, a stop that is
+// stored already takes the NaPTAN name, coordinate and codes and keeps its id. The stops imported
+// before that are no longer active in the file are reported as removed and left for an admin to
+// merge or delete, since users may have saved them
+func (ns *naptanService) Import(ctx context.Context, path string, dryRun bool) (*api.NaptanImportResponse, error) {
+	if path == "" {
+		path = ns.stopsPath
+	}
+	if path == "" {
+		return nil, errors.ErrBadRequest("no NaPTAN stops file is configured", nil)
+	}
+
+	if !ns.importing.TryLock() {
+		return nil, errors.ErrConflict("a NaPTAN import is running already", nil)
+	}
+	defer ns.importing.Unlock()
+
+	// the stops imported before, to find the ones that are gone from the file
+	previous, err := ns.placeRepository.FindIDsBySource(ctx, dao.PlaceSourceNaptan)
+	if err != nil {
+		log.Printf("Error finding the imported NaPTAN stops. Error: %v\n", err)
+		return nil, errors.ErrInternalServerError("failed to import NaPTAN stops", nil)
+	}
+
+	resp := &api.NaptanImportResponse{DryRun: dryRun, RemovedStops: []api.NaptanRemovedStopResponse{}}
+	active := make(map[string]bool)
+
+	var batch []dao.Place
+	write := func() error {
+		if dryRun || len(batch) == 0 {
+			return nil
+		}
+		inserted, updated, err := ns.placeRepository.ImportByKey(ctx, batch)
+		if err != nil {
+			return err
+		}
+		resp.Inserted += inserted
+		resp.Updated += updated
+		batch = batch[:0]
+		return nil
+	}
+
+	resp.Skipped, err = datasource.ReadNaptanStops(path, func(stop datasource.NaptanStop) error {
+		if !stop.Active {
+			resp.Inactive++
+			return nil
+		}
+		// the ATCO codes are unique in the file, a repeated one is the same stop
+		if active[stop.Place.Key] {
+			return nil
+		}
+		active[stop.Place.Key] = true
+		resp.Stops++
+
+		batch = append(batch, stop.Place)
+		if len(batch) < naptanImportBatchSize {
+			return nil
+		}
+		return write()
+	})
+	if err == nil {
+		err = write()
+	}
+	if err != nil {
+		log.Printf("Error importing NaPTAN stops from: %s. Error: %v\n", path, err)
+		return nil, errors.ErrInternalServerError("failed to import NaPTAN stops", []string{err.Error()})
+	}
+
+	for key, id := range previous {
+		if !active[key] {
+			resp.RemovedStops = append(resp.RemovedStops, api.NaptanRemovedStopResponse{
+				Id:       id.Hex(),
+				ATCOCode: strings.TrimPrefix(key, "atco:"),
+			})
+		}
+	}
+	sort.Slice(resp.RemovedStops, func(i, j int) bool {
+		return resp.RemovedStops[i].ATCOCode < resp.RemovedStops[j].ATCOCode
+	})
+	resp.Removed = len(resp.RemovedStops)
+	if resp.Removed > maxListedRemovedStops {
+		resp.RemovedStops = resp.RemovedStops[:maxListedRemovedStops]
+	}
+
+	log.Printf("Imported %d NaPTAN stops, %d inserted and %d updated, %d removed since the last import\n",
+		resp.Stops, resp.Inserted, resp.Updated, resp.Removed)
+	return resp, nil
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/lokate-go/config"
+	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+)
+
+// naptanStopsFixture is the NaPTAN stops file the datasource is tested with
+var naptanStopsFixture = filepath.Join("..", "datasource", "testdata", "naptan", "Stops.csv")
+
+// keyedPlaceRepo keeps the imported places by their key, the other place repository methods are not used
+type keyedPlaceRepo struct {
+	interfaces.PlaceRepositoryInterface
+	places map[string]dao.Place
+}
+
+func (k *keyedPlaceRepo) ImportByKey(ctx context.Context, places []dao.Place) (int64, int64, error) {
+	var inserted, updated int64
+	for _, p := range places {
+		if stored, ok := k.places[p.Key]; ok {
+			p.Id = stored.Id
+			updated++
+		} else {
+			p.Id = primitive.NewObjectID()
+			inserted++
+		}
+		k.places[p.Key] = p
+	}
+	return inserted, updated, nil
+}
+
+func (k *keyedPlaceRepo) FindIDsBySource(ctx context.Context, source string) (map[string]primitive.ObjectID, error) {
+	ids := make(map[string]primitive.ObjectID)
+	for key, p := range k.places {
+		if p.Source == source {
+			ids[key] = p.Id
+		}
+	}
+	return ids, nil
+}
+
+func TestNaptanImport(t *testing.T) {
+	placeRepo := &keyedPlaceRepo{places: make(map[string]dao.Place)}
+	cfg := map[string]string{config.NaptanStopsPath: naptanStopsFixture}
+	ns := NewNaptanService(&cfg, placeRepo)
+	ctx := context.Background()
+
+	// a dry run reports the import without writing it
+	resp, err := ns.Import(ctx, "", true)
+	if err != nil {
+		t.Fatalf("Import returned an error: %v", err)
+	}
+	want := &api.NaptanImportResponse{DryRun: true, Stops: 5, Inactive: 3, Skipped: 4, RemovedStops: []api.NaptanRemovedStopResponse{}}
+	if !reflect.DeepEqual(resp, want) || len(placeRepo.places) != 0 {
+		t.Fatalf("got %+v and %d stored places for a dry run, want %+v and none", resp, len(placeRepo.places), want)
+	}
+
+	// the active stops are stored once each, the del, pen and inactive ones are not
+	resp, err = ns.Import(ctx, "", false)
+	if err != nil {
+		t.Fatalf("Import returned an error: %v", err)
+	}
+	want = &api.NaptanImportResponse{Stops: 5, Inserted: 5, Inactive: 3, Skipped: 4, RemovedStops: []api.NaptanRemovedStopResponse{}}
+	if !reflect.DeepEqual(resp, want) {
+		t.Fatalf("got %+v, want %+v", resp, want)
+	}
+	for _, key := range []string{"atco:490000077E", "atco:9100CHRX", "atco:940GZZLUEMB", "atco:490000077H", "atco:490000077N"} {
+		if _, ok := placeRepo.places[key]; !ok {
+			t.Errorf("stop %s was not stored", key)
+		}
+	}
+	if name := placeRepo.places["atco:490000077E"].Name; name != "Charing Cross Station" {
+		t.Errorf("got the repeated stop named %q, want the first row", name)
+	}
+	firstIds := make(map[string]primitive.ObjectID)
+	for key, p := range placeRepo.places {
+		firstIds[key] = p.Id
+	}
+
+	// the next file deactivates one stop, drops another and adds a new one
+	next := filepath.Join(t.TempDir(), "Stops.csv")
+	rows := "ATCOCode,NaptanCode,CommonName,Indicator,Street,LocalityName,StopType,Status,Longitude,Latitude\n" +
+		"490000077E,75463,Charing Cross Station,Stop E,Strand,Charing Cross,BCT,del,-0.12448,51.50807\n" +
+		"940GZZLUEMB,,Embankment Underground Station,,Villiers Street,Embankment,MET,active,-0.12231,51.50705\n" +
+		"490000077H,75465,Trafalgar Square,Stop H,Cockspur Street,Charing Cross,BCT,act,-0.12890,51.50740\n" +
+		"490000077N,,Charing Cross Taxi Rank,,Strand,Charing Cross,TXR,act,-0.12480,51.50820\n" +
+		"490000077P,75467,Trafalgar Square,Stop P,Cockspur Street,Charing Cross,BCT,act,-0.12910,51.50720\n"
+	if err := os.WriteFile(next, []byte(rows), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err = ns.Import(ctx, next, false)
+	if err != nil {
+		t.Fatalf("Import returned an error: %v", err)
+	}
+	want = &api.NaptanImportResponse{Stops: 4, Inserted: 1, Updated: 3, Inactive: 1, Removed: 2, RemovedStops: []api.NaptanRemovedStopResponse{
+		{Id: firstIds["atco:490000077E"].Hex(), ATCOCode: "490000077E"},
+		{Id: firstIds["atco:9100CHRX"].Hex(), ATCOCode: "9100CHRX"},
+	}}
+	if !reflect.DeepEqual(resp, want) {
+		t.Errorf("got %+v, want %+v", resp, want)
+	}
+	// the removed stops are left for an admin, the updated ones keep their ids
+	if len(placeRepo.places) != 6 || placeRepo.places["atco:490000077H"].Id != firstIds["atco:490000077H"] {
+		t.Errorf("got %d stored places, want the removed stops kept and the updated ones with their ids", len(placeRepo.places))
+	}
+}
+
+func TestNaptanImportWithoutFile(t *testing.T) {
+	cfg := map[string]string{}
+	ns := NewNaptanService(&cfg, &keyedPlaceRepo{places: make(map[string]dao.Place)})
+
+	if _, err := ns.Import(context.Background(), "", false); errors.Status(err) != http.StatusBadRequest {
+		t.Errorf("got error %v without a file, want a bad request", err)
+	}
+	if _, err := ns.Import(context.Background(), filepath.Join(t.TempDir(), "Stops.csv"), false); errors.Status(err) != http.StatusInternalServerError {
+		t.Errorf("got error %v for a missing file, want an internal server error", err)
+	}
+}
@@ -0,0 +1,246 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/leonardchinonso/lokate-go/config"
+	"github.com/leonardchinonso/lokate-go/datasource"
+	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/metrics"
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+)
+
+// otpProviderName is the name OpenTripPlanner is selected by in the transit provider config
+const otpProviderName = "otp"
+
+// otpModes maps the modes exposed by the API to the OpenTripPlanner mode names
+var otpModes = map[api.JourneyMode]string{
+	api.ModeWalk:  "WALK",
+	api.ModeBus:   "BUS",
+	api.ModeTrain: "RAIL",
+	api.ModeTube:  "SUBWAY",
+	api.ModeTram:  "TRAM",
+	api.ModeBoat:  "FERRY",
+}
+
+// otpStopTypes maps the OpenTripPlanner mode names to the types of the stops they call at, the
+// first mode of a stop that is known gives its type
+var otpStopTypes = map[string]string{
+	"BUS":    "bus_stop",
+	"RAIL":   "train_station",
+	"SUBWAY": "tube_station",
+}
+
+// otpService holds the structure for services associated with an OpenTripPlanner compatible REST API
+type otpService struct {
+	baseUrl string
+	feedId  string
+}
+
+// NewOTPService returns the OpenTripPlanner adapter for the transit service methods
+func NewOTPService(cfg *map[string]string) interfaces.TransitServiceInterface {
+	return &otpService{
+		baseUrl: strings.TrimSuffix((*cfg)[config.OTPBaseUrl], "/"),
+		feedId:  (*cfg)[config.OTPFeedId],
+	}
+}
+
+// Name returns the name of the transit provider
+func (op *otpService) Name() string {
+	return otpProviderName
+}
+
+// SearchPlace searches for stops by name with the OpenTripPlanner geocoder
+func (op *otpService) SearchPlace(searchStr string, places *[]dao.Place) ([]api.PlaceResponse, error) {
+	if searchStr == "" {
+		log.Printf("Failed to get data for url. Error: invalid search query\n")
+		return nil, errors.ErrBadRequest("invalid search query", nil)
+	}
+
+	// the search string is escaped by the caller already
+	reqUrl := fmt.Sprintf("%s/geocode?query=%s&autocomplete=false", op.baseUrl, searchStr)
+
+	var results []json.RawMessage
+	if err := datasource.Get(reqUrl, &results); err != nil {
+		log.Printf("Failed to get data for url: %v. Error: %v\n", reqUrl, err)
+		return nil, errors.ErrInternalServerError("failed to reach OTP", nil)
+	}
+
+	// decode each result on its own so a malformed result is skipped instead of failing the response
+	for i, result := range results {
+		var r dao.OTPGeocodeResult
+		err := json.Unmarshal(result, &r)
+		if err == nil {
+			err = r.Validate()
+		}
+		if err != nil {
+			log.Printf("Skipping place %d from url: %v. Error: %v\n", i, reqUrl, err)
+			metrics.RejectedPlaces.Add(otpProviderName, 1)
+			continue
+		}
+		lat, lon := *r.Lat, *r.Lng
+
+		// the geocoder ids are prefixed with the feed id, e.g. "1:490000077E", and are kept whole
+		// since they are only known to OpenTripPlanner
+		place := dao.NewPlace(otpStopType(r.Modes), r.Description, "", "", "", "", "", "", nil, &lat, &lon)
+		place.OTPStopId = r.Id
+		place.Key = dao.PlaceKey(place)
+		*places = append(*places, *place)
+	}
+
+	return api.ToPlacesResponses(places), nil
+}
+
+// NearestPlaces is not supported by the OpenTripPlanner adapter, the next provider is tried instead
+func (op *otpService) NearestPlaces(query dto.NearestPlacesQuery, places *[]dao.Place) ([]api.PlaceResponse, error) {
+	return nil, errors.ErrNotImplemented("the OTP provider does not find the nearest places", nil)
+}
+
+// PublicJourney gets a journey between two coordinates from the OpenTripPlanner plan API
+func (op *otpService) PublicJourney(from dto.JourneyPoint, to dto.JourneyPoint, opts dto.JourneyOptions) (*api.JourneyResponse, error) {
+	fromPlace, err := otpPlace(from)
+	if err != nil {
+		return nil, err
+	}
+
+	toPlace, err := otpPlace(to)
+	if err != nil {
+		return nil, err
+	}
+
+	query := url.Values{}
+	query.Set("fromPlace", fromPlace)
+	query.Set("toPlace", toPlace)
+	if opts.HasTime() {
+		query.Set("date", opts.Time.Format("2006-01-02"))
+		query.Set("time", opts.Time.Format("15:04"))
+		query.Set("arriveBy", strconv.FormatBool(opts.TimeType == dto.ArriveBy))
+	}
+	query.Set("mode", otpModeList(opts))
+	if opts.MaxWalkDistance > 0 {
+		query.Set("maxWalkDistance", strconv.Itoa(opts.MaxWalkDistance))
+	}
+
+	reqUrl := fmt.Sprintf("%s/plan?%s", op.baseUrl, query.Encode())
+
+	var planResp dao.OTPPlanResp
+	if err := datasource.Get(reqUrl, &planResp); err != nil {
+		log.Printf("Failed to get data for url: %v. Error: %v", reqUrl, err)
+		return nil, errors.ErrInternalServerError("failed to reach OTP", nil)
+	}
+
+	// OTP reports unreachable places and trips with no routes in the error field
+	if planResp.Plan == nil {
+		msg := "no plan returned"
+		if planResp.Error != nil {
+			msg = planResp.Error.Msg
+		}
+		log.Printf("Failed to plan journey for url: %v. Error: %v", reqUrl, msg)
+		return nil, errors.ErrBadRequest(fmt.Sprintf("failed to plan journey: %s", msg), nil)
+	}
+
+	journeyResp, err := api.NewOTPJourneyResponse(planResp.Plan)
+	if err != nil {
+		log.Printf("Failed to convert journey for url: %v. Error: %v", reqUrl, err)
+		return nil, errors.ErrInternalServerError("failed to convert journey", nil)
+	}
+
+	return journeyResp, nil
+}
+
+// Departures gets the upcoming departures from a stop from the OpenTripPlanner index API
+// the stop code is looked up as a stop id in the configured feed, the stops found by
+// OpenTripPlanner already have the feed in their id
+func (op *otpService) Departures(stop dto.DepartureStop) (*api.DepartureBoardResponse, error) {
+	// the feed ids of the imported GTFS feeds are not the ones OpenTripPlanner gave them
+	if stop.Type == dto.GTFSStop {
+		return nil, errors.ErrNotImplemented("the OTP provider does not get departures for GTFS stops", nil)
+	}
+
+	stopId := fmt.Sprintf("%s:%s", op.feedId, stop.Code)
+	if stop.Type == dto.OTPStop {
+		stopId = stop.Code
+	}
+	stopId = url.PathEscape(stopId)
+
+	stopUrl := fmt.Sprintf("%s/index/stops/%s", op.baseUrl, stopId)
+	var otpStop dao.OTPStop
+	if err := datasource.Get(stopUrl, &otpStop); err != nil {
+		log.Printf("Failed to get data for url: %v. Error: %v", stopUrl, err)
+		return nil, errors.ErrInternalServerError("failed to reach OTP", nil)
+	}
+
+	stopTimesUrl := fmt.Sprintf("%s/index/stops/%s/stoptimes", op.baseUrl, stopId)
+	var patterns []dao.OTPPatternStopTimes
+	if err := datasource.Get(stopTimesUrl, &patterns); err != nil {
+		log.Printf("Failed to get data for url: %v. Error: %v", stopTimesUrl, err)
+		return nil, errors.ErrInternalServerError("failed to reach OTP", nil)
+	}
+
+	board := api.NewOTPDepartureBoardResponse(&otpStop, patterns)
+	if board.StopCode == "" {
+		board.StopCode = stop.Code
+	}
+
+	return board, nil
+}
+
+// otpStopType returns the type of a stop from the modes that call at it
+func otpStopType(modes []string) string {
+	for _, m := range modes {
+		if t, ok := otpStopTypes[strings.ToUpper(m)]; ok {
+			return t
+		}
+	}
+	return dao.PlaceTypeStop
+}
+
+// otpPlace converts a journey point to the "lat,lon" notation used by OpenTripPlanner
+func otpPlace(point dto.JourneyPoint) (string, error) {
+	if point.Kind != dto.LonLatPoint {
+		log.Printf("Failed to convert journey point of kind: %v for OTP\n", point.Kind)
+		return "", errors.ErrNotImplemented(fmt.Sprintf("OTP does not support %s journey points", point.Kind), nil)
+	}
+
+	lon, lat, found := strings.Cut(point.Value, ",")
+	if !found {
+		return "", errors.ErrBadRequest("invalid journey point", nil)
+	}
+
+	return fmt.Sprintf("%s,%s", lat, lon), nil
+}
+
+// otpModeList builds the comma separated list of modes OpenTripPlanner may use
+func otpModeList(opts dto.JourneyOptions) string {
+	modes := opts.Modes
+	if len(modes) == 0 {
+		modes = []api.JourneyMode{api.ModeWalk, api.ModeBus, api.ModeTrain, api.ModeTube, api.ModeTram, api.ModeBoat}
+	}
+
+	excluded := make(map[api.JourneyMode]bool)
+	for _, m := range opts.NotModes {
+		excluded[m] = true
+	}
+
+	var names []string
+	for _, m := range modes {
+		if !excluded[m] {
+			names = append(names, otpModes[m])
+		}
+	}
+
+	// OTP needs walking to reach the first stop, so it is always allowed
+	if !strings.Contains(strings.Join(names, ","), otpModes[api.ModeWalk]) {
+		names = append(names, otpModes[api.ModeWalk])
+	}
+
+	return strings.Join(names, ",")
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/leonardchinonso/lokate-go/config"
+	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+)
+
+func newTestOTPService(baseUrl string) *otpService {
+	cfg := map[string]string{
+		config.OTPBaseUrl: baseUrl + "/",
+		config.OTPFeedId:  "1",
+	}
+	return NewOTPService(&cfg).(*otpService)
+}
+
+func TestOTPSearchPlace(t *testing.T) {
+	stub := newStubServer(t, map[string]string{
+		"/geocode": `[
+			{"lat": 51.507, "lng": -0.128, "description": "Trafalgar Square", "id": "1:490000077E", "modes": ["BUS"]},
+			{"lat": 51.508, "lng": -0.124, "description": "Charing Cross", "id": "1:CHX", "modes": ["SUBWAY", "RAIL"]},
+			{"lat": 51.502, "lng": -0.120, "description": "Embankment Pier", "id": "1:EMB", "modes": ["FERRY"]},
+			{"lat": 51.505, "lng": -0.086, "description": "London Bridge", "id": "1:LBG"},
+			{"lat": "51.5", "lng": -0.1, "description": "Bad Latitude", "id": "1:BAD"},
+			{"lng": -0.1, "description": "No Latitude", "id": "1:NOLAT"},
+			{"lat": 51.5, "lng": -0.1, "description": "", "id": "1:NONAME"}
+		]`,
+	})
+	op := newTestOTPService(stub.URL)
+	rejected := rejectedPlaces(otpProviderName)
+
+	var places []dao.Place
+	if _, err := op.SearchPlace("trafalgar", &places); err != nil {
+		t.Fatalf("SearchPlace returned an error: %v", err)
+	}
+	if got := rejectedPlaces(otpProviderName) - rejected; got != 3 {
+		t.Errorf("got %d rejected places, want 3", got)
+	}
+
+	want := []struct {
+		placeType string
+		key       string
+	}{
+		{"bus_stop", "otp:1:490000077E"},
+		{"tube_station", "otp:1:CHX"},
+		{dao.PlaceTypeStop, "otp:1:EMB"},
+		{dao.PlaceTypeStop, "otp:1:LBG"},
+	}
+	if len(places) != len(want) {
+		t.Fatalf("got %d places, want %d", len(places), len(want))
+	}
+	for i, w := range want {
+		p := places[i]
+		if p.Type != w.placeType || p.Key != w.key {
+			t.Errorf("place %d: got type %q and key %q, want %q and %q", i, p.Type, p.Key, w.placeType, w.key)
+		}
+		// the OTP ids are not ATCO codes, so the place must not be sent to TAPI as a bus stop
+		if p.ATCOCode != "" || !p.IsStop() {
+			t.Errorf("place %d: got ATCO code %q, stop %v", i, p.ATCOCode, p.IsStop())
+		}
+	}
+
+	if got := stub.lastRequest(t).Query().Get("query"); got != "trafalgar" {
+		t.Errorf("got query %q, want trafalgar", got)
+	}
+}
+
+func TestOTPPublicJourney(t *testing.T) {
+	start := time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)
+	stub := newStubServer(t, map[string]string{
+		"/plan": `{"plan": {"date": 1677664800000, "itineraries": [{
+			"duration": 1500, "startTime": 1677664800000, "endTime": 1677666300000, "transfers": 0,
+			"legs": [
+				{"mode": "WALK", "startTime": 1677664800000, "endTime": 1677665100000, "duration": 300,
+				 "from": {"name": "Origin", "lat": 51.507, "lon": -0.128}, "to": {"name": "Stop", "lat": 51.507, "lon": -0.127, "stopId": "1:A"},
+				 "legGeometry": {"points": "_p~iF~ps|U_ulLnnqC"}},
+				{"mode": "BUS", "routeShortName": "24", "headsign": "Pimlico", "tripId": "1:T1",
+				 "startTime": 1677665100000, "endTime": 1677666300000, "duration": 1200,
+				 "from": {"name": "Stop", "lat": 51.507, "lon": -0.127, "stopId": "1:A"}, "to": {"name": "Destination", "lat": 51.505, "lon": -0.086},
+				 "legGeometry": {"points": "_p~iF~ps|U_ulLnnqC_mqNvxq` + "`" + `@"}}
+			]
+		}]}}`,
+	})
+	op := newTestOTPService(stub.URL)
+
+	from := dto.JourneyPoint{Kind: dto.LonLatPoint, Value: "-0.128,51.507"}
+	to := dto.JourneyPoint{Kind: dto.LonLatPoint, Value: "-0.086,51.505"}
+	opts := dto.JourneyOptions{TimeType: dto.ArriveBy, Time: start, NotModes: []api.JourneyMode{api.ModeTrain}}
+
+	journey, err := op.PublicJourney(from, to, opts)
+	if err != nil {
+		t.Fatalf("PublicJourney returned an error: %v", err)
+	}
+	if len(journey.Routes) != 1 || len(journey.Routes[0].Parts) != 2 {
+		t.Fatalf("unexpected journey: %+v", journey)
+	}
+	route := journey.Routes[0]
+	if route.DurationMinutes != 25 || route.WalkingMinutes != 5 || route.Parts[1].LineName != "24" || len(route.Parts[1].Coordinates) != 3 {
+		t.Errorf("unexpected route: %+v", route)
+	}
+
+	q := stub.lastRequest(t).Query()
+	if q.Get("fromPlace") != "51.507,-0.128" || q.Get("arriveBy") != "true" || q.Get("mode") != "WALK,BUS,SUBWAY,TRAM,FERRY" {
+		t.Errorf("unexpected query: %v", q)
+	}
+}
+
+func TestOTPPublicJourneyNoPlan(t *testing.T) {
+	stub := newStubServer(t, map[string]string{
+		"/plan": `{"error": {"id": 404, "msg": "No trip found"}}`,
+	})
+	op := newTestOTPService(stub.URL)
+
+	from := dto.JourneyPoint{Kind: dto.LonLatPoint, Value: "-0.128,51.507"}
+	to := dto.JourneyPoint{Kind: dto.LonLatPoint, Value: "-0.086,51.505"}
+	_, err := op.PublicJourney(from, to, dto.JourneyOptions{})
+	if errors.Status(err) != http.StatusBadRequest {
+		t.Fatalf("got error %v, want a bad request", err)
+	}
+
+	// OTP only takes coordinates
+	_, err = op.PublicJourney(dto.JourneyPoint{Kind: dto.PostcodePoint, Value: "SW1A1AA"}, to, dto.JourneyOptions{})
+	if errors.Status(err) != http.StatusNotImplemented {
+		t.Fatalf("got error %v for a postcode, want not implemented", err)
+	}
+}
+
+func TestOTPDepartures(t *testing.T) {
+	stop := `{"id": "1:490000077E", "name": "Trafalgar Square", "code": "77E"}`
+	stopTimes := `[{"pattern": {"id": "1:24:0", "desc": "24 to Pimlico"}, "times": [
+		{"scheduledDeparture": 36300, "realtimeDeparture": 36420, "serviceDay": 1677628800, "realtime": true, "headsign": "Pimlico", "tripId": "1:T2"},
+		{"scheduledDeparture": 36000, "realtimeDeparture": 36000, "serviceDay": 1677628800, "realtime": false, "headsign": "Pimlico", "tripId": "1:T1"}
+	]}]`
+	stub := newStubServer(t, map[string]string{
+		"/index/stops/1:490000077E":           stop,
+		"/index/stops/1:490000077E/stoptimes": stopTimes,
+	})
+	op := newTestOTPService(stub.URL)
+
+	// a bus stop is looked up in the configured feed, a stop found by OTP already has its feed
+	for _, s := range []dto.DepartureStop{
+		{Type: dto.BusStop, Code: "490000077E"},
+		{Type: dto.OTPStop, Code: "1:490000077E"},
+	} {
+		board, err := op.Departures(s)
+		if err != nil {
+			t.Fatalf("Departures of %v returned an error: %v", s, err)
+		}
+		if board.StopCode != "77E" || len(board.Departures) != 2 || board.Departures[0].TripId != "1:T1" {
+			t.Errorf("unexpected board for %v: %+v", s, board)
+		}
+		if board.Departures[1].ExpectedTime == nil {
+			t.Errorf("the tracked departure has no expected time")
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"fmt"
+	"math"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/utils"
+)
+
+// duplicateNameSimilarity is how similar the names of two places close to each other have to be
+// for the places to be taken for the same place
+const duplicateNameSimilarity = 0.8
+
+// duplicateCell is a cell of the grid the places are bucketed into to find the places close to each other
+type duplicateCell struct {
+	lat, lon int
+}
+
+// groupDuplicatePlaces groups the places that are within the radius of each other, have similar
+// names and no conflicting upstream codes. Places with the same key are always grouped. Only the
+// groups of more than one place are returned, as indices into the places
+func groupDuplicatePlaces(places []dao.Place, radius int) [][]int {
+	// codes holds the upstream codes of the places in a group by the root of the group, so a group
+	// never joins places with conflicting codes through a place that has none
+	parent := make([]int, len(places))
+	codes := make([]dao.Place, len(places))
+	for i := range parent {
+		parent[i] = i
+		codes[i] = dao.Place{ATCOCode: places[i].ATCOCode, StationCode: places[i].StationCode, TiplocCode: places[i].TiplocCode, OSMId: places[i].OSMId, OTPStopId: places[i].OTPStopId, GTFSStopId: places[i].GTFSStopId, BikeStationId: places[i].BikeStationId}
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+	union := func(i, j int) {
+		ri, rj := find(i), find(j)
+		if ri == rj || !dao.SameUpstreamPlace(&codes[ri], &codes[rj]) {
+			return
+		}
+		parent[ri] = rj
+		fillEmpty := func(dst *string, src string) {
+			if *dst == "" {
+				*dst = src
+			}
+		}
+		fillEmpty(&codes[rj].ATCOCode, codes[ri].ATCOCode)
+		fillEmpty(&codes[rj].StationCode, codes[ri].StationCode)
+		fillEmpty(&codes[rj].TiplocCode, codes[ri].TiplocCode)
+		fillEmpty(&codes[rj].OSMId, codes[ri].OSMId)
+		fillEmpty(&codes[rj].OTPStopId, codes[ri].OTPStopId)
+		fillEmpty(&codes[rj].GTFSStopId, codes[ri].GTFSStopId)
+		fillEmpty(&codes[rj].BikeStationId, codes[ri].BikeStationId)
+	}
+
+	// places with the same key were stored twice
+	byKey := make(map[string]int, len(places))
+	for i := range places {
+		if first, ok := byKey[places[i].Key]; ok && places[i].Key != "" {
+			union(i, first)
+			continue
+		}
+		byKey[places[i].Key] = i
+	}
+
+	// bucket the places into cells as tall as the radius
+	cellSize := float64(radius) / 111000
+	grid := make(map[duplicateCell][]int)
+	for i := range places {
+		if places[i].Latitude == nil || places[i].Longitude == nil {
+			continue
+		}
+		cell := duplicateCell{lat: int(math.Floor(*places[i].Latitude / cellSize)), lon: int(math.Floor(*places[i].Longitude / cellSize))}
+		grid[cell] = append(grid[cell], i)
+	}
+
+	for cell, members := range grid {
+		// a degree of longitude is shorter away from the equator, so more cells are looked at across
+		lat := (float64(cell.lat) + 0.5) * cellSize
+		lonCells := int(math.Ceil(1 / math.Max(math.Cos(lat*math.Pi/180), 0.01)))
+
+		for _, i := range members {
+			for dLat := -1; dLat <= 1; dLat++ {
+				for dLon := -lonCells; dLon <= lonCells; dLon++ {
+					for _, j := range grid[duplicateCell{lat: cell.lat + dLat, lon: cell.lon + dLon}] {
+						if j <= i || find(i) == find(j) {
+							continue
+						}
+						if duplicatePlaces(&places[i], &places[j], radius) {
+							union(i, j)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	// collect the groups in the order of their first place
+	var groups [][]int
+	groupOf := make(map[int]int)
+	for i := range places {
+		root := find(i)
+		g, ok := groupOf[root]
+		if !ok {
+			g = len(groups)
+			groupOf[root] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+
+	duplicates := groups[:0]
+	for _, g := range groups {
+		if len(g) > 1 {
+			duplicates = append(duplicates, g)
+		}
+	}
+	return duplicates
+}
+
+// duplicatePlaces determines if two places are close enough and named alike enough to be the same place
+func duplicatePlaces(a, b *dao.Place, radius int) bool {
+	if utils.HaversineMetres(*a.Latitude, *a.Longitude, *b.Latitude, *b.Longitude) > float64(radius) {
+		return false
+	}
+	return utils.NameSimilarity(a.Name, b.Name) >= duplicateNameSimilarity
+}
+
+// savedPlaceMerge is the saved place a user keeps when the places they saved are merged, and the
+// other saved places of the user that are deleted
+type savedPlaceMerge struct {
+	kept    dao.SavedPlace
+	removed []primitive.ObjectID
+}
+
+// mergeSavedPlaces decides what happens to the saved places of the places merged into the place
+// with the id. Each user keeps one saved place: the one of the place if they saved it, otherwise
+// the one they saved first. The kept saved place takes the alias and the pin of the deleted ones,
+// a user whose saved places have different aliases is a conflict the merge cannot resolve
+func mergeSavedPlaces(savedPlaces []dao.SavedPlace, placeId primitive.ObjectID) ([]savedPlaceMerge, error) {
+	var merges []savedPlaceMerge
+	byUser := make(map[primitive.ObjectID]int)
+	for _, sp := range savedPlaces {
+		i, ok := byUser[sp.UserId]
+		if !ok {
+			byUser[sp.UserId] = len(merges)
+			merges = append(merges, savedPlaceMerge{kept: sp})
+			continue
+		}
+
+		m := &merges[i]
+		keepNew := sp.PlaceId == placeId && m.kept.PlaceId != placeId ||
+			(sp.PlaceId == placeId) == (m.kept.PlaceId == placeId) && primitive.CompareTimestamp(sp.CreatedAt, m.kept.CreatedAt) < 0
+		kept, removed := m.kept, sp
+		if keepNew {
+			kept, removed = sp, m.kept
+		}
+
+		if hasAlias(removed.PlaceAlias) {
+			if hasAlias(kept.PlaceAlias) && kept.PlaceAlias != removed.PlaceAlias {
+				return nil, fmt.Errorf("user %s saved the places as both %s and %s", sp.UserId.Hex(), kept.PlaceAlias, removed.PlaceAlias)
+			}
+			kept.PlaceAlias = removed.PlaceAlias
+		}
+		if removed.Pinned && !kept.Pinned {
+			kept.Pinned, kept.PinSlot = true, removed.PinSlot
+		}
+
+		m.kept = kept
+		m.removed = append(m.removed, removed.Id)
+	}
+
+	// a user who only saved the place has nothing to merge
+	changed := merges[:0]
+	for _, m := range merges {
+		if m.kept.PlaceId == placeId && len(m.removed) == 0 {
+			continue
+		}
+		m.kept.PlaceId = placeId
+		changed = append(changed, m)
+	}
+	return changed, nil
+}
+
+// hasAlias determines if a saved place is the home or the work of the user
+func hasAlias(alias dao.PlaceAlias) bool {
+	return alias != "" && !alias.IsNone()
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
+
+func TestMergeSavedPlaces(t *testing.T) {
+	target, dupA, dupB := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	alice, bob, carol, dave := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+
+	saved := func(user, place primitive.ObjectID, created uint32, alias dao.PlaceAlias, slot int) dao.SavedPlace {
+		return dao.SavedPlace{
+			Id: primitive.NewObjectID(), UserId: user, PlaceId: place, Name: "Stop",
+			PlaceAlias: alias, Pinned: slot > 0, PinSlot: slot, CreatedAt: primitive.Timestamp{T: created},
+		}
+	}
+
+	// alice saved the place and a duplicate, which is her home and is pinned
+	aliceTarget := saved(alice, target, 10, dao.None, 0)
+	aliceDup := saved(alice, dupA, 5, dao.Home, 3)
+	// bob saved two duplicates but not the place
+	bobFirst := saved(bob, dupB, 1, dao.None, 0)
+	bobSecond := saved(bob, dupA, 2, dao.Work, 0)
+	// carol only saved the place
+	carolTarget := saved(carol, target, 1, dao.None, 0)
+	// dave saved one duplicate
+	daveDup := saved(dave, dupB, 1, dao.None, 2)
+
+	merges, err := mergeSavedPlaces([]dao.SavedPlace{aliceTarget, aliceDup, bobFirst, bobSecond, carolTarget, daveDup}, target)
+	if err != nil {
+		t.Fatalf("mergeSavedPlaces returned an error: %v", err)
+	}
+	if len(merges) != 3 {
+		t.Fatalf("got %d merges, want 3", len(merges))
+	}
+
+	tests := []struct {
+		name    string
+		merge   savedPlaceMerge
+		kept    primitive.ObjectID
+		alias   dao.PlaceAlias
+		slot    int
+		removed []primitive.ObjectID
+	}{
+		{name: "the saved place of the place takes the alias and the pin", merge: merges[0], kept: aliceTarget.Id, alias: dao.Home, slot: 3, removed: []primitive.ObjectID{aliceDup.Id}},
+		{name: "the first saved duplicate is kept", merge: merges[1], kept: bobFirst.Id, alias: dao.Work, removed: []primitive.ObjectID{bobSecond.Id}},
+		{name: "a single duplicate is moved", merge: merges[2], kept: daveDup.Id, alias: dao.None, slot: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kept := tt.merge.kept
+			if kept.Id != tt.kept || kept.PlaceId != target {
+				t.Errorf("kept saved place %v of place %v", kept.Id, kept.PlaceId)
+			}
+			if kept.PlaceAlias != tt.alias || kept.PinSlot != tt.slot || kept.Pinned != (tt.slot > 0) {
+				t.Errorf("got alias %s and pin slot %d, want %s and %d", kept.PlaceAlias, kept.PinSlot, tt.alias, tt.slot)
+			}
+			if len(tt.merge.removed) != len(tt.removed) || len(tt.removed) > 0 && tt.merge.removed[0] != tt.removed[0] {
+				t.Errorf("got removed %v, want %v", tt.merge.removed, tt.removed)
+			}
+		})
+	}
+}
+
+func TestMergeSavedPlacesAliasConflict(t *testing.T) {
+	target, dup, user := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	saved := []dao.SavedPlace{
+		{Id: primitive.NewObjectID(), UserId: user, PlaceId: target, PlaceAlias: dao.Home},
+		{Id: primitive.NewObjectID(), UserId: user, PlaceId: dup, PlaceAlias: dao.Work},
+	}
+
+	if _, err := mergeSavedPlaces(saved, target); err == nil {
+		t.Fatal("merging a home into a work returned no error")
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
+
+const (
+	// personalHistoryVisits is the number of the last visits of a user read to personalize a search
+	personalHistoryVisits = 200
+	// personalSavedBoost is what a place being saved by the user adds to its score
+	personalSavedBoost = 0.3
+	// personalVisitBoost is the most the visits of the user to a place add to its score
+	personalVisitBoost = 0.3
+	// personalVisitHalfLife is the age at which a visit counts for half a visit
+	personalVisitHalfLife = 14 * 24 * time.Hour
+	// personalVisitScale is the number of recent visits at which the visit boost is about two thirds of its most
+	personalVisitScale = 3
+)
+
+// the reasons the score of a place found by a search is boosted
+const (
+	personalBoostSaved   = "saved"
+	personalBoostVisited = "visited"
+)
+
+// personalHistory is what a user saved and visited, which boosts the places found by their searches
+type personalHistory struct {
+	saved  map[primitive.ObjectID]dao.SavedPlace
+	visits map[primitive.ObjectID][]time.Time
+}
+
+// loadPersonalHistory reads the saved places and the last visits of a user
+func (ps *placeService) loadPersonalHistory(ctx context.Context, userId primitive.ObjectID) (*personalHistory, error) {
+	var saved []dao.SavedPlace
+	if _, err := ps.savedPlaceRepository.Find(ctx, userId, &saved); err != nil {
+		return nil, err
+	}
+	var visits []dao.LastVisitedPlace
+	if _, err := ps.lastVisitedPlaceRepository.FindLastNVisitedPlaces(ctx, userId, &visits, personalHistoryVisits); err != nil {
+		return nil, err
+	}
+
+	history := &personalHistory{
+		saved:  make(map[primitive.ObjectID]dao.SavedPlace, len(saved)),
+		visits: make(map[primitive.ObjectID][]time.Time),
+	}
+	for _, sp := range saved {
+		history.saved[sp.PlaceId] = sp
+	}
+	// the visits are read the most recent first
+	for _, v := range visits {
+		history.visits[v.PlaceId] = append(history.visits[v.PlaceId], v.CreatedAt)
+	}
+	return history, nil
+}
+
+// boosts returns what the history of the user adds to the score of a place, and why
+func (h *personalHistory) boosts(placeId primitive.ObjectID, now time.Time) []api.PlaceRankingBoostResponse {
+	var boosts []api.PlaceRankingBoostResponse
+
+	if sp, ok := h.saved[placeId]; ok {
+		detail := fmt.Sprintf("saved as %q", sp.Name)
+		if !sp.PlaceAlias.IsNone() && sp.PlaceAlias != "" {
+			detail = fmt.Sprintf("saved as %q, %s", sp.Name, strings.ToLower(string(sp.PlaceAlias)))
+		}
+		boosts = append(boosts, api.PlaceRankingBoostResponse{Reason: personalBoostSaved, Score: personalSavedBoost, Detail: detail})
+	}
+
+	if visits := h.visits[placeId]; len(visits) > 0 {
+		// each visit counts for less the older it is, and each more visit adds less than the last
+		weight := 0.0
+		for _, at := range visits {
+			age := now.Sub(at)
+			if age < 0 {
+				age = 0
+			}
+			weight += math.Pow(0.5, float64(age)/float64(personalVisitHalfLife))
+		}
+		score := personalVisitBoost * (1 - math.Exp(-weight/personalVisitScale))
+		detail := fmt.Sprintf("visited %d times, last %s ago", len(visits), now.Sub(visits[0]).Truncate(time.Minute))
+		boosts = append(boosts, api.PlaceRankingBoostResponse{Reason: personalBoostVisited, Score: score, Detail: detail})
+	}
+
+	return boosts
+}
+
+// rankedPlace is a place found by a search with its score and why it has that score
+type rankedPlace struct {
+	scoredPlace
+	source api.PlaceSearchSource
+	boosts []api.PlaceRankingBoostResponse
+	total  float64
+}
+
+// personalize adds the boosts from the history of the user to the places found by a search and
+// orders the places by their boosted score. Places with the same score keep their order
+func (h *personalHistory) personalize(places []rankedPlace, now time.Time) {
+	for i := range places {
+		places[i].boosts = h.boosts(places[i].place.Id, now)
+		for _, b := range places[i].boosts {
+			places[i].total += b.Score
+		}
+	}
+	sort.SliceStable(places, func(i, j int) bool {
+		return places[i].total > places[j].total
+	})
+}
+
+// ranking explains the score of a place found by a search
+func (r *rankedPlace) ranking() *api.PlaceRankingResponse {
+	boosts := r.boosts
+	if boosts == nil {
+		boosts = []api.PlaceRankingBoostResponse{}
+	}
+	return &api.PlaceRankingResponse{
+		Score:     r.total,
+		Relevance: r.score,
+		Match:     r.match,
+		Boosts:    boosts,
+	}
+}
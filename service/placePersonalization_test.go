@@ -0,0 +1,199 @@
+package service
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
+
+// personalNow is the fixed time the boosts are computed at
+var personalNow = time.Date(2023, 3, 1, 8, 0, 0, 0, time.UTC)
+
+// visitsAgo returns the times of visits the given ages before personalNow, the most recent first
+func visitsAgo(ages ...time.Duration) []time.Time {
+	visits := make([]time.Time, len(ages))
+	for i, age := range ages {
+		visits[i] = personalNow.Add(-age)
+	}
+	return visits
+}
+
+// sameBoosts determines if two lists of boosts have the same reasons and details and about the same scores
+func sameBoosts(got, want []api.PlaceRankingBoostResponse) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i].Reason != want[i].Reason || got[i].Detail != want[i].Detail || math.Abs(got[i].Score-want[i].Score) > 1e-6 {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPersonalHistoryBoosts(t *testing.T) {
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name   string
+		saved  *dao.SavedPlace
+		visits []time.Time
+		want   []api.PlaceRankingBoostResponse
+	}{
+		{name: "no history"},
+		{
+			name:  "saved",
+			saved: &dao.SavedPlace{Name: "Gym", PlaceAlias: dao.None},
+			want:  []api.PlaceRankingBoostResponse{{Reason: personalBoostSaved, Score: 0.3, Detail: `saved as "Gym"`}},
+		},
+		{
+			name:  "saved without an alias",
+			saved: &dao.SavedPlace{Name: "Gym"},
+			want:  []api.PlaceRankingBoostResponse{{Reason: personalBoostSaved, Score: 0.3, Detail: `saved as "Gym"`}},
+		},
+		{
+			name:  "saved as home",
+			saved: &dao.SavedPlace{Name: "Flat", PlaceAlias: dao.Home},
+			want:  []api.PlaceRankingBoostResponse{{Reason: personalBoostSaved, Score: 0.3, Detail: `saved as "Flat", home`}},
+		},
+		{
+			name:   "visited now",
+			visits: visitsAgo(0),
+			want:   []api.PlaceRankingBoostResponse{{Reason: personalBoostVisited, Score: 0.085041, Detail: "visited 1 times, last 0s ago"}},
+		},
+		{
+			name:   "a visit a half life ago counts for half a visit",
+			visits: visitsAgo(14 * day),
+			want:   []api.PlaceRankingBoostResponse{{Reason: personalBoostVisited, Score: 0.046055, Detail: "visited 1 times, last 336h0m0s ago"}},
+		},
+		{
+			name:   "a visit two half lives ago counts for a quarter of a visit",
+			visits: visitsAgo(28*day + 90*time.Second),
+			want:   []api.PlaceRankingBoostResponse{{Reason: personalBoostVisited, Score: 0.023985, Detail: "visited 1 times, last 672h1m0s ago"}},
+		},
+		{
+			name:   "two visits of different ages",
+			visits: visitsAgo(0, 14*day),
+			want:   []api.PlaceRankingBoostResponse{{Reason: personalBoostVisited, Score: 0.118041, Detail: "visited 2 times, last 0s ago"}},
+		},
+		{
+			name:   "three recent visits",
+			visits: visitsAgo(time.Hour, 2*time.Hour, 3*time.Hour),
+			want:   []api.PlaceRankingBoostResponse{{Reason: personalBoostVisited, Score: 0.189181, Detail: "visited 3 times, last 1h0m0s ago"}},
+		},
+		{
+			name:   "saved and visited",
+			saved:  &dao.SavedPlace{Name: "Office", PlaceAlias: dao.Work},
+			visits: visitsAgo(0),
+			want: []api.PlaceRankingBoostResponse{
+				{Reason: personalBoostSaved, Score: 0.3, Detail: `saved as "Office", work`},
+				{Reason: personalBoostVisited, Score: 0.085041, Detail: "visited 1 times, last 0s ago"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			placeId := primitive.NewObjectID()
+			history := &personalHistory{
+				saved:  map[primitive.ObjectID]dao.SavedPlace{},
+				visits: map[primitive.ObjectID][]time.Time{},
+			}
+			if tt.saved != nil {
+				history.saved[placeId] = *tt.saved
+			}
+			if tt.visits != nil {
+				history.visits[placeId] = tt.visits
+			}
+
+			if got := history.boosts(placeId, personalNow); !sameBoosts(got, tt.want) {
+				t.Errorf("got boosts %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPersonalVisitBoostDiminishes(t *testing.T) {
+	placeId := primitive.NewObjectID()
+	history := &personalHistory{visits: map[primitive.ObjectID][]time.Time{}}
+
+	previous, added := 0.0, math.Inf(1)
+	for n := 1; n <= 20; n++ {
+		history.visits[placeId] = append(history.visits[placeId], personalNow)
+		score := history.boosts(placeId, personalNow)[0].Score
+
+		// each more visit adds less than the last, and the visits never add more than the most
+		if score-previous >= added || score >= personalVisitBoost {
+			t.Fatalf("%d visits score %f after %f, adding %f after %f", n, score, previous, score-previous, added)
+		}
+		previous, added = score, score-previous
+	}
+}
+
+func TestPersonalize(t *testing.T) {
+	placed := func(name string, score float64, rank int) rankedPlace {
+		place := dao.Place{Id: primitive.NewObjectID(), Name: name}
+		return rankedPlace{scoredPlace: scoredPlace{place: place, score: score, match: placeMatchPrefix, rank: rank}, total: score}
+	}
+	places := []rankedPlace{
+		placed("Aldwych", 0.9, 0),
+		placed("Bank", 0.7, 1),
+		placed("Covent Garden", 0.8, 2),
+		placed("Farringdon", 0.5, 3),
+		placed("Dalston", 0.8, 4),
+		placed("Embankment", 0.8, 5),
+	}
+	ids := make(map[string]primitive.ObjectID)
+	for _, p := range places {
+		ids[p.place.Name] = p.place.Id
+	}
+
+	history := &personalHistory{
+		saved: map[primitive.ObjectID]dao.SavedPlace{
+			ids["Bank"]:       {Name: "Work", PlaceAlias: dao.Work},
+			ids["Farringdon"]: {Name: "Gym"},
+		},
+		visits: map[primitive.ObjectID][]time.Time{ids["Covent Garden"]: visitsAgo(0)},
+	}
+	history.personalize(places, personalNow)
+
+	// the boosted places move up, Farringdon is boosted to the score of Dalston and Embankment
+	// and keeps its place before them
+	var order []string
+	for _, p := range places {
+		order = append(order, p.place.Name)
+	}
+	want := []string{"Bank", "Aldwych", "Covent Garden", "Farringdon", "Dalston", "Embankment"}
+	if !reflect.DeepEqual(order, want) {
+		t.Fatalf("got the places in the order %v, want %v", order, want)
+	}
+
+	rankings := []*api.PlaceRankingResponse{
+		{Score: 1.0, Relevance: 0.7, Match: placeMatchPrefix, Boosts: []api.PlaceRankingBoostResponse{
+			{Reason: personalBoostSaved, Score: 0.3, Detail: `saved as "Work", work`},
+		}},
+		{Score: 0.9, Relevance: 0.9, Match: placeMatchPrefix, Boosts: []api.PlaceRankingBoostResponse{}},
+		{Score: 0.885041, Relevance: 0.8, Match: placeMatchPrefix, Boosts: []api.PlaceRankingBoostResponse{
+			{Reason: personalBoostVisited, Score: 0.085041, Detail: "visited 1 times, last 0s ago"},
+		}},
+		{Score: 0.8, Relevance: 0.5, Match: placeMatchPrefix, Boosts: []api.PlaceRankingBoostResponse{
+			{Reason: personalBoostSaved, Score: 0.3, Detail: `saved as "Gym"`},
+		}},
+		{Score: 0.8, Relevance: 0.8, Match: placeMatchPrefix, Boosts: []api.PlaceRankingBoostResponse{}},
+		{Score: 0.8, Relevance: 0.8, Match: placeMatchPrefix, Boosts: []api.PlaceRankingBoostResponse{}},
+	}
+	for i, want := range rankings {
+		got := places[i].ranking()
+		// the boosts are never null in the debug output
+		if got.Boosts == nil || !sameBoosts(got.Boosts, want.Boosts) || math.Abs(got.Score-want.Score) > 1e-6 ||
+			got.Relevance != want.Relevance || got.Match != want.Match {
+			t.Errorf("got the ranking %+v for %s, want %+v", got, places[i].place.Name, want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package service
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/utils"
+)
+
+const (
+	// placeSearchCandidates is the number of places each kind of local match reads before they are scored
+	placeSearchCandidates = 50
+	// placeSearchExactScore is the score of a place whose name is the search text
+	placeSearchExactScore = 1
+	// placeSearchPrefixScore is the score of a place whose name starts with the search text
+	placeSearchPrefixScore = 0.9
+	// placeSearchWordsScore is the most a place whose words match the words of the search text scores
+	placeSearchWordsScore = 0.8
+	// placeSearchTextScore is the least a place found by the text index scores, it may only match
+	// by the description or by the stem of a word
+	placeSearchTextScore = 0.5
+)
+
+// the ways a place found by a search matches the search text, from the best
+const (
+	placeMatchExact    = "exact"
+	placeMatchPrefix   = "prefix"
+	placeMatchWords    = "words"
+	placeMatchText     = "text"
+	placeMatchUpstream = "upstream"
+)
+
+// scoredPlace is a place found by a search and how well it matches the search text
+type scoredPlace struct {
+	place dao.Place
+	score float64
+	match string
+	// rank is the order the place was found in, which breaks the ties between equal scores
+	rank int
+}
+
+// rankSearchPlaces scores the places found by the local search against the normalized search
+// text and returns the places that match, the best matches first. A place found more than once is
+// only returned once, and the places found by the text index score at least the text score
+func rankSearchPlaces(text string, textMatches, otherMatches []dao.Place) []scoredPlace {
+	found := make(map[string]*scoredPlace)
+	var scored []*scoredPlace
+
+	add := func(place dao.Place, floor float64, floorMatch string) {
+		id := place.Id.Hex()
+		score, match := placeMatchScore(text, place.Name)
+		if score < floor {
+			score, match = floor, floorMatch
+		}
+		if s, ok := found[id]; ok {
+			if score > s.score {
+				s.score, s.match = score, match
+			}
+			return
+		}
+		if score == 0 {
+			return
+		}
+		s := &scoredPlace{place: place, score: score, match: match, rank: len(scored)}
+		found[id] = s
+		scored = append(scored, s)
+	}
+	for _, p := range textMatches {
+		add(p, placeSearchTextScore, placeMatchText)
+	}
+	for _, p := range otherMatches {
+		add(p, 0, "")
+	}
+
+	sort.SliceStable(scored, func(i, j int) bool {
+		if scored[i].score != scored[j].score {
+			return scored[i].score > scored[j].score
+		}
+		return scored[i].rank < scored[j].rank
+	})
+
+	places := make([]scoredPlace, len(scored))
+	for i, s := range scored {
+		places[i] = *s
+	}
+	return places
+}
+
+// placeMatchScore scores how well a name matches a normalized search text from 0 to 1. A name that
+// is or starts with the text scores the most, then a name that has a word matching every word of the
+// text, where a word matches when it is the same, starts with it or is a typo away from it. It also
+// returns how the name matches
+func placeMatchScore(text, name string) (float64, string) {
+	name = utils.NormalizeName(name)
+	switch {
+	case text == "":
+		return 0, ""
+	case name == text:
+		return placeSearchExactScore, placeMatchExact
+	case strings.HasPrefix(name, text):
+		return placeSearchPrefixScore, placeMatchPrefix
+	}
+
+	words := strings.Fields(name)
+	textWords := strings.Fields(text)
+	total := 0.0
+	for _, tw := range textWords {
+		best := 0.0
+		for _, w := range words {
+			if s := wordMatchScore(tw, w); s > best {
+				best = s
+			}
+		}
+		// every word of the text has to match a word of the name
+		if best == 0 {
+			return 0, ""
+		}
+		total += best
+	}
+	return placeSearchWordsScore * total / float64(len(textWords)), placeMatchWords
+}
+
+// wordMatchScore scores how well a word of a name matches a word of the search text from 0 to 1
+// the longer a word is, the more typos it is allowed
+func wordMatchScore(textWord, word string) float64 {
+	if textWord == word {
+		return 1
+	}
+	if strings.HasPrefix(word, textWord) {
+		return 0.9
+	}
+
+	tw, w := []rune(textWord), []rune(word)
+	allowed := allowedTypos(tw)
+	if allowed == 0 {
+		return 0
+	}
+
+	// a word being typed is compared to the start of the word of the name that has its length
+	d := utils.EditDistance(tw, w)
+	if len(w) > len(tw) {
+		if pd := utils.EditDistance(tw, w[:len(tw)]); pd < d {
+			d = pd
+		}
+	}
+	if d > allowed {
+		return 0
+	}
+	return 0.8 * (1 - float64(d)/float64(len(tw)))
+}
+
+// allowedTypos returns the number of typos a word of the search text is allowed
+func allowedTypos(textWord []rune) int {
+	switch {
+	case len(textWord) >= 8:
+		return 2
+	case len(textWord) >= 4:
+		return 1
+	}
+	return 0
+}
+
+// placeMatchMinGrams returns the fewest trigrams of a normalized search text a name matching every
+// word of the text shares with it. A typo changes at most the three trigrams around it, and a word of
+// the name that goes on past the word of the text does not have the trigram at the end of that word
+func placeMatchMinGrams(text string) int {
+	lost := 0
+	for _, tw := range strings.Fields(text) {
+		lost += 3*allowedTypos([]rune(tw)) + 1
+	}
+	if shared := len(utils.NameTrigrams(text)) - lost; shared > 1 {
+		return shared
+	}
+	return 1
+}
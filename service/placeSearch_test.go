@@ -0,0 +1,106 @@
+package service
+
+import (
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/leonardchinonso/lokate-go/utils"
+)
+
+// sharedGrams returns the number of trigrams of a normalized search text the name of a place has
+func sharedGrams(text, name string) int {
+	nameGrams := make(map[string]bool)
+	for _, g := range utils.NameTrigrams(name) {
+		nameGrams[g] = true
+	}
+	shared := 0
+	for _, g := range utils.NameTrigrams(text) {
+		if nameGrams[g] {
+			shared++
+		}
+	}
+	return shared
+}
+
+// typo changes, adds or removes a random letter of a word
+func typo(r *rand.Rand, word string) string {
+	w := []rune(word)
+	i := r.Intn(len(w))
+	letter := rune('a' + r.Intn(26))
+	switch r.Intn(3) {
+	case 0:
+		w[i] = letter
+	case 1:
+		w = append(w[:i], append([]rune{letter}, w[i:]...)...)
+	default:
+		w = append(w[:i], w[i+1:]...)
+	}
+	return string(w)
+}
+
+func TestPlaceMatchMinGramsKeepsEveryMatch(t *testing.T) {
+	names := []string{
+		"London Waterloo", "Charing Cross", "Trafalgar Square (Stop S)", "St Pancras International",
+		"Elephant & Castle", "Manchester Piccadilly", "Bank", "Kings Cross St Pancras", "Oxford Circus",
+		"Clapham Junction", "Shepherd's Bush Market", "Heathrow Terminals 2 & 3",
+	}
+
+	r := rand.New(rand.NewSource(1))
+	checked := 0
+	for i := 0; i < 20000; i++ {
+		name := names[r.Intn(len(names))]
+		words := strings.Fields(utils.NormalizeName(name))
+
+		// a search text of some of the words of the name, the last one maybe still being typed,
+		// with up to two typos in each word
+		var textWords []string
+		for _, w := range words {
+			if r.Intn(3) == 0 {
+				continue
+			}
+			if r.Intn(4) == 0 && len(w) > 1 {
+				w = w[:1+r.Intn(len(w)-1)]
+			}
+			for n := r.Intn(3); n > 0 && len(w) > 1; n-- {
+				w = typo(r, w)
+			}
+			textWords = append(textWords, w)
+		}
+		text := utils.NormalizeName(strings.Join(textWords, " "))
+
+		// the trigram search never finds the names that share no trigram with the text, a word of
+		// one letter being typed is left to the prefix search
+		if score, _ := placeMatchScore(text, name); score == 0 || sharedGrams(text, name) == 0 {
+			continue
+		}
+		checked++
+		if shared, want := sharedGrams(text, name), placeMatchMinGrams(text); shared < want {
+			t.Fatalf("%q matches %q sharing %d trigrams, fewer than the %d the search requires", text, name, shared, want)
+		}
+	}
+	if checked < 1000 {
+		t.Fatalf("only %d search texts matched their name", checked)
+	}
+}
+
+func TestPlaceMatchMinGrams(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 1},
+		{"ba", 1},
+		{"bank", 1},
+		{"waterl", 2},
+		{"waterloo", 1},
+		{"piccadilly", 3},
+		{"manchester piccadilly", 6},
+	}
+
+	for _, tt := range tests {
+		if got := placeMatchMinGrams(tt.text); got != tt.want {
+			t.Errorf("placeMatchMinGrams(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
@@ -2,22 +2,83 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/lokate-go/config"
 	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/models/api"
 	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/dto"
 	"github.com/leonardchinonso/lokate-go/models/interfaces"
+	"github.com/leonardchinonso/lokate-go/utils"
 )
 
+// persistPlacesTimeout bounds the background write of the places found by a search
+const persistPlacesTimeout = 30 * time.Second
+
+// maxPlaceImportRows is the most places a single import can have
+const maxPlaceImportRows = 10000
+
 type placeService struct {
-	placeRepository interfaces.PlaceRepositoryInterface
+	placeRepository            interfaces.PlaceRepositoryInterface
+	savedPlaceRepository       interfaces.SavedPlaceRepositoryInterface
+	lastVisitedPlaceRepository interfaces.LastVisitedPlaceRepositoryInterface
+	transitService             interfaces.TransitServiceInterface
+	bikeShareService           interfaces.BikeShareServiceInterface
+	placeWriter                interfaces.PlaceWriterInterface
+	reverseMetres              int
+	duplicateMetres            int
+	searchMinLocal             int
 }
 
 // NewPlaceService returns an interface for the place service methods
-func NewPlaceService(placeRepo interfaces.PlaceRepositoryInterface) interfaces.PlaceServiceInterface {
-	return &placeService{
-		placeRepository: placeRepo,
+func NewPlaceService(cfg *map[string]string,
+	placeRepo interfaces.PlaceRepositoryInterface,
+	savedPlaceRepo interfaces.SavedPlaceRepositoryInterface,
+	lastVisitedPlaceRepo interfaces.LastVisitedPlaceRepositoryInterface,
+	transitService interfaces.TransitServiceInterface,
+	bikeShareService interfaces.BikeShareServiceInterface,
+	placeWriter interfaces.PlaceWriterInterface,
+) (interfaces.PlaceServiceInterface, error) {
+	reverseMetres, err := strconv.Atoi((*cfg)[config.ReverseGeocodeMetres])
+	if err != nil || reverseMetres <= 0 {
+		return nil, fmt.Errorf("invalid %s: %q", config.ReverseGeocodeMetres, (*cfg)[config.ReverseGeocodeMetres])
+	}
+
+	duplicateMetres, err := strconv.Atoi((*cfg)[config.PlaceDuplicateMetres])
+	if err != nil || duplicateMetres <= 0 {
+		return nil, fmt.Errorf("invalid %s: %q", config.PlaceDuplicateMetres, (*cfg)[config.PlaceDuplicateMetres])
 	}
+
+	searchMinLocal, err := strconv.Atoi((*cfg)[config.PlaceSearchMinLocal])
+	if err != nil || searchMinLocal < 0 {
+		return nil, fmt.Errorf("invalid %s: %q", config.PlaceSearchMinLocal, (*cfg)[config.PlaceSearchMinLocal])
+	}
+
+	return &placeService{
+		placeRepository:            placeRepo,
+		savedPlaceRepository:       savedPlaceRepo,
+		lastVisitedPlaceRepository: lastVisitedPlaceRepo,
+		transitService:             transitService,
+		bikeShareService:           bikeShareService,
+		placeWriter:                placeWriter,
+		reverseMetres:              reverseMetres,
+		duplicateMetres:            duplicateMetres,
+		searchMinLocal:             searchMinLocal,
+	}, nil
+}
+
+// DuplicateMetres returns the default radius in metres duplicate places are looked for in
+func (ps *placeService) DuplicateMetres() int {
+	return ps.duplicateMetres
 }
 
 // Create adds a place to the application
@@ -53,6 +114,12 @@ func (ps *placeService) GetPlace(ctx context.Context, place *dao.Place) error {
 		return errors.ErrBadRequest("invalid place id", nil)
 	}
 
+	// a place found by a search moments ago may still be being written
+	if err := ps.placeWriter.Wait(ctx, place.Id); err != nil {
+		log.Printf("Error waiting for the write of place with id: %v. Error: %v\n", place.Id, err)
+		return errors.ErrInternalServerError("failed to retrieve place", nil)
+	}
+
 	// find place in the database
 	placeExists, err := ps.placeRepository.FindByID(ctx, place)
 	if err != nil {
@@ -67,3 +134,595 @@ func (ps *placeService) GetPlace(ctx context.Context, place *dao.Place) error {
 
 	return nil
 }
+
+// GetNearbyPlaces gets a page of the stored places around a point, nearest first
+func (ps *placeService) GetNearbyPlaces(ctx context.Context, query dto.NearbyPlacesQuery) (*api.NearbyPlacesResponse, error) {
+	var places []dao.Place
+
+	// find the places in the radius of the point
+	err := ps.placeRepository.FindNearby(ctx, query, &places)
+	if err != nil {
+		log.Printf("Error finding nearby places. Error: %v\n", err)
+		return nil, errors.ErrInternalServerError("failed to retrieve nearby places", nil)
+	}
+
+	resp := api.NewNearbyPlacesResponse(places, query.Page, query.PerPage)
+	ps.bikeShareService.AnnotatePlaces(resp.Places)
+	return resp, nil
+}
+
+// DescribePlace returns the response of a place with the live availability of a bike share station
+func (ps *placeService) DescribePlace(place *dao.Place) *api.PlaceResponse {
+	resp := []api.PlaceResponse{*api.NewPlaceResponse(place)}
+	ps.bikeShareService.AnnotatePlaces(resp)
+	return &resp[0]
+}
+
+// SearchPlaces searches for places with text. The stored places are searched first, by the text
+// index, by the start of their name and by the trigrams of their name so a typo still matches. The
+// transit provider is only searched when too few stored places match, and the places it finds that
+// are not among the stored places are added after them. The places found for a logged-in user are
+// then boosted by how they were saved and visited by the user
+func (ps *placeService) SearchPlaces(ctx context.Context, userId primitive.ObjectID, query dto.PlaceSearchQuery) ([]api.PlaceResponse, error) {
+	text := utils.NormalizeName(query.Text)
+	local, err := ps.searchLocalPlaces(ctx, text)
+	if err != nil {
+		// the transit provider can still answer the search
+		log.Printf("Error searching the stored places. Error: %v\n", err)
+	}
+
+	// the places past the limit are kept until the boosts have ranked them
+	ranked := make([]rankedPlace, 0, len(local))
+	for _, p := range local {
+		ranked = append(ranked, rankedPlace{scoredPlace: p, source: api.PlaceSearchSourceLocal, total: p.score})
+	}
+
+	minLocal := ps.searchMinLocal
+	if query.Limit < minLocal {
+		minLocal = query.Limit
+	}
+	if len(local) < minLocal {
+		upstream, err := ps.searchUpstreamPlaces(ctx, text, query, local)
+		if err != nil && len(ranked) == 0 {
+			return nil, err
+		}
+		ranked = append(ranked, upstream...)
+	}
+
+	if !userId.IsZero() {
+		history, err := ps.loadPersonalHistory(ctx, userId)
+		if err != nil {
+			// the places are still found without the boosts
+			log.Printf("Error reading the history of a user to personalize a search. Error: %v\n", err)
+		} else {
+			history.personalize(ranked, time.Now())
+		}
+	}
+	if len(ranked) > query.Limit {
+		ranked = ranked[:query.Limit]
+	}
+
+	resp := make([]api.PlaceResponse, 0, len(ranked))
+	for i := range ranked {
+		p := api.NewPlaceResponse(&ranked[i].place)
+		p.Source = ranked[i].source
+		if query.Debug {
+			p.Ranking = ranked[i].ranking()
+		}
+		resp = append(resp, *p)
+	}
+
+	ps.bikeShareService.AnnotatePlaces(resp)
+	return resp, nil
+}
+
+// searchUpstreamPlaces searches the transit provider for the places that are not among the stored
+// places found by a search, and stores them so they can be saved and visited by their id
+func (ps *placeService) searchUpstreamPlaces(ctx context.Context, text string, query dto.PlaceSearchQuery, local []scoredPlace) ([]rankedPlace, error) {
+	var upstream []dao.Place
+	if _, err := ps.transitService.SearchPlace(url.QueryEscape(query.Text), &upstream); err != nil {
+		log.Printf("Error searching for places with the transit service. Error: %v\n", err)
+		return nil, err
+	}
+
+	// leave out the places found upstream that are already among the stored places
+	var added []dao.Place
+	for i := range upstream {
+		if len(local)+len(added) == query.Limit {
+			break
+		}
+		if !ps.foundLocally(&upstream[i], local) {
+			added = append(added, upstream[i])
+		}
+	}
+
+	if err := ps.PersistPlaces(ctx, added); err != nil {
+		return nil, err
+	}
+
+	// the transit provider found the places for the text, so they score at least the text score
+	ranked := make([]rankedPlace, 0, len(added))
+	for i := range added {
+		score, _ := placeMatchScore(text, added[i].Name)
+		if score < placeSearchTextScore {
+			score = placeSearchTextScore
+		}
+		p := scoredPlace{place: added[i], score: score, match: placeMatchUpstream, rank: len(local) + i}
+		ranked = append(ranked, rankedPlace{scoredPlace: p, source: api.PlaceSearchSourceUpstream, total: score})
+	}
+	return ranked, nil
+}
+
+// searchLocalPlaces finds the stored places that match a normalized search text, the best matches first
+func (ps *placeService) searchLocalPlaces(ctx context.Context, text string) ([]scoredPlace, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	var textMatches, prefixMatches, gramMatches []dao.Place
+	if err := ps.placeRepository.SearchText(ctx, text, placeSearchCandidates, &textMatches); err != nil {
+		return nil, err
+	}
+	if err := ps.placeRepository.SearchPrefix(ctx, text, placeSearchCandidates, &prefixMatches); err != nil {
+		return nil, err
+	}
+	if err := ps.placeRepository.SearchGrams(ctx, utils.NameTrigrams(text), placeMatchMinGrams(text), placeSearchCandidates, &gramMatches); err != nil {
+		return nil, err
+	}
+
+	return rankSearchPlaces(text, textMatches, append(prefixMatches, gramMatches...)), nil
+}
+
+// foundLocally determines if a place found upstream is one of the stored places found by a search,
+// by its key or by being a duplicate of one of them
+func (ps *placeService) foundLocally(place *dao.Place, local []scoredPlace) bool {
+	for i := range local {
+		l := &local[i].place
+		if l.Key == place.Key {
+			return true
+		}
+		if place.Latitude != nil && place.Longitude != nil && l.Latitude != nil && l.Longitude != nil &&
+			dao.SameUpstreamPlace(place, l) && duplicatePlaces(place, l, ps.duplicateMetres) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReversePlace gets the place at a point. The nearest stored place within the tolerance is
+// preferred, otherwise the nearest place from the transit provider is used
+func (ps *placeService) ReversePlace(ctx context.Context, query dto.ReversePlaceQuery) (*api.PlaceResponse, error) {
+	// look for a stored place first, it already has an id
+	var stored []dao.Place
+	nearby := dto.NearbyPlacesQuery{Latitude: query.Latitude, Longitude: query.Longitude, Radius: ps.reverseMetres, Page: 1, PerPage: 1}
+	if err := ps.placeRepository.FindNearby(ctx, nearby, &stored); err != nil {
+		log.Printf("Error finding nearby places. Error: %v\n", err)
+		return nil, errors.ErrInternalServerError("failed to retrieve place", nil)
+	}
+	if len(stored) > 0 {
+		return ps.DescribePlace(&stored[0]), nil
+	}
+
+	// fall back to the nearest place upstream
+	var places []dao.Place
+	nearest := dto.NearestPlacesQuery{Latitude: query.Latitude, Longitude: query.Longitude}
+	if _, err := ps.transitService.NearestPlaces(nearest, &places); err != nil {
+		log.Printf("Error getting nearest places from the transit service. Error: %v\n", err)
+		return nil, err
+	}
+	if len(places) == 0 {
+		return nil, errors.ErrBadRequest("no place found at this location", nil)
+	}
+
+	// the place is stored like the places found by a search, so it gets the id of its key
+	if query.Save {
+		if err := ps.PersistPlaces(ctx, places[:1]); err != nil {
+			return nil, err
+		}
+	}
+
+	return api.NewPlaceResponse(&places[0]), nil
+}
+
+// PersistPlaces gives each place the id it is stored with. Places that are stored already keep
+// their id and the others are queued to the place writer, so the caller does not wait for the
+// write. The requests that look a place up by its id wait for its write first
+func (ps *placeService) PersistPlaces(ctx context.Context, places []dao.Place) error {
+	if len(places) == 0 {
+		return nil
+	}
+
+	keys := make([]string, len(places))
+	for i := range places {
+		keys[i] = places[i].Key
+	}
+
+	// find the places that are stored already
+	var stored []dao.Place
+	if err := ps.placeRepository.FindByKeys(ctx, keys, &stored); err != nil {
+		log.Printf("Error finding places by key. Error: %v\n", err)
+		return errors.ErrInternalServerError("failed to store places", nil)
+	}
+	storedIds := make(map[string]primitive.ObjectID, len(stored))
+	for _, p := range stored {
+		storedIds[p.Key] = p.Id
+	}
+
+	// the other places get an id from their key, which is the id the write stores them with
+	var unstored []dao.Place
+	for i := range places {
+		if id, ok := storedIds[places[i].Key]; ok {
+			places[i].Id = id
+			continue
+		}
+		places[i].Id = dao.PlaceIdFromKey(places[i].Key)
+		storedIds[places[i].Key] = places[i].Id
+
+		// the distance is from the query point and is not kept with the stored place
+		place := places[i]
+		place.Distance = nil
+		unstored = append(unstored, place)
+	}
+
+	if err := ps.placeWriter.Write(ctx, unstored); err != nil {
+		log.Printf("Error storing %d places. Error: %v\n", len(unstored), err)
+		return errors.ErrInternalServerError("failed to store places", nil)
+	}
+
+	return nil
+}
+
+// FindDuplicatePlaces finds the groups of stored places that look like the same place. The
+// places are grouped from the few fields that tell duplicates apart, and only the places in a
+// group are read whole
+func (ps *placeService) FindDuplicatePlaces(ctx context.Context, radius int) (*api.DuplicatePlacesResponse, error) {
+	var places []dao.Place
+	if err := ps.placeRepository.FindAllForDuplicates(ctx, &places); err != nil {
+		log.Printf("Error finding all places. Error: %v\n", err)
+		return nil, errors.ErrInternalServerError("failed to retrieve places", nil)
+	}
+
+	groups := groupDuplicatePlaces(places, radius)
+	var ids []primitive.ObjectID
+	for _, group := range groups {
+		for _, idx := range group {
+			ids = append(ids, places[idx].Id)
+		}
+	}
+
+	var grouped []dao.Place
+	if len(ids) > 0 {
+		if err := ps.placeRepository.FindByIDs(ctx, ids, &grouped); err != nil {
+			log.Printf("Error finding places by id. Error: %v\n", err)
+			return nil, errors.ErrInternalServerError("failed to retrieve places", nil)
+		}
+	}
+	byId := make(map[primitive.ObjectID]*dao.Place, len(grouped))
+	for i := range grouped {
+		byId[grouped[i].Id] = &grouped[i]
+	}
+
+	resp := &api.DuplicatePlacesResponse{Radius: radius, Groups: []api.DuplicatePlaceGroupResponse{}}
+	for _, group := range groups {
+		groupResp := api.DuplicatePlaceGroupResponse{Places: make([]api.PlaceResponse, 0, len(group))}
+		for _, idx := range group {
+			// a place deleted since the scan is left out of its group
+			if place, ok := byId[places[idx].Id]; ok {
+				groupResp.Places = append(groupResp.Places, *api.NewPlaceResponse(place))
+			}
+		}
+		if len(groupResp.Places) > 1 {
+			resp.Groups = append(resp.Groups, groupResp)
+		}
+	}
+
+	return resp, nil
+}
+
+// MergePlaces merges duplicate places into a place. The saved places and the visits of the
+// duplicates are moved to the place before the duplicates are deleted, so a merge that fails
+// part of the way can be run again. A user left with several saved places of the place keeps one
+func (ps *placeService) MergePlaces(ctx context.Context, placeId primitive.ObjectID, duplicateIds []primitive.ObjectID) (*api.MergePlacesResponse, error) {
+	// the place to merge into must exist
+	place := &dao.Place{Id: placeId}
+	if err := ps.GetPlace(ctx, place); err != nil {
+		return nil, err
+	}
+
+	// every duplicate must exist
+	var duplicates []dao.Place
+	if err := ps.placeRepository.FindByIDs(ctx, duplicateIds, &duplicates); err != nil {
+		log.Printf("Error finding places by id. Error: %v\n", err)
+		return nil, errors.ErrInternalServerError("failed to retrieve places", nil)
+	}
+	if len(duplicates) != len(duplicateIds) {
+		return nil, errors.ErrBadRequest("place not found", nil)
+	}
+
+	// each user keeps one saved place of the merged places, with the alias and the pin of the others
+	var saved []dao.SavedPlace
+	if err := ps.savedPlaceRepository.FindByPlaceIDs(ctx, append([]primitive.ObjectID{placeId}, duplicateIds...), &saved); err != nil {
+		log.Printf("Error finding the saved places of duplicate places. Error: %v\n", err)
+		return nil, errors.ErrInternalServerError("failed to merge places", nil)
+	}
+	merges, err := mergeSavedPlaces(saved, placeId)
+	if err != nil {
+		return nil, errors.ErrConflict(err.Error(), nil)
+	}
+	now := utils.CurrentPrimitiveTime()
+	for i := range merges {
+		merges[i].kept.UpdatedAt = now
+		if err := ps.savedPlaceRepository.Merge(ctx, &merges[i].kept, merges[i].removed); err != nil {
+			log.Printf("Error moving the saved places of duplicate places. Error: %v\n", err)
+			return nil, errors.ErrInternalServerError("failed to merge places", nil)
+		}
+	}
+	savedPlaces := int64(len(merges))
+
+	lastVisitedPlaces, err := ps.lastVisitedPlaceRepository.RepointPlace(ctx, duplicateIds, placeId)
+	if err != nil {
+		log.Printf("Error moving the visits of duplicate places. Error: %v\n", err)
+		return nil, errors.ErrInternalServerError("failed to merge places", nil)
+	}
+
+	merged, err := ps.placeRepository.DeleteByIDs(ctx, duplicateIds)
+	if err != nil {
+		log.Printf("Error deleting duplicate places. Error: %v\n", err)
+		return nil, errors.ErrInternalServerError("failed to merge places", nil)
+	}
+
+	return &api.MergePlacesResponse{
+		PlaceId:           placeId.Hex(),
+		MergedPlaces:      merged,
+		SavedPlaces:       savedPlaces,
+		LastVisitedPlaces: lastVisitedPlaces,
+	}, nil
+}
+
+// MergeSameKeyPlaces merges the places stored with the same key, from before the keys were unique,
+// into the place with the id of the key or else the oldest one. The places whose saved places
+// cannot be merged have their key taken away instead, so they are left for an admin to merge.
+// It returns the number of places merged or left without a key
+func (ps *placeService) MergeSameKeyPlaces(ctx context.Context) (int64, error) {
+	groups, err := ps.placeRepository.FindSameKeyPlaces(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var merged int64
+	for key, ids := range groups {
+		keep := sameKeyPlaceToKeep(key, ids)
+		var duplicateIds []primitive.ObjectID
+		for _, id := range ids {
+			if id != keep {
+				duplicateIds = append(duplicateIds, id)
+			}
+		}
+
+		resp, err := ps.MergePlaces(ctx, keep, duplicateIds)
+		if errors.Status(err) == http.StatusConflict {
+			log.Printf("Failed to merge the places with key %s into %v, taking their key away. Error: %v\n", key, keep.Hex(), err)
+			unset, err := ps.placeRepository.UnsetKeys(ctx, duplicateIds)
+			if err != nil {
+				return merged, err
+			}
+			merged += unset
+			continue
+		}
+		if err != nil {
+			return merged, fmt.Errorf("failed to merge the places with key %s: %v", key, err)
+		}
+		merged += resp.MergedPlaces
+	}
+
+	return merged, nil
+}
+
+// sameKeyPlaceToKeep returns the place the places with the same key are merged into, the place
+// with the id the key gives keeps the ids handed out by the searches valid
+func sameKeyPlaceToKeep(key string, ids []primitive.ObjectID) primitive.ObjectID {
+	keyId := dao.PlaceIdFromKey(key)
+	oldest := ids[0]
+	for _, id := range ids {
+		if id == keyId {
+			return id
+		}
+		// the ids start with the time they were made at
+		if id.Hex() < oldest.Hex() {
+			oldest = id
+		}
+	}
+	return oldest
+}
+
+// UpdatePlace changes the fields of a place that are set in the request. The location and the key
+// of the place follow its new fields, and a place cannot take the key of another stored place
+func (ps *placeService) UpdatePlace(ctx context.Context, placeId primitive.ObjectID, req dto.UpdatePlaceRequest) (*api.PlaceResponse, error) {
+	place := &dao.Place{Id: placeId}
+	if err := ps.GetPlace(ctx, place); err != nil {
+		return nil, err
+	}
+
+	setString := func(dst *string, src *string) {
+		if src != nil {
+			*dst = strings.TrimSpace(*src)
+		}
+	}
+	setString(&place.Type, req.Type)
+	setString(&place.Name, req.Name)
+	setString(&place.Description, req.Description)
+	setString(&place.OSMId, req.OSMId)
+	setString(&place.ATCOCode, req.ATCOCode)
+	setString(&place.StationCode, req.StationCode)
+	setString(&place.TiplocCode, req.TiplocCode)
+	setString(&place.SMSCode, req.SMSCode)
+	if req.Latitude != nil {
+		place.Latitude = req.Latitude
+	}
+	if req.Longitude != nil {
+		place.Longitude = req.Longitude
+	}
+	if place.Latitude == nil || place.Longitude == nil {
+		return nil, errors.ErrBadRequest("a place must have a latitude and a longitude", nil)
+	}
+	place.Location = dao.NewGeoPoint(*place.Latitude, *place.Longitude)
+
+	// the new key must not belong to another place, which would be a duplicate to merge instead
+	key := dao.PlaceKey(place)
+	if key != place.Key {
+		other := &dao.Place{Key: key}
+		exists, err := ps.placeRepository.FindByKey(ctx, other)
+		if err != nil {
+			log.Printf("Error finding a place by key. Error: %v\n", err)
+			return nil, errors.ErrInternalServerError("failed to update place", nil)
+		}
+		if exists && other.Id != place.Id {
+			return nil, errors.ErrConflict("another place has the same key, merge the places instead", map[string]string{"place_id": other.Id.Hex()})
+		}
+		place.Key = key
+	}
+
+	if err := ps.placeRepository.Update(ctx, place); err != nil {
+		log.Printf("Error updating place with id: %v. Error: %v\n", place.Id, err)
+		return nil, errors.ErrInternalServerError("failed to update place", nil)
+	}
+
+	return api.NewPlaceResponse(place), nil
+}
+
+// DeletePlace deletes a place that no user has saved or visited. A place that is referenced
+// would leave the saved places and the visits pointing at nothing, so it has to be merged
+// into another place instead
+func (ps *placeService) DeletePlace(ctx context.Context, placeId primitive.ObjectID) error {
+	place := &dao.Place{Id: placeId}
+	if err := ps.GetPlace(ctx, place); err != nil {
+		return err
+	}
+
+	savedPlaces, err := ps.savedPlaceRepository.CountByPlaceID(ctx, placeId)
+	if err != nil {
+		log.Printf("Error counting the saved places of place with id: %v. Error: %v\n", placeId, err)
+		return errors.ErrInternalServerError("failed to delete place", nil)
+	}
+
+	lastVisitedPlaces, err := ps.lastVisitedPlaceRepository.CountByPlaceID(ctx, placeId)
+	if err != nil {
+		log.Printf("Error counting the visits of place with id: %v. Error: %v\n", placeId, err)
+		return errors.ErrInternalServerError("failed to delete place", nil)
+	}
+
+	if savedPlaces > 0 || lastVisitedPlaces > 0 {
+		return errors.ErrConflict("the place is saved or visited by users, merge it into another place instead", map[string]int64{
+			"saved_places":        savedPlaces,
+			"last_visited_places": lastVisitedPlaces,
+		})
+	}
+
+	if _, err = ps.placeRepository.DeleteByIDs(ctx, []primitive.ObjectID{placeId}); err != nil {
+		log.Printf("Error deleting place with id: %v. Error: %v\n", placeId, err)
+		return errors.ErrInternalServerError("failed to delete place", nil)
+	}
+
+	return nil
+}
+
+// ImportPlaces stores the valid places of an import that are not stored yet and reports what was
+// done with each row. The places get an id from their key, so running an import again stores
+// nothing new. A dry run reports the same without storing any place
+func (ps *placeService) ImportPlaces(ctx context.Context, rows []dto.PlaceImportRow, dryRun bool) (*api.PlaceImportResponse, error) {
+	if len(rows) == 0 {
+		return nil, errors.ErrBadRequest("the import has no places", nil)
+	}
+	if len(rows) > maxPlaceImportRows {
+		return nil, errors.ErrBadRequest(fmt.Sprintf("an import can have at most %d places", maxPlaceImportRows), nil)
+	}
+
+	resp := &api.PlaceImportResponse{DryRun: dryRun, Rows: make([]api.PlaceImportRowResponse, len(rows))}
+
+	// validate the rows, a row with the key of an earlier row is the same place
+	places := make([]*dao.Place, len(rows))
+	firstRow := make(map[string]int)
+	var keys []string
+	for i := range rows {
+		row := &resp.Rows[i]
+		row.Row = rows[i].Row
+
+		place, errs := rows[i].ToPlace()
+		if len(errs) > 0 {
+			row.Status, row.Errors = api.PlaceImportInvalid, errors.ErrorToStringSlice(errs)
+			continue
+		}
+		row.Key = place.Key
+
+		if first, ok := firstRow[place.Key]; ok {
+			row.Status, row.Errors = api.PlaceImportExists, []string{fmt.Sprintf("same place as row %d", rows[first].Row)}
+			continue
+		}
+		firstRow[place.Key] = i
+		places[i] = place
+		keys = append(keys, place.Key)
+	}
+
+	// find the places that are stored already
+	var stored []dao.Place
+	if len(keys) > 0 {
+		if err := ps.placeRepository.FindByKeys(ctx, keys, &stored); err != nil {
+			log.Printf("Error finding places by key. Error: %v\n", err)
+			return nil, errors.ErrInternalServerError("failed to import places", nil)
+		}
+	}
+	storedIds := make(map[string]primitive.ObjectID, len(stored))
+	for _, p := range stored {
+		storedIds[p.Key] = p.Id
+	}
+
+	var unstored []dao.Place
+	for i, place := range places {
+		if place == nil {
+			continue
+		}
+		row := &resp.Rows[i]
+
+		if id, ok := storedIds[place.Key]; ok {
+			row.Status, row.Id = api.PlaceImportExists, id.Hex()
+			continue
+		}
+
+		place.Id = dao.PlaceIdFromKey(place.Key)
+		row.Id = place.Id.Hex()
+		if dryRun {
+			row.Status = api.PlaceImportValid
+			continue
+		}
+		row.Status = api.PlaceImportCreated
+		unstored = append(unstored, *place)
+	}
+
+	if len(unstored) > 0 {
+		if err := ps.placeRepository.UpsertByKey(ctx, unstored); err != nil {
+			log.Printf("Error storing %d imported places. Error: %v\n", len(unstored), err)
+			return nil, errors.ErrInternalServerError("failed to import places", nil)
+		}
+	}
+
+	for i := range resp.Rows {
+		row := &resp.Rows[i]
+		// a repeated row is the place of the row it repeats
+		if row.Id == "" && row.Status == api.PlaceImportExists {
+			row.Id = resp.Rows[firstRow[row.Key]].Id
+		}
+
+		switch row.Status {
+		case api.PlaceImportCreated:
+			resp.Created++
+		case api.PlaceImportValid:
+			resp.Valid++
+		case api.PlaceImportExists:
+			resp.Exists++
+		case api.PlaceImportInvalid:
+			resp.Invalid++
+		}
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,34 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
+
+func TestSameKeyPlaceToKeep(t *testing.T) {
+	key := "atco:490000077E"
+	keyId := dao.PlaceIdFromKey(key)
+	older := primitive.NewObjectIDFromTimestamp(time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC))
+	newer := primitive.NewObjectIDFromTimestamp(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	tests := []struct {
+		name string
+		ids  []primitive.ObjectID
+		want primitive.ObjectID
+	}{
+		{"the id of the key", []primitive.ObjectID{older, keyId, newer}, keyId},
+		{"the oldest place", []primitive.ObjectID{newer, older}, older},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameKeyPlaceToKeep(key, tt.ids); got != tt.want {
+				t.Errorf("got %v, want %v", got.Hex(), tt.want.Hex())
+			}
+		})
+	}
+}
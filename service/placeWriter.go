@@ -0,0 +1,184 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+)
+
+const (
+	// placeWriterQueue is the most batches of places that wait to be written, a search that finds
+	// the queue full writes its places itself
+	placeWriterQueue = 64
+	// placeWriterWorkers is the number of batches of places written at the same time
+	placeWriterWorkers = 2
+	// placeWriterRetention is how long a place whose write failed is kept to be written again
+	placeWriterRetention = 10 * time.Minute
+)
+
+// pendingPlace is a place found by a search that is being written, done is closed once the write
+// is over and err is set if it failed
+type pendingPlace struct {
+	place    dao.Place
+	done     chan struct{}
+	err      error
+	failedAt time.Time
+}
+
+// placeWriter writes the places found by the searches in the background. The ids of the places
+// are handed out before they are written, so the requests that use an id wait for its write
+type placeWriter struct {
+	placeRepository interfaces.PlaceRepositoryInterface
+	queue           chan []*pendingPlace
+	workers         sync.WaitGroup
+
+	mu      sync.Mutex
+	closed  bool
+	pending map[primitive.ObjectID]*pendingPlace
+}
+
+// NewPlaceWriter returns an interface for the place writer methods and starts its workers
+func NewPlaceWriter(placeRepo interfaces.PlaceRepositoryInterface) interfaces.PlaceWriterInterface {
+	w := &placeWriter{
+		placeRepository: placeRepo,
+		queue:           make(chan []*pendingPlace, placeWriterQueue),
+		pending:         make(map[primitive.ObjectID]*pendingPlace),
+	}
+
+	w.workers.Add(placeWriterWorkers)
+	for i := 0; i < placeWriterWorkers; i++ {
+		go func() {
+			defer w.workers.Done()
+			for batch := range w.queue {
+				ctx, cancel := context.WithTimeout(context.Background(), persistPlacesTimeout)
+				if err := w.write(ctx, batch); err != nil {
+					log.Printf("Error storing %d places. Error: %v\n", len(batch), err)
+				}
+				cancel()
+			}
+		}()
+	}
+	return w
+}
+
+// Write queues the places to be stored, the places already being written are not queued again
+// the places are written before Write returns when the queue is full or the writer is closed
+func (w *placeWriter) Write(ctx context.Context, places []dao.Place) error {
+	now := time.Now()
+
+	w.mu.Lock()
+	var batch []*pendingPlace
+	for _, place := range places {
+		if p, ok := w.pending[place.Id]; ok && p.failedAt.IsZero() {
+			continue
+		}
+		p := &pendingPlace{place: place, done: make(chan struct{})}
+		w.pending[place.Id] = p
+		batch = append(batch, p)
+	}
+	// forget the places whose write failed long ago, nobody is going to ask for them
+	for id, p := range w.pending {
+		if !p.failedAt.IsZero() && now.Sub(p.failedAt) > placeWriterRetention {
+			delete(w.pending, id)
+		}
+	}
+
+	queued := false
+	if len(batch) > 0 && !w.closed {
+		select {
+		case w.queue <- batch:
+			queued = true
+		default:
+		}
+	}
+	w.mu.Unlock()
+
+	if len(batch) == 0 || queued {
+		return nil
+	}
+	return w.write(ctx, batch)
+}
+
+// write stores a batch of places and ends their pending writes
+func (w *placeWriter) write(ctx context.Context, batch []*pendingPlace) error {
+	places := make([]dao.Place, len(batch))
+	for i, p := range batch {
+		places[i] = p.place
+	}
+	err := w.placeRepository.UpsertByKey(ctx, places)
+
+	w.mu.Lock()
+	for _, p := range batch {
+		p.err = err
+		if err != nil {
+			p.failedAt = time.Now()
+		} else if w.pending[p.place.Id] == p {
+			delete(w.pending, p.place.Id)
+		}
+		close(p.done)
+	}
+	w.mu.Unlock()
+
+	return err
+}
+
+// Wait waits for the write of a place found by a search, it returns at once for a place that is
+// not being written. A place whose write failed is written again, so the id handed out for it
+// still resolves
+func (w *placeWriter) Wait(ctx context.Context, id primitive.ObjectID) error {
+	w.mu.Lock()
+	p, ok := w.pending[id]
+	w.mu.Unlock()
+	if !ok {
+		return nil
+	}
+
+	select {
+	case <-p.done:
+	case <-ctx.Done():
+		return fmt.Errorf("failed to wait for the write of place %s: %v", id.Hex(), ctx.Err())
+	}
+	if p.err == nil {
+		return nil
+	}
+
+	if err := w.placeRepository.UpsertByKey(ctx, []dao.Place{p.place}); err != nil {
+		return fmt.Errorf("failed to store place %s: %v", id.Hex(), err)
+	}
+	w.mu.Lock()
+	if w.pending[id] == p {
+		delete(w.pending, id)
+	}
+	w.mu.Unlock()
+	return nil
+}
+
+// Close stops queueing writes and waits for the queued ones until ctx is done
+func (w *placeWriter) Close(ctx context.Context) error {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.queue)
+	}
+	w.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		w.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to write the queued places: %v", ctx.Err())
+	}
+}
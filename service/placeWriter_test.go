@@ -0,0 +1,127 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+)
+
+// upsertPlaceRepo stores the upserted places in memory, the other place repository methods are not used
+type upsertPlaceRepo struct {
+	interfaces.PlaceRepositoryInterface
+
+	mu       sync.Mutex
+	stored   map[primitive.ObjectID]dao.Place
+	failures int
+	release  chan struct{}
+}
+
+func (r *upsertPlaceRepo) UpsertByKey(ctx context.Context, places []dao.Place) error {
+	if r.release != nil {
+		<-r.release
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.failures > 0 {
+		r.failures--
+		return fmt.Errorf("the database is unavailable")
+	}
+	for _, p := range places {
+		r.stored[p.Id] = p
+	}
+	return nil
+}
+
+func (r *upsertPlaceRepo) has(id primitive.ObjectID) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.stored[id]
+	return ok
+}
+
+func searchPlace(key string) dao.Place {
+	return dao.Place{Id: dao.PlaceIdFromKey(key), Key: key, Name: key}
+}
+
+func TestPlaceWriterWaitForQueuedWrite(t *testing.T) {
+	repo := &upsertPlaceRepo{stored: make(map[primitive.ObjectID]dao.Place), release: make(chan struct{})}
+	w := NewPlaceWriter(repo)
+	ctx := context.Background()
+
+	place := searchPlace("atco:490000077E")
+	if err := w.Write(ctx, []dao.Place{place}); err != nil {
+		t.Fatalf("Write returned an error: %v", err)
+	}
+
+	waited := make(chan error)
+	go func() { waited <- w.Wait(ctx, place.Id) }()
+	close(repo.release)
+
+	if err := <-waited; err != nil {
+		t.Fatalf("Wait returned an error: %v", err)
+	}
+	if !repo.has(place.Id) {
+		t.Error("Wait returned before the place was stored")
+	}
+	if err := w.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlaceWriterRetriesFailedWrite(t *testing.T) {
+	repo := &upsertPlaceRepo{stored: make(map[primitive.ObjectID]dao.Place), failures: 1}
+	w := NewPlaceWriter(repo)
+	ctx := context.Background()
+
+	place := searchPlace("crs:CHX")
+	if err := w.Write(ctx, []dao.Place{place}); err != nil {
+		t.Fatalf("Write returned an error: %v", err)
+	}
+
+	// the queued write fails, waiting for the place writes it again
+	if err := w.Wait(ctx, place.Id); err != nil {
+		t.Fatalf("Wait returned an error: %v", err)
+	}
+	if !repo.has(place.Id) {
+		t.Error("the place whose write failed was not written again")
+	}
+	if err := w.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPlaceWriterCloseDrainsQueue(t *testing.T) {
+	repo := &upsertPlaceRepo{stored: make(map[primitive.ObjectID]dao.Place)}
+	w := NewPlaceWriter(repo)
+	ctx := context.Background()
+
+	var places []dao.Place
+	for i := 0; i < 3*placeWriterQueue; i++ {
+		place := searchPlace(fmt.Sprintf("osm:%d", i))
+		places = append(places, place)
+		if err := w.Write(ctx, []dao.Place{place}); err != nil {
+			t.Fatalf("Write returned an error: %v", err)
+		}
+	}
+	if err := w.Close(ctx); err != nil {
+		t.Fatalf("Close returned an error: %v", err)
+	}
+
+	for _, p := range places {
+		if !repo.has(p.Id) {
+			t.Fatalf("place %s was not written before Close returned", p.Key)
+		}
+	}
+
+	// a writer that is closed writes the places itself
+	late := searchPlace("osm:late")
+	if err := w.Write(ctx, []dao.Place{late}); err != nil || !repo.has(late.Id) {
+		t.Errorf("Write after Close returned %v, stored %v", err, repo.has(late.Id))
+	}
+}
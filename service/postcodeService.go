@@ -0,0 +1,248 @@
+package service
+
+import (
+	stderrors "errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/leonardchinonso/lokate-go/config"
+	"github.com/leonardchinonso/lokate-go/datasource"
+	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+)
+
+// geocodedCountries holds the countries whose postal codes can be geocoded. The postcode directory
+// and the TAPI search only know UK postcodes, the other postal codes are validated but not geocoded
+var geocodedCountries = map[string]bool{
+	dto.CountryGB: true,
+}
+
+// postcodeService holds the structure for geocoding postcodes with the local postcode directory
+// and the transit provider for the postcodes that are not in it
+type postcodeService struct {
+	locations       map[string]dao.PostcodeLocation
+	transitService  interfaces.TransitServiceInterface
+	batchMaxRows    int
+	upstreamWorkers int
+}
+
+// NewPostcodeService returns an interface for the postcode service methods
+// the postcode directory is loaded when one is configured, otherwise no postcode is known locally
+func NewPostcodeService(cfg *map[string]string, transitService interfaces.TransitServiceInterface) (interfaces.PostcodeServiceInterface, error) {
+	maxRows, err := strconv.Atoi((*cfg)[config.PostcodeBatchMaxRows])
+	if err != nil || maxRows <= 0 {
+		return nil, fmt.Errorf("invalid %s: %q", config.PostcodeBatchMaxRows, (*cfg)[config.PostcodeBatchMaxRows])
+	}
+
+	workers, err := strconv.Atoi((*cfg)[config.PostcodeUpstreamWorkers])
+	if err != nil || workers <= 0 {
+		return nil, fmt.Errorf("invalid %s: %q", config.PostcodeUpstreamWorkers, (*cfg)[config.PostcodeUpstreamWorkers])
+	}
+
+	// the handlers fall back to the default country, so it must have a postal code format
+	if _, err := dto.ParseCountry((*cfg)[config.DefaultCountry], ""); err != nil {
+		return nil, fmt.Errorf("invalid %s: %q", config.DefaultCountry, (*cfg)[config.DefaultCountry])
+	}
+
+	ps := &postcodeService{
+		locations:       map[string]dao.PostcodeLocation{},
+		transitService:  transitService,
+		batchMaxRows:    maxRows,
+		upstreamWorkers: workers,
+	}
+
+	path := (*cfg)[config.PostcodeDirectoryPath]
+	if path == "" {
+		return ps, nil
+	}
+
+	locations, err := datasource.ReadPostcodeDirectory(path)
+	if err != nil {
+		return nil, err
+	}
+	ps.locations = locations
+
+	log.Printf("Loaded the coordinates of %d postcodes\n", len(locations))
+	return ps, nil
+}
+
+// Geocode gets the coordinate of a postcode from the postcode directory, or from the transit
+// provider when the postcode is not in the directory
+func (ps *postcodeService) Geocode(postcode dto.Postcode) (*api.PostcodeResponse, error) {
+	if err := checkGeocodedCountry(postcode.Country); err != nil {
+		return nil, err
+	}
+
+	if loc, ok := ps.localLocation(postcode); ok {
+		return api.NewPostcodeResponse(postcode.Country, postcode.String(), loc), nil
+	}
+
+	loc, err := ps.geocodeUpstream(postcode)
+	if err != nil {
+		return nil, err
+	}
+	return api.NewPostcodeResponse(postcode.Country, postcode.String(), *loc), nil
+}
+
+// checkGeocodedCountry returns an error when the postal codes of a country cannot be geocoded
+func checkGeocodedCountry(country string) error {
+	if !geocodedCountries[country] {
+		return errors.ErrNotImplemented(fmt.Sprintf("the postal codes of %s cannot be geocoded", country), nil)
+	}
+	return nil
+}
+
+// localLocation gets the coordinate of a postcode from the postcode directory
+func (ps *postcodeService) localLocation(postcode dto.Postcode) (dao.PostcodeLocation, bool) {
+	if postcode.Country != dto.CountryGB {
+		return dao.PostcodeLocation{}, false
+	}
+	loc, ok := ps.locations[postcode.StrVal]
+	return loc, ok
+}
+
+// geocodeUpstream gets the coordinate of a postcode by searching for it with the transit provider
+func (ps *postcodeService) geocodeUpstream(postcode dto.Postcode) (*dao.PostcodeLocation, error) {
+	var places []dao.Place
+	if _, err := ps.transitService.SearchPlace(url.QueryEscape(postcode.String()), &places); err != nil {
+		log.Printf("Error searching for postcode: %s. Error: %v\n", postcode.String(), err)
+		return nil, err
+	}
+
+	// the search also finds places named like the postcode, only the postcode itself is kept
+	for _, p := range places {
+		if p.Type != "postcode" || p.Latitude == nil || p.Longitude == nil {
+			continue
+		}
+		if strings.ToUpper(strings.Join(strings.Fields(p.Name), "")) == postcode.StrVal {
+			return &dao.PostcodeLocation{Latitude: *p.Latitude, Longitude: *p.Longitude}, nil
+		}
+	}
+
+	return nil, errors.ErrBadRequest("postcode not found", nil)
+}
+
+// GeocodeBatch gets the coordinates of a batch of postcodes. The postcodes in the postcode directory
+// are answered straight away and the others are looked up with the transit provider by a bounded
+// number of workers. A postcode that is invalid or not found fails its row only
+func (ps *postcodeService) GeocodeBatch(country string, inputs []dto.PostcodeBatchRow) (*api.PostcodeBatchResponse, error) {
+	if len(inputs) > ps.batchMaxRows {
+		return nil, errors.ErrBadRequest(fmt.Sprintf("a batch can have at most %d postcodes", ps.batchMaxRows), nil)
+	}
+	if err := checkGeocodedCountry(country); err != nil {
+		return nil, err
+	}
+
+	resp := &api.PostcodeBatchResponse{Country: country, Rows: make([]api.PostcodeBatchRowResponse, len(inputs))}
+
+	// the rows of each postcode that is looked up upstream, so a repeated postcode is looked up once
+	upstreamRows := make(map[string][]int)
+	var upstream []dto.Postcode
+
+	for i, input := range inputs {
+		row := &resp.Rows[i]
+		row.Row, row.Input = input.Row, input.Postcode
+
+		postcode, err := dto.NewPostalCode(country, input.Postcode)
+		if err != nil {
+			row.Error = "invalid postcode"
+			continue
+		}
+		row.Postcode = postcode.String()
+
+		if loc, ok := ps.localLocation(postcode); ok {
+			setPostcodeRowLocation(row, loc, api.PostcodeSourceLocal)
+			continue
+		}
+
+		if _, ok := upstreamRows[postcode.StrVal]; !ok {
+			upstream = append(upstream, postcode)
+		}
+		upstreamRows[postcode.StrVal] = append(upstreamRows[postcode.StrVal], i)
+	}
+
+	// look the other postcodes up with a bounded number of workers
+	type upstreamResult struct {
+		loc *dao.PostcodeLocation
+		err error
+	}
+	results := make([]upstreamResult, len(upstream))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for w := 0; w < ps.upstreamWorkers && w < len(upstream); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				loc, err := ps.geocodeUpstream(upstream[i])
+				results[i] = upstreamResult{loc: loc, err: err}
+			}
+		}()
+	}
+	for i := range upstream {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, postcode := range upstream {
+		for _, r := range upstreamRows[postcode.StrVal] {
+			if results[i].err != nil {
+				resp.Rows[r].Error = postcodeErrorMessage(results[i].err)
+				continue
+			}
+			setPostcodeRowLocation(&resp.Rows[r], *results[i].loc, api.PostcodeSourceUpstream)
+		}
+	}
+
+	for _, row := range resp.Rows {
+		if row.Error != "" {
+			resp.Failed++
+		} else {
+			resp.Found++
+		}
+	}
+
+	return resp, nil
+}
+
+// setPostcodeRowLocation sets the coordinate of a postcode in a batch and where it was found
+func setPostcodeRowLocation(row *api.PostcodeBatchRowResponse, loc dao.PostcodeLocation, source api.PostcodeSource) {
+	lat, lon := loc.Latitude, loc.Longitude
+	row.Latitude, row.Longitude, row.Source = &lat, &lon, source
+}
+
+// postcodeErrorMessage returns the message of a failed postcode lookup for a batch row
+func postcodeErrorMessage(err error) string {
+	var restErr *errors.RestError
+	if stderrors.As(err, &restErr) {
+		return restErr.Message
+	}
+	return err.Error()
+}
+
+// JourneyPoint returns the journey point of a postcode, which is its coordinate when the postcode
+// is in the postcode directory so the journey does not depend on the upstream knowing the postcode
+// the postal codes of the countries that are not geocoded are not sent to the upstream either
+func (ps *postcodeService) JourneyPoint(postcode dto.Postcode) (dto.JourneyPoint, error) {
+	if err := checkGeocodedCountry(postcode.Country); err != nil {
+		return dto.JourneyPoint{}, err
+	}
+
+	loc, ok := ps.localLocation(postcode)
+	if !ok {
+		return dto.PostcodeToJourneyPoint(postcode), nil
+	}
+
+	lat := strconv.FormatFloat(loc.Latitude, 'f', -1, 64)
+	lon := strconv.FormatFloat(loc.Longitude, 'f', -1, 64)
+	return dto.LocationToJourneyPoint(dto.NewLocation(lat, lon)), nil
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/leonardchinonso/lokate-go/config"
+	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+)
+
+// searchCountingTransit counts the place searches, the other transit methods are not used
+type searchCountingTransit struct {
+	interfaces.TransitServiceInterface
+	searches int
+}
+
+func (s *searchCountingTransit) SearchPlace(searchStr string, places *[]dao.Place) ([]api.PlaceResponse, error) {
+	s.searches++
+	return nil, nil
+}
+
+func TestPostcodeServiceDoesNotGeocodeOtherCountries(t *testing.T) {
+	transit := &searchCountingTransit{}
+	cfg := map[string]string{config.PostcodeBatchMaxRows: "10", config.PostcodeUpstreamWorkers: "2", config.DefaultCountry: dto.CountryGB}
+	ps, err := NewPostcodeService(&cfg, transit)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		country string
+		code    string
+	}{
+		{"IE", "D02 X285"},
+		{"NL", "1012 AB"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.country, func(t *testing.T) {
+			postcode, err := dto.NewPostalCode(tt.country, tt.code)
+			if err != nil {
+				t.Fatalf("NewPostalCode returned an error: %v", err)
+			}
+
+			if _, err := ps.Geocode(postcode); errors.Status(err) != http.StatusNotImplemented {
+				t.Errorf("Geocode returned %v, want a not implemented error", err)
+			}
+			if _, err := ps.GeocodeBatch(tt.country, []dto.PostcodeBatchRow{{Row: 1, Postcode: tt.code}}); errors.Status(err) != http.StatusNotImplemented {
+				t.Errorf("GeocodeBatch returned %v, want a not implemented error", err)
+			}
+			if _, err := ps.JourneyPoint(postcode); errors.Status(err) != http.StatusNotImplemented {
+				t.Errorf("JourneyPoint returned %v, want a not implemented error", err)
+			}
+		})
+	}
+
+	if transit.searches != 0 {
+		t.Errorf("searched the upstream %d times for postal codes it does not know", transit.searches)
+	}
+}
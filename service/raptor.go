@@ -0,0 +1,625 @@
+package service
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/utils"
+)
+
+const (
+	// walkingSpeed is the walking speed in metres per second used for footpaths
+	walkingSpeed = 1.3
+	// raptorMaxRounds is the most vehicles a journey may use when the client does not limit the changes
+	raptorMaxRounds = 5
+	// raptorInfinity marks a stop that has not been reached
+	raptorInfinity = math.MaxInt32
+	// stopGridSize is the size in degrees of a cell of the grid used to find nearby stops
+	stopGridSize = 0.01
+	// secondsPerDay is the length of a service day
+	secondsPerDay = 24 * 60 * 60
+)
+
+// raptorStop is a stop in the timetable
+type raptorStop struct {
+	feedId    string
+	stopId    string
+	code      string
+	name      string
+	latitude  float64
+	longitude float64
+}
+
+// raptorTrip is a trip of a raptorRoute, the times are seconds after midnight of the service day
+// and the sequences are the stop_sequence of each stop of the trip
+type raptorTrip struct {
+	tripId     string
+	serviceKey string
+	headsign   string
+	arrivals   []int
+	departures []int
+	sequences  []int
+}
+
+// raptorRoute groups the trips of a GTFS route that visit the same sequence of stops
+type raptorRoute struct {
+	mode     api.JourneyMode
+	lineName string
+	stops    []int
+	trips    []raptorTrip
+}
+
+// raptorRouteStop is a position of a stop on a raptorRoute
+type raptorRouteStop struct {
+	route    int
+	position int
+}
+
+// raptorFootpath is a walk from one stop to another
+type raptorFootpath struct {
+	to       int
+	metres   int
+	duration int
+}
+
+// gridCell is a cell of the grid used to find nearby stops
+type gridCell struct {
+	lat int
+	lon int
+}
+
+// raptorTimetable holds the imported feeds in the layout used by the router
+type raptorTimetable struct {
+	location     *time.Location
+	stops        []raptorStop
+	routes       []raptorRoute
+	routesByStop [][]raptorRouteStop
+	footpaths    [][]raptorFootpath
+	grid         map[gridCell][]int
+	calendars    map[string]dao.GTFSCalendar
+	exceptions   map[string]map[string]int
+}
+
+// newRaptorTimetable builds the timetable from the imported feeds
+// footpaths are added between stops that are at most maxWalkMetres apart
+func newRaptorTimetable(feeds []*dao.GTFSFeed, maxWalkMetres int) *raptorTimetable {
+	tt := &raptorTimetable{
+		location:   journeyLocation(feeds),
+		grid:       make(map[gridCell][]int),
+		calendars:  make(map[string]dao.GTFSCalendar),
+		exceptions: make(map[string]map[string]int),
+	}
+
+	stopIndex := make(map[string]int)
+	routeIndex := make(map[string]int)
+	for _, feed := range feeds {
+		key := func(id string) string { return feed.Info.FeedId + ":" + id }
+
+		for _, s := range feed.Stops {
+			stopIndex[key(s.StopId)] = len(tt.stops)
+			tt.stops = append(tt.stops, raptorStop{
+				feedId: feed.Info.FeedId, stopId: s.StopId, code: s.StopCode, name: s.Name,
+				latitude: s.Latitude, longitude: s.Longitude,
+			})
+		}
+
+		for _, c := range feed.Calendars {
+			tt.calendars[key(c.ServiceId)] = c
+		}
+		for _, cd := range feed.CalendarDates {
+			if tt.exceptions[key(cd.ServiceId)] == nil {
+				tt.exceptions[key(cd.ServiceId)] = make(map[string]int)
+			}
+			tt.exceptions[key(cd.ServiceId)][cd.Date] = cd.ExceptionType
+		}
+
+		routes := make(map[string]dao.GTFSRoute, len(feed.Routes))
+		for _, r := range feed.Routes {
+			routes[r.RouteId] = r
+		}
+
+		// group the stop times by trip and order them along the trip
+		stopTimes := make(map[string][]dao.GTFSStopTime)
+		for _, st := range feed.StopTimes {
+			stopTimes[st.TripId] = append(stopTimes[st.TripId], st)
+		}
+
+		for _, trip := range feed.Trips {
+			times := stopTimes[trip.TripId]
+			if len(times) < 2 {
+				continue
+			}
+			sort.Slice(times, func(i, j int) bool { return times[i].StopSequence < times[j].StopSequence })
+
+			rt := raptorTrip{
+				tripId: trip.TripId, serviceKey: key(trip.ServiceId), headsign: trip.Headsign,
+				arrivals: make([]int, 0, len(times)), departures: make([]int, 0, len(times)),
+				sequences: make([]int, 0, len(times)),
+			}
+			stops := make([]string, 0, len(times))
+			stopIds := make([]int, 0, len(times))
+			for _, st := range times {
+				s, ok := stopIndex[key(st.StopId)]
+				if !ok {
+					continue
+				}
+				stops = append(stops, strconv.Itoa(s))
+				stopIds = append(stopIds, s)
+				rt.arrivals = append(rt.arrivals, st.ArrivalTime)
+				rt.departures = append(rt.departures, st.DepartureTime)
+				rt.sequences = append(rt.sequences, st.StopSequence)
+			}
+			if len(stopIds) < 2 {
+				continue
+			}
+
+			// trips of a route that visit the same stops share a raptorRoute
+			patternKey := key(trip.RouteId) + "|" + strings.Join(stops, ",")
+			r, ok := routeIndex[patternKey]
+			if !ok {
+				gtfsRoute := routes[trip.RouteId]
+				r = len(tt.routes)
+				routeIndex[patternKey] = r
+				tt.routes = append(tt.routes, raptorRoute{
+					mode: gtfsRouteMode(gtfsRoute.Type), lineName: gtfsRouteLineName(gtfsRoute), stops: stopIds,
+				})
+			}
+			tt.routes[r].trips = append(tt.routes[r].trips, rt)
+		}
+	}
+
+	tt.routesByStop = make([][]raptorRouteStop, len(tt.stops))
+	for r := range tt.routes {
+		route := &tt.routes[r]
+		sort.Slice(route.trips, func(i, j int) bool { return route.trips[i].departures[0] < route.trips[j].departures[0] })
+		for pos, s := range route.stops {
+			tt.routesByStop[s] = append(tt.routesByStop[s], raptorRouteStop{route: r, position: pos})
+		}
+	}
+
+	for i, s := range tt.stops {
+		cell := stopGridCell(s.latitude, s.longitude)
+		tt.grid[cell] = append(tt.grid[cell], i)
+	}
+
+	tt.footpaths = make([][]raptorFootpath, len(tt.stops))
+	for i, s := range tt.stops {
+		for _, n := range tt.nearbyStops(s.latitude, s.longitude, maxWalkMetres) {
+			if n.to != i {
+				tt.footpaths[i] = append(tt.footpaths[i], n)
+			}
+		}
+	}
+
+	// the transfers in the feeds replace the walking time worked out from the distance
+	for _, feed := range feeds {
+		for _, t := range feed.Transfers {
+			from, okFrom := stopIndex[feed.Info.FeedId+":"+t.FromStopId]
+			to, okTo := stopIndex[feed.Info.FeedId+":"+t.ToStopId]
+			if !okFrom || !okTo || from == to {
+				continue
+			}
+			tt.setFootpath(from, to, t.MinTransferTime)
+		}
+	}
+
+	return tt
+}
+
+// setFootpath sets the duration of the footpath between two stops, adding it when it is missing
+func (tt *raptorTimetable) setFootpath(from, to, duration int) {
+	for i := range tt.footpaths[from] {
+		if tt.footpaths[from][i].to == to {
+			tt.footpaths[from][i].duration = duration
+			return
+		}
+	}
+
+	metres := utils.HaversineMetres(tt.stops[from].latitude, tt.stops[from].longitude, tt.stops[to].latitude, tt.stops[to].longitude)
+	tt.footpaths[from] = append(tt.footpaths[from], raptorFootpath{to: to, metres: int(metres), duration: duration})
+}
+
+// nearbyStops returns the footpaths from a coordinate to the stops at most maxMetres away
+func (tt *raptorTimetable) nearbyStops(lat, lon float64, maxMetres int) []raptorFootpath {
+	// a degree of latitude is about 111km, and a degree of longitude is shorter away from the equator
+	latCells := int(math.Ceil(float64(maxMetres)/111000/stopGridSize)) + 1
+	lonCells := int(math.Ceil(float64(maxMetres)/(111000*math.Max(math.Cos(lat*math.Pi/180), 0.01))/stopGridSize)) + 1
+
+	var paths []raptorFootpath
+	centre := stopGridCell(lat, lon)
+	for dLat := -latCells; dLat <= latCells; dLat++ {
+		for dLon := -lonCells; dLon <= lonCells; dLon++ {
+			for _, s := range tt.grid[gridCell{lat: centre.lat + dLat, lon: centre.lon + dLon}] {
+				metres := utils.HaversineMetres(lat, lon, tt.stops[s].latitude, tt.stops[s].longitude)
+				if metres <= float64(maxMetres) {
+					paths = append(paths, raptorFootpath{to: s, metres: int(metres), duration: walkingSeconds(metres)})
+				}
+			}
+		}
+	}
+	return paths
+}
+
+// serviceRuns determines if a service runs on a date
+func (tt *raptorTimetable) serviceRuns(serviceKey string, date time.Time) bool {
+	day := date.Format("20060102")
+	switch tt.exceptions[serviceKey][day] {
+	case dao.GTFSServiceAdded:
+		return true
+	case dao.GTFSServiceRemoved:
+		return false
+	}
+
+	c, ok := tt.calendars[serviceKey]
+	if !ok {
+		return false
+	}
+	return c.Days[date.Weekday()] && c.StartDate <= day && day <= c.EndDate
+}
+
+// serviceDay returns the time the seconds of a service day count from
+// GTFS defines it as noon minus 12 hours so the times stay right on the days the clocks change
+func (tt *raptorTimetable) serviceDay(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), date.Day(), 12, 0, 0, 0, tt.location).Add(-12 * time.Hour)
+}
+
+// raptorLabelKind is the way a stop was reached
+type raptorLabelKind int
+
+const (
+	accessLabel raptorLabelKind = iota
+	rideLabel
+	walkLabel
+)
+
+// raptorLabel records how a stop was reached in a round so the journey can be rebuilt
+type raptorLabel struct {
+	kind raptorLabelKind
+	// round is the round the label was set in, labels are carried over to the later rounds
+	round int
+	// from is the stop the ride was boarded at or the walk started from
+	from int
+	// route, trip, day and the positions identify the ride
+	route    int
+	trip     int
+	day      int
+	boardPos int
+	alightAt int
+	// start, duration and metres describe the walk
+	start    int
+	duration int
+	metres   int
+}
+
+// raptorQuery holds the options of a journey search
+type raptorQuery struct {
+	fromLat, fromLon float64
+	toLat, toLon     float64
+	date             time.Time
+	departure        int
+	maxWalkMetres    int
+	maxRounds        int
+	allowedModes     map[api.JourneyMode]bool
+}
+
+// search finds the routes with the earliest arrival for every number of vehicles used
+// each round of the search adds one more vehicle, so the routes are pareto optimal in
+// the arrival time and the number of changes
+func (tt *raptorTimetable) search(q raptorQuery) [][]api.RoutePartResponse {
+	nStops := len(tt.stops)
+
+	// the trips of the previous and next service days are searched too, so journeys around midnight are found
+	days := []int{-1, 0, 1}
+	running := make(map[string][]bool)
+	runs := func(serviceKey string, day int) bool {
+		r, ok := running[serviceKey]
+		if !ok {
+			r = make([]bool, len(days))
+			for i, d := range days {
+				r[i] = tt.serviceRuns(serviceKey, q.date.AddDate(0, 0, d))
+			}
+			running[serviceKey] = r
+		}
+		return r[day+1]
+	}
+
+	egress := make(map[int]raptorFootpath)
+	for _, p := range tt.nearbyStops(q.toLat, q.toLon, q.maxWalkMetres) {
+		egress[p.to] = p
+	}
+
+	arrivals := make([][]int, 1, q.maxRounds+1)
+	labels := make([][]raptorLabel, 1, q.maxRounds+1)
+	arrivals[0] = make([]int, nStops)
+	labels[0] = make([]raptorLabel, nStops)
+	best := make([]int, nStops)
+	for i := range best {
+		arrivals[0][i], best[i] = raptorInfinity, raptorInfinity
+	}
+
+	marked := make(map[int]bool)
+	for _, p := range tt.nearbyStops(q.fromLat, q.fromLon, q.maxWalkMetres) {
+		arrivals[0][p.to] = q.departure + p.duration
+		best[p.to] = arrivals[0][p.to]
+		labels[0][p.to] = raptorLabel{kind: accessLabel, duration: p.duration, metres: p.metres}
+		marked[p.to] = true
+	}
+
+	var results [][]api.RoutePartResponse
+	bestTarget := raptorInfinity
+
+	// a walk straight to the destination needs no vehicle
+	metres := utils.HaversineMetres(q.fromLat, q.fromLon, q.toLat, q.toLon)
+	if metres <= float64(q.maxWalkMetres) {
+		walk := walkingSeconds(metres)
+		bestTarget = q.departure + walk
+		results = append(results, []api.RoutePartResponse{tt.walkPart(q, -1, -1, q.departure, walk, int(metres))})
+	}
+
+	for k := 1; k <= q.maxRounds && len(marked) > 0; k++ {
+		arrivals = append(arrivals, append([]int(nil), arrivals[k-1]...))
+		labels = append(labels, append([]raptorLabel(nil), labels[k-1]...))
+
+		// collect the routes through the marked stops and the first marked stop along each of them
+		queue := make(map[int]int)
+		for s := range marked {
+			for _, rs := range tt.routesByStop[s] {
+				if !q.allowedModes[tt.routes[rs.route].mode] {
+					continue
+				}
+				if pos, ok := queue[rs.route]; !ok || rs.position < pos {
+					queue[rs.route] = rs.position
+				}
+			}
+		}
+		marked = make(map[int]bool)
+
+		for r, start := range queue {
+			route := &tt.routes[r]
+			trip, day, boardPos := -1, 0, -1
+			for pos := start; pos < len(route.stops); pos++ {
+				s := route.stops[pos]
+
+				if trip >= 0 {
+					arrival := route.trips[trip].arrivals[pos] + day*secondsPerDay
+					if arrival < best[s] && arrival < bestTarget {
+						arrivals[k][s], best[s] = arrival, arrival
+						labels[k][s] = raptorLabel{
+							kind: rideLabel, round: k, from: route.stops[boardPos],
+							route: r, trip: trip, day: day, boardPos: boardPos, alightAt: pos,
+						}
+						marked[s] = true
+					}
+				}
+
+				// board an earlier trip when the stop was reached in time for one in the previous round
+				ready := arrivals[k-1][s]
+				if ready == raptorInfinity {
+					continue
+				}
+				if trip >= 0 && route.trips[trip].departures[pos]+day*secondsPerDay <= ready {
+					continue
+				}
+				if t, d, ok := earliestTrip(route, pos, ready, days, runs); ok {
+					if trip < 0 || route.trips[t].departures[pos]+d*secondsPerDay < route.trips[trip].departures[pos]+day*secondsPerDay {
+						trip, day, boardPos = t, d, pos
+					}
+				}
+			}
+		}
+
+		// walk from the stops reached by a vehicle in this round to the stops nearby
+		for s := range marked {
+			if labels[k][s].kind != rideLabel || labels[k][s].round != k {
+				continue
+			}
+			for _, fp := range tt.footpaths[s] {
+				// stops reached by a vehicle in this round keep the ride, so the walks from them stay valid
+				if fp.metres > q.maxWalkMetres || (labels[k][fp.to].kind == rideLabel && labels[k][fp.to].round == k) {
+					continue
+				}
+				arrival := arrivals[k][s] + fp.duration
+				if arrival < best[fp.to] && arrival < bestTarget {
+					arrivals[k][fp.to], best[fp.to] = arrival, arrival
+					labels[k][fp.to] = raptorLabel{
+						kind: walkLabel, round: k, from: s, start: arrivals[k][s], duration: fp.duration, metres: fp.metres,
+					}
+					marked[fp.to] = true
+				}
+			}
+		}
+
+		// keep the route of this round when it arrives earlier than the routes with fewer vehicles
+		target, targetStop := raptorInfinity, -1
+		for s, p := range egress {
+			if arrivals[k][s] != raptorInfinity && arrivals[k][s]+p.duration < target {
+				target, targetStop = arrivals[k][s]+p.duration, s
+			}
+		}
+		if target < bestTarget {
+			bestTarget = target
+			results = append(results, tt.journeyParts(q, labels, k, targetStop, arrivals[k][targetStop], egress[targetStop]))
+		}
+	}
+
+	return results
+}
+
+// earliestTrip finds the trip of a route that leaves a stop first at or after a time
+func earliestTrip(route *raptorRoute, pos, after int, days []int, runs func(serviceKey string, day int) bool) (int, int, bool) {
+	bestTrip, bestDay, bestDeparture := -1, 0, raptorInfinity
+	for _, day := range days {
+		offset := day * secondsPerDay
+		for t := range route.trips {
+			departure := route.trips[t].departures[pos] + offset
+			if departure < after || departure >= bestDeparture {
+				continue
+			}
+			if runs(route.trips[t].serviceKey, day) {
+				bestTrip, bestDay, bestDeparture = t, day, departure
+			}
+		}
+	}
+	return bestTrip, bestDay, bestTrip >= 0
+}
+
+// journeyParts rebuilds the parts of the route that reaches a stop in a round, then walks to the destination
+func (tt *raptorTimetable) journeyParts(q raptorQuery, labels [][]raptorLabel, round, stop, arrival int, egress raptorFootpath) []api.RoutePartResponse {
+	// walks to and from a stop at the exact point of the journey are left out
+	var parts []api.RoutePartResponse
+	if egress.metres > 0 {
+		parts = append(parts, tt.walkPart(q, stop, -1, arrival, egress.duration, egress.metres))
+	}
+
+	k, s := round, stop
+	for {
+		label := labels[k][s]
+		switch label.kind {
+		case accessLabel:
+			if label.metres > 0 {
+				parts = append(parts, tt.walkPart(q, -1, s, q.departure, label.duration, label.metres))
+			}
+			reverseParts(parts)
+			return parts
+		case walkLabel:
+			parts = append(parts, tt.walkPart(q, label.from, s, label.start, label.duration, label.metres))
+			k, s = label.round, label.from
+		case rideLabel:
+			parts = append(parts, tt.ridePart(q, label))
+			k, s = label.round-1, label.from
+		}
+	}
+}
+
+// ridePart converts a ride on a trip to a route part
+func (tt *raptorTimetable) ridePart(q raptorQuery, label raptorLabel) api.RoutePartResponse {
+	route := &tt.routes[label.route]
+	trip := &route.trips[label.trip]
+	offset := label.day * secondsPerDay
+
+	coordinates := make([]api.CoordinateResponse, 0, label.alightAt-label.boardPos+1)
+	for pos := label.boardPos; pos <= label.alightAt; pos++ {
+		s := &tt.stops[route.stops[pos]]
+		coordinates = append(coordinates, api.CoordinateResponse{Latitude: s.latitude, Longitude: s.longitude})
+	}
+
+	departure := trip.departures[label.boardPos] + offset
+	arrival := trip.arrivals[label.alightAt] + offset
+
+	from, to := tt.stopResponse(route.stops[label.boardPos]), tt.stopResponse(route.stops[label.alightAt])
+	from.StopSequence, to.StopSequence = &trip.sequences[label.boardPos], &trip.sequences[label.alightAt]
+
+	return api.RoutePartResponse{
+		Mode:            route.mode,
+		LineName:        route.lineName,
+		Destination:     trip.headsign,
+		From:            from,
+		To:              to,
+		DurationMinutes: minutesBetween(departure, arrival),
+		DepartureTime:   tt.serviceDay(q.date).Add(time.Duration(departure) * time.Second),
+		ArrivalTime:     tt.serviceDay(q.date).Add(time.Duration(arrival) * time.Second),
+		Coordinates:     coordinates,
+		TripId:          trip.tripId,
+		// the trips of the previous service day run past midnight
+		ServiceDate: q.date.AddDate(0, 0, label.day).Format("20060102"),
+	}
+}
+
+// walkPart converts a walk to a route part, a stop of -1 is the start or the end of the journey
+func (tt *raptorTimetable) walkPart(q raptorQuery, from, to, departure, duration, metres int) api.RoutePartResponse {
+	fromStop := api.StopResponse{Name: "Start", Latitude: &q.fromLat, Longitude: &q.fromLon}
+	if from >= 0 {
+		fromStop = tt.stopResponse(from)
+	}
+	toStop := api.StopResponse{Name: "Destination", Latitude: &q.toLat, Longitude: &q.toLon}
+	if to >= 0 {
+		toStop = tt.stopResponse(to)
+	}
+
+	return api.RoutePartResponse{
+		Mode:            api.ModeWalk,
+		From:            fromStop,
+		To:              toStop,
+		DurationMinutes: minutesBetween(departure, departure+duration),
+		DepartureTime:   tt.serviceDay(q.date).Add(time.Duration(departure) * time.Second),
+		ArrivalTime:     tt.serviceDay(q.date).Add(time.Duration(departure+duration) * time.Second),
+		Coordinates: []api.CoordinateResponse{
+			{Latitude: *fromStop.Latitude, Longitude: *fromStop.Longitude},
+			{Latitude: *toStop.Latitude, Longitude: *toStop.Longitude},
+		},
+	}
+}
+
+// stopResponse converts a stop to the api response type
+func (tt *raptorTimetable) stopResponse(stop int) api.StopResponse {
+	s := &tt.stops[stop]
+	lat, lon := s.latitude, s.longitude
+	return api.StopResponse{Name: s.name, Latitude: &lat, Longitude: &lon, StopId: s.stopId}
+}
+
+// reverseParts reverses the parts of a route, which are rebuilt from the destination backwards
+func reverseParts(parts []api.RoutePartResponse) {
+	for i, j := 0, len(parts)-1; i < j; i, j = i+1, j-1 {
+		parts[i], parts[j] = parts[j], parts[i]
+	}
+}
+
+// minutesBetween returns the whole minutes between two times in seconds, rounded up
+func minutesBetween(from, to int) int {
+	return (to - from + 59) / 60
+}
+
+// walkingSeconds returns the time it takes to walk a distance
+func walkingSeconds(metres float64) int {
+	return int(math.Ceil(metres / walkingSpeed))
+}
+
+// stopGridCell returns the grid cell a coordinate is in
+func stopGridCell(lat, lon float64) gridCell {
+	return gridCell{lat: int(math.Floor(lat / stopGridSize)), lon: int(math.Floor(lon / stopGridSize))}
+}
+
+// journeyLocation returns the timezone of the feeds, the first feed decides when they differ
+func journeyLocation(feeds []*dao.GTFSFeed) *time.Location {
+	for _, feed := range feeds {
+		if loc, err := time.LoadLocation(feed.Info.Timezone); err == nil {
+			return loc
+		}
+	}
+
+	loc, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// gtfsRouteMode converts a GTFS route type, including the extended route types, to a JourneyMode
+func gtfsRouteMode(routeType int) api.JourneyMode {
+	switch {
+	case routeType == 0 || routeType == 5 || (routeType >= 900 && routeType < 1000):
+		return api.ModeTram
+	case routeType == 1 || (routeType >= 400 && routeType < 500):
+		return api.ModeTube
+	case routeType == 2 || routeType == 12 || (routeType >= 100 && routeType < 200):
+		return api.ModeTrain
+	case routeType == 3 || routeType == 11 || (routeType >= 200 && routeType < 300) || (routeType >= 700 && routeType < 800):
+		return api.ModeBus
+	case routeType == 4 || (routeType >= 1000 && routeType < 1100) || routeType == 1200:
+		return api.ModeBoat
+	}
+	return api.ModeOther
+}
+
+// gtfsRouteLineName returns the name riders know a route by
+func gtfsRouteLineName(r dao.GTFSRoute) string {
+	if r.ShortName != "" {
+		return r.ShortName
+	}
+	return r.LongName
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+)
+
+// fixtureStops are the coordinates of the stops of the fixture feed, as latitude and longitude
+var fixtureStops = map[string][2]float64{
+	"A": {51.5000, -0.2000},
+	"M": {51.5000, -0.1750},
+	"B": {51.5000, -0.1500},
+	"D": {51.5020, -0.1000},
+	"F": {51.5300, -0.1000},
+}
+
+// fixturePoint returns the journey point at a stop of the fixture feed
+func fixturePoint(stop string) dto.JourneyPoint {
+	c := fixtureStops[stop]
+	return dto.JourneyPoint{Kind: dto.LonLatPoint, Value: fmt.Sprintf("%f,%f", c[1], c[0])}
+}
+
+// departAt returns the journey options to depart at a local time in the fixture feed
+func departAt(year int, month time.Month, day, hour, minute int) dto.JourneyOptions {
+	return dto.JourneyOptions{TimeType: dto.DepartAt, Time: time.Date(year, month, day, hour, minute, 0, 0, time.UTC)}
+}
+
+// fastestRoute plans a journey and returns the route that arrives first, which the router finds last
+func fastestRoute(t *testing.T, gs *gtfsService, from, to string, opts dto.JourneyOptions) api.RouteResponse {
+	t.Helper()
+	journey, err := gs.PublicJourney(fixturePoint(from), fixturePoint(to), opts)
+	if err != nil {
+		t.Fatalf("PublicJourney from %s to %s returned an error: %v", from, to, err)
+	}
+	if len(journey.Routes) == 0 {
+		t.Fatalf("PublicJourney from %s to %s found no routes", from, to)
+	}
+	return journey.Routes[len(journey.Routes)-1]
+}
+
+// partSummary describes the parts of a route as their modes and their stops
+func partSummary(route api.RouteResponse) string {
+	summary := ""
+	for i, p := range route.Parts {
+		if i > 0 {
+			summary += " "
+		}
+		summary += fmt.Sprintf("%s:%s-%s", p.Mode, p.From.StopId, p.To.StopId)
+	}
+	return summary
+}
+
+// localTime formats a time in the timezone of the fixture feed
+func localTime(t *testing.T, tm time.Time) string {
+	t.Helper()
+	london, err := time.LoadLocation("Europe/London")
+	if err != nil {
+		t.Skip("the timezone database is not available")
+	}
+	return tm.In(london).Format("2006-01-02 15:04")
+}
+
+func TestRaptorFootpathBetweenStops(t *testing.T) {
+	gs := newFixtureGTFSService(t)
+
+	// Monday: the bus to B, a walk to C nearby and the train to D
+	route := fastestRoute(t, gs, "A", "D", departAt(2023, time.January, 2, 7, 55))
+
+	if got, want := partSummary(route), "bus:A-B walk:B-C train:C-D"; got != want {
+		t.Errorf("got parts %s, want %s", got, want)
+	}
+	if got := localTime(t, route.ArrivalTime); got != "2023-01-02 08:50" {
+		t.Errorf("got arrival %s, want 2023-01-02 08:50", got)
+	}
+	if route.Interchanges != 1 {
+		t.Errorf("got %d interchanges, want 1", route.Interchanges)
+	}
+}
+
+func TestRaptorTransferTimeReplacesWalkingTime(t *testing.T) {
+	gs := newFixtureGTFSService(t)
+
+	// B to E is a four minute walk, but the feed asks for ten minutes, so the 08:27 tram is missed
+	route := fastestRoute(t, gs, "A", "F", departAt(2023, time.January, 2, 7, 55))
+
+	if got, want := partSummary(route), "bus:A-B walk:B-E tram:E-F"; got != want {
+		t.Fatalf("got parts %s, want %s", got, want)
+	}
+	if got := route.Parts[1].DurationMinutes; got != 10 {
+		t.Errorf("got a %d minute transfer, want 10", got)
+	}
+	if got := localTime(t, route.ArrivalTime); got != "2023-01-02 09:00" {
+		t.Errorf("got arrival %s, want 2023-01-02 09:00", got)
+	}
+}
+
+func TestRaptorServiceDays(t *testing.T) {
+	gs := newFixtureGTFSService(t)
+
+	tests := []struct {
+		name    string
+		from    string
+		opts    dto.JourneyOptions
+		arrival string
+	}{
+		{
+			name:    "the night bus arrives after midnight",
+			from:    "A",
+			opts:    departAt(2023, time.January, 2, 23, 45),
+			arrival: "2023-01-03 00:20",
+		},
+		{
+			name:    "the night bus of the day before is boarded after midnight",
+			from:    "M",
+			opts:    departAt(2023, time.January, 3, 0, 5),
+			arrival: "2023-01-03 00:20",
+		},
+		{
+			name:    "the first bus of the next day",
+			from:    "A",
+			opts:    departAt(2023, time.January, 2, 23, 55),
+			arrival: "2023-01-03 08:20",
+		},
+		{
+			name:    "a service removed on a date does not run",
+			from:    "A",
+			opts:    departAt(2023, time.January, 4, 7, 55),
+			arrival: "2023-01-05 08:20",
+		},
+		{
+			name:    "a service added on a date runs",
+			from:    "A",
+			opts:    departAt(2023, time.January, 7, 7, 55),
+			arrival: "2023-01-07 10:20",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			route := fastestRoute(t, gs, tt.from, "B", tt.opts)
+			if got := localTime(t, route.ArrivalTime); got != tt.arrival {
+				t.Errorf("got arrival %s, want %s", got, tt.arrival)
+			}
+		})
+	}
+}
+
+func TestRaptorNoRoute(t *testing.T) {
+	gs := newFixtureGTFSService(t)
+
+	tests := []struct {
+		name string
+		from dto.JourneyPoint
+		to   dto.JourneyPoint
+		opts dto.JourneyOptions
+	}{
+		{
+			// Saturday: the night bus of Friday has left M and it does not run on Saturday or Sunday
+			name: "no trip runs on the service days",
+			from: fixturePoint("M"),
+			to:   fixturePoint("B"),
+			opts: departAt(2023, time.January, 7, 0, 15),
+		},
+		{
+			name: "no stop near the start",
+			from: dto.JourneyPoint{Kind: dto.LonLatPoint, Value: "-1.5000,52.0000"},
+			to:   fixturePoint("B"),
+			opts: departAt(2023, time.January, 2, 7, 55),
+		},
+		{
+			name: "the only mode is excluded",
+			from: fixturePoint("A"),
+			to:   fixturePoint("B"),
+			opts: dto.JourneyOptions{
+				TimeType: dto.DepartAt, Time: time.Date(2023, time.January, 2, 7, 55, 0, 0, time.UTC),
+				NotModes: []api.JourneyMode{api.ModeBus},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := gs.PublicJourney(tt.from, tt.to, tt.opts)
+			if errors.Status(err) != http.StatusBadRequest {
+				t.Errorf("got error %v, want a bad request", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,435 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/leonardchinonso/lokate-go/config"
+	"github.com/leonardchinonso/lokate-go/datasource"
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+)
+
+// realtimeStaleAfter is the number of poll intervals after which a feed that keeps failing is ignored
+const realtimeStaleAfter = 5
+
+// realtimeFeed is the last feed read from a source
+type realtimeFeed struct {
+	feed      *dao.GTFSRTFeed
+	fetchedAt time.Time
+}
+
+// realtimeState indexes the entities of all the fresh feeds by the ids they are looked up with
+type realtimeState struct {
+	tripUpdates map[string]*realtimeTrip
+	vehicles    map[string]*dao.GTFSRTVehiclePosition
+	alerts      []dao.GTFSRTAlert
+}
+
+// realtimeTrip holds the updates of a trip, a trip that runs past midnight can have an update for
+// each of the days it starts on
+type realtimeTrip struct {
+	updates []*dao.GTFSRTTripUpdate
+}
+
+// realtimeService holds the structure for the GTFS-Realtime ingester
+type realtimeService struct {
+	sources  []string
+	interval time.Duration
+
+	mu    sync.RWMutex
+	feeds map[string]realtimeFeed
+	state *realtimeState
+}
+
+// NewRealtimeService returns an interface for the GTFS-Realtime ingester methods
+func NewRealtimeService(cfg *map[string]string) (interfaces.RealtimeServiceInterface, error) {
+	seconds, err := strconv.Atoi((*cfg)[config.GTFSRealtimePollSeconds])
+	if err != nil || seconds <= 0 {
+		return nil, fmt.Errorf("invalid %s: %q", config.GTFSRealtimePollSeconds, (*cfg)[config.GTFSRealtimePollSeconds])
+	}
+
+	return &realtimeService{
+		sources:  GTFSRealtimeSources(cfg),
+		interval: time.Duration(seconds) * time.Second,
+		feeds:    make(map[string]realtimeFeed),
+		state:    newRealtimeState(nil),
+	}, nil
+}
+
+// GTFSRealtimeSources returns the configured GTFS-Realtime feed urls and files
+func GTFSRealtimeSources(cfg *map[string]string) []string {
+	var sources []string
+	for _, source := range strings.Split((*cfg)[config.GTFSRealtimeUrls], ",") {
+		if source = strings.TrimSpace(source); source != "" {
+			sources = append(sources, source)
+		}
+	}
+	return sources
+}
+
+// Start polls the feeds in the background until the context is done
+func (rs *realtimeService) Start(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(rs.interval)
+		defer ticker.Stop()
+
+		for {
+			if err := rs.Poll(); err != nil {
+				log.Printf("Failed to poll GTFS-Realtime feeds. Error: %v\n", err)
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// Poll reads every feed once and replaces the realtime state
+// a source that fails keeps its last feed until it goes stale
+func (rs *realtimeService) Poll() error {
+	var failed []string
+	for _, source := range rs.sources {
+		feed, err := datasource.FetchGTFSRealtime(source)
+		if err != nil {
+			log.Printf("Failed to fetch GTFS-Realtime feed. Error: %v\n", err)
+			failed = append(failed, source)
+			continue
+		}
+
+		rs.mu.Lock()
+		rs.feeds[source] = realtimeFeed{feed: feed, fetchedAt: time.Now()}
+		rs.mu.Unlock()
+	}
+
+	rs.mu.Lock()
+	var fresh []*dao.GTFSRTFeed
+	for _, f := range rs.feeds {
+		if time.Since(f.fetchedAt) <= realtimeStaleAfter*rs.interval {
+			fresh = append(fresh, f.feed)
+		}
+	}
+	rs.state = newRealtimeState(fresh)
+	rs.mu.Unlock()
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to fetch %d of %d feeds: %s", len(failed), len(rs.sources), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// newRealtimeState indexes the entities of the feeds
+func newRealtimeState(feeds []*dao.GTFSRTFeed) *realtimeState {
+	state := &realtimeState{
+		tripUpdates: make(map[string]*realtimeTrip),
+		vehicles:    make(map[string]*dao.GTFSRTVehiclePosition),
+	}
+
+	for _, feed := range feeds {
+		for i := range feed.TripUpdates {
+			tu := &feed.TripUpdates[i]
+			trip, ok := state.tripUpdates[tu.TripId]
+			if !ok {
+				trip = &realtimeTrip{}
+				state.tripUpdates[tu.TripId] = trip
+			}
+			trip.updates = append(trip.updates, tu)
+		}
+		for i := range feed.VehiclePositions {
+			if tripId := feed.VehiclePositions[i].TripId; tripId != "" {
+				state.vehicles[tripId] = &feed.VehiclePositions[i]
+			}
+		}
+		state.alerts = append(state.alerts, feed.Alerts...)
+	}
+
+	return state
+}
+
+// currentState returns the realtime state of the last poll
+func (rs *realtimeService) currentState() *realtimeState {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.state
+}
+
+// AnnotateJourney sets the expected times, cancellations, vehicle positions and alerts on the
+// parts of the routes whose trips are in the realtime feeds
+func (rs *realtimeService) AnnotateJourney(journey *api.JourneyResponse) {
+	state := rs.currentState()
+	now := time.Now()
+
+	for r := range journey.Routes {
+		route := &journey.Routes[r]
+
+		var lastRide *api.RoutePartResponse
+		for p := range route.Parts {
+			part := &route.Parts[p]
+			if part.Mode == api.ModeWalk {
+				continue
+			}
+			lastRide = part
+
+			part.Alerts = state.alertsFor(now, part.TripId, part.From.StopId, part.To.StopId)
+
+			tu := state.tripUpdate(part.TripId, part.ServiceDate)
+			if tu == nil {
+				continue
+			}
+
+			part.Cancelled = tu.Cancelled
+			if vp := state.vehicle(part.TripId); vp != nil {
+				part.Vehicle = api.NewVehicleResponse(vp)
+			}
+
+			departure, skippedFrom := expectedTime(tu, part.From.StopId, part.From.StopSequence, part.DepartureTime, true)
+			arrival, skippedTo := expectedTime(tu, part.To.StopId, part.To.StopSequence, part.ArrivalTime, false)
+			part.ExpectedDepartureTime, part.ExpectedArrivalTime = departure, arrival
+
+			// the trip does not call at a stop it is skipping, so the part cannot be travelled
+			if skippedFrom || skippedTo {
+				part.Cancelled = true
+			}
+
+			if part.Cancelled {
+				route.Cancelled = true
+			}
+		}
+
+		// the parts after the last ride are walks, so they are shifted by the delay of the last ride
+		if lastRide != nil && lastRide.ExpectedArrivalTime != nil {
+			expected := route.ArrivalTime.Add(lastRide.ExpectedArrivalTime.Sub(lastRide.ArrivalTime))
+			route.ExpectedArrivalTime = &expected
+		}
+	}
+}
+
+// AnnotateDepartures sets the expected times and cancellations on the departures whose trips are in the
+// realtime feeds, departures that already have an expected time from the provider are left alone
+func (rs *realtimeService) AnnotateDepartures(board *api.DepartureBoardResponse) {
+	state := rs.currentState()
+
+	for i := range board.Departures {
+		d := &board.Departures[i]
+		if d.ExpectedTime != nil || d.Cancelled {
+			continue
+		}
+
+		tu := state.tripUpdate(d.TripId, d.ServiceDate)
+		if tu == nil {
+			continue
+		}
+
+		expected, skipped := expectedTime(tu, board.StopCode, d.StopSequence, d.ScheduledTime, true)
+		d.Cancelled = tu.Cancelled || skipped
+		if !d.Cancelled {
+			d.ExpectedTime = expected
+		}
+	}
+	api.SortDepartures(board.Departures)
+}
+
+// tripUpdate returns the update of a trip on the day it runs, as YYYYMMDD. The providers may prefix
+// the ids with their feed id, e.g. "1:T100", so the id without the prefix is tried too. The feeds
+// may leave out the start date of a trip and the providers may not know the day it runs, the update
+// of another day is never used but an update without a date is, as is the only update of a trip
+// when the day is not known
+func (s *realtimeState) tripUpdate(tripId, serviceDate string) *dao.GTFSRTTripUpdate {
+	trip := lookupRealtimeId(s.tripUpdates, tripId)
+	if trip == nil {
+		return nil
+	}
+
+	var undated *dao.GTFSRTTripUpdate
+	for _, tu := range trip.updates {
+		switch {
+		case serviceDate != "" && tu.StartDate == serviceDate:
+			return tu
+		case tu.StartDate == "":
+			undated = tu
+		}
+	}
+	if undated == nil && serviceDate == "" && len(trip.updates) == 1 {
+		return trip.updates[0]
+	}
+	return undated
+}
+
+// vehicle returns the position of the vehicle running a trip
+func (s *realtimeState) vehicle(tripId string) *dao.GTFSRTVehiclePosition {
+	return lookupRealtimeId(s.vehicles, tripId)
+}
+
+// alertsFor returns the active alerts that affect a trip or one of the stops
+func (s *realtimeState) alertsFor(now time.Time, tripId string, stopIds ...string) []api.AlertResponse {
+	var alerts []api.AlertResponse
+	for i := range s.alerts {
+		a := &s.alerts[i]
+		if (a.Start != nil && now.Before(*a.Start)) || (a.End != nil && now.After(*a.End)) {
+			continue
+		}
+
+		if matchesRealtimeId(a.TripIds, tripId) || matchesRealtimeId(a.StopIds, stopIds...) {
+			alerts = append(alerts, api.NewAlertResponse(a))
+		}
+	}
+	return alerts
+}
+
+// lookupRealtimeId looks up an id in a realtime index, with and without the feed id prefix
+func lookupRealtimeId[T any](index map[string]*T, id string) *T {
+	if id == "" {
+		return nil
+	}
+	if v, ok := index[id]; ok {
+		return v
+	}
+	if _, unprefixed, found := strings.Cut(id, ":"); found {
+		return index[unprefixed]
+	}
+	return nil
+}
+
+// matchesRealtimeId determines if any of the ids is in the list, with or without the feed id prefix
+func matchesRealtimeId(list []string, ids ...string) bool {
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+		_, unprefixed, _ := strings.Cut(id, ":")
+		for _, l := range list {
+			if l == id || l == unprefixed {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// expectedTime returns the expected time of a trip at a stop and whether the stop is skipped. The update for the
+// stop is used when there is one. The feeds only list the stops where the delay changes, so otherwise the delay
+// of the nearest update at or before the stop in the trip is used, and there is no prediction for the stops
+// before the first update. The delay of the trip is only used when the feed has no updates for its stops or
+// the position of the stop on the trip is not known
+func expectedTime(tu *dao.GTFSRTTripUpdate, stopId string, stopSequence *int, scheduled time.Time, departure bool) (*time.Time, bool) {
+	var delay *int
+	if update := stopTimeUpdate(tu, stopId, stopSequence); update != nil {
+		if update.Skipped {
+			return nil, true
+		}
+
+		at, d := update.ArrivalTime, update.ArrivalDelay
+		if departure {
+			at, d = update.DepartureTime, update.DepartureDelay
+		}
+		if at == nil && d == nil {
+			// the feeds may only give the arrival or the departure of a stop
+			at, d = update.DepartureTime, update.DepartureDelay
+			if departure {
+				at, d = update.ArrivalTime, update.ArrivalDelay
+			}
+		}
+
+		if at != nil {
+			expected := at.In(scheduled.Location())
+			return &expected, false
+		}
+		delay = d
+	} else if stopSequence != nil && len(tu.StopTimeUpdates) > 0 {
+		delay = precedingDelay(tu, *stopSequence)
+	} else {
+		delay = tu.Delay
+	}
+
+	if delay == nil {
+		return nil, false
+	}
+
+	expected := scheduled.Add(time.Duration(*delay) * time.Second)
+	return &expected, false
+}
+
+// precedingDelay returns the delay a trip leaves the nearest stop before a position on the trip with, from the
+// update of that stop. A skipped stop does not pass on a delay, so the updates before it are looked at
+func precedingDelay(tu *dao.GTFSRTTripUpdate, stopSequence int) *int {
+	var nearest *dao.GTFSRTStopTimeUpdate
+	for i := range tu.StopTimeUpdates {
+		u := &tu.StopTimeUpdates[i]
+		if u.StopSequence == nil || *u.StopSequence > stopSequence || u.Skipped {
+			continue
+		}
+		if nearest == nil || *u.StopSequence > *nearest.StopSequence {
+			nearest = u
+		}
+	}
+	if nearest == nil {
+		return nil
+	}
+
+	if nearest.DepartureDelay != nil {
+		return nearest.DepartureDelay
+	}
+	return nearest.ArrivalDelay
+}
+
+// stopTimeUpdate returns the update of a trip at a stop, or nil when the feed has none for the stop. The stop
+// is found by its position on the trip when both are known, since a trip can call at a stop twice
+func stopTimeUpdate(tu *dao.GTFSRTTripUpdate, stopId string, stopSequence *int) *dao.GTFSRTStopTimeUpdate {
+	_, unprefixed, _ := strings.Cut(stopId, ":")
+	for i := range tu.StopTimeUpdates {
+		u := &tu.StopTimeUpdates[i]
+		if stopSequence != nil && u.StopSequence != nil {
+			if *u.StopSequence == *stopSequence {
+				return u
+			}
+			continue
+		}
+		if stopId != "" && (u.StopId == stopId || u.StopId == unprefixed) {
+			return u
+		}
+	}
+	return nil
+}
+
+// realtimeTransit adds the realtime state to the journeys and departures of a transit provider
+type realtimeTransit struct {
+	interfaces.TransitServiceInterface
+	realtime interfaces.RealtimeServiceInterface
+}
+
+// NewRealtimeTransitService returns a transit service that annotates the results of the provider with the realtime feeds
+func NewRealtimeTransitService(transit interfaces.TransitServiceInterface, realtime interfaces.RealtimeServiceInterface) interfaces.TransitServiceInterface {
+	return &realtimeTransit{TransitServiceInterface: transit, realtime: realtime}
+}
+
+// PublicJourney gets a journey from the provider and annotates it with the realtime state
+func (rt *realtimeTransit) PublicJourney(from dto.JourneyPoint, to dto.JourneyPoint, opts dto.JourneyOptions) (*api.JourneyResponse, error) {
+	journey, err := rt.TransitServiceInterface.PublicJourney(from, to, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	rt.realtime.AnnotateJourney(journey)
+	return journey, nil
+}
+
+// Departures gets the departures from the provider and annotates them with the realtime state
+func (rt *realtimeTransit) Departures(stop dto.DepartureStop) (*api.DepartureBoardResponse, error) {
+	board, err := rt.TransitServiceInterface.Departures(stop)
+	if err != nil {
+		return nil, err
+	}
+
+	rt.realtime.AnnotateDepartures(board)
+	return board, nil
+}
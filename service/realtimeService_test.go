@@ -0,0 +1,217 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+)
+
+func intPtr(v int) *int {
+	return &v
+}
+
+// stopUpdate is a stop time update with a departure delay at a position on the trip
+func stopUpdate(sequence int, stopId string, delay int) dao.GTFSRTStopTimeUpdate {
+	return dao.GTFSRTStopTimeUpdate{StopSequence: intPtr(sequence), StopId: stopId, DepartureDelay: intPtr(delay)}
+}
+
+func TestExpectedTime(t *testing.T) {
+	scheduled := time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)
+	at := time.Date(2023, 3, 1, 10, 7, 0, 0, time.UTC)
+	skipped := dao.GTFSRTStopTimeUpdate{StopSequence: intPtr(6), StopId: "F", Skipped: true, DepartureDelay: intPtr(900)}
+	onlyTime := dao.GTFSRTStopTimeUpdate{StopSequence: intPtr(8), StopId: "H", ArrivalTime: &at}
+
+	tu := &dao.GTFSRTTripUpdate{
+		TripId: "T100",
+		Delay:  intPtr(30),
+		StopTimeUpdates: []dao.GTFSRTStopTimeUpdate{
+			stopUpdate(3, "C", 120), stopUpdate(5, "E", 300), skipped, onlyTime,
+		},
+	}
+
+	tests := []struct {
+		name        string
+		tu          *dao.GTFSRTTripUpdate
+		stopId      string
+		sequence    *int
+		wantDelay   *int
+		wantSkipped bool
+	}{
+		{"update for the stop", tu, "C", intPtr(3), intPtr(120), false},
+		{"update for the stop found by its id", tu, "E", nil, intPtr(300), false},
+		{"update for the stop with a feed prefix", tu, "1:E", nil, intPtr(300), false},
+		{"nearest earlier update", tu, "D", intPtr(4), intPtr(120), false},
+		{"the later update is not used", tu, "B", intPtr(2), nil, false},
+		{"skipped stop", tu, "F", intPtr(6), nil, true},
+		{"a skipped stop does not pass on its delay", tu, "G", intPtr(7), intPtr(300), false},
+		{"absolute time", tu, "H", intPtr(8), intPtr(7 * 60), false},
+		{"an earlier update without a delay", tu, "I", intPtr(9), nil, false},
+		{"trip delay when the position is not known", tu, "Z", nil, intPtr(30), false},
+		{"trip delay without stop updates", &dao.GTFSRTTripUpdate{Delay: intPtr(45)}, "C", intPtr(3), intPtr(45), false},
+		{"no delay at all", &dao.GTFSRTTripUpdate{}, "C", intPtr(3), nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expected, skipped := expectedTime(tt.tu, tt.stopId, tt.sequence, scheduled, true)
+			if skipped != tt.wantSkipped {
+				t.Fatalf("got skipped %v, want %v", skipped, tt.wantSkipped)
+			}
+			if tt.wantDelay == nil {
+				if expected != nil {
+					t.Errorf("got expected time %v, want no prediction", expected)
+				}
+				return
+			}
+			if want := scheduled.Add(time.Duration(*tt.wantDelay) * time.Second); expected == nil || !expected.Equal(want) {
+				t.Errorf("got expected time %v, want %v", expected, want)
+			}
+		})
+	}
+}
+
+func TestRealtimeTripUpdateByServiceDate(t *testing.T) {
+	today := dao.GTFSRTTripUpdate{TripId: "T100", StartDate: "20230301", Delay: intPtr(60)}
+	yesterday := dao.GTFSRTTripUpdate{TripId: "T100", StartDate: "20230228", Delay: intPtr(120)}
+	undated := dao.GTFSRTTripUpdate{TripId: "T200", Delay: intPtr(180)}
+	only := dao.GTFSRTTripUpdate{TripId: "T300", StartDate: "20230301", Delay: intPtr(240)}
+	state := newRealtimeState([]*dao.GTFSRTFeed{{TripUpdates: []dao.GTFSRTTripUpdate{today, yesterday, undated, only}}})
+
+	tests := []struct {
+		name        string
+		tripId      string
+		serviceDate string
+		wantDelay   int
+	}{
+		{"the update of the day", "T100", "20230301", 60},
+		{"the update of the previous day", "1:T100", "20230228", 120},
+		{"no update for the day", "T100", "20230302", 0},
+		{"several updates and no day", "T100", "", 0},
+		{"update without a date", "T200", "20230301", 180},
+		{"the only update and no day", "T300", "", 240},
+		{"the only update of another day", "T300", "20230302", 0},
+		{"unknown trip", "T400", "20230301", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tu := state.tripUpdate(tt.tripId, tt.serviceDate)
+			if tt.wantDelay == 0 {
+				if tu != nil {
+					t.Errorf("got the update of %s on %s, want none", tu.TripId, tu.StartDate)
+				}
+				return
+			}
+			if tu == nil || *tu.Delay != tt.wantDelay {
+				t.Errorf("got update %+v, want the one with a delay of %d", tu, tt.wantDelay)
+			}
+		})
+	}
+}
+
+func TestAnnotateJourney(t *testing.T) {
+	start := time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)
+	minutes := func(m int) time.Time { return start.Add(time.Duration(m) * time.Minute) }
+	vehicleAt := 51.5
+
+	rs := &realtimeService{state: newRealtimeState([]*dao.GTFSRTFeed{{
+		TripUpdates: []dao.GTFSRTTripUpdate{
+			{TripId: "T100", StartDate: "20230301", StopTimeUpdates: []dao.GTFSRTStopTimeUpdate{stopUpdate(2, "B", 180)}},
+			{TripId: "T100", StartDate: "20230228", StopTimeUpdates: []dao.GTFSRTStopTimeUpdate{stopUpdate(1, "A", 900)}},
+			{TripId: "T200", StartDate: "20230301", StopTimeUpdates: []dao.GTFSRTStopTimeUpdate{
+				{StopSequence: intPtr(4), StopId: "D", Skipped: true},
+			}},
+		},
+		VehiclePositions: []dao.GTFSRTVehiclePosition{{TripId: "T100", VehicleId: "V1", Latitude: vehicleAt}},
+		Alerts:           []dao.GTFSRTAlert{{Id: "works", Header: "Engineering works", StopIds: []string{"C"}}},
+	}})}
+
+	stop := func(id string, sequence int) api.StopResponse {
+		return api.StopResponse{Name: id, StopId: id, StopSequence: intPtr(sequence)}
+	}
+	journey := &api.JourneyResponse{Routes: []api.RouteResponse{
+		{
+			ArrivalTime: minutes(40),
+			Parts: []api.RoutePartResponse{
+				{Mode: api.ModeBus, TripId: "T100", ServiceDate: "20230301", From: stop("A", 1), To: stop("C", 3),
+					DepartureTime: minutes(0), ArrivalTime: minutes(30)},
+				{Mode: api.ModeWalk, DepartureTime: minutes(30), ArrivalTime: minutes(40)},
+			},
+		},
+		{
+			ArrivalTime: minutes(50),
+			Parts: []api.RoutePartResponse{
+				{Mode: api.ModeBus, TripId: "T200", ServiceDate: "20230301", From: stop("C", 3), To: stop("D", 4),
+					DepartureTime: minutes(20), ArrivalTime: minutes(50)},
+			},
+		},
+	}}
+
+	rs.AnnotateJourney(journey)
+
+	ride := journey.Routes[0].Parts[0]
+	// the delay of the previous day's run is not used and the boarding stop is before the first update
+	if ride.ExpectedDepartureTime != nil {
+		t.Errorf("got expected departure %v, want no prediction", ride.ExpectedDepartureTime)
+	}
+	if ride.ExpectedArrivalTime == nil || !ride.ExpectedArrivalTime.Equal(minutes(33)) {
+		t.Errorf("got expected arrival %v, want %v", ride.ExpectedArrivalTime, minutes(33))
+	}
+	if ride.Vehicle == nil || len(ride.Alerts) != 1 || ride.Cancelled {
+		t.Errorf("got vehicle %+v, alerts %+v and cancelled %v", ride.Vehicle, ride.Alerts, ride.Cancelled)
+	}
+	// the walk after the last ride is shifted by its delay
+	if got := journey.Routes[0].ExpectedArrivalTime; got == nil || !got.Equal(minutes(43)) {
+		t.Errorf("got expected route arrival %v, want %v", got, minutes(43))
+	}
+
+	if !journey.Routes[1].Parts[0].Cancelled || !journey.Routes[1].Cancelled {
+		t.Errorf("the route alighting at a skipped stop was not cancelled")
+	}
+}
+
+func TestAnnotateDepartures(t *testing.T) {
+	scheduled := time.Date(2023, 3, 1, 10, 0, 0, 0, time.UTC)
+	provided := scheduled.Add(time.Minute)
+
+	rs := &realtimeService{state: newRealtimeState([]*dao.GTFSRTFeed{{
+		TripUpdates: []dao.GTFSRTTripUpdate{
+			{TripId: "T100", StopTimeUpdates: []dao.GTFSRTStopTimeUpdate{stopUpdate(2, "A", 600)}},
+			{TripId: "T200", StopTimeUpdates: []dao.GTFSRTStopTimeUpdate{{StopSequence: intPtr(5), StopId: "A", Skipped: true}}},
+			{TripId: "T300", StopTimeUpdates: []dao.GTFSRTStopTimeUpdate{stopUpdate(2, "A", 600)}},
+		},
+	}})}
+
+	board := &api.DepartureBoardResponse{StopCode: "A", Departures: []api.DepartureResponse{
+		{Line: "1", TripId: "T100", ScheduledTime: scheduled, StopSequence: intPtr(2)},
+		{Line: "2", TripId: "T200", ScheduledTime: scheduled.Add(5 * time.Minute), StopSequence: intPtr(5)},
+		{Line: "3", TripId: "T300", ScheduledTime: scheduled.Add(2 * time.Minute), ExpectedTime: &provided},
+		{Line: "4", ScheduledTime: scheduled.Add(3 * time.Minute)},
+	}}
+
+	rs.AnnotateDepartures(board)
+
+	lines := ""
+	for _, d := range board.Departures {
+		lines += d.Line
+	}
+	// the first departure is now expected last and the provider's own prediction is kept
+	if lines != "3421" {
+		t.Errorf("got the lines in the order %s, want 3421", lines)
+	}
+	byLine := make(map[string]api.DepartureResponse)
+	for _, d := range board.Departures {
+		byLine[d.Line] = d
+	}
+	if d := byLine["1"]; d.ExpectedTime == nil || !d.ExpectedTime.Equal(scheduled.Add(10*time.Minute)) {
+		t.Errorf("got expected time %v for line 1", d.ExpectedTime)
+	}
+	if d := byLine["2"]; !d.Cancelled || d.ExpectedTime != nil {
+		t.Errorf("the departure skipping the stop got %+v", d)
+	}
+	if d := byLine["3"]; !d.ExpectedTime.Equal(provided) {
+		t.Errorf("the provider's prediction was replaced by %v", d.ExpectedTime)
+	}
+}
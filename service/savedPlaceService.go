@@ -2,23 +2,30 @@ package service
 
 import (
 	"context"
+	"fmt"
 	"github.com/leonardchinonso/lokate-go/errors"
 	"github.com/leonardchinonso/lokate-go/models/dao"
 	"github.com/leonardchinonso/lokate-go/models/interfaces"
+	"github.com/leonardchinonso/lokate-go/utils"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"log"
 )
 
+// maxPinnedPlaces is the most saved places a user can pin to the departures dashboard
+const maxPinnedPlaces = 10
+
 type savedPlaceService struct {
 	placeRepository      interfaces.PlaceRepositoryInterface
 	savedPlaceRepository interfaces.SavedPlaceRepositoryInterface
+	placeWriter          interfaces.PlaceWriterInterface
 }
 
 // NewSavedPlaceService returns an interface for the savedPlace service methods
-func NewSavedPlaceService(placeRepo interfaces.PlaceRepositoryInterface, savedPlaceRepo interfaces.SavedPlaceRepositoryInterface) interfaces.SavedPlaceServiceInterface {
+func NewSavedPlaceService(placeRepo interfaces.PlaceRepositoryInterface, savedPlaceRepo interfaces.SavedPlaceRepositoryInterface, placeWriter interfaces.PlaceWriterInterface) interfaces.SavedPlaceServiceInterface {
 	return &savedPlaceService{
 		placeRepository:      placeRepo,
 		savedPlaceRepository: savedPlaceRepo,
+		placeWriter:          placeWriter,
 	}
 }
 
@@ -36,6 +43,12 @@ func (ps *savedPlaceService) AddSavedPlace(ctx context.Context, savedPlace *dao.
 		return errors.ErrBadRequest("invalid place id", nil)
 	}
 
+	// a place found by a search moments ago may still be being written
+	if err := ps.placeWriter.Wait(ctx, savedPlace.PlaceId); err != nil {
+		log.Printf("Error waiting for the write of place with id: %v. Error: %v\n", savedPlace.PlaceId, err)
+		return errors.ErrInternalServerError("failed to retrieve place", nil)
+	}
+
 	// check that the place exists
 	placeExists, err := ps.placeRepository.FindByID(ctx, &dao.Place{Id: savedPlace.PlaceId})
 	if err != nil {
@@ -196,6 +209,53 @@ func (ps *savedPlaceService) DeleteSavedPlace(ctx context.Context, savedPlace *d
 	return nil
 }
 
+// PinSavedPlace pins a saved stop to the user's departures dashboard or unpins it
+func (ps *savedPlaceService) PinSavedPlace(ctx context.Context, savedPlace *dao.SavedPlace, pinned bool) error {
+	// find the saved place to pin
+	if err := ps.GetSavedPlace(ctx, savedPlace); err != nil {
+		return err
+	}
+
+	savedPlace.UpdatedAt = utils.CurrentPrimitiveTime()
+
+	if !pinned {
+		if err := ps.savedPlaceRepository.Unpin(ctx, savedPlace); err != nil {
+			log.Printf("Error unpinning savedPlace with id: %v and userId: %v. Error: %v\n", savedPlace.Id, savedPlace.UserId, err)
+			return errors.ErrInternalServerError("failed to unpin saved place", nil)
+		}
+		savedPlace.Pinned = false
+		return nil
+	}
+
+	if savedPlace.Pinned {
+		return nil
+	}
+
+	// only stops have departures to show on the dashboard
+	place := &dao.Place{Id: savedPlace.PlaceId}
+	placeExists, err := ps.placeRepository.FindByID(ctx, place)
+	if err != nil {
+		log.Printf("Error finding place with id: %v. Error: %v\n", savedPlace.PlaceId, err)
+		return errors.ErrInternalServerError("failed to retrieve place", nil)
+	}
+	if !placeExists || !place.IsStop() {
+		return errors.ErrBadRequest("only bus stops and train stations can be pinned", nil)
+	}
+
+	// keep the dashboard small enough to fetch in one go, the pin only succeeds if it gets one of the slots
+	ok, err := ps.savedPlaceRepository.Pin(ctx, savedPlace, maxPinnedPlaces)
+	if err != nil {
+		log.Printf("Error pinning savedPlace with id: %v and userId: %v. Error: %v\n", savedPlace.Id, savedPlace.UserId, err)
+		return errors.ErrInternalServerError("failed to pin saved place", nil)
+	}
+	if !ok {
+		return errors.ErrBadRequest(fmt.Sprintf("you can pin at most %d stops", maxPinnedPlaces), nil)
+	}
+
+	savedPlace.Pinned = true
+	return nil
+}
+
 // resetPlaceAliasForUser resets a user's HOME  or WORK to "NONE" to allow the new update
 func (ps *savedPlaceService) resetPlaceAliasForUser(ctx context.Context, savedPlace *dao.SavedPlace) error {
 	if savedPlace.PlaceAlias.IsNone() {
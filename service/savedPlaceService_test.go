@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+
+	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+)
+
+// pinSavedPlaceRepo keeps the saved places of one user in memory and pins them up to the limit it
+// is given, the other saved place repository methods are not used
+type pinSavedPlaceRepo struct {
+	interfaces.SavedPlaceRepositoryInterface
+	saved map[primitive.ObjectID]*dao.SavedPlace
+}
+
+func (s *pinSavedPlaceRepo) FindOneByIDAndUserID(ctx context.Context, savedPlace *dao.SavedPlace) (bool, error) {
+	sp, ok := s.saved[savedPlace.Id]
+	if !ok || sp.UserId != savedPlace.UserId {
+		return false, nil
+	}
+	*savedPlace = *sp
+	return true, nil
+}
+
+func (s *pinSavedPlaceRepo) Pin(ctx context.Context, savedPlace *dao.SavedPlace, maxPinned int) (bool, error) {
+	pinned := 0
+	for _, sp := range s.saved {
+		if sp.Pinned {
+			pinned++
+		}
+	}
+	if pinned >= maxPinned {
+		return false, nil
+	}
+	s.saved[savedPlace.Id].Pinned = true
+	return true, nil
+}
+
+func (s *pinSavedPlaceRepo) Unpin(ctx context.Context, savedPlace *dao.SavedPlace) error {
+	s.saved[savedPlace.Id].Pinned = false
+	return nil
+}
+
+// placesRepo finds the places it holds by id, the other place repository methods are not used
+type placesRepo struct {
+	interfaces.PlaceRepositoryInterface
+	places map[primitive.ObjectID]dao.Place
+}
+
+func (p *placesRepo) FindByID(ctx context.Context, place *dao.Place) (bool, error) {
+	found, ok := p.places[place.Id]
+	if ok {
+		*place = found
+	}
+	return ok, nil
+}
+
+func TestPinSavedPlace(t *testing.T) {
+	userId := primitive.NewObjectID()
+	stop := dao.Place{Id: primitive.NewObjectID(), Name: "Waterloo Station", ATCOCode: "490000077E"}
+	poi := dao.Place{Id: primitive.NewObjectID(), Name: "Big Ben"}
+
+	savedPlaces := &pinSavedPlaceRepo{saved: make(map[primitive.ObjectID]*dao.SavedPlace)}
+	save := func(place dao.Place) *dao.SavedPlace {
+		sp := &dao.SavedPlace{Id: primitive.NewObjectID(), UserId: userId, PlaceId: place.Id}
+		savedPlaces.saved[sp.Id] = sp
+		return &dao.SavedPlace{Id: sp.Id, UserId: userId}
+	}
+	var stops []*dao.SavedPlace
+	for i := 0; i <= maxPinnedPlaces; i++ {
+		stops = append(stops, save(stop))
+	}
+	notStop := save(poi)
+
+	ps := NewSavedPlaceService(&placesRepo{places: map[primitive.ObjectID]dao.Place{stop.Id: stop, poi.Id: poi}}, savedPlaces, nil)
+	ctx := context.Background()
+
+	for _, sp := range stops[:maxPinnedPlaces] {
+		if err := ps.PinSavedPlace(ctx, sp, true); err != nil {
+			t.Fatalf("pinning a stop under the limit failed: %v", err)
+		}
+	}
+	if err := ps.PinSavedPlace(ctx, stops[maxPinnedPlaces], true); errors.Status(err) != http.StatusBadRequest {
+		t.Fatalf("pinning a stop over the limit got %v, want a bad request", err)
+	}
+	if err := ps.PinSavedPlace(ctx, stops[0], true); err != nil {
+		t.Errorf("pinning a pinned stop again failed: %v", err)
+	}
+
+	// an unpin frees a place on the dashboard
+	if err := ps.PinSavedPlace(ctx, stops[0], false); err != nil {
+		t.Fatal(err)
+	}
+	if err := ps.PinSavedPlace(ctx, stops[maxPinnedPlaces], true); err != nil {
+		t.Errorf("pinning a stop after an unpin failed: %v", err)
+	}
+
+	if err := ps.PinSavedPlace(ctx, notStop, true); errors.Status(err) != http.StatusBadRequest {
+		t.Errorf("pinning a place that is not a stop got %v, want a bad request", err)
+	}
+	if err := ps.PinSavedPlace(ctx, &dao.SavedPlace{Id: primitive.NewObjectID(), UserId: userId}, true); errors.Status(err) != http.StatusBadRequest {
+		t.Errorf("pinning a saved place that does not exist got %v, want a bad request", err)
+	}
+}
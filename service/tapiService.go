@@ -7,33 +7,50 @@ import (
 	"github.com/leonardchinonso/lokate-go/models/api"
 	"github.com/leonardchinonso/lokate-go/models/dto"
 	"log"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/leonardchinonso/lokate-go/config"
 	"github.com/leonardchinonso/lokate-go/datasource"
+	"github.com/leonardchinonso/lokate-go/metrics"
 	"github.com/leonardchinonso/lokate-go/models/dao"
 	"github.com/leonardchinonso/lokate-go/models/interfaces"
 )
 
+// tapiProviderName is the name TransportAPI is selected by in the transit provider config
+const tapiProviderName = "tapi"
+
 // tapiService holds the structure for services associated with TAPI
 type tapiService struct {
-	tapiAppId         string
-	tapiAppKey        string
-	tapiPlacesUrl     string
-	tapiPublicJourney string
-	tapiServiceName   string
+	tapiAppId              string
+	tapiAppKey             string
+	tapiPlacesUrl          string
+	tapiPublicJourney      string
+	tapiServiceName        string
+	tapiBusDeparturesUrl   string
+	tapiTrainDeparturesUrl string
 }
 
-// NewTAPIService returns an interface for the TAPI service methods
-func NewTAPIService(cfg *map[string]string) interfaces.TAPIServiceInterface {
+// NewTAPIService returns the TransportAPI adapter for the transit service methods
+func NewTAPIService(cfg *map[string]string) interfaces.TransitServiceInterface {
 	return &tapiService{
-		tapiAppId:         (*cfg)[config.TAPIAppId],
-		tapiAppKey:        (*cfg)[config.TAPIAppKey],
-		tapiPlacesUrl:     (*cfg)[config.TAPIPlacesUrl],
-		tapiPublicJourney: (*cfg)[config.TAPIPublicJourneyUrl],
-		tapiServiceName:   (*cfg)[config.TAPIServiceName],
+		tapiAppId:              (*cfg)[config.TAPIAppId],
+		tapiAppKey:             (*cfg)[config.TAPIAppKey],
+		tapiPlacesUrl:          (*cfg)[config.TAPIPlacesUrl],
+		tapiPublicJourney:      (*cfg)[config.TAPIPublicJourneyUrl],
+		tapiServiceName:        (*cfg)[config.TAPIServiceName],
+		tapiBusDeparturesUrl:   (*cfg)[config.TAPIBusDeparturesUrl],
+		tapiTrainDeparturesUrl: (*cfg)[config.TAPITrainDeparturesUrl],
 	}
 }
 
+// Name returns the name of the transit provider
+func (ts *tapiService) Name() string {
+	return tapiProviderName
+}
+
 // SearchPlace makes a http request to TAPI to get a query string
 func (ts *tapiService) SearchPlace(searchStr string, places *[]dao.Place) ([]api.PlaceResponse, error) {
 	if searchStr == "" {
@@ -44,66 +61,184 @@ func (ts *tapiService) SearchPlace(searchStr string, places *[]dao.Place) ([]api
 	// build the url to search
 	url := fmt.Sprintf("%s?query=%s&app_id=%s&app_key=%s", ts.tapiPlacesUrl, searchStr, ts.tapiAppId, ts.tapiAppKey)
 
+	if err := ts.places(url, places); err != nil {
+		return nil, err
+	}
+
+	return api.ToPlacesResponses(places), nil
+}
+
+// NearestPlaces makes a http request to TAPI to get the places nearest to a point, nearest first
+func (ts *tapiService) NearestPlaces(query dto.NearestPlacesQuery, places *[]dao.Place) ([]api.PlaceResponse, error) {
+	// build the url to search around the point, TAPI takes the types as a comma separated list
+	values := url.Values{}
+	values.Set("lat", strconv.FormatFloat(query.Latitude, 'f', -1, 64))
+	values.Set("lon", strconv.FormatFloat(query.Longitude, 'f', -1, 64))
+	if len(query.Types) > 0 {
+		values.Set("type", strings.Join(query.Types, ","))
+	}
+	values.Set("app_id", ts.tapiAppId)
+	values.Set("app_key", ts.tapiAppKey)
+	reqUrl := fmt.Sprintf("%s?%s", ts.tapiPlacesUrl, values.Encode())
+
+	if err := ts.places(reqUrl, places); err != nil {
+		return nil, err
+	}
+
+	// places without a distance go last
+	sort.SliceStable(*places, func(i, j int) bool {
+		a, b := (*places)[i].Distance, (*places)[j].Distance
+		return a != nil && (b == nil || *a < *b)
+	})
+
+	return api.ToPlacesResponses(places), nil
+}
+
+// places gets the places from a TAPI places url and adds them to the places
+func (ts *tapiService) places(reqUrl string, places *[]dao.Place) error {
 	// create object to marshal into
 	var placeResp dao.PlaceResp
 
 	// make a http request to the url
-	err := datasource.Get(url, &placeResp)
+	err := datasource.Get(reqUrl, &placeResp)
 	if err != nil {
-		log.Printf("Failed to get data for url: %v. Error: %v\n", url, err)
-		return nil, errors.ErrInternalServerError("failed to reach TAPI", nil)
+		log.Printf("Failed to get data for url: %v. Error: %v\n", reqUrl, err)
+		return errors.ErrInternalServerError("failed to reach TAPI", nil)
 	}
 
-	// Convert the "members" field to have access to all its nested values
-	for _, item := range placeResp.Member {
-		marshalled, err := json.Marshal(item)
-		if err != nil {
-			log.Printf("failed to marshal with error: %v\n", err)
-			return nil, errors.ErrInternalServerError("failed to convert place", nil)
-		}
-
-		place := &dao.Place{}
-		err = json.Unmarshal(marshalled, place)
-		if err != nil {
-			log.Printf("failed to unmarshal with error: %v\n", err)
-			return nil, errors.ErrInternalServerError("failed to convert place", nil)
+	// decode each member on its own so a malformed member is skipped instead of failing the response
+	for i, member := range placeResp.Member {
+		var place dao.Place
+		if err := json.Unmarshal(member, &place); err != nil {
+			log.Printf("Skipping place %d from url: %v. Error: %v\n", i, reqUrl, err)
+			metrics.RejectedPlaces.Add(tapiProviderName, 1)
+			continue
 		}
 
-		*places = append(*places, *place)
+		*places = append(*places, place)
 	}
 
-	return api.ToPlacesResponses(places), nil
+	return nil
 }
 
-// PublicJourneyLonLat specifies the method for getting a public journey by lonlat format from TAPI
-func (ts *tapiService) PublicJourneyLonLat(from dto.Location, to dto.Location) (interface{}, error) {
-	// build the url to query location by lonlat format
-	url := fmt.Sprintf("%s/from/lonlat:%s/to/lonlat:%s.json?service=%s&app_id=%s&app_key=%s",
-		ts.tapiPublicJourney, from.Notation, to.Notation, ts.tapiServiceName, ts.tapiAppId, ts.tapiAppKey)
+// PublicJourney specifies the method for getting a public journey between any two journey points from TAPI
+func (ts *tapiService) PublicJourney(from dto.JourneyPoint, to dto.JourneyPoint, opts dto.JourneyOptions) (*api.JourneyResponse, error) {
+	// build the path to query the journey with the notation of each point
+	path := fmt.Sprintf("%s/from/%s/to/%s", ts.tapiPublicJourney, from.Notation(), to.Notation())
 
-	return ts.publicJourney(url)
-}
+	// plan for the requested time instead of now, TAPI uses "at" for departures and "by" for arrivals
+	if opts.HasTime() {
+		timeType := "at"
+		if opts.TimeType == dto.ArriveBy {
+			timeType = "by"
+		}
+		path = fmt.Sprintf("%s/%s/%s/%s", path, timeType, opts.Time.Format("2006-01-02"), opts.Time.Format("15:04"))
+	}
 
-// PublicJourneyPostcode specifies the method for getting a public journey by postcode from TAPI
-func (ts *tapiService) PublicJourneyPostcode(from dto.Postcode, to dto.Postcode) (interface{}, error) {
-	// build the url to query location by postcode format
-	url := fmt.Sprintf("%s/from/postcode:%s/to/postcode:%s.json?service=%s&app_id=%s&app_key=%s",
-		ts.tapiPublicJourney, from.StrVal, to.StrVal, ts.tapiServiceName, ts.tapiAppId, ts.tapiAppKey)
+	// build the query with the credentials and the journey options
+	query := url.Values{}
+	query.Set("service", ts.tapiServiceName)
+	query.Set("app_id", ts.tapiAppId)
+	query.Set("app_key", ts.tapiAppKey)
+	if len(opts.Modes) > 0 {
+		query.Set("modes", tapiModes(opts.Modes))
+	}
+	// walking is left out of the excluded modes, TAPI would drop every route that walks to a stop
+	var notModes []api.JourneyMode
+	for _, m := range opts.NotModes {
+		if m != api.ModeWalk {
+			notModes = append(notModes, m)
+		}
+	}
+	if len(notModes) > 0 {
+		query.Set("not_modes", tapiModes(notModes))
+	}
+	if opts.MaxWalkDistance > 0 {
+		query.Set("max_walk_distance", strconv.Itoa(opts.MaxWalkDistance))
+	}
 
-	return ts.publicJourney(url)
+	return ts.publicJourney(fmt.Sprintf("%s.json?%s", path, query.Encode()))
+}
+
+// tapiModes converts modes to the hyphen separated list of mode names used by TAPI
+func tapiModes(modes []api.JourneyMode) string {
+	names := make([]string, len(modes))
+	for i, m := range modes {
+		if m == api.ModeWalk {
+			names[i] = "foot"
+			continue
+		}
+		names[i] = string(m)
+	}
+	return strings.Join(names, "-")
 }
 
 // publicJourney gets a public journey from TAPI using a specified url
-func (ts *tapiService) publicJourney(url string) (interface{}, error) {
+func (ts *tapiService) publicJourney(reqUrl string) (*api.JourneyResponse, error) {
 	// create object to marshal into
 	var pubJourneyResp dao.PublicJourneyResp
 
 	// make a http request to the url
-	err := datasource.Get(url, &pubJourneyResp)
+	err := datasource.Get(reqUrl, &pubJourneyResp)
 	if err != nil {
-		log.Printf("Failed to get data for url: %v. Error: %v", url, err)
+		log.Printf("Failed to get data for url: %v. Error: %v", reqUrl, err)
 		return nil, errors.ErrInternalServerError("failed to reach TAPI", nil)
 	}
 
-	return pubJourneyResp, nil
+	// convert the upstream journey to the api response
+	journeyResp, err := api.NewJourneyResponse(&pubJourneyResp)
+	if err != nil {
+		log.Printf("Failed to convert journey for url: %v. Error: %v", reqUrl, err)
+		return nil, errors.ErrInternalServerError("failed to convert journey", nil)
+	}
+
+	return journeyResp, nil
+}
+
+// Departures gets the live departures from a bus stop or a train station from TAPI
+func (ts *tapiService) Departures(stop dto.DepartureStop) (*api.DepartureBoardResponse, error) {
+	// the stops found by OpenTripPlanner or in the GTFS feeds are not known to TAPI, the next provider is tried instead
+	if stop.Type == dto.OTPStop || stop.Type == dto.GTFSStop {
+		return nil, errors.ErrNotImplemented("the TAPI provider does not get departures for OTP or GTFS stops", nil)
+	}
+
+	query := url.Values{}
+	query.Set("app_id", ts.tapiAppId)
+	query.Set("app_key", ts.tapiAppKey)
+
+	if stop.Type == dto.TrainStation {
+		// build the url to query the station by its CRS code
+		reqUrl := fmt.Sprintf("%s/%s/live.json?%s", ts.tapiTrainDeparturesUrl, url.PathEscape(stop.Code), query.Encode())
+
+		var departuresResp dao.TrainDeparturesResp
+		if err := datasource.Get(reqUrl, &departuresResp); err != nil {
+			log.Printf("Failed to get data for url: %v. Error: %v", reqUrl, err)
+			return nil, errors.ErrInternalServerError("failed to reach TAPI", nil)
+		}
+
+		board, err := api.NewTrainDepartureBoardResponse(&departuresResp)
+		if err != nil {
+			log.Printf("Failed to convert departures for url: %v. Error: %v", reqUrl, err)
+			return nil, errors.ErrInternalServerError("failed to convert departures", nil)
+		}
+		return board, nil
+	}
+
+	// build the url to query the bus stop by its ATCO code, ungrouped so all lines are in one list
+	query.Set("group", "no")
+	query.Set("nextbuses", "yes")
+	reqUrl := fmt.Sprintf("%s/%s/live.json?%s", ts.tapiBusDeparturesUrl, url.PathEscape(stop.Code), query.Encode())
+
+	var departuresResp dao.BusDeparturesResp
+	if err := datasource.Get(reqUrl, &departuresResp); err != nil {
+		log.Printf("Failed to get data for url: %v. Error: %v", reqUrl, err)
+		return nil, errors.ErrInternalServerError("failed to reach TAPI", nil)
+	}
+
+	board, err := api.NewBusDepartureBoardResponse(&departuresResp)
+	if err != nil {
+		log.Printf("Failed to convert departures for url: %v. Error: %v", reqUrl, err)
+		return nil, errors.ErrInternalServerError("failed to convert departures", nil)
+	}
+	return board, nil
 }
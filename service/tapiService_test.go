@@ -0,0 +1,251 @@
+package service
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leonardchinonso/lokate-go/config"
+	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/metrics"
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+)
+
+// stubServer serves a fixed body for each path and records the requests it is sent
+type stubServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests []*url.URL
+}
+
+// newStubServer starts a stub upstream, a path without a body is answered with a 404
+func newStubServer(t *testing.T, bodies map[string]string) *stubServer {
+	t.Helper()
+	s := &stubServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.URL)
+		s.mu.Unlock()
+
+		body, ok := bodies[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// lastRequest returns the last request the stub was sent
+func (s *stubServer) lastRequest(t *testing.T) *url.URL {
+	t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.requests) == 0 {
+		t.Fatal("the stub was not called")
+	}
+	return s.requests[len(s.requests)-1]
+}
+
+// rejectedPlaces returns the number of places of a provider counted as rejected so far
+func rejectedPlaces(provider string) int64 {
+	if v, ok := metrics.RejectedPlaces.Get(provider).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+func newTestTAPIService(baseUrl string) *tapiService {
+	cfg := map[string]string{
+		config.TAPIAppId:              "app-id",
+		config.TAPIAppKey:             "app-key",
+		config.TAPIPlacesUrl:          baseUrl + "/places.json",
+		config.TAPIPublicJourneyUrl:   baseUrl + "/public/journey",
+		config.TAPIServiceName:        "southeast",
+		config.TAPIBusDeparturesUrl:   baseUrl + "/bus/stop",
+		config.TAPITrainDeparturesUrl: baseUrl + "/train/station",
+	}
+	return NewTAPIService(&cfg).(*tapiService)
+}
+
+func TestTAPISearchPlaceSkipsMalformedMembers(t *testing.T) {
+	stub := newStubServer(t, map[string]string{
+		"/places.json": `{"member": [
+			{"type": "bus_stop", "name": "Trafalgar Square", "latitude": 51.507, "longitude": -0.128, "atcocode": "490000077E"},
+			{"type": "bus_stop", "name": "No Coordinates"},
+			{"type": "train_station", "name": "Charing Cross", "latitude": "51.508", "longitude": -0.124},
+			{"type": "train_station", "name": "London Bridge", "latitude": 51.505, "longitude": -0.086, "station_code": "LBG"}
+		]}`,
+	})
+	ts := newTestTAPIService(stub.URL)
+	rejected := rejectedPlaces(tapiProviderName)
+
+	var places []dao.Place
+	resp, err := ts.SearchPlace(url.QueryEscape("london"), &places)
+	if err != nil {
+		t.Fatalf("SearchPlace returned an error: %v", err)
+	}
+	if got := rejectedPlaces(tapiProviderName) - rejected; got != 2 {
+		t.Errorf("got %d rejected places, want 2", got)
+	}
+
+	if len(places) != 2 || len(resp) != 2 {
+		t.Fatalf("got %d places and %d responses, want 2 of each", len(places), len(resp))
+	}
+	if places[0].Key != "atco:490000077E" || places[1].Key != "crs:LBG" {
+		t.Errorf("got keys %q and %q", places[0].Key, places[1].Key)
+	}
+
+	q := stub.lastRequest(t).Query()
+	if q.Get("query") != "london" || q.Get("app_id") != "app-id" || q.Get("app_key") != "app-key" {
+		t.Errorf("unexpected query: %v", q)
+	}
+}
+
+func TestTAPINearestPlacesSortsByDistance(t *testing.T) {
+	stub := newStubServer(t, map[string]string{
+		"/places.json": `{"member": [
+			{"type": "poi", "name": "Far", "latitude": 51.5, "longitude": -0.1, "distance": 300},
+			{"type": "poi", "name": "Unknown", "latitude": 51.5, "longitude": -0.1},
+			{"type": "poi", "name": "Near", "latitude": 51.5, "longitude": -0.1, "distance": 20}
+		]}`,
+	})
+	ts := newTestTAPIService(stub.URL)
+
+	var places []dao.Place
+	query := dto.NearestPlacesQuery{Latitude: 51.5, Longitude: -0.1, Types: []string{"bus_stop", "poi"}}
+	if _, err := ts.NearestPlaces(query, &places); err != nil {
+		t.Fatalf("NearestPlaces returned an error: %v", err)
+	}
+
+	var names []string
+	for _, p := range places {
+		names = append(names, p.Name)
+	}
+	if got := strings.Join(names, ","); got != "Near,Far,Unknown" {
+		t.Errorf("got places %s, want Near,Far,Unknown", got)
+	}
+	if got := stub.lastRequest(t).Query().Get("type"); got != "bus_stop,poi" {
+		t.Errorf("got type %q, want bus_stop,poi", got)
+	}
+}
+
+func TestTAPIUnreachable(t *testing.T) {
+	stub := newStubServer(t, nil)
+	ts := newTestTAPIService(stub.URL)
+
+	var places []dao.Place
+	_, err := ts.SearchPlace("london", &places)
+	if errors.Status(err) != http.StatusInternalServerError {
+		t.Fatalf("got error %v, want an internal server error", err)
+	}
+}
+
+func TestTAPIPublicJourney(t *testing.T) {
+	stub := newStubServer(t, map[string]string{
+		"/public/journey/from/lonlat:-0.128,51.507/to/lonlat:-0.086,51.505/at/2023-03-01/23:50.json": `{
+			"request_time": "2023-03-01T23:45:00+00:00",
+			"routes": [
+				{
+					"duration": "00:25:00", "departure_date": "2023-03-01", "departure_time": "23:50",
+					"arrival_date": "2023-03-02", "arrival_time": "00:15",
+					"route_parts": [
+						{"mode": "foot", "duration": "00:05:00", "departure_time": "23:50", "arrival_time": "23:55",
+						 "coordinates": [[-0.128, 51.507], [-0.127, 51.507]]},
+						{"mode": "bus", "line_name": "N15", "duration": "00:20:00", "departure_time": "23:55", "arrival_time": "00:15",
+						 "coordinates": [[-0.127, 51.507], [-0.086, 51.505]]}
+					]
+				},
+				{"duration": "not a duration", "departure_date": "2023-03-01", "departure_time": "23:52",
+				 "arrival_date": "2023-03-02", "arrival_time": "00:20", "route_parts": []}
+			]
+		}`,
+	})
+	ts := newTestTAPIService(stub.URL)
+
+	from := dto.JourneyPoint{Kind: dto.LonLatPoint, Value: "-0.128,51.507"}
+	to := dto.JourneyPoint{Kind: dto.LonLatPoint, Value: "-0.086,51.505"}
+	opts := dto.JourneyOptions{
+		TimeType: dto.DepartAt,
+		Time:     time.Date(2023, 3, 1, 23, 50, 0, 0, time.UTC),
+		NotModes: []api.JourneyMode{api.ModeWalk, api.ModeTrain},
+	}
+
+	journey, err := ts.PublicJourney(from, to, opts)
+	if err != nil {
+		t.Fatalf("PublicJourney returned an error: %v", err)
+	}
+
+	// the route with a bad duration is left out
+	if len(journey.Routes) != 1 {
+		t.Fatalf("got %d routes, want 1", len(journey.Routes))
+	}
+	route := journey.Routes[0]
+	if route.WalkingMinutes != 5 || route.Interchanges != 0 || !route.HasMode(api.ModeBus) {
+		t.Errorf("unexpected route summary: %+v", route)
+	}
+	// the bus arrives after midnight, on the next day
+	if got := route.Parts[1].ArrivalTime.Day(); got != 2 {
+		t.Errorf("got the bus arriving on day %d, want 2", got)
+	}
+
+	q := stub.lastRequest(t).Query()
+	if q.Get("not_modes") != "train" || q.Get("service") != "southeast" {
+		t.Errorf("unexpected query: %v", q)
+	}
+}
+
+func TestTAPIDepartures(t *testing.T) {
+	stub := newStubServer(t, map[string]string{
+		"/bus/stop/490000077E/live.json": `{
+			"atcocode": "490000077E", "name": "Trafalgar Square",
+			"departures": {"all": [
+				{"mode": "bus", "line": "24", "line_name": "24", "direction": "Pimlico", "date": "2023-03-01",
+				 "aimed_departure_time": "10:05", "expected_departure_date": "2023-03-01", "expected_departure_time": "10:07"}
+			]}
+		}`,
+		"/train/station/CHX/live.json": `{
+			"station_code": "CHX", "station_name": "London Charing Cross", "date": "2023-03-01",
+			"departures": {"all": [
+				{"mode": "train", "service": "24680004", "train_uid": "C12345", "platform": "3", "destination_name": "Dartford",
+				 "status": "CANCELLED", "aimed_departure_time": "10:10", "expected_departure_time": "10:10"}
+			]}
+		}`,
+	})
+	ts := newTestTAPIService(stub.URL)
+
+	bus, err := ts.Departures(dto.DepartureStop{Type: dto.BusStop, Code: "490000077E"})
+	if err != nil {
+		t.Fatalf("bus Departures returned an error: %v", err)
+	}
+	if len(bus.Departures) != 1 || bus.Departures[0].ExpectedTime == nil || bus.Departures[0].ExpectedTime.Minute() != 7 {
+		t.Errorf("unexpected bus departures: %+v", bus.Departures)
+	}
+	if q := stub.lastRequest(t).Query(); q.Get("group") != "no" {
+		t.Errorf("unexpected bus query: %v", q)
+	}
+
+	train, err := ts.Departures(dto.DepartureStop{Type: dto.TrainStation, Code: "CHX"})
+	if err != nil {
+		t.Fatalf("train Departures returned an error: %v", err)
+	}
+	if len(train.Departures) != 1 || !train.Departures[0].Cancelled || train.Departures[0].ExpectedTime != nil {
+		t.Errorf("unexpected train departures: %+v", train.Departures)
+	}
+
+	// the stops found by OpenTripPlanner are passed on to the next provider
+	_, err = ts.Departures(dto.DepartureStop{Type: dto.OTPStop, Code: "1:490000077E"})
+	if errors.Status(err) != http.StatusNotImplemented {
+		t.Errorf("got error %v for an OTP stop, want not implemented", err)
+	}
+}
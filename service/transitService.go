@@ -0,0 +1,150 @@
+package service
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/leonardchinonso/lokate-go/config"
+	"github.com/leonardchinonso/lokate-go/errors"
+	"github.com/leonardchinonso/lokate-go/models/api"
+	"github.com/leonardchinonso/lokate-go/models/dao"
+	"github.com/leonardchinonso/lokate-go/models/dto"
+	"github.com/leonardchinonso/lokate-go/models/interfaces"
+)
+
+// transitProviders holds the constructors of the transit providers by the name they are configured with
+var transitProviders = map[string]func(cfg *map[string]string) interfaces.TransitServiceInterface{
+	tapiProviderName: NewTAPIService,
+	otpProviderName:  NewOTPService,
+}
+
+// transitChain tries each transit provider in order until one of them does not fail
+type transitChain struct {
+	providers []interfaces.TransitServiceInterface
+}
+
+// NewTransitService returns the configured transit provider, wrapped in a fallback chain
+// when fallback providers are configured. Providers that need more than the config to be
+// created are passed in already built and are selected by their name
+func NewTransitService(cfg *map[string]string, built ...interfaces.TransitServiceInterface) (interfaces.TransitServiceInterface, error) {
+	names := []string{(*cfg)[config.TransitProvider]}
+	for _, name := range strings.Split((*cfg)[config.TransitFallbackProviders], ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	var providers []interfaces.TransitServiceInterface
+	for _, name := range names {
+		if p := findTransitProvider(built, name); p != nil {
+			providers = append(providers, p)
+			continue
+		}
+
+		newProvider, ok := transitProviders[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown transit provider: %q", name)
+		}
+		providers = append(providers, newProvider(cfg))
+	}
+
+	return NewTransitChain(providers...), nil
+}
+
+// findTransitProvider returns the provider with the name, or nil when none of them has it
+func findTransitProvider(providers []interfaces.TransitServiceInterface, name string) interfaces.TransitServiceInterface {
+	for _, p := range providers {
+		if strings.EqualFold(p.Name(), name) {
+			return p
+		}
+	}
+	return nil
+}
+
+// NewTransitChain returns a transit service that falls back to the next provider when one fails
+func NewTransitChain(providers ...interfaces.TransitServiceInterface) interfaces.TransitServiceInterface {
+	if len(providers) == 1 {
+		return providers[0]
+	}
+	return &transitChain{providers: providers}
+}
+
+// Name returns the names of the providers in the chain
+func (tc *transitChain) Name() string {
+	names := make([]string, len(tc.providers))
+	for i, p := range tc.providers {
+		names[i] = p.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// SearchPlace searches for places with the first provider that does not fail
+func (tc *transitChain) SearchPlace(searchStr string, places *[]dao.Place) ([]api.PlaceResponse, error) {
+	var resp []api.PlaceResponse
+	err := tc.try("search place", func(p interfaces.TransitServiceInterface) error {
+		// drop the places a failed provider may have added before trying the next one
+		*places = (*places)[:0]
+
+		var err error
+		resp, err = p.SearchPlace(searchStr, places)
+		return err
+	})
+	return resp, err
+}
+
+// NearestPlaces finds the places nearest to a point with the first provider that does not fail
+func (tc *transitChain) NearestPlaces(query dto.NearestPlacesQuery, places *[]dao.Place) ([]api.PlaceResponse, error) {
+	var resp []api.PlaceResponse
+	err := tc.try("nearest places", func(p interfaces.TransitServiceInterface) error {
+		// drop the places a failed provider may have added before trying the next one
+		*places = (*places)[:0]
+
+		var err error
+		resp, err = p.NearestPlaces(query, places)
+		return err
+	})
+	return resp, err
+}
+
+// PublicJourney gets a journey with the first provider that does not fail
+func (tc *transitChain) PublicJourney(from dto.JourneyPoint, to dto.JourneyPoint, opts dto.JourneyOptions) (*api.JourneyResponse, error) {
+	var resp *api.JourneyResponse
+	err := tc.try("public journey", func(p interfaces.TransitServiceInterface) error {
+		var err error
+		resp, err = p.PublicJourney(from, to, opts)
+		return err
+	})
+	return resp, err
+}
+
+// Departures gets the departures from a stop with the first provider that does not fail
+func (tc *transitChain) Departures(stop dto.DepartureStop) (*api.DepartureBoardResponse, error) {
+	var resp *api.DepartureBoardResponse
+	err := tc.try("departures", func(p interfaces.TransitServiceInterface) error {
+		var err error
+		resp, err = p.Departures(stop)
+		return err
+	})
+	return resp, err
+}
+
+// try calls the action with each provider in order. Client errors are returned straight away
+// since the next provider would reject the same request, any other error moves on to the next provider
+func (tc *transitChain) try(action string, call func(p interfaces.TransitServiceInterface) error) error {
+	var err error
+	for _, p := range tc.providers {
+		err = call(p)
+		if err == nil {
+			return nil
+		}
+
+		if errors.Status(err) < http.StatusInternalServerError {
+			return err
+		}
+
+		log.Printf("Transit provider %s failed to get %s, trying the next provider. Error: %v\n", p.Name(), action, err)
+	}
+	return err
+}
@@ -0,0 +1,54 @@
+package utils
+
+import "math"
+
+// earthRadiusMetres is the mean radius of the earth
+const earthRadiusMetres = 6371000
+
+// HaversineMetres returns the great circle distance in metres between two coordinates
+func HaversineMetres(lat1, lon1, lat2, lon2 float64) float64 {
+	toRadians := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRadians(lat2 - lat1)
+	dLon := toRadians(lon2 - lon1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRadians(lat1))*math.Cos(toRadians(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusMetres * math.Asin(math.Sqrt(a))
+}
+
+// geohashAlphabet is the base32 alphabet of geohashes
+const geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// Geohash returns the geohash of a coordinate with the number of characters in precision
+// each character narrows the cell down by 5 bits, alternating between longitude and latitude
+func Geohash(lat, lon float64, precision int) string {
+	latRange := [2]float64{-90, 90}
+	lonRange := [2]float64{-180, 180}
+
+	hash := make([]byte, 0, precision)
+	bit, ch, even := 0, 0, true
+	for len(hash) < precision {
+		value, r := lat, &latRange
+		if even {
+			value, r = lon, &lonRange
+		}
+
+		mid := (r[0] + r[1]) / 2
+		ch <<= 1
+		if value >= mid {
+			ch |= 1
+			r[0] = mid
+		} else {
+			r[1] = mid
+		}
+		even = !even
+
+		if bit++; bit == 5 {
+			hash = append(hash, geohashAlphabet[ch])
+			bit, ch = 0, 0
+		}
+	}
+	return string(hash)
+}
@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NormalizeName lower cases a name and keeps only its letters and digits, with the
+// words separated by a single space, so names that only differ in punctuation are equal
+func NormalizeName(name string) string {
+	words := strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
+
+// NameTrigrams returns the distinct trigrams of the words of a normalized name. Each word is
+// padded with a space on both sides, so the start and the end of a word are trigrams of their own
+// and a word of one or two letters still has one
+func NameTrigrams(name string) []string {
+	seen := make(map[string]bool)
+	var grams []string
+	for _, word := range strings.Fields(NormalizeName(name)) {
+		r := []rune(" " + word + " ")
+		for i := 0; i+3 <= len(r); i++ {
+			g := string(r[i : i+3])
+			if !seen[g] {
+				seen[g] = true
+				grams = append(grams, g)
+			}
+		}
+	}
+	return grams
+}
+
+// NameSimilarity returns how similar two names are from 0 to 1, from the edit distance
+// between their normalized forms
+func NameSimilarity(a, b string) float64 {
+	ra, rb := []rune(NormalizeName(a)), []rune(NormalizeName(b))
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(EditDistance(ra, rb))/float64(longest)
+}
+
+// EditDistance returns the Levenshtein distance between two strings of runes
+func EditDistance(a, b []rune) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, minInt(curr[j-1]+1, prev[j-1]+cost))
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// minInt returns the smaller of two ints
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// CSVHeader returns the column names of the header of a csv file without their surrounding spaces
+// and without the byte order mark some exporters write at the start of the file
+func CSVHeader(record []string) []string {
+	header := make([]string, len(record))
+	for i, column := range record {
+		if i == 0 {
+			column = strings.TrimPrefix(column, "\ufeff")
+		}
+		header[i] = strings.TrimSpace(column)
+	}
+	return header
+}